@@ -0,0 +1,223 @@
+package apierrors
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Code is a stable, machine-readable identifier of an error. Clients should switch on codes rather than on detail messages, which may change
+type Code string
+
+/**
+ * Codes of errors that are not caused by the request
+ */
+const (
+	CodeInternal    Code = "internal_error"
+	CodeUnavailable Code = "service_unavailable"
+)
+
+/**
+ * Codes for malformed requests
+ */
+const (
+	CodeUnsupportedMediaType Code = "unsupported_media_type"
+	CodeEmptyBody            Code = "empty_body"
+	CodeMalformedJSON        Code = "malformed_json"
+	CodeMissingFields        Code = "missing_fields"
+	CodeInvalidField         Code = "invalid_field"
+	CodeMalformedID          Code = "malformed_id"
+)
+
+/**
+ * Codes for authentication and authorization failures
+ */
+const (
+	CodeAuthRequired       Code = "authentication_required"
+	CodeInvalidToken       Code = "invalid_token"
+	CodeRevokedToken       Code = "revoked_token"
+	CodeTokenNotFound      Code = "token_not_found"
+	CodeTokenUsed          Code = "token_used"
+	CodeSessionNotFound    Code = "session_not_found"
+	CodeInvalidCredentials Code = "invalid_credentials"
+	CodeInvalidPassword    Code = "invalid_password"
+	CodeWeakPassword       Code = "weak_password"
+	CodeInsufficientRep    Code = "insufficient_rep"
+	CodeNotCreator         Code = "not_creator"
+	CodeProviderNotFound   Code = "provider_not_found"
+	CodeIdentityLinked     Code = "identity_linked"
+	CodeProviderRejected   Code = "provider_rejected"
+	CodeInsufficientRole   Code = "insufficient_role"
+	CodeAccountBanned      Code = "account_banned"
+	CodeOutranked          Code = "outranked"
+)
+
+/**
+ * Codes for requests that are throttled
+ */
+const (
+	CodeRateLimited   Code = "rate_limited"
+	CodeAccountLocked Code = "account_locked"
+)
+
+/**
+ * Codes for failures that concern the content of the api
+ */
+const (
+	CodeQuestionNotFound    Code = "question_not_found"
+	CodeAnswerNotFound      Code = "answer_not_found"
+	CodeUserNotFound        Code = "user_not_found"
+	CodeCategoryNotFound    Code = "category_not_found"
+	CodeRevisionNotFound    Code = "revision_not_found"
+	CodeRevisionAnswerGone  Code = "revision_answer_gone"
+	CodeInvalidRevision     Code = "invalid_revision_number"
+	CodeReferenceNotFound   Code = "reference_not_found"
+	CodeNotUnique           Code = "not_unique"
+	CodeDuplicateAnswer     Code = "duplicate_answer"
+	CodeAnswerSlotsFull     Code = "answer_slots_full"
+	CodeSelfVote            Code = "self_vote"
+	CodeInvalidSortCriteria Code = "invalid_sort_criteria"
+	CodeAlreadyFlagged      Code = "already_flagged"
+	CodeFlagNotFound        Code = "flag_not_found"
+	CodePossibleDuplicate   Code = "possible_duplicate"
+)
+
+// Kind classifies errors by their cause, and determines the http status code they are reported with
+type Kind int
+
+const (
+	KindInternal Kind = iota
+	KindInvalid
+	KindUnprocessable
+	KindUnauthorized
+	KindForbidden
+	KindNotFound
+	KindConflict
+	KindGone
+	KindUnavailable
+	KindTooManyRequests
+)
+
+var kindStatusCodes = map[Kind]int{
+	KindInternal:        http.StatusInternalServerError,
+	KindInvalid:         http.StatusBadRequest,
+	KindUnprocessable:   http.StatusUnprocessableEntity,
+	KindUnauthorized:    http.StatusUnauthorized,
+	KindForbidden:       http.StatusForbidden,
+	KindNotFound:        http.StatusNotFound,
+	KindConflict:        http.StatusConflict,
+	KindGone:            http.StatusGone,
+	KindUnavailable:     http.StatusServiceUnavailable,
+	KindTooManyRequests: http.StatusTooManyRequests,
+}
+
+// FieldError pinpoints the field of a request body that caused an error
+type FieldError struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+// Error is the error type returned by the datastores, services and middleware. Detail, Fields and Data are shown to clients, while Err is the underlying cause, which is only logged
+type Error struct {
+	Kind   Kind
+	Code   Code
+	Detail string
+	Fields []FieldError
+	Data   interface{} // Anything that helps clients resolve the error, such as the candidates of a possible duplicate
+	Err    error
+}
+
+func New(kind Kind, code Code, detail string, fields ...FieldError) *Error {
+	return &Error{Kind: kind, Code: code, Detail: detail, Fields: fields}
+}
+
+// Wrap classifies err, keeping it as the cause of the returned error
+func Wrap(kind Kind, code Code, detail string, err error) *Error {
+	return &Error{Kind: kind, Code: code, Detail: detail, Err: err}
+}
+
+// Internal wraps errors that clients can do nothing about, such as failed queries
+func Internal(err error) *Error {
+	return Wrap(KindInternal, CodeInternal, "Internal error", err)
+}
+
+// Unavailable wraps errors that are caused by a backing service being unreachable, which clients may retry
+func Unavailable(err error) *Error {
+	return Wrap(KindUnavailable, CodeUnavailable, "A service that the api depends on is unavailable, try again later", err)
+}
+
+func (err *Error) Error() string {
+	if err.Err != nil {
+		return err.Detail + ": " + err.Err.Error()
+	}
+	return err.Detail
+}
+
+func (err *Error) Unwrap() error {
+	return err.Err
+}
+
+// Is reports errors with the same code as equal, so that errors.Is can match predefined errors regardless of their cause
+func (err *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == err.Code
+}
+
+// KindOf returns the kind of the first *Error in err's chain. Errors that were never classified are internal
+func KindOf(err error) Kind {
+
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Kind
+	}
+
+	return KindInternal
+}
+
+// CodeOf returns the code of the first *Error in err's chain. Errors that were never classified are internal
+func CodeOf(err error) Code {
+
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Code
+	}
+
+	return CodeInternal
+}
+
+// StatusCode returns the http status code that err should be reported with
+func StatusCode(err error) int {
+	return kindStatusCodes[KindOf(err)]
+}
+
+// Problem is the RFC 7807 problem details document that is written for every error response
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Code      Code         `json:"code"`
+	RequestID string       `json:"requestId,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+	Data      interface{}  `json:"data,omitempty"`
+}
+
+// NewProblem describes err as a problem details document. Errors that were never classified are reported as internal errors, without their messages, since they may expose internals
+func NewProblem(err error) *Problem {
+
+	statusCode := StatusCode(err)
+	problem := &Problem{Title: http.StatusText(statusCode), Status: statusCode}
+
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		problem.Code = apiErr.Code
+		problem.Detail = apiErr.Detail
+		problem.Errors = apiErr.Fields
+		problem.Data = apiErr.Data
+	} else {
+		problem.Code = CodeInternal
+	}
+
+	problem.Type = "urn:answer-patch:problem:" + string(problem.Code)
+
+	return problem
+}
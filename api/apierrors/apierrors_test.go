@@ -0,0 +1,56 @@
+package apierrors
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestNewProblemWithWrappedError(t *testing.T) {
+
+	err := New(KindConflict, CodeNotUnique, "The provided username is not unique", FieldError{"username", "not_unique"})
+
+	problem := NewProblem(fmt.Errorf("Failed to register: %w", err))
+
+	if problem.Status != http.StatusConflict {
+		t.Errorf("Expected a status of 409, but recieved %d", problem.Status)
+	} else if problem.Code != CodeNotUnique || problem.Detail != err.Detail {
+		t.Errorf("Expected the problem to carry the code %s and the detail \"%s\", but recieved %+v", CodeNotUnique, err.Detail, problem)
+	} else if len(problem.Errors) != 1 || problem.Errors[0].Field != "username" {
+		t.Errorf("Expected the problem to pinpoint the username field, but recieved %+v", problem.Errors)
+	}
+}
+
+func TestNewProblemHidesCauses(t *testing.T) {
+
+	problemTests := []struct {
+		err            error
+		expectedStatus int
+		expectedCode   Code
+	}{
+		{errors.New("pq: relation \"ap_user\" does not exist"), http.StatusInternalServerError, CodeInternal},
+		{Internal(errors.New("pq: relation \"ap_user\" does not exist")), http.StatusInternalServerError, CodeInternal},
+		{Unavailable(errors.New("dial tcp 127.0.0.1:6379: connect: connection refused")), http.StatusServiceUnavailable, CodeUnavailable},
+	}
+
+	for _, pt := range problemTests {
+		problem := NewProblem(pt.err)
+		if problem.Status != pt.expectedStatus || problem.Code != pt.expectedCode {
+			t.Errorf("Expected a status of %d and the code %s, but recieved %+v", pt.expectedStatus, pt.expectedCode, problem)
+		} else if problem.Detail == pt.err.Error() {
+			t.Errorf("Expected the cause \"%s\" to be withheld from the problem", pt.err.Error())
+		}
+	}
+}
+
+func TestErrorsIsMatchesCodes(t *testing.T) {
+
+	errIncorrectCredentials := New(KindUnauthorized, CodeInvalidCredentials, "Credentials are incorrect")
+
+	if !errors.Is(fmt.Errorf("login: %w", errIncorrectCredentials), errIncorrectCredentials) {
+		t.Errorf("Expected errors.Is to match a wrapped error with the same code")
+	} else if errors.Is(Internal(errors.New("timeout")), errIncorrectCredentials) {
+		t.Errorf("Expected errors.Is to not match errors with different codes")
+	}
+}
@@ -2,20 +2,23 @@ package datastores
 
 import (
 	"database/sql"
-	"errors"
-	"log"
-	"net/http"
 
+	"github.com/mangoslicer/answer-patch/apierrors"
 	"github.com/mangoslicer/answer-patch/models"
+	"github.com/mangoslicer/answer-patch/settings"
 )
 
 type AnswerStoreServices interface {
 	IsAnswerSlotAvailable(string) (bool, error)
-	StoreAnswer(string, string, string, int) (error, int)
-	CastVote(string, int) (string, error, int)
-	AssessAnswers(string) (error, int)
+	StoreAnswer(string, string, string, int) error
+	CastVote(string, string, string, int) (*models.Answer, int, error)
+	RetractVote(string, string, string) (*models.Answer, int, error)
+	FindVotes(string, string, models.PageRequest) (*models.AnswerVotePage, error)
+	AssessAnswers(string) error
 }
 
+var errAnswerNotFound = apierrors.New(apierrors.KindNotFound, apierrors.CodeAnswerNotFound, "No answer exists with the provided answer id")
+
 type AnswerStore struct {
 	DB *sql.DB
 }
@@ -24,102 +27,182 @@ func (store *AnswerStore) IsAnswerSlotAvailable(questionID string) (bool, error)
 
 	var pending int
 
-	row := store.DB.QueryRow(`SELECT pending_count FROM question WHERE id = $1`, questionID)
+	row := store.DB.QueryRow(`SELECT pending_count FROM question WHERE id = $1 AND removed_at IS NULL`, questionID)
 
 	err := row.Scan(&pending)
-	if err != nil {
-		log.Fatal(err)
-		return false, InternalErr
+	if err == sql.ErrNoRows {
+		return false, errQuestionNotFound
+	} else if err != nil {
+		return false, evaluateSQLError(err)
 	}
 
-	return pending < 5, nil
+	return pending < settings.Get().Rules.MaxPendingAnswers, nil
 }
 
-func (store *AnswerStore) StoreAnswer(questionID, userID, content string, reqUpvotes int) (error, int) {
+func (store *AnswerStore) StoreAnswer(questionID, userID, content string, reqUpvotes int) error {
 
 	row, err := store.DB.Query(`SELECT id FROM answer WHERE question_id = $1::uuid AND user_id = $2::uuid AND content = $3 AND required_upvotes = $4`, questionID, userID, content, reqUpvotes)
 	if err != nil {
-		evaluateSQLError(err)
-	} else if row.Next() {
-		return errors.New("Question already exists"), http.StatusBadRequest
+		return evaluateSQLError(err)
+	}
+	defer row.Close()
+
+	if row.Next() {
+		return apierrors.New(apierrors.KindConflict, apierrors.CodeDuplicateAnswer, "Question already exists")
 	}
 
-	return transact(store.DB, func(tx *sql.Tx) (error, int) {
+	return transact(store.DB, func(tx *sql.Tx) error {
 		_, err := tx.Exec(`INSERT INTO answer(question_id, user_id, content, required_upvotes) values($1::uuid, $2::uuid, $3, $4)`, questionID, userID, content, reqUpvotes)
 		if err != nil {
-			log.Fatal(err)
 			return evaluateSQLError(err)
 		}
 
 		_, err = tx.Exec(`UPDATE question SET pending_count = pending_count + 1 WHERE id = $1::uuid`, questionID)
 		if err != nil {
-			log.Fatal(err)
 			return evaluateSQLError(err)
 		}
 
-		return nil, http.StatusOK
+		return nil
 	})
 
 }
 
-func (store *AnswerStore) CastVote(answerID string, vote int) (string, error, int) {
+// CastVote records a user's vote on an answer to a question of category and returns the answer along with the resulting change in upvotes
+// Each user holds at most one vote per answer, so voting again replaces the previous vote rather than adding to it.
+// Answers to questions of other categories are not found, since the vote is credited to the author's rep in category
+func (store *AnswerStore) CastVote(answerID, category, userID string, vote int) (*models.Answer, int, error) {
+	return store.replaceVote(answerID, category, userID, vote)
+}
 
-	var userID string
+// RetractVote removes a user's vote on an answer to a question of category and returns the answer along with the resulting change in upvotes
+func (store *AnswerStore) RetractVote(answerID, category, userID string) (*models.Answer, int, error) {
+	return store.replaceVote(answerID, category, userID, 0)
+}
 
-	row, err := store.DB.Query(`SELECT user_id FROM answer WHERE id = $1::uuid`, answerID)
+// FindVotes lists a page of the votes a user has cast on answers, latest first. If questionID is not empty, only votes on the answers of that question are listed
+func (store *AnswerStore) FindVotes(userID, questionID string, page models.PageRequest) (*models.AnswerVotePage, error) {
+
+	c, err := decodeCursor("votes", page.Cursor, timeKey, idKey)
 	if err != nil {
-		log.Fatal(err)
-		return "", InternalErr, http.StatusInternalServerError
-	} else if !row.Next() {
-		return "", errors.New("No answer exists with the provided answer id"), http.StatusBadRequest
+		return nil, err
+	}
+
+	var where conditions
+	where.add(`v.user_id = ` + where.param(userID) + `::uuid`)
+	if questionID != "" {
+		where.add(`a.question_id = ` + where.param(questionID) + `::uuid`)
 	}
 
-	err = row.Scan(&userID)
+	orderBy := where.addKeyset(c, []string{"v.cast_at", "v.answer_id"}, true)
+	limit := where.param(page.Size + 1)
+
+	rows, err := store.DB.Query(`SELECT v.answer_id, a.question_id, v.vote, v.cast_at FROM answer_vote v INNER JOIN answer a ON v.answer_id = a.id`+where.String()+orderBy+` LIMIT `+limit, where.params...)
 	if err != nil {
-		log.Fatal(err)
-		return "", InternalErr, http.StatusInternalServerError
+		return nil, evaluateSQLError(err)
 	}
+	defer rows.Close()
+
+	votes := []*models.AnswerVote{}
 
-	err, statusCode := transact(store.DB, func(tx *sql.Tx) (error, int) {
-		_, err := tx.Exec(`UPDATE answer SET upvotes = upvotes + $1 WHERE id = $2`, vote, answerID)
+	for rows.Next() {
+		vote := new(models.AnswerVote)
+		err = rows.Scan(&vote.AnswerID, &vote.QuestionID, &vote.Vote, &vote.CastAt)
+		if err != nil {
+			return nil, evaluateSQLError(err)
+		}
+		votes = append(votes, vote)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, evaluateSQLError(err)
+	}
+
+	n, more := trimPage(votes, c, page.Size)
+	votes = votes[:n]
+
+	return &models.AnswerVotePage{Items: votes, Cursors: pageCursors("votes", c, n, more, func(i int) []interface{} {
+		return []interface{}{votes[i].CastAt, votes[i].AnswerID}
+	})}, nil
+}
+
+// replaceVote sets a user's vote on an answer in the answer_vote ledger, where a vote of 0 removes the user's vote
+// The answer's upvotes column caches the ledger's total and is updated within the same transaction
+func (store *AnswerStore) replaceVote(answerID, category, userID string, vote int) (*models.Answer, int, error) {
+
+	answer := models.NewAnswer()
+	var change int
+
+	err := transact(store.DB, func(tx *sql.Tx) error {
+
+		// Locking the answer row serializes concurrent votes, so that the ledger and the cached total can not drift apart
+		err := tx.QueryRow(`SELECT a.id, a.question_id, a.user_id FROM answer a INNER JOIN question q ON q.id = a.question_id INNER JOIN category c ON c.id = q.category_id
+WHERE a.id = $1::uuid AND lower(c.category_name) = lower($2) AND a.removed_at IS NULL FOR UPDATE OF a`, answerID, category).Scan(&answer.ID, &answer.QuestionID, &answer.UserID)
+		if err == sql.ErrNoRows {
+			return errAnswerNotFound
+		} else if err != nil {
+			return evaluateSQLError(err)
+		} else if answer.UserID == userID {
+			return apierrors.New(apierrors.KindForbidden, apierrors.CodeSelfVote, "Users can not vote on their own answers")
+		}
+
+		var previousVote int
+
+		err = tx.QueryRow(`SELECT vote FROM answer_vote WHERE user_id = $1 AND answer_id = $2`, userID, answerID).Scan(&previousVote)
+		if err != nil && err != sql.ErrNoRows {
+			return evaluateSQLError(err)
+		}
+
+		change = vote - previousVote
+		if change == 0 {
+			return nil
+		}
+
+		if vote == 0 {
+			_, err = tx.Exec(`DELETE FROM answer_vote WHERE user_id = $1 AND answer_id = $2`, userID, answerID)
+		} else {
+			_, err = tx.Exec(`INSERT INTO answer_vote(user_id, answer_id, vote) VALUES($1, $2, $3) ON CONFLICT (user_id, answer_id) WHERE user_id <> '00000000-0000-0000-0000-000000000000' DO UPDATE SET vote = EXCLUDED.vote, cast_at = (CURRENT_TIMESTAMP AT TIME ZONE 'UTC')`, userID, answerID, vote)
+		}
+		if err != nil {
+			return evaluateSQLError(err)
+		}
+
+		_, err = tx.Exec(`UPDATE answer SET upvotes = upvotes + $1 WHERE id = $2`, change, answerID)
 		if err != nil {
-			log.Fatal(err)
 			return evaluateSQLError(err)
 		}
 
-		return nil, http.StatusOK
+		return nil
 	})
 	if err != nil {
-		return "", err, statusCode
+		return nil, 0, err
 	}
 
-	return userID, nil, http.StatusOK
+	return answer, change, nil
 }
 
 // AssessAnswers determines the answer that is most qualified to be considered the current answer
-func (store *AnswerStore) AssessAnswers(questionID string) (error, int) {
+func (store *AnswerStore) AssessAnswers(questionID string) error {
 
 	var qualifiedAnswers []*models.Answer
 	var isCurrentAnswerExistant bool = false
 
-	_, err := store.DB.Exec(`DELETE FROM answer WHERE upvotes = 0`)
+	// Removed answers are kept for the moderation log, even once they fall to zero upvotes
+	_, err := store.DB.Exec(`DELETE FROM answer WHERE question_id = $1 AND upvotes = 0 AND removed_at IS NULL`, questionID)
 	if err != nil {
-		log.Fatal(err)
-		return InternalErr, http.StatusInternalServerError
+		return evaluateSQLError(err)
 	}
 
-	rows, err := store.DB.Query(`SELECT id, user_id, is_current_answer, upvotes, required_upvotes FROM answer WHERE question_id = $1 ORDER BY upvotes DESC, is_current_answer DESC, last_edited_at ASC`, questionID)
+	// Upvotes are totalled from the answer_vote ledger, rather than read from the cached upvotes column
+	rows, err := store.DB.Query(`SELECT a.id, a.user_id, a.is_current_answer, COALESCE(SUM(v.vote), 0) AS total, a.required_upvotes FROM answer a LEFT JOIN answer_vote v ON v.answer_id = a.id WHERE a.question_id = $1 AND a.removed_at IS NULL GROUP BY a.id ORDER BY total DESC, a.is_current_answer DESC, a.last_edited_at ASC`, questionID)
 	if err != nil {
-		log.Fatal(err)
-		return InternalErr, http.StatusInternalServerError
+		return evaluateSQLError(err)
 	}
+	defer rows.Close()
 
 	for rows.Next() {
 		tempAnswer := new(models.Answer)
 		err := rows.Scan(&tempAnswer.ID, &tempAnswer.UserID, &tempAnswer.IsCurrentAnswer, &tempAnswer.Upvotes, &tempAnswer.ReqUpvotes)
 		if err != nil {
-			log.Fatal(err)
-			return InternalErr, http.StatusInternalServerError
+			return evaluateSQLError(err)
 		}
 		//Appends all answers that have satisfied their calculated required upvotes
 		if tempAnswer.Upvotes >= tempAnswer.ReqUpvotes {
@@ -135,33 +218,38 @@ func (store *AnswerStore) AssessAnswers(questionID string) (error, int) {
 
 	//Either none of the answers satisfy their required amount of upvotes or the current answer is still the best candidate
 	if len(qualifiedAnswers) == 0 || (len(qualifiedAnswers) == 1 && isCurrentAnswerExistant == true) {
-		return nil, http.StatusOK
+		return nil
 
 	}
 
-	return transact(store.DB, func(tx *sql.Tx) (error, int) {
+	return transact(store.DB, func(tx *sql.Tx) error {
 
 		_, err := tx.Exec(`UPDATE answer SET is_current_answer = 'true' WHERE id = $1`, qualifiedAnswers[0].ID)
 		if err != nil {
 			return evaluateSQLError(err)
 		}
 
+		err = recordRevision(tx, qualifiedAnswers[0].ID)
+		if err != nil {
+			return err
+		}
+
 		if isCurrentAnswerExistant == true {
 			_, err = tx.Exec(`UPDATE answer SET is_current_answer = 'false' WHERE id = $1`, qualifiedAnswers[len(qualifiedAnswers)-1].ID)
 			if err != nil {
 				return evaluateSQLError(err)
 			}
-			_, err = tx.Exec(`UPDATE question SET pending_count = edit_count + 1`)
+			_, err = tx.Exec(`UPDATE question SET pending_count = edit_count + 1 WHERE id = $1`, questionID)
 			if err != nil {
 				return evaluateSQLError(err)
 			}
 		}
 
-		_, err = tx.Exec(`UPDATE question SET edit_count = edit_count + 1`)
+		_, err = tx.Exec(`UPDATE question SET edit_count = edit_count + 1 WHERE id = $1`, questionID)
 		if err != nil {
 			return evaluateSQLError(err)
 		}
-		return nil, http.StatusOK
+		return nil
 	})
 
 }
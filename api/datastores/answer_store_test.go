@@ -13,11 +13,17 @@ var GlobalAnswerStore *AnswerStore
 func init() {
 
 	settings.SetPreproductionEnv()
-	GlobalAnswerStore = &AnswerStore{ConnectToPostgres()}
+	GlobalAnswerStore = &AnswerStore{mustConnectToPostgres()}
 
-	dropPostgresTables(GlobalAnswerStore.DB)
-	initializePostgres(GlobalAnswerStore.DB)
-	populatePostgres(GlobalAnswerStore.DB)
+	if err := MigrateDown(GlobalAnswerStore.DB, len(migrations)); err != nil {
+		log.Fatal(err)
+	}
+	if err := MigrateUp(GlobalAnswerStore.DB); err != nil {
+		log.Fatal(err)
+	}
+	if err := populatePostgres(GlobalAnswerStore.DB); err != nil {
+		log.Fatal(err)
+	}
 
 }
 
@@ -88,9 +94,11 @@ func TestCastVote(t *testing.T) {
 		t.Error(err)
 	}
 
-	retrievedUserID, err, _ = GlobalAnswerStore.CastVote(answerID, 1)
+	answer, _, err := GlobalAnswerStore.CastVote(answerID, "balling", "0c1b2b91-9164-4d52-87b0-9c4b444ee62d", 1)
 	if err != nil {
 		t.Error(err)
+	} else {
+		retrievedUserID = answer.UserID
 	}
 
 	row = GlobalAnswerStore.DB.QueryRow(`SELECT upvotes FROM answer WHERE id = $1::uuid`, answerID)
@@ -108,18 +116,67 @@ func TestCastVote(t *testing.T) {
 
 func TestCastVoteWithNonexistantAnswerID(t *testing.T) {
 
-	_, err, _ := GlobalAnswerStore.CastVote("1da8f5f3-271e-4f35-a0dc-d2935effc524", -1) //the provided UUID does not exist
+	_, _, err := GlobalAnswerStore.CastVote("1da8f5f3-271e-4f35-a0dc-d2935effc524", "balling", "0c1b2b91-9164-4d52-87b0-9c4b444ee62d", -1) //the provided UUID does not exist
 
 	if err.Error() != "No answer exists with the provided answer id" {
 		t.Errorf("Expected the CastVote to return \"No answer exists with the provided answer id\", but CastVote returned %s", err.Error())
 	}
 }
 
+func TestCastVoteSwitchAndRetract(t *testing.T) {
+
+	answerID := "7253b7cd-0783-4b29-a11c-90bbc5d09c0e"
+	voterID := "85c3bdbc-5882-4571-aaee-e46a32713e91"
+
+	_, change, err := GlobalAnswerStore.CastVote(answerID, "city dining", voterID, 1)
+	if err != nil {
+		t.Error(err)
+	} else if change != 1 {
+		t.Errorf("Expected an upvote to change the upvotes by 1, but the upvotes changed by %d", change)
+	}
+
+	_, change, err = GlobalAnswerStore.CastVote(answerID, "city dining", voterID, 1)
+	if err != nil {
+		t.Error(err)
+	} else if change != 0 {
+		t.Errorf("Expected a repeated upvote to leave the upvotes unchanged, but the upvotes changed by %d", change)
+	}
+
+	_, change, err = GlobalAnswerStore.CastVote(answerID, "city dining", voterID, -1)
+	if err != nil {
+		t.Error(err)
+	} else if change != -2 {
+		t.Errorf("Expected switching an upvote to a downvote to change the upvotes by -2, but the upvotes changed by %d", change)
+	}
+
+	votes, err := GlobalAnswerStore.FindVotes(voterID, "526c4576-0e49-4e90-b760-e6976c698574", models.PageRequest{Size: 10})
+	if err != nil {
+		t.Error(err)
+	} else if len(votes.Items) != 1 || votes.Items[0].Vote != -1 {
+		t.Errorf("Expected the voter to hold a single downvote on the answers of the question, but recieved %+v", votes.Items)
+	}
+
+	_, change, err = GlobalAnswerStore.RetractVote(answerID, "city dining", voterID)
+	if err != nil {
+		t.Error(err)
+	} else if change != 1 {
+		t.Errorf("Expected retracting a downvote to change the upvotes by 1, but the upvotes changed by %d", change)
+	}
+}
+
+func TestCastVoteOnOwnAnswer(t *testing.T) {
+
+	_, _, err := GlobalAnswerStore.CastVote("7253b7cd-0783-4b29-a11c-90bbc5d09c0e", "city dining", "61633349-89f3-43c9-ac91-653b3229ecf7", 1)
+	if err == nil {
+		t.Errorf("Expected CastVote to reject a vote cast by the author of the answer")
+	}
+}
+
 func TestAssessAnswersWithNoQualifiedCurrentAnswers(t *testing.T) {
 
 	questionID := "38681976-4d2d-4581-8a68-1e4acfadcfa0"
 
-	err, _ := GlobalAnswerStore.AssessAnswers(questionID)
+	err := GlobalAnswerStore.AssessAnswers(questionID)
 
 	row, err := GlobalAnswerStore.DB.Query(`SELECT id FROM answer WHERE question_id = $1 AND is_current_answer = 'true'`, questionID)
 	if err != nil {
@@ -140,7 +197,7 @@ func TestAssessAnswersWithNewlyQualifiedCurrentAnswer(t *testing.T) {
 	questionID := "28a12532-bc7a-427c-8f55-b72b18df7c02"
 	expectedUserID := "df38ea24-e67b-43c6-92bf-184cecee3003"
 
-	err, _ := GlobalAnswerStore.AssessAnswers(questionID)
+	err := GlobalAnswerStore.AssessAnswers(questionID)
 	if err != nil {
 		t.Error(err)
 	}
@@ -183,7 +240,7 @@ func TestAssessAnswersWithNoNewCurrentAnswer(t *testing.T) {
 
 	expectedCurrentAnswerUserID := findCurrentAnswerUserID(questionID)
 
-	err, _ := GlobalAnswerStore.AssessAnswers(questionID)
+	err := GlobalAnswerStore.AssessAnswers(questionID)
 	if err != nil {
 		t.Error(err)
 	}
@@ -200,7 +257,7 @@ func TestAssessAnswersWithNewCurrentAnswer(t *testing.T) {
 	questionID := "b19dc050-5ab2-417b-931c-d02445c27aca"
 	expectedCurrentAnswerUserID := "85c3bdbc-5882-4571-aaee-e46a32713e91"
 
-	err, _ := GlobalAnswerStore.AssessAnswers(questionID)
+	err := GlobalAnswerStore.AssessAnswers(questionID)
 
 	if err != nil {
 		t.Error(err)
@@ -218,7 +275,7 @@ func TestAssessAnswersWithSameUpvotesAndCurrentAnswer(t *testing.T) {
 	questionID := "0a24c4cd-4c73-42e4-bcca-3844d088de85"
 	expectedCurrentAnswerUserID := "baeee18f-45db-4e68-81c4-25671beaab5f"
 
-	err, _ := GlobalAnswerStore.AssessAnswers(questionID)
+	err := GlobalAnswerStore.AssessAnswers(questionID)
 	if err != nil {
 		t.Error(err)
 	}
@@ -235,7 +292,7 @@ func TestAssessAnswersWithSameUpvotesAndNoCurrentAnswer(t *testing.T) {
 	questionID := "bf8111f3-e75f-40d7-8d5a-813ce3a429fe"
 	expectedCurrentAnswerUserID := "0c1b2b91-9164-4d52-87b0-9c4b444ee62d"
 
-	err, _ := GlobalAnswerStore.AssessAnswers(questionID)
+	err := GlobalAnswerStore.AssessAnswers(questionID)
 	if err != nil {
 		t.Error(err)
 	}
@@ -0,0 +1,133 @@
+package datastores
+
+import (
+	"database/sql"
+
+	"github.com/mangoslicer/answer-patch/apierrors"
+	"github.com/mangoslicer/answer-patch/models"
+)
+
+type CategoryStoreServices interface {
+	FindCategories(models.PageRequest) (*models.CategoryPage, error)
+	FindCategory(string) (*models.Category, error)
+	IsCategoryRegistered(string) (bool, error)
+	StoreCategory(string, string, string) error
+	UpdateCategory(string, string, string) error
+}
+
+var errCategoryNotFound = apierrors.New(apierrors.KindNotFound, apierrors.CodeCategoryNotFound, "The provided category does not exist")
+
+type CategoryStore struct {
+	DB *sql.DB
+}
+
+const categoryColumns = `c.id, c.category_name, c.description, c.user_id, u.username, c.created_at`
+
+// FindCategories lists a page of the categories, sorted by name
+func (store *CategoryStore) FindCategories(page models.PageRequest) (*models.CategoryPage, error) {
+
+	c, err := decodeCursor("categories", page.Cursor, textKey, idKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var where conditions
+	orderBy := where.addKeyset(c, []string{`c.category_name COLLATE "C"`, "c.id"}, false)
+	limit := where.param(page.Size + 1)
+
+	rows, err := store.DB.Query(`SELECT `+categoryColumns+` FROM category c INNER JOIN ap_user u ON c.user_id = u.id`+where.String()+orderBy+` LIMIT `+limit, where.params...)
+	if err != nil {
+		return nil, evaluateSQLError(err)
+	}
+	defer rows.Close()
+
+	categories := make([]*models.Category, 0)
+
+	for rows.Next() {
+		category := new(models.Category)
+		err = scanCategory(rows, category)
+		if err != nil {
+			return nil, evaluateSQLError(err)
+		}
+		categories = append(categories, category)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, evaluateSQLError(err)
+	}
+
+	n, more := trimPage(categories, c, page.Size)
+	categories = categories[:n]
+
+	return &models.CategoryPage{Items: categories, Cursors: pageCursors("categories", c, n, more, func(i int) []interface{} {
+		return []interface{}{categories[i].Name, categories[i].ID}
+	})}, nil
+}
+
+// FindCategory looks up a category by name. Names are compared case-insensitively, since the {category} route variables only match lowercase letters
+func (store *CategoryStore) FindCategory(name string) (*models.Category, error) {
+
+	row, err := store.DB.Query(`SELECT `+categoryColumns+` FROM category c INNER JOIN ap_user u ON c.user_id = u.id WHERE lower(c.category_name) = lower($1)`, name)
+	if err != nil {
+		return nil, evaluateSQLError(err)
+	}
+	defer row.Close()
+
+	if !row.Next() {
+		return nil, errCategoryNotFound
+	}
+
+	category := new(models.Category)
+	err = scanCategory(row, category)
+	if err != nil {
+		return nil, evaluateSQLError(err)
+	}
+
+	return category, nil
+}
+
+func (store *CategoryStore) IsCategoryRegistered(name string) (bool, error) {
+
+	row, err := store.DB.Query(`SELECT category_name FROM category WHERE lower(category_name) = lower($1)`, name)
+	if err != nil {
+		return false, evaluateSQLError(err)
+	}
+	defer row.Close()
+
+	return row.Next(), nil
+}
+
+func (store *CategoryStore) StoreCategory(userID, name, description string) error {
+
+	return transact(store.DB, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`INSERT INTO category(user_id, category_name, description) VALUES($1::uuid, $2, $3)`, userID, name, description)
+		if err != nil {
+			return evaluateSQLError(err)
+		}
+
+		return nil
+	})
+}
+
+// UpdateCategory renames and/or redescribes a category. Empty values leave the corresponding column unchanged
+func (store *CategoryStore) UpdateCategory(name, newName, newDescription string) error {
+
+	return transact(store.DB, func(tx *sql.Tx) error {
+		result, err := tx.Exec(`UPDATE category SET category_name = COALESCE(NULLIF($2, ''), category_name), description = COALESCE(NULLIF($3, ''), description) WHERE lower(category_name) = lower($1)`, name, newName, newDescription)
+		if err != nil {
+			return evaluateSQLError(err)
+		}
+
+		updated, err := result.RowsAffected()
+		if err != nil {
+			return evaluateSQLError(err)
+		} else if updated == 0 {
+			return errCategoryNotFound
+		}
+
+		return nil
+	})
+}
+
+func scanCategory(rows *sql.Rows, category *models.Category) error {
+	return rows.Scan(&category.ID, &category.Name, &category.Description, &category.UserID, &category.Username, &category.CreatedAt)
+}
@@ -0,0 +1,76 @@
+package datastores
+
+import (
+	"testing"
+
+	"github.com/mangoslicer/answer-patch/settings"
+)
+
+var GlobalCategoryStore *CategoryStore
+
+func init() {
+	settings.SetPreproductionEnv()
+	GlobalCategoryStore = &CategoryStore{mustConnectToPostgres()}
+}
+
+func TestIsCategoryRegistered(t *testing.T) {
+
+	registeredTests := []struct {
+		category string
+		expected bool
+	}{
+		{"gains", true},
+		{"Balling", true},
+		{"nonexistent", false},
+	}
+
+	for _, rt := range registeredTests {
+		result, err := GlobalCategoryStore.IsCategoryRegistered(rt.category)
+		if err != nil {
+			t.Error(err)
+		} else if result != rt.expected {
+			t.Errorf("Expected IsCategoryRegistered to return %t for the category %s, but recieved %t", rt.expected, rt.category, result)
+		}
+	}
+}
+
+func TestFindCategory(t *testing.T) {
+
+	category, err := GlobalCategoryStore.FindCategory("gains")
+	if err != nil {
+		t.Error(err)
+	} else if category.ID != "33f6b77a-4564-4aa9-8cc8-50bb01c6a609" || category.Username != "Tester2" {
+		t.Errorf("Expected the Gains category created by Tester2, but recieved %+v", category)
+	}
+}
+
+func TestStoreCategoryWithExistingName(t *testing.T) {
+
+	err := GlobalCategoryStore.StoreCategory("95954f28-a8c3-4e76-8c80-18de07931639", "GAINS", "")
+
+	expectedErrMessage := "The provided category_name is not unique"
+
+	if err == nil || err.Error() != expectedErrMessage {
+		t.Errorf("Expected an error message of %s, but recieved %v", expectedErrMessage, err)
+	}
+}
+
+func TestUpdateCategory(t *testing.T) {
+
+	err := GlobalCategoryStore.StoreCategory("95954f28-a8c3-4e76-8c80-18de07931639", "cooking", "")
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = GlobalCategoryStore.UpdateCategory("cooking", "baking", "Bread and cakes")
+	if err != nil {
+		t.Error(err)
+	}
+
+	category, err := GlobalCategoryStore.FindCategory("baking")
+	if err != nil {
+		t.Error(err)
+	} else if category.Description != "Bread and cakes" {
+		t.Errorf("Expected the renamed category to have a description of \"Bread and cakes\", but recieved \"%s\"", category.Description)
+	}
+}
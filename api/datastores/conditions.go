@@ -0,0 +1,45 @@
+package datastores
+
+import (
+	"strconv"
+	"strings"
+)
+
+// conditions builds the WHERE clause of a query out of conditions that are written by the stores themselves.
+// Values never become part of the text of a query; param passes them as numbered parameters instead
+type conditions struct {
+	clauses []string
+	params  []interface{}
+}
+
+// param adds a parameter and returns its placeholder
+func (where *conditions) param(value interface{}) string {
+	where.params = append(where.params, value)
+	return "$" + strconv.Itoa(len(where.params))
+}
+
+// add appends a condition, which is joined to the others with AND
+func (where *conditions) add(clause string) {
+	where.clauses = append(where.clauses, clause)
+}
+
+// addKeyset appends the condition of keysetSQL, whose parameters are numbered from the next placeholder on
+func (where *conditions) addKeyset(c *cursor, columns []string, descending bool) string {
+
+	keyset, orderBy, params := keysetSQL(c, columns, descending, len(where.params)+1)
+	if keyset != "" {
+		where.add(keyset)
+		where.params = append(where.params, params...)
+	}
+
+	return orderBy
+}
+
+func (where *conditions) String() string {
+
+	if len(where.clauses) == 0 {
+		return ""
+	}
+
+	return ` WHERE ` + strings.Join(where.clauses, ` AND `)
+}
@@ -0,0 +1,38 @@
+package datastores_test
+
+import (
+	"testing"
+
+	"github.com/mangoslicer/answer-patch/datastores"
+	"github.com/mangoslicer/answer-patch/datastores/storetest"
+)
+
+func TestDatabaseStoresConformance(t *testing.T) {
+
+	db, err := datastores.ConnectToPostgres()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := datastores.ConnectToRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	col, err := datastores.ConnectToMongoCol()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every test starts from a freshly populated schema. Rep and tokens need no reset, since their tests use unique keys
+	storetest.Run(t, func(t *testing.T) *datastores.Stores {
+		if err := datastores.MigrateDown(db, len(datastores.Migrations)); err != nil {
+			t.Fatal(err)
+		}
+		if err := datastores.MigrateUp(db); err != nil {
+			t.Fatal(err)
+		}
+		if err := datastores.PopulatePostgres(db); err != nil {
+			t.Fatal(err)
+		}
+		return datastores.NewDatabaseStores(db, conn, col)
+	})
+}
@@ -0,0 +1,289 @@
+package datastores
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mangoslicer/answer-patch/apierrors"
+	"github.com/mangoslicer/answer-patch/models"
+)
+
+// keyKind determines how a sort key of a cursor is parsed, compared and cast in queries
+type keyKind int
+
+const (
+	intKey keyKind = iota
+	floatKey
+	timeKey
+	idKey
+	textKey // Compared byte by byte, so the columns of text keys must be sorted with the "C" collation
+)
+
+var keyCasts = map[keyKind]string{
+	intKey:   "int",
+	floatKey: "numeric",
+	timeKey:  "timestamp",
+	idKey:    "uuid",
+	textKey:  "text",
+}
+
+// cursor is the decoded form of the opaque cursors that lists are paged with. Keys are the sort keys of the item at the edge of the page
+// that the cursor was made from, with the id of the item last, since ids break every tie. Listing names the list and its order,
+// so that a cursor can not be used to page through another list
+type cursor struct {
+	Listing  string   `json:"l"`
+	Keys     []string `json:"k"`
+	Backward bool     `json:"b,omitempty"` // Leads to the items before the item, rather than after it
+
+	kinds  []keyKind
+	values []interface{} // Keys parsed according to their kinds
+}
+
+var errInvalidCursor = apierrors.New(apierrors.KindInvalid, apierrors.CodeInvalidField, "The cursor is malformed, or belongs to another list", apierrors.FieldError{Field: "cursor", Reason: "invalid"})
+
+// decodeCursor returns nil for an empty cursor, which selects the first page of the list
+func decodeCursor(listing, encoded string, kinds ...keyKind) (*cursor, error) {
+
+	if encoded == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	c := &cursor{kinds: kinds, values: make([]interface{}, len(kinds))}
+	if err = json.Unmarshal(data, c); err != nil || c.Listing != listing || len(c.Keys) != len(kinds) {
+		return nil, errInvalidCursor
+	}
+
+	for i, kind := range kinds {
+		if c.values[i], err = parseKey(kind, c.Keys[i]); err != nil {
+			return nil, errInvalidCursor
+		}
+	}
+
+	return c, nil
+}
+
+func encodeCursor(listing string, keys []interface{}, backward bool) string {
+
+	c := cursor{Listing: listing, Keys: make([]string, len(keys)), Backward: backward}
+	for i, key := range keys {
+		c.Keys[i] = formatKey(key)
+	}
+
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func parseKey(kind keyKind, key string) (interface{}, error) {
+
+	switch kind {
+	case intKey:
+		return strconv.Atoi(key)
+	case floatKey:
+		return strconv.ParseFloat(key, 64)
+	case timeKey:
+		return time.Parse(time.RFC3339Nano, key)
+	case textKey:
+		return key, nil
+	}
+
+	return parseMemoryID(key)
+}
+
+// formatKey formats the sort keys of items, which are ints, floats, times, ids or text
+func formatKey(key interface{}) string {
+
+	switch k := key.(type) {
+	case int:
+		return strconv.Itoa(k)
+	case float64:
+		return strconv.FormatFloat(k, 'f', -1, 64)
+	case time.Time:
+		return k.UTC().Format(time.RFC3339Nano)
+	}
+
+	return key.(string)
+}
+
+// compareKeys compares two sets of sort keys of the same kinds, the first key first
+func compareKeys(x, y []interface{}) int {
+
+	for i := range x {
+		var order int
+		switch k := x[i].(type) {
+		case int:
+			order = compareInts(int64(k), int64(y[i].(int)))
+		case float64:
+			if l := y[i].(float64); k < l {
+				order = -1
+			} else if k > l {
+				order = 1
+			}
+		case time.Time:
+			order = compareInts(k.UnixNano(), y[i].(time.Time).UnixNano())
+		case string:
+			order = strings.Compare(k, y[i].(string))
+		}
+		if order != 0 {
+			return order
+		}
+	}
+
+	return 0
+}
+
+func compareInts(x, y int64) int {
+	if x < y {
+		return -1
+	} else if x > y {
+		return 1
+	}
+	return 0
+}
+
+// keysetSQL returns the condition that selects the rows past the cursor, along with its parameters, which are numbered from $first on.
+// It also returns the ORDER BY clause that the rows are fetched in, which is reversed for backward pages. The columns are listed in the
+// order that the list is sorted by, in which every column is descending if descending is set
+func keysetSQL(c *cursor, columns []string, descending bool, first int) (string, string, []interface{}) {
+
+	fetchDescending := descending != (c != nil && c.Backward)
+
+	direction := " ASC"
+	if fetchDescending {
+		direction = " DESC"
+	}
+	orderBy := ` ORDER BY ` + strings.Join(columns, direction+", ") + direction
+
+	if c == nil {
+		return "", orderBy, nil
+	}
+
+	placeholders := make([]string, len(columns))
+	params := make([]interface{}, len(columns))
+	for i := range columns {
+		placeholders[i] = "$" + strconv.Itoa(first+i) + "::" + keyCasts[c.kinds[i]]
+		params[i] = c.Keys[i]
+	}
+
+	operator := " > "
+	if fetchDescending {
+		operator = " < "
+	}
+
+	return "(" + strings.Join(columns, ", ") + ")" + operator + "(" + strings.Join(placeholders, ", ") + ")", orderBy, params
+}
+
+// memoryPage returns the bounds of the page that the cursor selects out of length items, which are sorted already.
+// keys returns the sort keys of the ith item
+func memoryPage(length int, c *cursor, size int, descending bool, keys func(int) []interface{}) (int, int, bool) {
+
+	if c == nil {
+		to := size
+		if to > length {
+			to = length
+		}
+		return 0, to, to < length
+	}
+
+	// Compares the ith item with the item of the cursor, in the order of the list
+	compare := func(i int) int {
+		order := compareKeys(keys(i), c.values)
+		if descending {
+			return -order
+		}
+		return order
+	}
+
+	if c.Backward {
+		to := sort.Search(length, func(i int) bool { return compare(i) >= 0 })
+		from := to - size
+		if from < 0 {
+			from = 0
+		}
+		return from, to, from > 0
+	}
+
+	from := sort.Search(length, func(i int) bool { return compare(i) > 0 })
+	to := from + size
+	if to > length {
+		to = length
+	}
+	return from, to, to < length
+}
+
+// sortByKeys sorts the items of a slice by their sort keys, which keys returns for the ith item
+func sortByKeys(items interface{}, descending bool, keys func(int) []interface{}) {
+	sort.Slice(items, func(i, j int) bool {
+		order := compareKeys(keys(i), keys(j))
+		if descending {
+			return order > 0
+		}
+		return order < 0
+	})
+}
+
+// trimPage drops the extra row that is fetched to tell whether the list goes on past a page, and restores the order of backward pages,
+// which are fetched in reverse. It returns the length of the page, and whether the list goes on in the direction it was fetched in
+func trimPage(items interface{}, c *cursor, size int) (int, bool) {
+
+	n := reflect.ValueOf(items).Len()
+	more := n > size
+	if more {
+		n = size
+	}
+
+	if c != nil && c.Backward {
+		swap := reflect.Swapper(items)
+		for i := 0; i < n/2; i++ {
+			swap(i, n-1-i)
+		}
+	}
+
+	return n, more
+}
+
+// pageCursors makes the cursors that lead from a page of n items to the pages next to it. keys returns the sort keys of the ith item of the page
+func pageCursors(listing string, c *cursor, n int, more bool, keys func(int) []interface{}) models.Cursors {
+
+	var cursors models.Cursors
+	if n == 0 {
+		return cursors
+	}
+
+	backward := c != nil && c.Backward
+	if (backward && more) || (!backward && c != nil) {
+		cursors.Prev = encodeCursor(listing, keys(0), true)
+	}
+	if (!backward && more) || backward {
+		cursors.Next = encodeCursor(listing, keys(n-1), false)
+	}
+
+	return cursors
+}
+
+// PageSessions pages the sessions of a user, most recently used first. Sessions are kept in the token store rather than in a table,
+// so they are sorted and paged in memory like the lists of the memory stores
+func PageSessions(sessions []*models.Session, page models.PageRequest) (*models.SessionPage, error) {
+
+	c, err := decodeCursor("sessions", page.Cursor, timeKey, textKey)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := func(i int) []interface{} { return []interface{}{sessions[i].LastUsedAt, sessions[i].ID} }
+	sortByKeys(sessions, true, keys)
+
+	from, to, more := memoryPage(len(sessions), c, page.Size, true, keys)
+	sessions = sessions[from:to]
+
+	return &models.SessionPage{Items: sessions, Cursors: pageCursors("sessions", c, len(sessions), more, keys)}, nil
+}
@@ -0,0 +1,7 @@
+package datastores
+
+// Exposes the schema helpers to the conformance tests, which live in the external datastores_test package since they import storetest
+var (
+	Migrations       = migrations
+	PopulatePostgres = populatePostgres
+)
@@ -0,0 +1,95 @@
+// Package fixtures holds the users, categories, questions and answers that every backend is populated with for testing
+package fixtures
+
+import (
+	"strconv"
+	"strings"
+)
+
+type User struct {
+	ID             string
+	Username       string
+	HashedPassword string
+}
+
+type Category struct {
+	ID     string
+	Name   string
+	UserID string
+}
+
+type Question struct {
+	ID           string
+	UserID       string
+	CategoryID   string
+	Title        string
+	Content      string
+	Upvotes      int
+	EditCount    int
+	PendingCount int
+}
+
+type Answer struct {
+	ID              string
+	QuestionID      string
+	UserID          string
+	IsCurrentAnswer bool
+	Content         string
+	Upvotes         int // Backed by as many upvotes from the first voters, ordered by username
+	ReqUpvotes      int
+}
+
+var Users = []User{
+	{"0c1b2b91-9164-4d52-87b0-9c4b444ee62d", "Tester1", "$2a$10$lWqqb7MhwH7YryO4DyjdeOsFQ9hK7qxZ8PPcm6qjuNlM47KNInHMK"},
+	{"95954f28-a8c3-4e76-8c80-18de07931639", "Tester2", "$2a$10$16XDQDyDfQxvil6dqC7fV.tWlf/lc1kD9sA9/8qONoGEm9GiJz6vS"},
+	{"85c3bdbc-5882-4571-aaee-e46a32713e91", "Tester3", "$2a$10$DtKbFWQgT0Htcu9dQUwSZ.Mu2Wp0YQDiyufWVfxDp30Bi/Fpru3A2"},
+	{"df38ea24-e67b-43c6-92bf-184cecee3003", "Tester4", "$2a$10$5uDl2b6dNMYsJ/G3AnnM3.4UkHmiAAW7t2u4CphLQnsmDpUec9wfe"},
+	{"61633349-89f3-43c9-ac91-653b3229ecf7", "Tester5", "$2a$10$LT3lw8NX7Ybnt0QJn511zuuP9JDBSW8g3/YSOasqN6L1b41SYvRDa"},
+	{"baeee18f-45db-4e68-81c4-25671beaab5f", "Tester6", "$2a$10$mks.PzG/45yOGtmmj9N..e.uUg8tdp9psNqK9Xw5kCRMy2ZFWuR6e"},
+}
+
+var Categories = []Category{
+	{"33f6b77a-4564-4aa9-8cc8-50bb01c6a609", "Gains", "95954f28-a8c3-4e76-8c80-18de07931639"},
+	{"7d2b570d-54c6-48b1-8f46-68304f163d6a", "City Dining", "95954f28-a8c3-4e76-8c80-18de07931639"},
+	{"cb996d64-bd2d-414c-bbdc-81faba62cdc2", "Balling", "95954f28-a8c3-4e76-8c80-18de07931639"},
+}
+
+var Questions = []Question{
+	{"38681976-4d2d-4581-8a68-1e4acfadcfa0", "0c1b2b91-9164-4d52-87b0-9c4b444ee62d", "33f6b77a-4564-4aa9-8cc8-50bb01c6a609", "What should my squat to bench ratio be?", "I need gains", 13, 4, 1},
+	{"526c4576-0e49-4e90-b760-e6976c698574", "0c1b2b91-9164-4d52-87b0-9c4b444ee62d", "7d2b570d-54c6-48b1-8f46-68304f163d6a", "Where is the best sushi place?", "I have cravings", 15, 5, 2},
+	{"0a24c4cd-4c73-42e4-bcca-3844d088de85", "85c3bdbc-5882-4571-aaee-e46a32713e91", "cb996d64-bd2d-414c-bbdc-81faba62cdc2", "Can Jordans make me a sick baller?", "I need to improve my game", 10, 1, 5},
+	{"b19dc050-5ab2-417b-931c-d02445c27aca", "df38ea24-e67b-43c6-92bf-184cecee3003", "33f6b77a-4564-4aa9-8cc8-50bb01c6a609", "How can I convince people to skip leg day?", "Please", 15, 2, 2},
+	{"28a12532-bc7a-427c-8f55-b72b18df7c02", "61633349-89f3-43c9-ac91-653b3229ecf7", "7d2b570d-54c6-48b1-8f46-68304f163d6a", "Should I sign up for a Groupon account?", "I like to dine at new places", 5, 3, 1},
+	{"bf8111f3-e75f-40d7-8d5a-813ce3a429fe", "baeee18f-45db-4e68-81c4-25671beaab5f", "cb996d64-bd2d-414c-bbdc-81faba62cdc2", "Is ball really life?", "I am having an existential crisis", 10, 2, 1},
+}
+
+// Answers are listed in the order they are submitted in, which breaks ties between equally upvoted answers
+var Answers = []Answer{
+	{"f46fd5c9-ea9b-4677-ba8a-433b27fc097c", "38681976-4d2d-4581-8a68-1e4acfadcfa0", "61633349-89f3-43c9-ac91-653b3229ecf7", false, "Always to never", 20, 25},
+	{"150aebd1-a381-4ba5-a612-cee110f771f0", "38681976-4d2d-4581-8a68-1e4acfadcfa0", "95954f28-a8c3-4e76-8c80-18de07931639", false, "It depends on the amount of cardio you do before leg day", 10, 25},
+	{"4bf6d7e3-681b-4ec3-9353-34490aba965b", "38681976-4d2d-4581-8a68-1e4acfadcfa0", "85c3bdbc-5882-4571-aaee-e46a32713e91", false, "Why would you disgrace the bench?", 14, 15},
+	{"c6f753ea-8b55-468f-9eb2-3ac03f6ed179", "526c4576-0e49-4e90-b760-e6976c698574", "df38ea24-e67b-43c6-92bf-184cecee3003", true, "Not Utah", 40, 15},
+	{"7253b7cd-0783-4b29-a11c-90bbc5d09c0e", "526c4576-0e49-4e90-b760-e6976c698574", "61633349-89f3-43c9-ac91-653b3229ecf7", false, "Not Massachusetts", 10, 15},
+	{"b50f0224-3fda-435b-a8a6-8257fcbf5aa7", "0a24c4cd-4c73-42e4-bcca-3844d088de85", "baeee18f-45db-4e68-81c4-25671beaab5f", true, "Yeah, get the ones with the neon laces", 25, 20},
+	{"fbd3d2ac-df1f-4861-8e46-9dd902f6f071", "0a24c4cd-4c73-42e4-bcca-3844d088de85", "0c1b2b91-9164-4d52-87b0-9c4b444ee62d", false, "Yeah, get the shinny ones", 25, 20},
+	{"d8e17edf-d58c-49d9-81a7-72badb2786e3", "b19dc050-5ab2-417b-931c-d02445c27aca", "95954f28-a8c3-4e76-8c80-18de07931639", true, "Convince them that small calfs are genetic", 40, 4},
+	{"c0392c0e-bd4e-41f7-94e7-ddd03ae58416", "b19dc050-5ab2-417b-931c-d02445c27aca", "85c3bdbc-5882-4571-aaee-e46a32713e91", false, "Be honest about the gain loss", 45, 10},
+	{"3b745a45-d085-476d-b909-11a1164dddb2", "28a12532-bc7a-427c-8f55-b72b18df7c02", "df38ea24-e67b-43c6-92bf-184cecee3003", false, "Yes, Groupon can save you a ton of money", 25, 25},
+	{"924310dc-9f18-447d-9dab-301653aed3bf", "28a12532-bc7a-427c-8f55-b72b18df7c02", "baeee18f-45db-4e68-81c4-25671beaab5f", false, "Only if can stand disappointing food", 1, 25},
+	{"43573548-9d27-4bc9-a0b1-5d72c8f6d5a5", "bf8111f3-e75f-40d7-8d5a-813ce3a429fe", "0c1b2b91-9164-4d52-87b0-9c4b444ee62d", false, "Well, this is has puzzled many philosophers. In fact, Albert Camus thought that this question was the only true philosophical question. There is not simple answer.", 30, 20},
+	{"7e0dca3b-0477-42c0-a501-05a6f89288c8", "bf8111f3-e75f-40d7-8d5a-813ce3a429fe", "95954f28-a8c3-4e76-8c80-18de07931639", false, "Yes.", 30, 20},
+}
+
+// Voters is the amount of users that are generated to cast the upvotes of the answers, since every upvote is backed by a vote in the ledger
+const Voters = 50
+
+// VoterUsername returns the username of the nth voter, counting from 1
+func VoterUsername(n int) string {
+	return "Voter" + strconv.Itoa(n)
+}
+
+// VoterHashedPassword returns a placeholder that is unique and as long as a bcrypt hash, since voters never log in
+func VoterHashedPassword(n int) string {
+	hash := "$2a$10$Voter" + strconv.Itoa(n)
+	return hash + strings.Repeat(".", 60-len(hash))
+}
@@ -0,0 +1,290 @@
+package datastores
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mangoslicer/answer-patch/apierrors"
+	"github.com/mangoslicer/answer-patch/datastores/fixtures"
+	"github.com/mangoslicer/answer-patch/models"
+)
+
+// MemoryDB holds the tables of the postgres schema in memory, so that the api and its tests can run without any database
+// Every memory store that shares a MemoryDB also shares its lock, which makes each store method atomic like a postgres transaction
+type MemoryDB struct {
+	mu sync.RWMutex
+
+	users         map[string]*userRow
+	categories    map[string]*categoryRow
+	questions     map[string]*questionRow
+	answers       map[string]*answerRow
+	questionVotes map[voteKey]*voteRow
+	answerVotes   map[voteKey]*voteRow
+	revisions     map[string][]*revisionRow // Keyed by question id, ordered by revision number
+	identities    map[identityKey]string    // Ids of the users that the subjects of identity providers are linked to
+	roles         map[roleKey]*roleRow
+	bans          map[string]*banRow // Keyed by the id of the banned user
+	flags         map[flagKey]*flagRow
+	actions       []*moderationActionRow // Ordered by the time the actions were taken
+	search        searchIndex            // Has a lock of its own, since searches only read lock the tables
+
+	seq int64 // Orders rows that were created within the same instant
+}
+
+type userRow struct {
+	id             string
+	username       string
+	hashedPassword string
+	email          string // Empty for users that registered before emails were collected
+	emailVerified  bool
+	createdAt      time.Time
+}
+
+type identityKey struct {
+	provider string
+	subject  string
+}
+
+// roleKey identifies the scope of a role. The categoryID of roles that apply everywhere is empty
+type roleKey struct {
+	userID     string
+	categoryID string
+}
+
+type roleRow struct {
+	role      models.Role
+	grantedAt time.Time
+	seq       int64
+}
+
+type banRow struct {
+	bannedBy string
+	reason   string
+	bannedAt time.Time
+}
+
+// flagKey identifies the flag of a user on a post. The answerID of flagged questions is empty
+type flagKey struct {
+	userID     string
+	questionID string
+	answerID   string
+}
+
+type flagRow struct {
+	reason     models.FlagReason
+	detail     string
+	flaggedAt  time.Time
+	resolvedAt time.Time // Zero until the flag is resolved
+	seq        int64
+}
+
+type moderationActionRow struct {
+	id          string
+	moderatorID string // Empty once the moderator is deleted
+	action      models.ModerationActionType
+	postType    models.PostType
+	questionID  string
+	answerID    string // Empty once the answer is deleted
+	reason      string
+	takenAt     time.Time
+}
+
+type categoryRow struct {
+	id          string
+	name        string
+	description string
+	userID      string
+	createdAt   time.Time
+}
+
+type questionRow struct {
+	id           string
+	userID       string
+	categoryID   string
+	title        string
+	content      string
+	upvotes      int
+	editCount    int
+	pendingCount int
+	submittedAt  time.Time
+	removedAt    time.Time // Zero unless the question was removed by a moderator
+	duplicateOf  string    // Empty unless the question was linked as a duplicate
+	seq          int64
+}
+
+type answerRow struct {
+	id              string
+	questionID      string
+	userID          string
+	isCurrentAnswer bool
+	content         string
+	upvotes         int
+	reqUpvotes      int
+	lastEditedAt    time.Time
+	removedAt       time.Time // Zero unless the answer was removed by a moderator
+	seq             int64
+}
+
+// removed mirrors the removed_at IS NOT NULL conditions of the postgres stores
+func (row *questionRow) removed() bool {
+	return !row.removedAt.IsZero()
+}
+
+func (row *answerRow) removed() bool {
+	return !row.removedAt.IsZero()
+}
+
+type revisionRow struct {
+	id              string
+	questionID      string
+	revision        int
+	answerID        string
+	userID          string
+	content         string
+	upvotes         int
+	becameCurrentAt time.Time
+}
+
+type voteKey struct {
+	userID string
+	postID string
+	// The votes of deleted users are reassigned to the placeholder user, and kept apart by the id of the user that cast them
+	formerUserID string
+}
+
+type voteRow struct {
+	vote   int
+	castAt time.Time
+	seq    int64
+}
+
+func NewMemoryDB() *MemoryDB {
+	return &MemoryDB{
+		// The placeholder user of deleted accounts exists from the start, like it does after the migrations of postgres
+		users:         map[string]*userRow{DeletedUserID: {id: DeletedUserID, username: DeletedUsername, hashedPassword: "!"}},
+		categories:    make(map[string]*categoryRow),
+		questions:     make(map[string]*questionRow),
+		answers:       make(map[string]*answerRow),
+		questionVotes: make(map[voteKey]*voteRow),
+		answerVotes:   make(map[voteKey]*voteRow),
+		revisions:     make(map[string][]*revisionRow),
+		identities:    make(map[identityKey]string),
+		roles:         make(map[roleKey]*roleRow),
+		bans:          make(map[string]*banRow),
+		flags:         make(map[flagKey]*flagRow),
+	}
+}
+
+// Populate fills the tables with the users, categories, questions and answers of the fixtures package, like populatePostgres
+func (db *MemoryDB) Populate() error {
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	now := db.now()
+
+	for _, user := range fixtures.Users {
+		db.users[user.ID] = &userRow{id: user.ID, username: user.Username, hashedPassword: user.HashedPassword, createdAt: now}
+	}
+
+	for _, category := range fixtures.Categories {
+		if _, ok := db.users[category.UserID]; !ok {
+			return fmt.Errorf("fixture category %s refers to the unknown user %s", category.Name, category.UserID)
+		}
+		db.categories[category.ID] = &categoryRow{id: category.ID, name: category.Name, userID: category.UserID, createdAt: now}
+	}
+
+	for _, question := range fixtures.Questions {
+		if _, ok := db.categories[question.CategoryID]; !ok {
+			return fmt.Errorf("fixture question %s refers to the unknown category %s", question.ID, question.CategoryID)
+		}
+		db.questions[question.ID] = &questionRow{id: question.ID, userID: question.UserID, categoryID: question.CategoryID, title: question.Title, content: question.Content, upvotes: question.Upvotes, editCount: question.EditCount, pendingCount: question.PendingCount, submittedAt: now, seq: db.nextSeq()}
+	}
+
+	// Each answer is timestamped a microsecond after the previous one, like the separate inserts of populatePostgres
+	for i, answer := range fixtures.Answers {
+		if _, ok := db.questions[answer.QuestionID]; !ok {
+			return fmt.Errorf("fixture answer %s refers to the unknown question %s", answer.ID, answer.QuestionID)
+		}
+		db.answers[answer.ID] = &answerRow{id: answer.ID, questionID: answer.QuestionID, userID: answer.UserID, isCurrentAnswer: answer.IsCurrentAnswer, content: answer.Content, upvotes: answer.Upvotes, reqUpvotes: answer.ReqUpvotes, lastEditedAt: now.Add(time.Duration(i) * time.Microsecond), seq: db.nextSeq()}
+	}
+
+	// Voters are ordered by username, which determines the voters that back the upvotes of each answer
+	voters := make([]*userRow, fixtures.Voters)
+	for n := 1; n <= fixtures.Voters; n++ {
+		voter := &userRow{id: newMemoryID(), username: fixtures.VoterUsername(n), hashedPassword: fixtures.VoterHashedPassword(n), createdAt: now}
+		db.users[voter.id] = voter
+		voters[n-1] = voter
+	}
+	sort.Slice(voters, func(i, j int) bool { return voters[i].username < voters[j].username })
+
+	for _, answer := range fixtures.Answers {
+		for i := 0; i < answer.Upvotes && i < len(voters); i++ {
+			db.answerVotes[voteKey{userID: voters[i].id, postID: answer.ID}] = &voteRow{vote: 1, castAt: now, seq: db.nextSeq()}
+		}
+	}
+
+	return nil
+}
+
+// now mirrors the CURRENT_TIMESTAMP AT TIME ZONE 'UTC' defaults of the schema, which are stored with microsecond precision
+func (db *MemoryDB) now() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+func (db *MemoryDB) nextSeq() int64 {
+	db.seq++
+	return db.seq
+}
+
+// newMemoryID generates a random (version 4) uuid, like the uuid_generate_v4() defaults of the schema
+func newMemoryID() string {
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err) // crypto/rand only fails if the operating system's entropy source is unavailable
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return formatUUID(hex.EncodeToString(b))
+}
+
+// parseMemoryID accepts the same uuid formats as postgres and returns the canonical form that the memory tables are keyed by
+func parseMemoryID(id string) (string, error) {
+
+	trimmed := id
+	if strings.HasPrefix(trimmed, "{") && strings.HasSuffix(trimmed, "}") {
+		trimmed = trimmed[1 : len(trimmed)-1]
+	}
+
+	digits := strings.ToLower(strings.Replace(trimmed, "-", "", -1))
+	if _, err := hex.DecodeString(digits); err != nil || len(digits) != 32 {
+		return "", apierrors.Wrap(apierrors.KindInvalid, apierrors.CodeMalformedID, "The provided id is malformed", fmt.Errorf("invalid input syntax for type uuid: %q", id))
+	}
+
+	return formatUUID(digits), nil
+}
+
+func formatUUID(digits string) string {
+	return digits[0:8] + "-" + digits[8:12] + "-" + digits[12:16] + "-" + digits[16:20] + "-" + digits[20:32]
+}
+
+// errMemoryReference mirrors the foreign key violations that postgres reports through evaluateSQLError
+func errMemoryReference(column string) error {
+	return apierrors.New(apierrors.KindInvalid, apierrors.CodeReferenceNotFound, "The provided "+column+" does not exist")
+}
+
+// errMemoryNotUnique mirrors the unique violations that postgres reports through evaluateSQLError
+func errMemoryNotUnique(column string) error {
+	return apierrors.New(apierrors.KindConflict, apierrors.CodeNotUnique, "The provided "+column+" is not unique", apierrors.FieldError{Field: uniqueColumnFields[column], Reason: "not_unique"})
+}
+
+// errMemoryUnsupported stands in for the errors that postgres raises when a query refers to a column or filter that does not exist
+var errMemoryUnsupported = errors.New("unsupported query")
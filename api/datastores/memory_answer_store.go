@@ -0,0 +1,316 @@
+package datastores
+
+import (
+	"sort"
+
+	"github.com/mangoslicer/answer-patch/apierrors"
+	"github.com/mangoslicer/answer-patch/models"
+	"github.com/mangoslicer/answer-patch/settings"
+)
+
+// MemoryAnswerStore implements AnswerStoreServices with the same semantics as AnswerStore
+type MemoryAnswerStore struct {
+	DB *MemoryDB
+}
+
+func (store *MemoryAnswerStore) IsAnswerSlotAvailable(questionID string) (bool, error) {
+
+	id, err := parseMemoryID(questionID)
+	if err != nil {
+		return false, err
+	}
+
+	store.DB.mu.RLock()
+	defer store.DB.mu.RUnlock()
+
+	row, ok := store.DB.questions[id]
+	if !ok || row.removed() {
+		return false, errQuestionNotFound
+	}
+
+	return row.pendingCount < settings.Get().Rules.MaxPendingAnswers, nil
+}
+
+func (store *MemoryAnswerStore) StoreAnswer(questionID, userID, content string, reqUpvotes int) error {
+
+	qid, err := parseMemoryID(questionID)
+	if err != nil {
+		return err
+	}
+	uid, err := parseMemoryID(userID)
+	if err != nil {
+		return err
+	}
+
+	store.DB.mu.Lock()
+	defer store.DB.mu.Unlock()
+
+	for _, row := range store.DB.answers {
+		if row.questionID == qid && row.userID == uid && row.content == content && row.reqUpvotes == reqUpvotes {
+			return apierrors.New(apierrors.KindConflict, apierrors.CodeDuplicateAnswer, "Question already exists")
+		}
+	}
+
+	question, ok := store.DB.questions[qid]
+	if !ok {
+		return errMemoryReference("question_id")
+	}
+	if _, ok := store.DB.users[uid]; !ok {
+		return errMemoryReference("user_id")
+	}
+
+	id := newMemoryID()
+	store.DB.answers[id] = &answerRow{id: id, questionID: qid, userID: uid, content: content, reqUpvotes: reqUpvotes, lastEditedAt: store.DB.now(), seq: store.DB.nextSeq()}
+	question.pendingCount++
+
+	return nil
+}
+
+// CastVote records a user's vote on an answer to a question of category and returns the answer along with the resulting change in upvotes
+// Each user holds at most one vote per answer, so voting again replaces the previous vote rather than adding to it.
+// Answers to questions of other categories are not found, since the vote is credited to the author's rep in category
+func (store *MemoryAnswerStore) CastVote(answerID, category, userID string, vote int) (*models.Answer, int, error) {
+	return store.replaceVote(answerID, category, userID, vote)
+}
+
+// RetractVote removes a user's vote on an answer to a question of category and returns the answer along with the resulting change in upvotes
+func (store *MemoryAnswerStore) RetractVote(answerID, category, userID string) (*models.Answer, int, error) {
+	return store.replaceVote(answerID, category, userID, 0)
+}
+
+// FindVotes lists a page of the votes a user has cast on answers, latest first. If questionID is not empty, only votes on the answers of that question are listed
+func (store *MemoryAnswerStore) FindVotes(userID, questionID string, page models.PageRequest) (*models.AnswerVotePage, error) {
+
+	c, err := decodeCursor("votes", page.Cursor, timeKey, idKey)
+	if err != nil {
+		return nil, err
+	}
+
+	uid, err := parseMemoryID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var qid string
+	if questionID != "" {
+		if qid, err = parseMemoryID(questionID); err != nil {
+			return nil, err
+		}
+	}
+
+	store.DB.mu.RLock()
+	defer store.DB.mu.RUnlock()
+
+	votes := []*models.AnswerVote{}
+
+	for key, row := range store.DB.answerVotes {
+		answer := store.DB.answers[key.postID]
+		if key.userID != uid || (qid != "" && answer.questionID != qid) {
+			continue
+		}
+		votes = append(votes, &models.AnswerVote{AnswerID: answer.id, QuestionID: answer.questionID, Vote: row.vote, CastAt: row.castAt})
+	}
+
+	keys := func(i int) []interface{} { return []interface{}{votes[i].CastAt, votes[i].AnswerID} }
+	sortByKeys(votes, true, keys)
+
+	from, to, more := memoryPage(len(votes), c, page.Size, true, keys)
+	votes = votes[from:to]
+
+	return &models.AnswerVotePage{Items: votes, Cursors: pageCursors("votes", c, len(votes), more, keys)}, nil
+}
+
+// replaceVote sets a user's vote on an answer in the vote ledger, where a vote of 0 removes the user's vote
+// The answer's upvotes cache the ledger's total and are updated under the same lock
+func (store *MemoryAnswerStore) replaceVote(answerID, category, userID string, vote int) (*models.Answer, int, error) {
+
+	id, err := parseMemoryID(answerID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	store.DB.mu.Lock()
+	defer store.DB.mu.Unlock()
+
+	row, ok := store.DB.answers[id]
+	if !ok || row.removed() || !store.DB.inCategory(store.DB.questions[row.questionID], category) {
+		return nil, 0, errAnswerNotFound
+	} else if row.userID == userID {
+		return nil, 0, apierrors.New(apierrors.KindForbidden, apierrors.CodeSelfVote, "Users can not vote on their own answers")
+	}
+
+	uid, err := parseMemoryID(userID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	answer := models.NewAnswer()
+	answer.ID, answer.QuestionID, answer.UserID = row.id, row.questionID, row.userID
+
+	key := voteKey{userID: uid, postID: id}
+
+	var previousVote int
+	if previous, ok := store.DB.answerVotes[key]; ok {
+		previousVote = previous.vote
+	}
+
+	change := vote - previousVote
+	if change == 0 {
+		return answer, 0, nil
+	}
+
+	if vote == 0 {
+		delete(store.DB.answerVotes, key)
+	} else if _, ok := store.DB.users[uid]; !ok {
+		return nil, 0, errMemoryReference("user_id")
+	} else {
+		store.DB.answerVotes[key] = &voteRow{vote: vote, castAt: store.DB.now(), seq: store.DB.nextSeq()}
+	}
+
+	row.upvotes += change
+
+	return answer, change, nil
+}
+
+// AssessAnswers determines the answer that is most qualified to be considered the current answer
+func (store *MemoryAnswerStore) AssessAnswers(questionID string) error {
+
+	qid, err := parseMemoryID(questionID)
+	if err != nil {
+		return err
+	}
+
+	store.DB.mu.Lock()
+	defer store.DB.mu.Unlock()
+
+	var candidates []*answerRow
+	totals := make(map[string]int)
+
+	for _, row := range store.DB.answers {
+		// Removed answers are kept for the moderation log, even once they fall to zero upvotes
+		if row.questionID != qid || row.removed() {
+			continue
+		} else if row.upvotes == 0 {
+			store.DB.deleteAnswer(row.id)
+			continue
+		}
+		candidates = append(candidates, row)
+	}
+
+	// Upvotes are totalled from the vote ledger, rather than read from the cached upvotes
+	for key, vote := range store.DB.answerVotes {
+		totals[key.postID] += vote.vote
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if totals[a.id] != totals[b.id] {
+			return totals[a.id] > totals[b.id]
+		} else if a.isCurrentAnswer != b.isCurrentAnswer {
+			return a.isCurrentAnswer
+		} else if !a.lastEditedAt.Equal(b.lastEditedAt) {
+			return a.lastEditedAt.Before(b.lastEditedAt)
+		}
+		return a.seq < b.seq
+	})
+
+	var qualifiedAnswers []*answerRow
+	var isCurrentAnswerExistant bool = false
+
+	for _, candidate := range candidates {
+		//Appends all answers that have satisfied their calculated required upvotes
+		if totals[candidate.id] >= candidate.reqUpvotes {
+			qualifiedAnswers = append(qualifiedAnswers, candidate)
+		}
+
+		// Breaks loop after the current answer in order to avoid considering answers that have less upvotes that the current answer
+		if candidate.isCurrentAnswer == true {
+			isCurrentAnswerExistant = true
+			break
+		}
+	}
+
+	//Either none of the answers satisfy their required amount of upvotes or the current answer is still the best candidate
+	if len(qualifiedAnswers) == 0 || (len(qualifiedAnswers) == 1 && isCurrentAnswerExistant == true) {
+		return nil
+	}
+
+	question := store.DB.questions[qid]
+
+	qualifiedAnswers[0].isCurrentAnswer = true
+	store.DB.recordRevision(qualifiedAnswers[0])
+
+	if isCurrentAnswerExistant == true {
+		qualifiedAnswers[len(qualifiedAnswers)-1].isCurrentAnswer = false
+		question.pendingCount = question.editCount + 1
+	}
+
+	question.editCount++
+
+	return nil
+}
+
+// currentAnswer returns the current answer of a question, or nil if the question lacks one
+func (db *MemoryDB) currentAnswer(questionID string) *answerRow {
+	for _, row := range db.answers {
+		if row.questionID == questionID && row.isCurrentAnswer && !row.removed() {
+			return row
+		}
+	}
+	return nil
+}
+
+// hasPendingAnswers reports whether a question has answers besides its current answer
+func (db *MemoryDB) hasPendingAnswers(questionID string) bool {
+	for _, row := range db.answers {
+		if row.questionID == questionID && !row.isCurrentAnswer && !row.removed() {
+			return true
+		}
+	}
+	return false
+}
+
+// deleteAnswer removes an answer along with its votes, while its revisions outlive it like the answer_id foreign key's ON DELETE SET NULL
+func (db *MemoryDB) deleteAnswer(answerID string) {
+
+	delete(db.answers, answerID)
+
+	for key := range db.answerVotes {
+		if key.postID == answerID {
+			delete(db.answerVotes, key)
+		}
+	}
+
+	for key := range db.flags {
+		if key.answerID == answerID {
+			delete(db.flags, key)
+		}
+	}
+	for _, action := range db.actions {
+		if action.answerID == answerID {
+			action.answerID = ""
+		}
+	}
+
+	for _, revisions := range db.revisions {
+		for _, revision := range revisions {
+			if revision.answerID == answerID {
+				revision.answerID = ""
+			}
+		}
+	}
+}
+
+func (db *MemoryDB) answer(row *answerRow) *models.Answer {
+	return &models.Answer{
+		ID:              row.id,
+		QuestionID:      row.questionID,
+		UserID:          row.userID,
+		Username:        db.users[row.userID].username,
+		IsCurrentAnswer: row.isCurrentAnswer,
+		Content:         row.content,
+		Upvotes:         row.upvotes,
+		ReqUpvotes:      row.reqUpvotes,
+		LastEditedAt:    row.lastEditedAt,
+	}
+}
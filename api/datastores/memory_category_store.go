@@ -0,0 +1,131 @@
+package datastores
+
+import (
+	"strings"
+
+	"github.com/mangoslicer/answer-patch/models"
+)
+
+// MemoryCategoryStore implements CategoryStoreServices with the same semantics as CategoryStore
+type MemoryCategoryStore struct {
+	DB *MemoryDB
+}
+
+// FindCategories lists a page of the categories, sorted by name
+func (store *MemoryCategoryStore) FindCategories(page models.PageRequest) (*models.CategoryPage, error) {
+
+	c, err := decodeCursor("categories", page.Cursor, textKey, idKey)
+	if err != nil {
+		return nil, err
+	}
+
+	store.DB.mu.RLock()
+	defer store.DB.mu.RUnlock()
+
+	categories := make([]*models.Category, 0, len(store.DB.categories))
+	for _, row := range store.DB.categories {
+		categories = append(categories, store.DB.category(row))
+	}
+
+	keys := func(i int) []interface{} { return []interface{}{categories[i].Name, categories[i].ID} }
+	sortByKeys(categories, false, keys)
+
+	from, to, more := memoryPage(len(categories), c, page.Size, false, keys)
+	categories = categories[from:to]
+
+	return &models.CategoryPage{Items: categories, Cursors: pageCursors("categories", c, len(categories), more, keys)}, nil
+}
+
+// FindCategory looks up a category by name. Names are compared case-insensitively, since the {category} route variables only match lowercase letters
+func (store *MemoryCategoryStore) FindCategory(name string) (*models.Category, error) {
+
+	store.DB.mu.RLock()
+	defer store.DB.mu.RUnlock()
+
+	row := store.DB.findCategory(name)
+	if row == nil {
+		return nil, errCategoryNotFound
+	}
+
+	return store.DB.category(row), nil
+}
+
+func (store *MemoryCategoryStore) IsCategoryRegistered(name string) (bool, error) {
+
+	store.DB.mu.RLock()
+	defer store.DB.mu.RUnlock()
+
+	return store.DB.findCategory(name) != nil, nil
+}
+
+func (store *MemoryCategoryStore) StoreCategory(userID, name, description string) error {
+
+	uid, err := parseMemoryID(userID)
+	if err != nil {
+		return err
+	}
+
+	store.DB.mu.Lock()
+	defer store.DB.mu.Unlock()
+
+	if store.DB.findCategory(name) != nil {
+		return errMemoryNotUnique("category_name")
+	}
+	if _, ok := store.DB.users[uid]; !ok {
+		return errMemoryReference("user_id")
+	}
+
+	id := newMemoryID()
+	store.DB.categories[id] = &categoryRow{id: id, name: name, description: description, userID: uid, createdAt: store.DB.now()}
+
+	return nil
+}
+
+// UpdateCategory renames and/or redescribes a category. Empty values leave the corresponding field unchanged
+func (store *MemoryCategoryStore) UpdateCategory(name, newName, newDescription string) error {
+
+	store.DB.mu.Lock()
+	defer store.DB.mu.Unlock()
+
+	row := store.DB.findCategory(name)
+	if row == nil {
+		return errCategoryNotFound
+	}
+
+	if newName != "" {
+		if other := store.DB.findCategory(newName); other != nil && other != row {
+			return errMemoryNotUnique("category_name")
+		}
+		row.name = newName
+	}
+	if newDescription != "" {
+		row.description = newDescription
+	}
+
+	return nil
+}
+
+func (db *MemoryDB) findCategory(name string) *categoryRow {
+	for _, row := range db.categories {
+		if strings.ToLower(row.name) == strings.ToLower(name) {
+			return row
+		}
+	}
+	return nil
+}
+
+// inCategory reports whether question was asked in the category of the given name, ignoring case like the postgres stores do
+func (db *MemoryDB) inCategory(question *questionRow, name string) bool {
+	return strings.ToLower(db.categories[question.categoryID].name) == strings.ToLower(name)
+}
+
+func (db *MemoryDB) category(row *categoryRow) *models.Category {
+	return &models.Category{
+		ID:          row.id,
+		Name:        row.name,
+		Description: row.description,
+		UserID:      row.userID,
+		Username:    db.users[row.userID].username,
+		CreatedAt:   row.createdAt,
+	}
+}
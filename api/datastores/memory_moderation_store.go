@@ -0,0 +1,254 @@
+package datastores
+
+import (
+	"strings"
+
+	"github.com/mangoslicer/answer-patch/models"
+)
+
+// MemoryModerationStore implements ModerationStoreServices with the same semantics as ModerationStore
+type MemoryModerationStore struct {
+	DB *MemoryDB
+}
+
+func (store *MemoryModerationStore) FlagPost(post models.PostRef, userID string, reason models.FlagReason, detail string) error {
+
+	uid, err := parseMemoryID(userID)
+	if err != nil {
+		return err
+	}
+
+	store.DB.mu.Lock()
+	defer store.DB.mu.Unlock()
+
+	question, answer, err := store.DB.findModeratedPost(post)
+	if err != nil {
+		return err
+	}
+
+	key := flagKey{userID: uid, questionID: question.id}
+	if answer != nil {
+		key.answerID = answer.id
+	}
+
+	if _, ok := store.DB.flags[key]; ok {
+		return errAlreadyFlagged
+	} else if _, ok := store.DB.users[uid]; !ok {
+		return errMemoryReference("user_id")
+	}
+
+	store.DB.flags[key] = &flagRow{reason: reason, detail: detail, flaggedAt: store.DB.now(), seq: store.DB.nextSeq()}
+
+	return nil
+}
+
+func (store *MemoryModerationStore) FindFlaggedPosts(category string, page models.PageRequest) (*models.FlaggedPostPage, error) {
+
+	c, err := decodeCursor("flags", page.Cursor, intKey, timeKey, idKey, idKey)
+	if err != nil {
+		return nil, err
+	}
+
+	store.DB.mu.RLock()
+	defer store.DB.mu.RUnlock()
+
+	type postKey struct {
+		questionID string
+		answerID   string
+	}
+
+	pending := make(map[postKey]*models.FlaggedPost)
+	firstSeqs := make(map[postKey]int64)
+
+	for key, flag := range store.DB.flags {
+
+		question := store.DB.questions[key.questionID]
+		categoryName := store.DB.categories[question.categoryID].name
+		if !flag.resolvedAt.IsZero() || question.removed() || (category != "" && strings.ToLower(categoryName) != strings.ToLower(category)) {
+			continue
+		}
+
+		var answer *answerRow
+		if key.answerID != "" {
+			if answer = store.DB.answers[key.answerID]; answer.removed() {
+				continue
+			}
+		}
+
+		pk := postKey{key.questionID, key.answerID}
+		post, ok := pending[pk]
+		if !ok {
+			post = &models.FlaggedPost{Type: models.PostQuestion, QuestionID: question.id, Category: categoryName, Title: question.title, Content: question.content, UserID: question.userID, Reasons: make(map[models.FlagReason]int)}
+			if answer != nil {
+				post.Type, post.AnswerID, post.Content, post.UserID = models.PostAnswer, answer.id, answer.content, answer.userID
+			}
+			post.Username = store.DB.users[post.UserID].username
+			post.FirstFlaggedAt = flag.flaggedAt
+			firstSeqs[pk] = flag.seq
+			pending[pk] = post
+		}
+
+		post.FlagCount++
+		post.Reasons[flag.reason]++
+		if flag.seq < firstSeqs[pk] {
+			post.FirstFlaggedAt = flag.flaggedAt
+			firstSeqs[pk] = flag.seq
+		}
+	}
+
+	posts := make([]*models.FlaggedPost, 0, len(pending))
+	for _, post := range pending {
+		posts = append(posts, post)
+	}
+
+	keys := func(i int) []interface{} { return flaggedPostKeys(posts[i]) }
+	sortByKeys(posts, false, keys)
+
+	from, to, more := memoryPage(len(posts), c, page.Size, false, keys)
+	posts = posts[from:to]
+
+	return &models.FlaggedPostPage{Items: posts, Cursors: pageCursors("flags", c, len(posts), more, keys)}, nil
+}
+
+func (store *MemoryModerationStore) DismissFlags(post models.PostRef, moderatorID string) error {
+
+	mid, err := parseMemoryID(moderatorID)
+	if err != nil {
+		return err
+	}
+
+	store.DB.mu.Lock()
+	defer store.DB.mu.Unlock()
+
+	question, answer, err := store.DB.findModeratedPost(post)
+	if err != nil {
+		return err
+	} else if _, ok := store.DB.users[mid]; !ok {
+		return errMemoryReference("user_id")
+	}
+
+	if store.DB.resolveFlags(question, answer) == 0 {
+		return errNoPendingFlags
+	}
+
+	store.DB.logModerationAction(mid, models.ActionDismissFlags, post.Type, question, answer, "")
+
+	return nil
+}
+
+func (store *MemoryModerationStore) RemovePost(post models.PostRef, moderatorID, reason string) (string, bool, error) {
+
+	mid, err := parseMemoryID(moderatorID)
+	if err != nil {
+		return "", false, err
+	}
+
+	store.DB.mu.Lock()
+	defer store.DB.mu.Unlock()
+
+	question, answer, err := store.DB.findModeratedPost(post)
+	if err != nil {
+		return "", false, err
+	} else if _, ok := store.DB.users[mid]; !ok {
+		return "", false, errMemoryReference("user_id")
+	}
+
+	var wasCurrent bool
+	if answer == nil {
+		question.removedAt = store.DB.now()
+	} else {
+		wasCurrent = answer.isCurrentAnswer
+		answer.removedAt = store.DB.now()
+		answer.isCurrentAnswer = false
+	}
+
+	store.DB.resolveFlags(question, answer)
+	store.DB.logModerationAction(mid, models.ActionRemove, post.Type, question, answer, reason)
+
+	return question.id, wasCurrent, nil
+}
+
+func (store *MemoryModerationStore) FindModerationActions(page models.PageRequest) (*models.ModerationActionPage, error) {
+
+	c, err := decodeCursor("moderation", page.Cursor, timeKey, idKey)
+	if err != nil {
+		return nil, err
+	}
+
+	store.DB.mu.RLock()
+	defer store.DB.mu.RUnlock()
+
+	actions := make([]*models.ModerationAction, len(store.DB.actions))
+	for i, row := range store.DB.actions {
+		actions[i] = &models.ModerationAction{ID: row.id, ModeratorID: row.moderatorID, Action: row.action, PostType: row.postType, QuestionID: row.questionID, AnswerID: row.answerID, Reason: row.reason, TakenAt: row.takenAt}
+		if moderator, ok := store.DB.users[row.moderatorID]; ok {
+			actions[i].ModeratorUsername = moderator.username
+		}
+	}
+
+	keys := func(i int) []interface{} { return []interface{}{actions[i].TakenAt, actions[i].ID} }
+	sortByKeys(actions, true, keys)
+
+	from, to, more := memoryPage(len(actions), c, page.Size, true, keys)
+	actions = actions[from:to]
+
+	return &models.ModerationActionPage{Items: actions, Cursors: pageCursors("moderation", c, len(actions), more, keys)}, nil
+}
+
+// findModeratedPost mirrors findModeratedPost of ModerationStore. The answer is nil if the post is a question. The caller must hold the lock
+func (db *MemoryDB) findModeratedPost(post models.PostRef) (*questionRow, *answerRow, error) {
+
+	id, err := parseMemoryID(post.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if post.Type == models.PostQuestion {
+		question, ok := db.questions[id]
+		if !ok || question.removed() || !db.inCategory(question, post.Category) {
+			return nil, nil, errQuestionNotFound
+		}
+		return question, nil, nil
+	}
+
+	answer, ok := db.answers[id]
+	if !ok || answer.removed() {
+		return nil, nil, errAnswerNotFound
+	}
+	question := db.questions[answer.questionID]
+	if question.removed() || !db.inCategory(question, post.Category) {
+		return nil, nil, errAnswerNotFound
+	}
+
+	return question, answer, nil
+}
+
+// resolveFlags resolves the flags of a post that await review, and returns the amount of flags that were resolved. The caller must hold the write lock
+func (db *MemoryDB) resolveFlags(question *questionRow, answer *answerRow) int {
+
+	var answerID string
+	if answer != nil {
+		answerID = answer.id
+	}
+
+	var resolved int
+	for key, flag := range db.flags {
+		if key.questionID == question.id && key.answerID == answerID && flag.resolvedAt.IsZero() {
+			flag.resolvedAt = db.now()
+			resolved++
+		}
+	}
+
+	return resolved
+}
+
+// logModerationAction appends an entry to the moderation log. The caller must hold the write lock
+func (db *MemoryDB) logModerationAction(moderatorID string, action models.ModerationActionType, postType models.PostType, question *questionRow, answer *answerRow, reason string) {
+
+	row := &moderationActionRow{id: newMemoryID(), moderatorID: moderatorID, action: action, postType: postType, questionID: question.id, reason: reason, takenAt: db.now()}
+	if answer != nil {
+		row.answerID = answer.id
+	}
+
+	db.actions = append(db.actions, row)
+}
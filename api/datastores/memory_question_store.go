@@ -0,0 +1,390 @@
+package datastores
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/mangoslicer/answer-patch/apierrors"
+	"github.com/mangoslicer/answer-patch/models"
+)
+
+// MemoryQuestionStore implements QuestionStoreServices with the same semantics as QuestionStore
+type MemoryQuestionStore struct {
+	DB *MemoryDB
+}
+
+func (store *MemoryQuestionStore) FindPostByID(questionID string) (*models.Question, *models.Answer, error) {
+
+	id, err := parseMemoryID(questionID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	store.DB.mu.RLock()
+	defer store.DB.mu.RUnlock()
+
+	row, ok := store.DB.questions[id]
+	if !ok || row.removed() {
+		return nil, nil, apierrors.New(apierrors.KindNotFound, apierrors.CodeQuestionNotFound, "No question exists with the id of "+questionID)
+	}
+
+	current := store.DB.currentAnswer(id)
+	if current == nil {
+		return store.DB.question(row), nil, nil // Returns only a question, if the question lacks any valid answer at the current moment
+	}
+
+	return store.DB.question(row), store.DB.answer(current), nil
+}
+
+func (store *MemoryQuestionStore) FindQuestionsByFilter(filter, val string, page models.PageRequest) (*models.QuestionPage, error) {
+
+	listing := "questions:" + filter
+	c, err := decodeCursor(listing, page.Cursor, intKey, idKey)
+	if err != nil {
+		return nil, err
+	}
+
+	store.DB.mu.RLock()
+	defer store.DB.mu.RUnlock()
+
+	var matches []*questionRow
+
+	for _, row := range store.DB.questions {
+		if row.removed() {
+			continue
+		}
+
+		switch filter {
+		case "posted-by":
+			if store.DB.users[row.userID].username == val {
+				matches = append(matches, row)
+			}
+		case "answered-by":
+			if current := store.DB.currentAnswer(row.id); current != nil && store.DB.users[current.userID].username == val {
+				matches = append(matches, row)
+			}
+		case "category":
+			if store.DB.categories[row.categoryID].name == val {
+				matches = append(matches, row)
+			}
+		default:
+			return nil, apierrors.Internal(errMemoryUnsupported)
+		}
+	}
+
+	keys := func(i int) []interface{} { return []interface{}{matches[i].upvotes, matches[i].id} }
+	sortByKeys(matches, true, keys)
+
+	from, to, more := memoryPage(len(matches), c, page.Size, true, keys)
+
+	return store.DB.questionPage(listing, c, matches[from:to], more, func(row *questionRow) interface{} { return row.upvotes }), nil
+}
+
+func (store *MemoryQuestionStore) SortQuestions(postComponent, filter, order string, page models.PageRequest) (*models.QuestionPage, error) {
+
+	store.DB.mu.RLock()
+	defer store.DB.mu.RUnlock()
+
+	var rows []*questionRow
+	var key func(*questionRow) interface{}
+	var kind keyKind
+	var answers map[string]*answerRow
+
+	if postComponent == "question" {
+		for _, row := range store.DB.questions {
+			if !row.removed() {
+				rows = append(rows, row)
+			}
+		}
+		switch filter {
+		case "upvotes":
+			key, kind = func(row *questionRow) interface{} { return row.upvotes }, intKey
+		case "date":
+			key, kind = func(row *questionRow) interface{} { return row.submittedAt }, timeKey
+		case "edits":
+			key, kind = func(row *questionRow) interface{} { return row.editCount }, intKey
+		}
+	} else if postComponent == "answer" {
+		answers = make(map[string]*answerRow)
+		for _, row := range store.DB.questions {
+			if current := store.DB.currentAnswer(row.id); current != nil && !row.removed() {
+				answers[row.id] = current
+				rows = append(rows, row)
+			}
+		}
+		switch filter {
+		case "upvotes":
+			key, kind = func(row *questionRow) interface{} { return answers[row.id].upvotes }, intKey
+		case "date":
+			key, kind = func(row *questionRow) interface{} { return answers[row.id].lastEditedAt }, timeKey
+		}
+	}
+	descending, validOrder := sortOrders[strings.ToLower(order)]
+	if key == nil || !validOrder { // Return nil if the url param "filter" can not be converted into a valid sort key
+		return nil, apierrors.New(apierrors.KindInvalid, apierrors.CodeInvalidSortCriteria, "Could not recognize the sorting criteria")
+	}
+
+	listing := "sorted:" + postComponent + ":" + filter + ":" + strings.ToLower(order)
+	c, err := decodeCursor(listing, page.Cursor, kind, idKey)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := func(i int) []interface{} { return []interface{}{key(rows[i]), rows[i].id} }
+	sortByKeys(rows, descending, keys)
+
+	from, to, more := memoryPage(len(rows), c, page.Size, descending, keys)
+
+	return store.DB.questionPage(listing, c, rows[from:to], more, key), nil
+}
+
+func (store *MemoryQuestionStore) QueryQuestions(query *models.QuestionQuery, page models.PageRequest) (*models.QuestionPage, error) {
+
+	column, ok := questionQuerySorts[query.Sort]
+	if !ok {
+		return nil, apierrors.New(apierrors.KindInvalid, apierrors.CodeInvalidSortCriteria, "Could not recognize the sorting criteria")
+	}
+
+	listing := questionQueryListing(query)
+	c, err := decodeCursor(listing, page.Cursor, column.kind, idKey)
+	if err != nil {
+		return nil, err
+	}
+
+	categories := make(map[string]bool)
+	for _, category := range query.Categories {
+		categories[strings.ToLower(category)] = true
+	}
+
+	store.DB.mu.RLock()
+	defer store.DB.mu.RUnlock()
+
+	rows := make([]*questionRow, 0)
+	for _, row := range store.DB.questions {
+		switch {
+		case row.removed():
+		case len(categories) != 0 && !categories[strings.ToLower(store.DB.categories[row.categoryID].name)]:
+		case query.Author != "" && store.DB.users[row.userID].username != query.Author:
+		case query.Answered != nil && (store.DB.currentAnswer(row.id) != nil) != *query.Answered:
+		case query.HasPending != nil && store.DB.hasPendingAnswers(row.id) != *query.HasPending:
+		case !query.From.IsZero() && row.submittedAt.Before(query.From):
+		case !query.To.IsZero() && !row.submittedAt.Before(query.To):
+		case query.MinUpvotes != nil && row.upvotes < *query.MinUpvotes:
+		default:
+			rows = append(rows, row)
+		}
+	}
+
+	key := memoryQuestionSortKeys[query.Sort]
+	keys := func(i int) []interface{} { return []interface{}{key(rows[i]), rows[i].id} }
+	sortByKeys(rows, query.Descending, keys)
+
+	from, to, more := memoryPage(len(rows), c, page.Size, query.Descending, keys)
+
+	return store.DB.questionPage(listing, c, rows[from:to], more, key), nil
+}
+
+// Sort keys of the rows of questions, keyed by the sorts of questionQuerySorts
+var memoryQuestionSortKeys = map[string]func(*questionRow) interface{}{
+	"upvotes": func(row *questionRow) interface{} { return row.upvotes },
+	"date":    func(row *questionRow) interface{} { return row.submittedAt },
+	"edits":   func(row *questionRow) interface{} { return row.editCount },
+	"pending": func(row *questionRow) interface{} { return row.pendingCount },
+}
+
+func (store *MemoryQuestionStore) StoreQuestion(userID, categoryID, title, content, duplicateOf string) error {
+
+	uid, err := parseMemoryID(userID)
+	if err != nil {
+		return err
+	}
+	cid, err := parseMemoryID(categoryID)
+	if err != nil {
+		return err
+	}
+	if duplicateOf != "" {
+		if duplicateOf, err = parseMemoryID(duplicateOf); err != nil {
+			return err
+		}
+	}
+
+	store.DB.mu.Lock()
+	defer store.DB.mu.Unlock()
+
+	if duplicateOf != "" {
+		if row, ok := store.DB.questions[duplicateOf]; !ok || row.categoryID != cid || row.removed() {
+			return errDuplicateNotFound
+		}
+	}
+
+	for _, row := range store.DB.questions {
+		if row.title == title {
+			return errMemoryNotUnique("title")
+		}
+	}
+	if _, ok := store.DB.users[uid]; !ok {
+		return errMemoryReference("user_id")
+	}
+	if _, ok := store.DB.categories[cid]; !ok {
+		return errMemoryReference("category_id")
+	}
+
+	id := newMemoryID()
+	store.DB.questions[id] = &questionRow{id: id, userID: uid, categoryID: cid, title: title, content: content, duplicateOf: duplicateOf, submittedAt: store.DB.now(), seq: store.DB.nextSeq()}
+
+	return nil
+}
+
+func (store *MemoryQuestionStore) FindSimilarQuestions(categoryID, title, content string) ([]*models.DuplicateCandidate, error) {
+
+	cid, err := parseMemoryID(categoryID)
+	if err != nil {
+		return nil, err
+	}
+
+	store.DB.mu.RLock()
+	defer store.DB.mu.RUnlock()
+
+	titleTrigrams, contentTrigrams := trigrams(title), trigrams(content)
+	seqs := make(map[string]int64)
+
+	candidates := make([]*models.DuplicateCandidate, 0)
+	for _, row := range store.DB.questions {
+		if row.categoryID != cid || row.removed() {
+			continue
+		}
+
+		titleSimilarity := trigramSimilarity(titleTrigrams, trigrams(row.title))
+		if titleSimilarity < trigramThreshold {
+			continue
+		}
+		score := math.Max(titleSimilarity, titleSimilarityWeight*titleSimilarity+(1-titleSimilarityWeight)*trigramSimilarity(contentTrigrams, trigrams(row.content)))
+		if score < duplicateThreshold {
+			continue
+		}
+
+		candidates = append(candidates, &models.DuplicateCandidate{QuestionID: row.id, Username: store.DB.users[row.userID].username, Title: row.title, Score: score})
+		seqs[row.id] = row.seq
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Score != candidates[j].Score {
+			return candidates[i].Score > candidates[j].Score
+		}
+		return seqs[candidates[i].QuestionID] < seqs[candidates[j].QuestionID]
+	})
+
+	if len(candidates) > maxDuplicateCandidates {
+		candidates = candidates[:maxDuplicateCandidates]
+	}
+
+	return candidates, nil
+}
+
+// CastVote records a user's vote on a question of category and returns the question's author along with the resulting change in upvotes
+// Each user holds at most one vote per question, so voting again replaces the previous vote rather than adding to it.
+// Questions of other categories are not found, since the vote is credited to the author's rep in category
+func (store *MemoryQuestionStore) CastVote(questionID, category, userID string, vote int) (string, int, error) {
+
+	id, err := parseMemoryID(questionID)
+	if err != nil {
+		return "", 0, err
+	}
+
+	store.DB.mu.Lock()
+	defer store.DB.mu.Unlock()
+
+	row, ok := store.DB.questions[id]
+	if !ok || row.removed() || !store.DB.inCategory(row, category) {
+		return "", 0, errQuestionNotFound
+	} else if row.userID == userID {
+		return "", 0, apierrors.New(apierrors.KindForbidden, apierrors.CodeSelfVote, "Users can not vote on their own questions")
+	}
+
+	uid, err := parseMemoryID(userID)
+	if err != nil {
+		return "", 0, err
+	}
+
+	key := voteKey{userID: uid, postID: id}
+
+	var previousVote int
+	if previous, ok := store.DB.questionVotes[key]; ok {
+		previousVote = previous.vote
+	}
+
+	change := vote - previousVote
+	if change == 0 {
+		return row.userID, 0, nil
+	}
+
+	if _, ok := store.DB.users[uid]; !ok {
+		return "", 0, errMemoryReference("user_id")
+	}
+
+	store.DB.questionVotes[key] = &voteRow{vote: vote, castAt: store.DB.now(), seq: store.DB.nextSeq()}
+	row.upvotes += change
+
+	return row.userID, change, nil
+}
+
+// questionPage makes a page out of rows of questions. key returns the first sort key of a row of the page
+func (db *MemoryDB) questionPage(listing string, c *cursor, rows []*questionRow, more bool, key func(*questionRow) interface{}) *models.QuestionPage {
+
+	questions := make([]*models.Question, len(rows))
+	for i, row := range rows {
+		questions[i] = db.question(row)
+	}
+
+	return &models.QuestionPage{Items: questions, Cursors: pageCursors(listing, c, len(rows), more, func(i int) []interface{} {
+		return []interface{}{key(rows[i]), rows[i].id}
+	})}
+}
+
+func (db *MemoryDB) question(row *questionRow) *models.Question {
+	return &models.Question{
+		ID:           row.id,
+		UserID:       row.userID,
+		Username:     db.users[row.userID].username,
+		Category:     db.categories[row.categoryID].name,
+		Title:        row.title,
+		Content:      row.content,
+		Upvotes:      row.upvotes,
+		EditCount:    row.editCount,
+		PendingCount: row.pendingCount,
+		SubmittedAt:  row.submittedAt,
+		DuplicateOf:  row.duplicateOf,
+	}
+}
+
+// trigrams returns the set of trigrams of a text like pg_trgm does. Every lowercased word is padded with two spaces in front and one behind
+func trigrams(text string) map[string]bool {
+
+	set := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+		padded := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			set[string(padded[i:i+3])] = true
+		}
+	}
+
+	return set
+}
+
+// trigramSimilarity mirrors the similarity function of pg_trgm, which divides the amount of shared trigrams by the amount of distinct trigrams
+func trigramSimilarity(x, y map[string]bool) float64 {
+
+	var shared int
+	for trigram := range x {
+		if y[trigram] {
+			shared++
+		}
+	}
+
+	if union := len(x) + len(y) - shared; union != 0 {
+		return float64(shared) / float64(union)
+	}
+	return 0
+}
@@ -0,0 +1,88 @@
+package datastores
+
+import (
+	"sync"
+)
+
+// MemoryRepStore implements RepStoreServices with the same semantics as RepStore
+type MemoryRepStore struct {
+	mu  sync.Mutex
+	rep map[repKey]int
+}
+
+type repKey struct {
+	category string
+	userID   string
+}
+
+func NewMemoryRepStore() *MemoryRepStore {
+	return &MemoryRepStore{rep: make(map[repKey]int)}
+}
+
+func (store *MemoryRepStore) FindRep(category, userID string) (int, error) {
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	key := repKey{category, userID}
+
+	rep, ok := store.rep[key]
+	if !ok {
+		rep = startingRep
+		store.rep[key] = rep
+	}
+
+	return rep, nil
+}
+
+func (store *MemoryRepStore) UpdateRep(category, userID string, rep int) error {
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	key := repKey{category, userID}
+
+	if _, ok := store.rep[key]; !ok {
+		store.rep[key] = startingRep
+	}
+	store.rep[key] += rep
+
+	return nil
+}
+
+// FindTotalRep sums the rep that a user has earned across every category, leaving out the starting rep of each
+func (store *MemoryRepStore) FindTotalRep(userID string) (int, error) {
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	var total int
+
+	for key, rep := range store.rep {
+		if key.userID == userID {
+			total += rep - startingRep
+		}
+	}
+
+	return total, nil
+}
+
+// RenameCategory moves the rep that users have in a category over to its new name
+func (store *MemoryRepStore) RenameCategory(name, newName string) error {
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if name == newName {
+		return nil
+	}
+
+	for key, rep := range store.rep {
+		if key.category == name {
+			store.rep[repKey{newName, key.userID}] = rep
+			delete(store.rep, key)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,157 @@
+package datastores
+
+import (
+	"strconv"
+
+	"github.com/mangoslicer/answer-patch/apierrors"
+	"github.com/mangoslicer/answer-patch/models"
+)
+
+// MemoryRevisionStore implements RevisionStoreServices with the same semantics as RevisionStore
+type MemoryRevisionStore struct {
+	DB *MemoryDB
+}
+
+// FindRevisions lists a page of the answers that have been the current answer of a question, oldest first
+func (store *MemoryRevisionStore) FindRevisions(questionID string, page models.PageRequest) (*models.AnswerRevisionPage, error) {
+
+	c, err := decodeCursor("revisions", page.Cursor, intKey, idKey)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := parseMemoryID(questionID)
+	if err != nil {
+		return nil, err
+	}
+
+	store.DB.mu.RLock()
+	defer store.DB.mu.RUnlock()
+
+	if question, ok := store.DB.questions[id]; !ok || question.removed() {
+		return nil, errQuestionNotFound
+	}
+
+	revisions := make([]*models.AnswerRevision, 0)
+	for _, row := range store.DB.revisions[id] {
+		if store.DB.revisionVisible(row) {
+			revisions = append(revisions, store.DB.revision(row))
+		}
+	}
+
+	// Revisions are recorded in order, so they are sorted already
+	keys := func(i int) []interface{} { return []interface{}{revisions[i].Revision, revisions[i].ID} }
+
+	from, to, more := memoryPage(len(revisions), c, page.Size, false, keys)
+	revisions = revisions[from:to]
+
+	return &models.AnswerRevisionPage{Items: revisions, Cursors: pageCursors("revisions", c, len(revisions), more, keys)}, nil
+}
+
+func (store *MemoryRevisionStore) FindRevision(questionID string, revisionNumber int) (*models.AnswerRevision, error) {
+
+	id, err := parseMemoryID(questionID)
+	if err != nil {
+		return nil, err
+	}
+
+	store.DB.mu.RLock()
+	defer store.DB.mu.RUnlock()
+
+	row := store.DB.findRevision(id, revisionNumber)
+	if row == nil {
+		return nil, apierrors.New(apierrors.KindNotFound, apierrors.CodeRevisionNotFound, "No revision "+strconv.Itoa(revisionNumber)+" exists for the question with the id of "+questionID)
+	}
+
+	return store.DB.revision(row), nil
+}
+
+// RollbackToRevision makes the answer of a past revision the current answer again, which is itself recorded as a new revision
+func (store *MemoryRevisionStore) RollbackToRevision(questionID, category string, revisionNumber int) error {
+
+	id, err := parseMemoryID(questionID)
+	if err != nil {
+		return err
+	}
+
+	store.DB.mu.Lock()
+	defer store.DB.mu.Unlock()
+
+	if question, ok := store.DB.questions[id]; !ok || question.removed() || !store.DB.inCategory(question, category) {
+		return errQuestionNotFound
+	}
+
+	revision := store.DB.findRevision(id, revisionNumber)
+	if revision == nil {
+		return apierrors.New(apierrors.KindNotFound, apierrors.CodeRevisionNotFound, "No revision "+strconv.Itoa(revisionNumber)+" exists for the question with the id of "+questionID)
+	} else if revision.answerID == "" {
+		return apierrors.New(apierrors.KindGone, apierrors.CodeRevisionAnswerGone, "The answer of revision "+strconv.Itoa(revisionNumber)+" no longer exists")
+	}
+
+	if current := store.DB.currentAnswer(id); current != nil {
+		current.isCurrentAnswer = false
+	}
+
+	answer := store.DB.answers[revision.answerID]
+	answer.isCurrentAnswer = true
+	store.DB.recordRevision(answer)
+
+	return nil
+}
+
+func (db *MemoryDB) findRevision(questionID string, revisionNumber int) *revisionRow {
+	for _, row := range db.revisions[questionID] {
+		if row.revision == revisionNumber && db.revisionVisible(row) {
+			return row
+		}
+	}
+	return nil
+}
+
+// revisionVisible reports whether neither the question nor the answer of a revision has been removed by a moderator,
+// since removed posts drop out of the history along with everything else
+func (db *MemoryDB) revisionVisible(row *revisionRow) bool {
+
+	if question, ok := db.questions[row.questionID]; !ok || question.removed() {
+		return false
+	}
+
+	answer, ok := db.answers[row.answerID]
+	return !ok || !answer.removed()
+}
+
+// recordRevision snapshots an answer that has just become the current answer. The caller must hold the write lock
+func (db *MemoryDB) recordRevision(answer *answerRow) {
+
+	revisions := db.revisions[answer.questionID]
+
+	number := 1
+	if len(revisions) != 0 {
+		number = revisions[len(revisions)-1].revision + 1
+	}
+
+	db.revisions[answer.questionID] = append(revisions, &revisionRow{
+		id:              newMemoryID(),
+		questionID:      answer.questionID,
+		revision:        number,
+		answerID:        answer.id,
+		userID:          answer.userID,
+		content:         answer.content,
+		upvotes:         answer.upvotes,
+		becameCurrentAt: db.now(),
+	})
+}
+
+func (db *MemoryDB) revision(row *revisionRow) *models.AnswerRevision {
+	return &models.AnswerRevision{
+		ID:              row.id,
+		QuestionID:      row.questionID,
+		Revision:        row.revision,
+		AnswerID:        row.answerID,
+		UserID:          row.userID,
+		Username:        db.users[row.userID].username,
+		Content:         row.content,
+		Upvotes:         row.upvotes,
+		BecameCurrentAt: row.becameCurrentAt,
+	}
+}
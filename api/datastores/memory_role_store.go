@@ -0,0 +1,149 @@
+package datastores
+
+import (
+	"strings"
+
+	"github.com/mangoslicer/answer-patch/models"
+)
+
+// MemoryRoleStore implements RoleStoreServices with the same semantics as RoleStore
+type MemoryRoleStore struct {
+	DB *MemoryDB
+}
+
+func (store *MemoryRoleStore) FindRoles(userID string) (*models.Roles, error) {
+
+	id, err := parseMemoryID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	store.DB.mu.RLock()
+	defer store.DB.mu.RUnlock()
+
+	roles := &models.Roles{Role: models.RoleUser}
+
+	for key, row := range store.DB.roles {
+		if key.userID != id {
+			continue
+		}
+
+		if key.categoryID == "" {
+			roles.Role = row.role
+			continue
+		}
+		if roles.CategoryRoles == nil {
+			roles.CategoryRoles = make(map[string]models.Role)
+		}
+		roles.CategoryRoles[strings.ToLower(store.DB.categories[key.categoryID].name)] = row.role
+	}
+
+	_, roles.Banned = store.DB.bans[id]
+
+	return roles, nil
+}
+
+func (store *MemoryRoleStore) FindRoleGrants(page models.PageRequest) (*models.RoleGrantPage, error) {
+
+	c, err := decodeCursor("role_grants", page.Cursor, timeKey, textKey, idKey)
+	if err != nil {
+		return nil, err
+	}
+
+	store.DB.mu.RLock()
+	defer store.DB.mu.RUnlock()
+
+	grants := []*models.RoleGrant{}
+	for key, row := range store.DB.roles {
+
+		grant := &models.RoleGrant{UserID: key.userID, Username: store.DB.users[key.userID].username, Role: row.role, GrantedAt: row.grantedAt}
+		if key.categoryID != "" {
+			grant.Category = store.DB.categories[key.categoryID].name
+		}
+
+		grants = append(grants, grant)
+	}
+
+	keys := func(i int) []interface{} {
+		return []interface{}{grants[i].GrantedAt, grants[i].Category, grants[i].UserID}
+	}
+	sortByKeys(grants, false, keys)
+
+	from, to, more := memoryPage(len(grants), c, page.Size, false, keys)
+	grants = grants[from:to]
+
+	return &models.RoleGrantPage{Items: grants, Cursors: pageCursors("role_grants", c, len(grants), more, keys)}, nil
+}
+
+func (store *MemoryRoleStore) SetRole(userID, category string, role models.Role) error {
+
+	id, err := parseMemoryID(userID)
+	if err != nil {
+		return err
+	}
+
+	store.DB.mu.Lock()
+	defer store.DB.mu.Unlock()
+
+	key := roleKey{userID: id}
+	if category != "" {
+		row := store.DB.findCategory(category)
+		if row == nil {
+			return errCategoryNotFound
+		}
+		key.categoryID = row.id
+	}
+
+	if role == models.RoleUser {
+		delete(store.DB.roles, key)
+		return nil
+	}
+
+	if _, ok := store.DB.users[id]; !ok {
+		return errMemoryReference("user_id")
+	}
+
+	store.DB.roles[key] = &roleRow{role: role, grantedAt: store.DB.now(), seq: store.DB.nextSeq()}
+
+	return nil
+}
+
+func (store *MemoryRoleStore) BanUser(userID, bannedBy, reason string) error {
+
+	id, err := parseMemoryID(userID)
+	if err != nil {
+		return err
+	}
+	byID, err := parseMemoryID(bannedBy)
+	if err != nil {
+		return err
+	}
+
+	store.DB.mu.Lock()
+	defer store.DB.mu.Unlock()
+
+	if _, ok := store.DB.users[id]; !ok {
+		return errMemoryReference("user_id")
+	} else if _, ok := store.DB.bans[id]; ok {
+		return nil
+	}
+
+	store.DB.bans[id] = &banRow{bannedBy: byID, reason: reason, bannedAt: store.DB.now()}
+
+	return nil
+}
+
+func (store *MemoryRoleStore) UnbanUser(userID string) error {
+
+	id, err := parseMemoryID(userID)
+	if err != nil {
+		return err
+	}
+
+	store.DB.mu.Lock()
+	defer store.DB.mu.Unlock()
+
+	delete(store.DB.bans, id)
+
+	return nil
+}
@@ -0,0 +1,284 @@
+package datastores
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/mangoslicer/answer-patch/models"
+)
+
+// Weights of the words of titles, questions and current answers, which match the default weights of ts_rank for A, B and C
+var searchWeights = [3]float64{1.0, 0.4, 0.2}
+
+// Words that are left out of the index and of queries, like the stop words of the english configuration of postgres
+var searchStopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true, "but": true, "by": true, "can": true,
+	"do": true, "for": true, "from": true, "how": true, "i": true, "if": true, "in": true, "is": true, "it": true, "my": true,
+	"of": true, "on": true, "or": true, "should": true, "so": true, "that": true, "the": true, "this": true, "to": true, "was": true,
+	"what": true, "when": true, "where": true, "which": true, "who": true, "why": true, "will": true, "with": true, "you": true, "your": true,
+}
+
+// Amount of words in a snippet, and how many of them precede its first match
+const (
+	snippetWords = 35
+	snippetLead  = 5
+)
+
+// searchIndex holds the terms of every question, in place of the search_vector column that the triggers of postgres keep up to date.
+// An entry is rebuilt when it is searched after the title, the question or the current answer it was built from has changed
+type searchIndex struct {
+	mu   sync.Mutex
+	docs map[string]*searchDoc // Keyed by question id
+}
+
+type searchDoc struct {
+	source [3]string          // Title, question and current answer
+	terms  map[string]float64 // Weighted frequency of every term
+}
+
+// MemorySearchStore implements SearchStoreServices on top of the index of db, ranking its results like SearchStore
+type MemorySearchStore struct {
+	DB *MemoryDB
+}
+
+func (store *MemorySearchStore) SearchQuestions(query *models.SearchQuery, page models.PageRequest) (*models.SearchResultPage, error) {
+
+	c, err := decodeCursor("search", page.Cursor, floatKey, intKey, idKey)
+	if err != nil {
+		return nil, err
+	}
+
+	terms := searchTerms(query.Terms)
+	results := make([]*models.SearchResult, 0)
+	if len(terms) == 0 {
+		return &models.SearchResultPage{Items: results}, nil
+	}
+
+	store.DB.mu.RLock()
+	defer store.DB.mu.RUnlock()
+
+	index := &store.DB.search
+	index.mu.Lock()
+	defer index.mu.Unlock()
+
+	if index.docs == nil {
+		index.docs = make(map[string]*searchDoc)
+	}
+
+	for id, question := range store.DB.questions {
+
+		source := [3]string{question.title, question.content}
+		if answer := store.DB.currentAnswer(id); answer != nil {
+			source[2] = answer.content
+		}
+
+		doc, ok := index.docs[id]
+		if !ok || doc.source != source {
+			doc = newSearchDoc(source)
+			index.docs[id] = doc
+		}
+
+		if question.removed() {
+			continue
+		}
+
+		categoryName := store.DB.categories[question.categoryID].name
+		if query.Category != "" && strings.ToLower(categoryName) != strings.ToLower(query.Category) {
+			continue
+		}
+		username := store.DB.users[question.userID].username
+		if query.Author != "" && username != query.Author {
+			continue
+		}
+
+		rank, matched := doc.rank(terms)
+		if !matched {
+			continue
+		}
+
+		snippetSource := source[1]
+		if source[2] != "" {
+			snippetSource += "\n" + source[2]
+		}
+
+		results = append(results, &models.SearchResult{
+			QuestionID:     id,
+			UserID:         question.userID,
+			Username:       username,
+			Category:       categoryName,
+			Title:          question.title,
+			Upvotes:        question.upvotes,
+			SubmittedAt:    question.submittedAt,
+			TitleHighlight: formatHighlight(highlightTerms(question.title, terms, false)),
+			Snippet:        formatHighlight(highlightTerms(snippetSource, terms, true)),
+			Rank:           math.Round(rank*math.Pow10(rankDecimals)) / math.Pow10(rankDecimals),
+		})
+	}
+
+	// Entries of deleted questions are dropped rather than kept around
+	for id := range index.docs {
+		if _, ok := store.DB.questions[id]; !ok {
+			delete(index.docs, id)
+		}
+	}
+
+	keys := func(i int) []interface{} { return searchResultKeys(results[i]) }
+	sortByKeys(results, true, keys)
+
+	from, to, more := memoryPage(len(results), c, page.Size, true, keys)
+	results = results[from:to]
+
+	return &models.SearchResultPage{Items: results, Cursors: pageCursors("search", c, len(results), more, keys)}, nil
+}
+
+func newSearchDoc(source [3]string) *searchDoc {
+
+	doc := &searchDoc{source: source, terms: make(map[string]float64)}
+	for i, text := range source {
+		for _, term := range searchTerms(text) {
+			doc.terms[term] += searchWeights[i]
+		}
+	}
+
+	return doc
+}
+
+// rank sums the weights of the terms of a query, and reports whether the document contains every one of them
+func (doc *searchDoc) rank(terms []string) (float64, bool) {
+
+	var rank float64
+	for _, term := range terms {
+		weight, ok := doc.terms[term]
+		if !ok {
+			return 0, false
+		}
+		rank += weight
+	}
+
+	return rank, true
+}
+
+// wordSpan is the byte range of a word within a text
+type wordSpan struct {
+	start, end int
+}
+
+func searchWords(text string) []wordSpan {
+
+	var spans []wordSpan
+	start := -1
+	for i, r := range text {
+		isWordRune := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if isWordRune && start < 0 {
+			start = i
+		} else if !isWordRune && start >= 0 {
+			spans = append(spans, wordSpan{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, wordSpan{start, len(text)})
+	}
+
+	return spans
+}
+
+// searchTerms lowercases and stems the words of a text, leaving out its stop words
+func searchTerms(text string) []string {
+
+	var terms []string
+	for _, span := range searchWords(text) {
+		if term, ok := searchTerm(text[span.start:span.end]); ok {
+			terms = append(terms, term)
+		}
+	}
+
+	return terms
+}
+
+func searchTerm(word string) (string, bool) {
+
+	word = strings.ToLower(word)
+	if searchStopWords[word] {
+		return "", false
+	}
+
+	return stemTerm(word), true
+}
+
+// stemTerm strips the most common english suffixes, so that plurals and verb forms match their stem like they do with to_tsvector
+func stemTerm(term string) string {
+
+	switch {
+	case len(term) > 4 && strings.HasSuffix(term, "ies"):
+		return term[:len(term)-3] + "y"
+	case len(term) > 5 && strings.HasSuffix(term, "ing"):
+		return term[:len(term)-3]
+	case len(term) > 4 && strings.HasSuffix(term, "ed"):
+		return term[:len(term)-2]
+	case len(term) > 3 && strings.HasSuffix(term, "s") && !strings.HasSuffix(term, "ss"):
+		return term[:len(term)-1]
+	}
+
+	return term
+}
+
+// highlightTerms wraps the words of a text that match the terms in the highlight markers, like ts_headline.
+// A snippet is cut down to the words around its first match, or to its first words if nothing matches
+func highlightTerms(text string, terms []string, snippet bool) string {
+
+	wanted := make(map[string]bool, len(terms))
+	for _, term := range terms {
+		wanted[term] = true
+	}
+
+	spans := searchWords(text)
+	matches := make([]bool, len(spans))
+	first := -1
+	for i, span := range spans {
+		if term, ok := searchTerm(text[span.start:span.end]); ok && wanted[term] {
+			matches[i] = true
+			if first < 0 {
+				first = i
+			}
+		}
+	}
+
+	from, to := 0, len(spans)
+	if snippet {
+		if first > snippetLead {
+			from = first - snippetLead
+		}
+		if from+snippetWords < to {
+			to = from + snippetWords
+		}
+	}
+	if from >= to {
+		if snippet {
+			return ""
+		}
+		return text
+	}
+
+	var headline strings.Builder
+	last := spans[from].start
+	if !snippet {
+		last = 0
+	}
+	for i := from; i < to; i++ {
+		headline.WriteString(text[last:spans[i].start])
+		word := text[spans[i].start:spans[i].end]
+		if matches[i] {
+			word = highlightStart + word + highlightStop
+		}
+		headline.WriteString(word)
+		last = spans[i].end
+	}
+	if !snippet {
+		headline.WriteString(text[last:])
+	}
+
+	return headline.String()
+}
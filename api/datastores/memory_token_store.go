@@ -0,0 +1,218 @@
+package datastores
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mangoslicer/answer-patch/apierrors"
+)
+
+// MemoryTokenStore implements TokenStoreServices with the same semantics as JWTStore, including the expiry of stored tokens
+type MemoryTokenStore struct {
+	mu        sync.Mutex
+	tokens    map[string]memoryToken
+	sets      map[string]memoryTokenSet
+	buckets   map[string]memoryBucket
+	nextSweep time.Time // When buckets that have refilled are next dropped
+}
+
+type memoryToken struct {
+	val       string
+	expiresAt time.Time
+}
+
+type memoryTokenSet struct {
+	members   map[string]bool
+	expiresAt time.Time
+}
+
+type memoryBucket struct {
+	tokens    float64
+	updatedAt time.Time
+	fullAt    time.Time // When the bucket has refilled, after which it is the same as no bucket
+}
+
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{tokens: make(map[string]memoryToken), sets: make(map[string]memoryTokenSet), buckets: make(map[string]memoryBucket)}
+}
+
+func (store *MemoryTokenStore) StoreToken(userID, signedToken string, exp int) error {
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.tokens[userID] = memoryToken{val: signedToken, expiresAt: time.Now().Add(time.Duration(exp) * time.Second)}
+
+	return nil
+}
+
+func (store *MemoryTokenStore) StoreTokenOnce(key, val string, exp int) (bool, error) {
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if _, ok := store.find(key); ok {
+		return false, nil
+	}
+	store.tokens[key] = memoryToken{val: val, expiresAt: time.Now().Add(time.Duration(exp) * time.Second)}
+
+	return true, nil
+}
+
+func (store *MemoryTokenStore) IsTokenStored(userID string) (bool, error) {
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	_, ok := store.find(userID)
+
+	return ok, nil
+}
+
+func (store *MemoryTokenStore) FindToken(key string) (string, error) {
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	token, ok := store.find(key)
+	if !ok {
+		return "", ErrTokenNotFound
+	}
+
+	return token.val, nil
+}
+
+// find returns the token stored under key, unless it has expired. The caller must hold the lock
+func (store *MemoryTokenStore) find(key string) (memoryToken, bool) {
+
+	token, ok := store.tokens[key]
+	if ok && !time.Now().Before(token.expiresAt) {
+		delete(store.tokens, key) // Expired keys are evicted lazily, like redis does on access
+		return memoryToken{}, false
+	}
+
+	return token, ok
+}
+
+func (store *MemoryTokenStore) AddToTokenSet(key, member string, exp int) error {
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	set, ok := store.findSet(key)
+	if !ok {
+		set = memoryTokenSet{members: make(map[string]bool)}
+	}
+	set.members[member] = true
+	set.expiresAt = time.Now().Add(time.Duration(exp) * time.Second)
+	store.sets[key] = set
+
+	return nil
+}
+
+func (store *MemoryTokenStore) FindTokenSet(key string) ([]string, error) {
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	set, _ := store.findSet(key)
+
+	members := make([]string, 0, len(set.members))
+	for member := range set.members {
+		members = append(members, member)
+	}
+
+	return members, nil
+}
+
+func (store *MemoryTokenStore) RemoveFromTokenSet(key, member string) error {
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if set, ok := store.findSet(key); ok {
+		delete(set.members, member)
+		if len(set.members) == 0 {
+			delete(store.sets, key) // Redis deletes sets once their last member is removed
+		}
+	}
+
+	return nil
+}
+
+func (store *MemoryTokenStore) RemoveToken(key string) error {
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	delete(store.tokens, key)
+
+	return nil
+}
+
+func (store *MemoryTokenStore) IncrementToken(key string, exp int) (int, error) {
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	count := 1
+	if token, ok := store.find(key); ok {
+		n, err := strconv.Atoi(token.val)
+		if err != nil {
+			return 0, apierrors.Internal(err) // Redis refuses to increment values that are not integers as well
+		}
+		count = n + 1
+	}
+	store.tokens[key] = memoryToken{val: strconv.Itoa(count), expiresAt: time.Now().Add(time.Duration(exp) * time.Second)}
+
+	return count, nil
+}
+
+func (store *MemoryTokenStore) TakeFromBucket(key string, burst int, perSecond float64) (bool, float64, error) {
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	now := time.Now()
+
+	bucket, ok := store.buckets[key]
+	if !ok {
+		bucket = memoryBucket{tokens: float64(burst), updatedAt: now}
+	}
+	bucket.tokens = math.Min(float64(burst), bucket.tokens+now.Sub(bucket.updatedAt).Seconds()*perSecond)
+	bucket.updatedAt = now
+
+	taken := bucket.tokens >= 1
+	if taken {
+		bucket.tokens--
+	}
+
+	bucket.fullAt = now.Add(time.Duration((float64(burst) - bucket.tokens) / perSecond * float64(time.Second)))
+	store.buckets[key] = bucket
+
+	// Buckets that have refilled are dropped, like the expiring buckets of JWTStore, so that the buckets of past clients do not accumulate
+	if now.After(store.nextSweep) {
+		for key, bucket := range store.buckets {
+			if now.After(bucket.fullAt) {
+				delete(store.buckets, key)
+			}
+		}
+		store.nextSweep = now.Add(time.Minute)
+	}
+
+	return taken, bucket.tokens, nil
+}
+
+// findSet returns the set stored under key, unless it has expired. The caller must hold the lock
+func (store *MemoryTokenStore) findSet(key string) (memoryTokenSet, bool) {
+
+	set, ok := store.sets[key]
+	if ok && !time.Now().Before(set.expiresAt) {
+		delete(store.sets, key)
+		return memoryTokenSet{}, false
+	}
+
+	return set, ok
+}
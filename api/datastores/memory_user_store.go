@@ -0,0 +1,244 @@
+package datastores
+
+import (
+	"github.com/mangoslicer/answer-patch/apierrors"
+	"github.com/mangoslicer/answer-patch/models"
+)
+
+// MemoryUserStore implements UserStoreServices with the same semantics as UserStore
+type MemoryUserStore struct {
+	DB *MemoryDB
+}
+
+// FindUser looks up a user by the value of the "id" or "username" column
+func (store *MemoryUserStore) FindUser(filter, searchVal string) (*models.User, error) {
+
+	var match func(*userRow) bool
+
+	switch filter {
+	case "id":
+		id, err := parseMemoryID(searchVal)
+		if err != nil {
+			return nil, err
+		}
+		match = func(row *userRow) bool { return row.id == id }
+	case "username":
+		match = func(row *userRow) bool { return row.username == searchVal }
+	case "email":
+		match = func(row *userRow) bool { return row.email != "" && row.email == searchVal }
+	default:
+		return nil, apierrors.Internal(errMemoryUnsupported)
+	}
+
+	store.DB.mu.RLock()
+	defer store.DB.mu.RUnlock()
+
+	for _, row := range store.DB.users {
+		if match(row) {
+			return &models.User{ID: row.id, Username: row.username, HashedPassword: row.hashedPassword, Email: row.email, EmailVerified: row.emailVerified, CreatedAt: row.createdAt}, nil
+		}
+	}
+
+	return nil, ErrUserNotFound
+}
+
+func (store *MemoryUserStore) StoreUser(username, hashedpassword, email string) error {
+
+	store.DB.mu.Lock()
+	defer store.DB.mu.Unlock()
+
+	for _, row := range store.DB.users {
+		if row.username == username {
+			return errMemoryNotUnique("username")
+		} else if email != "" && row.email == email {
+			return errMemoryNotUnique("email")
+		}
+	}
+
+	id := newMemoryID()
+	store.DB.users[id] = &userRow{id: id, username: username, hashedPassword: hashedpassword, email: email, createdAt: store.DB.now()}
+
+	return nil
+}
+
+func (store *MemoryUserStore) VerifyEmail(userID, email string) error {
+
+	id, err := parseMemoryID(userID)
+	if err != nil {
+		return err
+	}
+
+	store.DB.mu.Lock()
+	defer store.DB.mu.Unlock()
+
+	row, ok := store.DB.users[id]
+	if !ok || row.email == "" || row.email != email {
+		return ErrUserNotFound
+	}
+	row.emailVerified = true
+
+	return nil
+}
+
+func (store *MemoryUserStore) UpdatePassword(userID, hashedPassword string) error {
+
+	id, err := parseMemoryID(userID)
+	if err != nil {
+		return err
+	}
+
+	store.DB.mu.Lock()
+	defer store.DB.mu.Unlock()
+
+	row, ok := store.DB.users[id]
+	if !ok {
+		return ErrUserNotFound
+	}
+	row.hashedPassword = hashedPassword
+
+	return nil
+}
+
+func (store *MemoryUserStore) UpdateUsername(userID, username string) error {
+
+	id, err := parseMemoryID(userID)
+	if err != nil {
+		return err
+	}
+
+	store.DB.mu.Lock()
+	defer store.DB.mu.Unlock()
+
+	row, ok := store.DB.users[id]
+	if !ok || id == DeletedUserID {
+		return ErrUserNotFound
+	}
+
+	for _, other := range store.DB.users {
+		if other.username == username && other != row {
+			return errMemoryNotUnique("username")
+		}
+	}
+	row.username = username
+
+	return nil
+}
+
+func (store *MemoryUserStore) DeleteUser(userID string) error {
+
+	id, err := parseMemoryID(userID)
+	if err != nil {
+		return err
+	}
+
+	store.DB.mu.Lock()
+	defer store.DB.mu.Unlock()
+
+	if _, ok := store.DB.users[id]; !ok || id == DeletedUserID {
+		return ErrUserNotFound
+	}
+
+	for key, row := range store.DB.questionVotes {
+		if key.userID == id {
+			delete(store.DB.questionVotes, key)
+			store.DB.questionVotes[voteKey{userID: DeletedUserID, postID: key.postID, formerUserID: id}] = row
+		}
+	}
+	for key, row := range store.DB.answerVotes {
+		if key.userID == id {
+			delete(store.DB.answerVotes, key)
+			store.DB.answerVotes[voteKey{userID: DeletedUserID, postID: key.postID, formerUserID: id}] = row
+		}
+	}
+
+	for _, row := range store.DB.categories {
+		if row.userID == id {
+			row.userID = DeletedUserID
+		}
+	}
+	for _, row := range store.DB.questions {
+		if row.userID == id {
+			row.userID = DeletedUserID
+		}
+	}
+	for _, row := range store.DB.answers {
+		if row.userID == id {
+			row.userID = DeletedUserID
+		}
+	}
+	for _, revisions := range store.DB.revisions {
+		for _, row := range revisions {
+			if row.userID == id {
+				row.userID = DeletedUserID
+			}
+		}
+	}
+
+	for key, userID := range store.DB.identities {
+		if userID == id {
+			delete(store.DB.identities, key)
+		}
+	}
+	for key := range store.DB.roles {
+		if key.userID == id {
+			delete(store.DB.roles, key)
+		}
+	}
+	delete(store.DB.bans, id)
+	for _, row := range store.DB.bans {
+		if row.bannedBy == id {
+			row.bannedBy = ""
+		}
+	}
+
+	for key := range store.DB.flags {
+		if key.userID == id {
+			delete(store.DB.flags, key)
+		}
+	}
+	for _, action := range store.DB.actions {
+		if action.moderatorID == id {
+			action.moderatorID = ""
+		}
+	}
+
+	delete(store.DB.users, id)
+
+	return nil
+}
+
+func (store *MemoryUserStore) FindUserByIdentity(provider, subject string) (*models.User, error) {
+
+	store.DB.mu.RLock()
+	defer store.DB.mu.RUnlock()
+
+	row, ok := store.DB.users[store.DB.identities[identityKey{provider, subject}]]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+
+	return &models.User{ID: row.id, Username: row.username, HashedPassword: row.hashedPassword, Email: row.email, EmailVerified: row.emailVerified, CreatedAt: row.createdAt}, nil
+}
+
+func (store *MemoryUserStore) LinkIdentity(userID, provider, subject string) error {
+
+	id, err := parseMemoryID(userID)
+	if err != nil {
+		return err
+	}
+
+	store.DB.mu.Lock()
+	defer store.DB.mu.Unlock()
+
+	if _, ok := store.DB.users[id]; !ok {
+		return errMemoryReference("user_id")
+	}
+
+	key := identityKey{provider, subject}
+	if linkedID, ok := store.DB.identities[key]; ok && linkedID != id {
+		return ErrIdentityLinked
+	}
+	store.DB.identities[key] = id
+
+	return nil
+}
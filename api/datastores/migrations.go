@@ -0,0 +1,350 @@
+package datastores
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Migration is a numbered change to the postgres schema, along with the statements that revert it
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationState reports whether a migration has been applied to the database
+type MigrationState struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Key of the postgres advisory lock held while migrations run, so that API instances starting at the same time do not race
+const migrationLockID = 3030
+
+// Migrations must be appended with increasing version numbers. Never edit a migration that has already been released, add a new one instead
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "initial_schema",
+		// IF NOT EXISTS allows databases created before migrations were introduced to be brought under version control
+		Up: `CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
+CREATE TABLE IF NOT EXISTS ap_user(id uuid PRIMARY KEY DEFAULT uuid_generate_v4(), username varchar(20) NOT NULL UNIQUE, hashed_password char(60) NOT NULL UNIQUE, created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'UTC'));
+CREATE TABLE IF NOT EXISTS category (id uuid PRIMARY KEY DEFAULT uuid_generate_v4(), category_name varchar(15) NOT NULL, user_id uuid REFERENCES ap_user NOT NULL, created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'UTC'));
+CREATE TABLE IF NOT EXISTS question (id uuid PRIMARY KEY DEFAULT uuid_generate_v4(), user_id uuid REFERENCES ap_user NOT NULL, category_id uuid REFERENCES category NOT NULL, title varchar(255) NOT NULL UNIQUE, content text NOT NULL, upvotes integer DEFAULT 0, edit_count integer DEFAULT 0, pending_count integer DEFAULT 0, submitted_at TIMESTAMP WITHOUT TIME ZONE DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'UTC'));
+CREATE TABLE IF NOT EXISTS answer (id uuid PRIMARY KEY DEFAULT uuid_generate_v4(), question_id uuid REFERENCES question ON DELETE CASCADE NOT NULL, user_id uuid REFERENCES ap_user NOT NULL, content text, upvotes integer DEFAULT 0, required_upvotes integer DEFAULT 0, is_current_answer boolean DEFAULT false, last_edited_at TIMESTAMP WITHOUT TIME ZONE DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'UTC'));`,
+		Down: `DROP TABLE IF EXISTS answer;
+DROP TABLE IF EXISTS question;
+DROP TABLE IF EXISTS category;
+DROP TABLE IF EXISTS ap_user;`,
+	},
+	{
+		Version: 2,
+		Name:    "create_answer_revision",
+		// answer_id is nulled rather than cascaded because AssessAnswers deletes answers that fall to zero upvotes, while their revisions should outlive them
+		Up:   `CREATE TABLE answer_revision (id uuid PRIMARY KEY DEFAULT uuid_generate_v4(), question_id uuid REFERENCES question ON DELETE CASCADE NOT NULL, revision integer NOT NULL, answer_id uuid REFERENCES answer ON DELETE SET NULL, user_id uuid REFERENCES ap_user NOT NULL, content text, upvotes integer DEFAULT 0, became_current_at TIMESTAMP WITHOUT TIME ZONE DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'UTC'), UNIQUE (question_id, revision));`,
+		Down: `DROP TABLE IF EXISTS answer_revision;`,
+	},
+	{
+		Version: 3,
+		Name:    "add_category_description",
+		// Category names are matched case-insensitively against the {category} route variables, so they must also be unique case-insensitively
+		Up: `ALTER TABLE category ADD COLUMN description text NOT NULL DEFAULT '';
+CREATE UNIQUE INDEX category_name_key ON category (lower(category_name));`,
+		Down: `DROP INDEX IF EXISTS category_name_key;
+ALTER TABLE category DROP COLUMN IF EXISTS description;`,
+	},
+	{
+		Version: 4,
+		Name:    "create_question_vote",
+		Up:      `CREATE TABLE question_vote (user_id uuid REFERENCES ap_user NOT NULL, question_id uuid REFERENCES question ON DELETE CASCADE NOT NULL, vote smallint NOT NULL CHECK (vote IN (-1, 1)), cast_at TIMESTAMP WITHOUT TIME ZONE DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'UTC'), PRIMARY KEY (user_id, question_id));`,
+		Down:    `DROP TABLE IF EXISTS question_vote;`,
+	},
+	{
+		Version: 5,
+		Name:    "create_answer_vote",
+		Up:      `CREATE TABLE answer_vote (user_id uuid REFERENCES ap_user NOT NULL, answer_id uuid REFERENCES answer ON DELETE CASCADE NOT NULL, vote smallint NOT NULL CHECK (vote IN (-1, 1)), cast_at TIMESTAMP WITHOUT TIME ZONE DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'UTC'), CONSTRAINT answer_vote_user_answer_key UNIQUE (user_id, answer_id));`,
+		Down:    `DROP TABLE IF EXISTS answer_vote;`,
+	},
+	{
+		Version: 6,
+		Name:    "widen_hashed_password",
+		// char(60) only fits bcrypt hashes. Reverting fails while any longer hash, such as an argon2id hash, is stored
+		Up:   `ALTER TABLE ap_user ALTER COLUMN hashed_password TYPE varchar(255);`,
+		Down: `ALTER TABLE ap_user ALTER COLUMN hashed_password TYPE char(60);`,
+	},
+	{
+		Version: 7,
+		Name:    "create_deleted_user",
+		// The content of deleted accounts is reassigned to this user. Its hashed password is no valid hash, so it can never log in
+		Up:   `INSERT INTO ap_user(id, username, hashed_password) VALUES ('00000000-0000-0000-0000-000000000000', '[deleted]', '!') ON CONFLICT DO NOTHING;`,
+		Down: `DELETE FROM ap_user WHERE id = '00000000-0000-0000-0000-000000000000';`,
+	},
+	{
+		Version: 8,
+		Name:    "add_user_email",
+		// email is nullable, since users that registered before it was added have none. Emails are stored in lower case, so the unique constraint is case-insensitive
+		Up: `ALTER TABLE ap_user ADD COLUMN email varchar(254) UNIQUE;
+ALTER TABLE ap_user ADD COLUMN email_verified boolean NOT NULL DEFAULT false;`,
+		Down: `ALTER TABLE ap_user DROP COLUMN IF EXISTS email_verified;
+ALTER TABLE ap_user DROP COLUMN IF EXISTS email;`,
+	},
+	{
+		Version: 9,
+		Name:    "create_user_identity",
+		// Links the subjects of OpenID Connect providers to users. A subject is only unique within its provider
+		Up: `CREATE TABLE user_identity (provider varchar(64) NOT NULL, subject varchar(255) NOT NULL, user_id uuid REFERENCES ap_user ON DELETE CASCADE NOT NULL, linked_at TIMESTAMP WITHOUT TIME ZONE DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'UTC'), PRIMARY KEY (provider, subject));
+CREATE INDEX user_identity_user_id_idx ON user_identity (user_id);`,
+		Down: `DROP TABLE IF EXISTS user_identity;`,
+	},
+	{
+		Version: 10,
+		Name:    "create_user_role_and_ban",
+		// A user has at most one role everywhere, whose category_id is null, and one role in each category. The unique index maps null to the nil uuid,
+		// since unique constraints never consider nulls equal. The user role is never stored, so the check only allows the roles above it
+		Up: `CREATE TABLE user_role (user_id uuid REFERENCES ap_user ON DELETE CASCADE NOT NULL, category_id uuid REFERENCES category ON DELETE CASCADE, role varchar(16) NOT NULL CHECK (role IN ('moderator', 'admin')), granted_at TIMESTAMP WITHOUT TIME ZONE DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'UTC'));
+CREATE UNIQUE INDEX user_role_scope_key ON user_role (user_id, COALESCE(category_id, '00000000-0000-0000-0000-000000000000'::uuid));
+CREATE TABLE user_ban (user_id uuid PRIMARY KEY REFERENCES ap_user ON DELETE CASCADE, banned_by uuid REFERENCES ap_user ON DELETE SET NULL, reason text NOT NULL, banned_at TIMESTAMP WITHOUT TIME ZONE DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'UTC'));`,
+		Down: `DROP TABLE IF EXISTS user_ban;
+DROP TABLE IF EXISTS user_role;`,
+	},
+	{
+		Version: 11,
+		Name:    "create_post_flag_and_moderation_action",
+		// Removed posts keep their rows, so that removals can be audited. A user flags a post at most once, where the answer_id of flagged questions is null.
+		// moderation_action keeps its entries once the answer they refer to is deleted by AssessAnswers, which is why it records the post_type as well
+		Up: `ALTER TABLE question ADD COLUMN removed_at TIMESTAMP WITHOUT TIME ZONE;
+ALTER TABLE answer ADD COLUMN removed_at TIMESTAMP WITHOUT TIME ZONE;
+CREATE TABLE post_flag (id uuid PRIMARY KEY DEFAULT uuid_generate_v4(), question_id uuid REFERENCES question ON DELETE CASCADE NOT NULL, answer_id uuid REFERENCES answer ON DELETE CASCADE, user_id uuid REFERENCES ap_user ON DELETE CASCADE NOT NULL, reason varchar(16) NOT NULL CHECK (reason IN ('spam', 'abusive', 'off_topic', 'other')), detail text NOT NULL DEFAULT '', flagged_at TIMESTAMP WITHOUT TIME ZONE DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'UTC'), resolved_at TIMESTAMP WITHOUT TIME ZONE);
+CREATE UNIQUE INDEX post_flag_user_key ON post_flag (user_id, question_id, COALESCE(answer_id, '00000000-0000-0000-0000-000000000000'::uuid));
+CREATE INDEX post_flag_pending_idx ON post_flag (question_id, answer_id) WHERE resolved_at IS NULL;
+CREATE TABLE moderation_action (id uuid PRIMARY KEY DEFAULT uuid_generate_v4(), moderator_id uuid REFERENCES ap_user ON DELETE SET NULL, action varchar(16) NOT NULL CHECK (action IN ('remove', 'dismiss_flags')), post_type varchar(8) NOT NULL CHECK (post_type IN ('question', 'answer')), question_id uuid REFERENCES question ON DELETE CASCADE NOT NULL, answer_id uuid REFERENCES answer ON DELETE SET NULL, reason text NOT NULL DEFAULT '', taken_at TIMESTAMP WITHOUT TIME ZONE DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'UTC'));`,
+		Down: `DROP TABLE IF EXISTS moderation_action;
+DROP TABLE IF EXISTS post_flag;
+ALTER TABLE answer DROP COLUMN IF EXISTS removed_at;
+ALTER TABLE question DROP COLUMN IF EXISTS removed_at;`,
+	},
+	{
+		Version: 12,
+		Name:    "add_question_search_vector",
+		// search_vector weighs the words of the title over those of the question, and those of the question over those of its current answer.
+		// The triggers rebuild it whenever the title, the question or the current answer changes, including when an answer is promoted or removed
+		Up: `ALTER TABLE question ADD COLUMN search_vector tsvector;
+CREATE FUNCTION question_search_vector(uuid, text, text) RETURNS tsvector AS $$
+	SELECT setweight(to_tsvector('english', $2), 'A') || setweight(to_tsvector('english', $3), 'B') ||
+	setweight(to_tsvector('english', COALESCE((SELECT a.content FROM answer a WHERE a.question_id = $1 AND a.is_current_answer = 'true' AND a.removed_at IS NULL LIMIT 1), '')), 'C')
+$$ LANGUAGE SQL STABLE;
+CREATE FUNCTION question_search_vector_trigger() RETURNS trigger AS $$
+BEGIN
+	NEW.search_vector := question_search_vector(NEW.id, NEW.title, NEW.content);
+	RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+CREATE TRIGGER question_search_vector_update BEFORE INSERT OR UPDATE OF title, content ON question FOR EACH ROW EXECUTE PROCEDURE question_search_vector_trigger();
+CREATE FUNCTION answer_search_vector_trigger() RETURNS trigger AS $$
+DECLARE
+	changed_question uuid;
+BEGIN
+	IF TG_OP = 'DELETE' THEN
+		changed_question := OLD.question_id;
+	ELSE
+		changed_question := NEW.question_id;
+	END IF;
+	UPDATE question SET search_vector = question_search_vector(id, title, content) WHERE id = changed_question;
+	RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+CREATE TRIGGER answer_search_vector_update AFTER INSERT OR DELETE OR UPDATE OF content, is_current_answer, removed_at ON answer FOR EACH ROW EXECUTE PROCEDURE answer_search_vector_trigger();
+UPDATE question SET search_vector = question_search_vector(id, title, content);
+CREATE INDEX question_search_vector_idx ON question USING GIN (search_vector);`,
+		Down: `DROP TRIGGER IF EXISTS answer_search_vector_update ON answer;
+DROP TRIGGER IF EXISTS question_search_vector_update ON question;
+DROP FUNCTION IF EXISTS answer_search_vector_trigger();
+DROP FUNCTION IF EXISTS question_search_vector_trigger();
+DROP FUNCTION IF EXISTS question_search_vector(uuid, text, text);
+DROP INDEX IF EXISTS question_search_vector_idx;
+ALTER TABLE question DROP COLUMN IF EXISTS search_vector;`,
+	},
+	{
+		Version: 13,
+		Name:    "add_question_duplicate_of",
+		// The trigram index serves the % operator, with which FindSimilarQuestions narrows down the candidate duplicates of submitted questions
+		Up: `CREATE EXTENSION IF NOT EXISTS pg_trgm;
+ALTER TABLE question ADD COLUMN duplicate_of uuid REFERENCES question ON DELETE SET NULL;
+CREATE INDEX question_title_trgm_idx ON question USING GIN (title gin_trgm_ops);`,
+		Down: `DROP INDEX IF EXISTS question_title_trgm_idx;
+ALTER TABLE question DROP COLUMN IF EXISTS duplicate_of;`,
+	},
+	{
+		Version: 14,
+		Name:    "add_keyset_indexes",
+		// Lists are paged by cursors, which select the rows past the sort keys and id of the row at the edge of the previous page.
+		// These indexes match the keys of the sorted questions and of the moderation log, so that every page is read straight from an index
+		Up: `CREATE INDEX question_upvotes_keyset_idx ON question (upvotes, id) WHERE removed_at IS NULL;
+CREATE INDEX question_submitted_at_keyset_idx ON question (submitted_at, id) WHERE removed_at IS NULL;
+CREATE INDEX question_edit_count_keyset_idx ON question (edit_count, id) WHERE removed_at IS NULL;
+CREATE INDEX moderation_action_keyset_idx ON moderation_action (taken_at, id);`,
+		Down: `DROP INDEX IF EXISTS moderation_action_keyset_idx;
+DROP INDEX IF EXISTS question_edit_count_keyset_idx;
+DROP INDEX IF EXISTS question_submitted_at_keyset_idx;
+DROP INDEX IF EXISTS question_upvotes_keyset_idx;`,
+	},
+	{
+		Version: 15,
+		Name:    "allow_votes_of_deleted_users",
+		// The votes of deleted users are reassigned to the placeholder user, which therefore holds a vote per deleted user on a post rather than one.
+		// Reverting fails while the placeholder holds more than one vote on any post
+		Up: `ALTER TABLE question_vote DROP CONSTRAINT question_vote_pkey;
+CREATE UNIQUE INDEX question_vote_user_question_key ON question_vote (user_id, question_id) WHERE user_id <> '00000000-0000-0000-0000-000000000000';
+ALTER TABLE answer_vote DROP CONSTRAINT answer_vote_user_answer_key;
+CREATE UNIQUE INDEX answer_vote_user_answer_key ON answer_vote (user_id, answer_id) WHERE user_id <> '00000000-0000-0000-0000-000000000000';`,
+		Down: `DROP INDEX IF EXISTS answer_vote_user_answer_key;
+ALTER TABLE answer_vote ADD CONSTRAINT answer_vote_user_answer_key UNIQUE (user_id, answer_id);
+DROP INDEX IF EXISTS question_vote_user_question_key;
+ALTER TABLE question_vote ADD PRIMARY KEY (user_id, question_id);`,
+	},
+}
+
+// MigrateUp applies every migration that has not yet been recorded in the schema_migrations table
+func MigrateUp(db *sql.DB) error {
+
+	return migrate(db, func(tx *sql.Tx, applied map[int]bool) error {
+
+		for _, mig := range migrations {
+			if applied[mig.Version] {
+				continue
+			}
+
+			if _, err := tx.Exec(mig.Up); err != nil {
+				return fmt.Errorf("Failed to apply migration %s: %v", mig.String(), err)
+			}
+
+			if _, err := tx.Exec(`INSERT INTO schema_migrations(version, name) VALUES($1, $2)`, mig.Version, mig.Name); err != nil {
+				return fmt.Errorf("Failed to record migration %s: %v", mig.String(), err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// MigrateDown reverts the most recently applied migrations, one for each step
+func MigrateDown(db *sql.DB, steps int) error {
+
+	return migrate(db, func(tx *sql.Tx, applied map[int]bool) error {
+
+		for i := len(migrations) - 1; i >= 0 && steps > 0; i-- {
+			mig := migrations[i]
+			if !applied[mig.Version] {
+				continue
+			}
+
+			if _, err := tx.Exec(mig.Down); err != nil {
+				return fmt.Errorf("Failed to revert migration %s: %v", mig.String(), err)
+			}
+
+			if _, err := tx.Exec(`DELETE FROM schema_migrations WHERE version = $1`, mig.Version); err != nil {
+				return fmt.Errorf("Failed to unrecord migration %s: %v", mig.String(), err)
+			}
+			steps--
+		}
+
+		return nil
+	})
+}
+
+// MigrationStatus lists every known migration along with the time it was applied, if it has been
+func MigrationStatus(db *sql.DB) ([]*MigrationState, error) {
+
+	if _, err := db.Exec(createSchemaMigrations); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	appliedAt := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err = rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		appliedAt[version] = at
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var states []*MigrationState
+	for _, mig := range migrations {
+		at, ok := appliedAt[mig.Version]
+		states = append(states, &MigrationState{Migration: mig, Applied: ok, AppliedAt: at})
+	}
+
+	return states, nil
+}
+
+func (mig Migration) String() string {
+	return fmt.Sprintf("%04d_%s", mig.Version, mig.Name)
+}
+
+const createSchemaMigrations = `CREATE TABLE IF NOT EXISTS schema_migrations (version integer PRIMARY KEY, name varchar(255) NOT NULL, applied_at TIMESTAMP WITHOUT TIME ZONE DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'UTC'))`
+
+// migrate runs fn in a single transaction that holds the migration advisory lock, so that either every step of fn is applied or none of them are
+func migrate(db *sql.DB, fn func(*sql.Tx, map[int]bool) error) error {
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	// The lock is released automatically when the transaction commits or rolls back
+	if _, err = tx.Exec(`SELECT pg_advisory_xact_lock($1)`, migrationLockID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if _, err = tx.Exec(createSchemaMigrations); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	applied, err := appliedVersions(tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = fn(tx, applied); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func appliedVersions(tx *sql.Tx) (map[int]bool, error) {
+
+	rows, err := tx.Query(`SELECT version FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]bool)
+	for rows.Next() {
+		var version int
+		if err = rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+
+	return applied, rows.Err()
+}
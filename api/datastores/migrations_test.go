@@ -0,0 +1,40 @@
+package datastores
+
+import (
+	"testing"
+)
+
+func TestMigrationVersionsIncrease(t *testing.T) {
+
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].Version <= migrations[i-1].Version {
+			t.Errorf("Expected migration %s to have a greater version than migration %s", migrations[i].String(), migrations[i-1].String())
+		}
+	}
+}
+
+func TestMigrationStatus(t *testing.T) {
+
+	// The test database is migrated up by the init function of answer_store_test.go
+	states, err := MigrationStatus(GlobalAnswerStore.DB)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(states) != len(migrations) {
+		t.Errorf("Expected the status of %d migrations, but recieved the status of %d migrations", len(migrations), len(states))
+	}
+
+	for _, state := range states {
+		if !state.Applied {
+			t.Errorf("Expected migration %s to be applied", state.String())
+		}
+	}
+}
+
+func TestMigrateUpIsIdempotent(t *testing.T) {
+
+	if err := MigrateUp(GlobalAnswerStore.DB); err != nil {
+		t.Errorf("Expected MigrateUp to do nothing when every migration is already applied, but recieved %s", err.Error())
+	}
+}
@@ -0,0 +1,294 @@
+package datastores
+
+import (
+	"database/sql"
+
+	"github.com/mangoslicer/answer-patch/apierrors"
+	"github.com/mangoslicer/answer-patch/models"
+)
+
+type ModerationStoreServices interface {
+	FlagPost(models.PostRef, string, models.FlagReason, string) error
+	FindFlaggedPosts(string, models.PageRequest) (*models.FlaggedPostPage, error)
+	DismissFlags(models.PostRef, string) error
+	RemovePost(models.PostRef, string, string) (string, bool, error)
+	FindModerationActions(models.PageRequest) (*models.ModerationActionPage, error)
+}
+
+// Stands in for the answer id of flagged questions in the sort keys of the review queue, where it sorts first like NULLS FIRST would
+const nilID = "00000000-0000-0000-0000-000000000000"
+
+var (
+	errAlreadyFlagged = apierrors.New(apierrors.KindConflict, apierrors.CodeAlreadyFlagged, "The post has already been flagged by the user")
+	errNoPendingFlags = apierrors.New(apierrors.KindNotFound, apierrors.CodeFlagNotFound, "The post has no flags that await review")
+)
+
+type ModerationStore struct {
+	DB *sql.DB
+}
+
+// FlagPost reports a post to the moderators. Every user flags a post at most once
+func (store *ModerationStore) FlagPost(post models.PostRef, userID string, reason models.FlagReason, detail string) error {
+
+	return transact(store.DB, func(tx *sql.Tx) error {
+
+		questionID, answerID, _, err := findModeratedPost(tx, post)
+		if err != nil {
+			return err
+		}
+
+		result, err := tx.Exec(`INSERT INTO post_flag(question_id, answer_id, user_id, reason, detail) VALUES($1::uuid, $2::uuid, $3::uuid, $4, $5)
+ON CONFLICT (user_id, question_id, COALESCE(answer_id, '00000000-0000-0000-0000-000000000000'::uuid)) DO NOTHING`, questionID, answerID, userID, string(reason), detail)
+		if err != nil {
+			return evaluateSQLError(err)
+		}
+
+		if flagged, err := result.RowsAffected(); err != nil {
+			return evaluateSQLError(err)
+		} else if flagged == 0 {
+			return errAlreadyFlagged
+		}
+
+		return nil
+	})
+}
+
+// FindFlaggedPosts lists a page of the posts with flags that await review, within category if it is not empty.
+// The posts with the most flags are listed first, and ties are listed in the order they were first flagged in
+func (store *ModerationStore) FindFlaggedPosts(category string, page models.PageRequest) (*models.FlaggedPostPage, error) {
+
+	c, err := decodeCursor("flags", page.Cursor, intKey, timeKey, idKey, idKey)
+	if err != nil {
+		return nil, err
+	}
+	keyset, orderBy, keyParams := keysetSQL(c, []string{"-p.flag_count", "p.first_flagged_at", "p.question_id", "COALESCE(p.answer_id, '" + nilID + "'::uuid)"}, false, 3)
+	if keyset != "" {
+		keyset = ` AND ` + keyset
+	}
+
+	rows, err := store.DB.Query(`WITH pending AS (SELECT question_id, answer_id, COUNT(*) AS flag_count, MIN(flagged_at) AS first_flagged_at FROM post_flag WHERE resolved_at IS NULL GROUP BY question_id, answer_id)
+SELECT p.question_id, COALESCE(p.answer_id::text, ''), c.category_name, q.title, COALESCE(a.content, q.content), COALESCE(a.user_id, q.user_id), u.username, p.flag_count, p.first_flagged_at
+FROM pending p INNER JOIN question q ON q.id = p.question_id INNER JOIN category c ON c.id = q.category_id LEFT OUTER JOIN answer a ON a.id = p.answer_id INNER JOIN ap_user u ON u.id = COALESCE(a.user_id, q.user_id)
+WHERE q.removed_at IS NULL AND a.removed_at IS NULL AND ($1 = '' OR lower(c.category_name) = lower($1))`+keyset+orderBy+` LIMIT $2`, append([]interface{}{category, page.Size + 1}, keyParams...)...)
+	if err != nil {
+		return nil, evaluateSQLError(err)
+	}
+	defer rows.Close()
+
+	posts := make([]*models.FlaggedPost, 0)
+	for rows.Next() {
+		post := &models.FlaggedPost{Type: models.PostQuestion, Reasons: make(map[models.FlagReason]int)}
+		err = rows.Scan(&post.QuestionID, &post.AnswerID, &post.Category, &post.Title, &post.Content, &post.UserID, &post.Username, &post.FlagCount, &post.FirstFlaggedAt)
+		if err != nil {
+			return nil, evaluateSQLError(err)
+		}
+		if post.AnswerID != "" {
+			post.Type = models.PostAnswer
+		}
+		posts = append(posts, post)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, evaluateSQLError(err)
+	}
+
+	n, more := trimPage(posts, c, page.Size)
+	posts = posts[:n]
+
+	// A page holds few enough posts that their reasons are counted one post at a time
+	for _, post := range posts {
+		if err = store.countFlagReasons(post); err != nil {
+			return nil, err
+		}
+	}
+
+	return &models.FlaggedPostPage{Items: posts, Cursors: pageCursors("flags", c, n, more, func(i int) []interface{} { return flaggedPostKeys(posts[i]) })}, nil
+}
+
+// flaggedPostKeys returns the sort keys of a post in the review queue, which is sorted by them in ascending order
+func flaggedPostKeys(post *models.FlaggedPost) []interface{} {
+
+	answerID := post.AnswerID
+	if answerID == "" {
+		answerID = nilID
+	}
+
+	return []interface{}{-post.FlagCount, post.FirstFlaggedAt, post.QuestionID, answerID}
+}
+
+func (store *ModerationStore) countFlagReasons(post *models.FlaggedPost) error {
+
+	rows, err := store.DB.Query(`SELECT reason, COUNT(*) FROM post_flag WHERE question_id = $1 AND answer_id IS NOT DISTINCT FROM NULLIF($2, '')::uuid AND resolved_at IS NULL GROUP BY reason`, post.QuestionID, post.AnswerID)
+	if err != nil {
+		return evaluateSQLError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var reason models.FlagReason
+		var count int
+		if err = rows.Scan(&reason, &count); err != nil {
+			return evaluateSQLError(err)
+		}
+		post.Reasons[reason] = count
+	}
+	if err = rows.Err(); err != nil {
+		return evaluateSQLError(err)
+	}
+
+	return nil
+}
+
+// DismissFlags resolves the flags of a post that await review without removing it, and records the dismissal in the moderation log
+func (store *ModerationStore) DismissFlags(post models.PostRef, moderatorID string) error {
+
+	return transact(store.DB, func(tx *sql.Tx) error {
+
+		questionID, answerID, _, err := findModeratedPost(tx, post)
+		if err != nil {
+			return err
+		}
+
+		dismissed, err := resolveFlags(tx, questionID, answerID)
+		if err != nil {
+			return err
+		} else if dismissed == 0 {
+			return errNoPendingFlags
+		}
+
+		return logModerationAction(tx, moderatorID, models.ActionDismissFlags, post.Type, questionID, answerID, "")
+	})
+}
+
+// RemovePost hides a post, resolves its flags and records the removal in the moderation log. It returns the id of the question of the post,
+// and whether the post was the current answer of that question, in which case the caller must reassess the answers of the question
+func (store *ModerationStore) RemovePost(post models.PostRef, moderatorID, reason string) (string, bool, error) {
+
+	var questionID string
+	var wasCurrent bool
+
+	err := transact(store.DB, func(tx *sql.Tx) error {
+
+		var answerID sql.NullString
+		var err error
+
+		questionID, answerID, wasCurrent, err = findModeratedPost(tx, post)
+		if err != nil {
+			return err
+		}
+
+		if post.Type == models.PostQuestion {
+			_, err = tx.Exec(`UPDATE question SET removed_at = (CURRENT_TIMESTAMP AT TIME ZONE 'UTC') WHERE id = $1`, questionID)
+		} else {
+			_, err = tx.Exec(`UPDATE answer SET removed_at = (CURRENT_TIMESTAMP AT TIME ZONE 'UTC'), is_current_answer = 'false' WHERE id = $1`, answerID)
+		}
+		if err != nil {
+			return evaluateSQLError(err)
+		}
+
+		if _, err = resolveFlags(tx, questionID, answerID); err != nil {
+			return err
+		}
+
+		return logModerationAction(tx, moderatorID, models.ActionRemove, post.Type, questionID, answerID, reason)
+	})
+	if err != nil {
+		return "", false, err
+	}
+
+	return questionID, wasCurrent, nil
+}
+
+// FindModerationActions lists a page of the moderation log, newest first
+func (store *ModerationStore) FindModerationActions(page models.PageRequest) (*models.ModerationActionPage, error) {
+
+	c, err := decodeCursor("moderation", page.Cursor, timeKey, idKey)
+	if err != nil {
+		return nil, err
+	}
+	keyset, orderBy, keyParams := keysetSQL(c, []string{"ma.taken_at", "ma.id"}, true, 2)
+	if keyset != "" {
+		keyset = ` WHERE ` + keyset
+	}
+
+	rows, err := store.DB.Query(`SELECT ma.id, COALESCE(ma.moderator_id::text, ''), COALESCE(u.username, ''), ma.action, ma.post_type, ma.question_id, COALESCE(ma.answer_id::text, ''), ma.reason, ma.taken_at
+FROM moderation_action ma LEFT OUTER JOIN ap_user u ON u.id = ma.moderator_id`+keyset+orderBy+` LIMIT $1`, append([]interface{}{page.Size + 1}, keyParams...)...)
+	if err != nil {
+		return nil, evaluateSQLError(err)
+	}
+	defer rows.Close()
+
+	actions := make([]*models.ModerationAction, 0)
+	for rows.Next() {
+		action := new(models.ModerationAction)
+		err = rows.Scan(&action.ID, &action.ModeratorID, &action.ModeratorUsername, &action.Action, &action.PostType, &action.QuestionID, &action.AnswerID, &action.Reason, &action.TakenAt)
+		if err != nil {
+			return nil, evaluateSQLError(err)
+		}
+		actions = append(actions, action)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, evaluateSQLError(err)
+	}
+
+	n, more := trimPage(actions, c, page.Size)
+	actions = actions[:n]
+
+	return &models.ModerationActionPage{Items: actions, Cursors: pageCursors("moderation", c, n, more, func(i int) []interface{} {
+		return []interface{}{actions[i].TakenAt, actions[i].ID}
+	})}, nil
+}
+
+// findModeratedPost locks a post that has not been removed within the category of post, and returns the id of its question,
+// the id of the answer if the post is one, and whether the answer is the current answer
+func findModeratedPost(tx *sql.Tx, post models.PostRef) (string, sql.NullString, bool, error) {
+
+	var questionID string
+	var answerID sql.NullString
+	var isCurrent bool
+
+	if post.Type == models.PostQuestion {
+		err := tx.QueryRow(`SELECT q.id FROM question q INNER JOIN category c ON c.id = q.category_id WHERE q.id = $1::uuid AND lower(c.category_name) = lower($2) AND q.removed_at IS NULL FOR UPDATE OF q`, post.ID, post.Category).Scan(&questionID)
+		if err == sql.ErrNoRows {
+			return "", answerID, false, errQuestionNotFound
+		} else if err != nil {
+			return "", answerID, false, evaluateSQLError(err)
+		}
+		return questionID, answerID, false, nil
+	}
+
+	err := tx.QueryRow(`SELECT a.question_id, a.id, a.is_current_answer FROM answer a INNER JOIN question q ON q.id = a.question_id INNER JOIN category c ON c.id = q.category_id
+WHERE a.id = $1::uuid AND lower(c.category_name) = lower($2) AND a.removed_at IS NULL AND q.removed_at IS NULL FOR UPDATE OF a`, post.ID, post.Category).Scan(&questionID, &answerID, &isCurrent)
+	if err == sql.ErrNoRows {
+		return "", answerID, false, errAnswerNotFound
+	} else if err != nil {
+		return "", answerID, false, evaluateSQLError(err)
+	}
+
+	return questionID, answerID, isCurrent, nil
+}
+
+// resolveFlags resolves the flags of a post that await review, and returns the amount of flags that were resolved
+func resolveFlags(tx *sql.Tx, questionID string, answerID sql.NullString) (int64, error) {
+
+	result, err := tx.Exec(`UPDATE post_flag SET resolved_at = (CURRENT_TIMESTAMP AT TIME ZONE 'UTC') WHERE question_id = $1 AND answer_id IS NOT DISTINCT FROM $2::uuid AND resolved_at IS NULL`, questionID, answerID)
+	if err != nil {
+		return 0, evaluateSQLError(err)
+	}
+
+	resolved, err := result.RowsAffected()
+	if err != nil {
+		return 0, evaluateSQLError(err)
+	}
+
+	return resolved, nil
+}
+
+func logModerationAction(tx *sql.Tx, moderatorID string, action models.ModerationActionType, postType models.PostType, questionID string, answerID sql.NullString, reason string) error {
+
+	_, err := tx.Exec(`INSERT INTO moderation_action(moderator_id, action, post_type, question_id, answer_id, reason) VALUES($1::uuid, $2, $3, $4::uuid, $5::uuid, $6)`, moderatorID, string(action), string(postType), questionID, answerID, reason)
+	if err != nil {
+		return evaluateSQLError(err)
+	}
+
+	return nil
+}
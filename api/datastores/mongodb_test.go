@@ -16,7 +16,10 @@ func init() {
 
 func TestConnectToMongoCol(t *testing.T) {
 
-	col := ConnectToMongoCol()
+	col, err := ConnectToMongoCol()
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	if col == nil {
 		t.Errorf("Expected a *mgo.Collection, but recieved nil")
@@ -24,6 +27,14 @@ func TestConnectToMongoCol(t *testing.T) {
 
 }
 
+func mustConnectToMongoCol() *mgo.Collection {
+	col, err := ConnectToMongoCol()
+	if err != nil {
+		log.Fatal(err)
+	}
+	return col
+}
+
 func populateMongoCol(col *mgo.Collection) {
 
 	_, err := col.RemoveAll(bson.M{})
@@ -2,13 +2,51 @@ package datastores
 
 import (
 	"database/sql"
+	"errors"
+	"log"
+	"net"
 	"testing"
+
+	"github.com/mangoslicer/answer-patch/apierrors"
 )
 
 var GlobalDB *sql.DB
 
 func TestConnectToPostgres(t *testing.T) {
-	if err := ConnectToPostgres().Ping(); err != nil {
+	db, err := ConnectToPostgres()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.Ping(); err != nil {
 		t.Error(err)
 	}
 }
+
+// mustConnectToPostgres is used by the init functions of the store tests, which can not run without a database
+func mustConnectToPostgres() *sql.DB {
+	db, err := ConnectToPostgres()
+	if err != nil {
+		log.Fatal(err)
+	}
+	return db
+}
+
+func TestEvaluateConnError(t *testing.T) {
+
+	errorTests := []struct {
+		err          error
+		expectedKind apierrors.Kind
+	}{
+		{&net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, apierrors.KindUnavailable},
+		{errors.New("sql: Scan error on column index 0"), apierrors.KindInternal},
+	}
+
+	for _, et := range errorTests {
+		err := evaluateConnError(et.err)
+		if kind := apierrors.KindOf(err); kind != et.expectedKind {
+			t.Errorf("Expected %v to be classified as kind %d, but recieved kind %d", et.err, et.expectedKind, kind)
+		} else if !errors.Is(err, et.err) {
+			t.Errorf("Expected the classified error to wrap %v", et.err)
+		}
+	}
+}
@@ -2,147 +2,411 @@ package datastores
 
 import (
 	"database/sql"
-	"errors"
-	"log"
-	"net/http"
 	"strings"
 
+	"github.com/lib/pq"
+	"github.com/mangoslicer/answer-patch/apierrors"
 	"github.com/mangoslicer/answer-patch/models"
 )
 
 type QuestionStoreServices interface {
-	FindPostByID(string) (*models.Question, *models.Answer, error, int)
-	FindQuestionsByFilter(string, string) ([]*models.Question, error, int)
-	SortQuestions(string, string, string, string) ([]*models.Question, error, int)
-	StoreQuestion(string, string, string, string) (error, int)
+	FindPostByID(string) (*models.Question, *models.Answer, error)
+	FindQuestionsByFilter(string, string, models.PageRequest) (*models.QuestionPage, error)
+	SortQuestions(string, string, string, models.PageRequest) (*models.QuestionPage, error)
+	QueryQuestions(*models.QuestionQuery, models.PageRequest) (*models.QuestionPage, error)
+	StoreQuestion(string, string, string, string, string) error
+	FindSimilarQuestions(string, string, string) ([]*models.DuplicateCandidate, error)
+	CastVote(string, string, string, int) (string, int, error)
 }
 
+var (
+	errQuestionNotFound  = apierrors.New(apierrors.KindNotFound, apierrors.CodeQuestionNotFound, "No question exists with the provided question id")
+	errDuplicateNotFound = apierrors.New(apierrors.KindInvalid, apierrors.CodeInvalidField, "The question to link as a duplicate does not exist in this category", apierrors.FieldError{Field: "duplicateOfID", Reason: "invalid"})
+)
+
+// Submitted questions that reach duplicateThreshold in similarity to existing questions are reported as possible duplicates.
+// Titles must also reach the default threshold of the % operator of pg_trgm, which is 0.3, before contents are compared
+const (
+	duplicateThreshold     = 0.55
+	titleSimilarityWeight  = 0.75
+	trigramThreshold       = 0.3
+	maxDuplicateCandidates = 5
+)
+
 type QuestionStore struct {
 	DB *sql.DB
 }
 
-func (store *QuestionStore) FindPostByID(questionID string) (*models.Question, *models.Answer, error, int) {
+const questionColumns = `q.id, q.user_id, u.username, c.category_name, q.title, q.content, q.upvotes, q.edit_count, q.pending_count, q.submitted_at, COALESCE(q.duplicate_of::text, '')`
 
-	row, err := store.DB.Query(`SELECT q.id, q.user_id, u.username, c.category_name, q.title, q.content, q.upvotes, q.edit_count, q.pending_count, q.submitted_at FROM question q INNER JOIN ap_user u ON q.user_id = u.id INNER JOIN category c ON q.category_id = c.id WHERE q.id =$1`, questionID)
-	if err != nil {
-		log.Fatal(err)
-		return nil, nil, InternalErr, http.StatusInternalServerError
-	} else if !row.Next() { // row.Next returns false, if 0 rows were returned by the query
-		return nil, nil, errors.New("No question exists with the id of " + questionID), http.StatusBadRequest
-	}
+// sortColumn is a column that questions can be sorted by, along with the kind of its values
+type sortColumn struct {
+	name string
+	kind keyKind
+}
 
-	question := new(models.Question)
-	err = row.Scan(&question.ID, &question.UserID, &question.Username, &question.Category, &question.Title, &question.Content, &question.Upvotes, &question.EditCount, &question.PendingCount, &question.SubmittedAt)
-	if err != nil {
-		log.Fatal(err)
-		return nil, nil, InternalErr, http.StatusInternalServerError
-	}
+// Sort orders of the routes, which are descending if true
+var sortOrders = map[string]bool{
+	"asc":  false,
+	"desc": true,
+}
 
-	row, err = store.DB.Query(`SELECT a.id, a.question_id, a.user_id, u.username, a.is_current_answer, a.content, a.upvotes, a.required_upvotes, a.last_edited_at FROM answer a INNER JOIN ap_user u ON a.user_id = u.id WHERE a.question_id = $1 AND is_current_answer = 'true'`, questionID)
-	if err != nil {
-		log.Fatal(err)
-		return nil, nil, InternalErr, http.StatusInternalServerError
-	} else if !row.Next() {
-		return question, nil, nil, http.StatusOK // Returns only a question, if the question lacks any valid answer at the current moment
+// Columns that QueryQuestions sorts by, keyed by the sorts of models.QuestionQuery
+var questionQuerySorts = map[string]sortColumn{
+	"upvotes": {"q.upvotes", intKey},
+	"date":    {"q.submitted_at", timeKey},
+	"edits":   {"q.edit_count", intKey},
+	"pending": {"q.pending_count", intKey},
+}
+
+func (store *QuestionStore) FindPostByID(questionID string) (*models.Question, *models.Answer, error) {
+
+	question := new(models.Question)
+	err := store.DB.QueryRow(`SELECT `+questionColumns+` FROM question q INNER JOIN ap_user u ON q.user_id = u.id INNER JOIN category c ON q.category_id = c.id WHERE q.id =$1 AND q.removed_at IS NULL`, questionID).Scan(questionFields(question)...)
+	if err == sql.ErrNoRows {
+		return nil, nil, apierrors.New(apierrors.KindNotFound, apierrors.CodeQuestionNotFound, "No question exists with the id of "+questionID)
+	} else if err != nil {
+		return nil, nil, evaluateSQLError(err)
 	}
 
 	answer := models.NewAnswer()
-	err = row.Scan(&answer.ID, &answer.QuestionID, &answer.UserID, &answer.Username, &answer.IsCurrentAnswer, &answer.Content, &answer.Upvotes, &answer.ReqUpvotes, &answer.LastEditedAt)
-	if err != nil {
-		log.Fatal(err)
-		return nil, nil, InternalErr, http.StatusInternalServerError
+	err = store.DB.QueryRow(`SELECT a.id, a.question_id, a.user_id, u.username, a.is_current_answer, a.content, a.upvotes, a.required_upvotes, a.last_edited_at FROM answer a INNER JOIN ap_user u ON a.user_id = u.id WHERE a.question_id = $1 AND is_current_answer = 'true' AND a.removed_at IS NULL`, questionID).Scan(&answer.ID, &answer.QuestionID, &answer.UserID, &answer.Username, &answer.IsCurrentAnswer, &answer.Content, &answer.Upvotes, &answer.ReqUpvotes, &answer.LastEditedAt)
+	if err == sql.ErrNoRows {
+		return question, nil, nil // Returns only a question, if the question lacks any valid answer at the current moment
+	} else if err != nil {
+		return nil, nil, evaluateSQLError(err)
 	}
 
-	return question, answer, nil, http.StatusOK
+	return question, answer, nil
 }
 
-func (store *QuestionStore) FindQuestionsByFilter(filter, val string) ([]*models.Question, error, int) {
+// FindQuestionsByFilter lists a page of the questions that were posted by, answered by or posted in val, most upvoted first
+func (store *QuestionStore) FindQuestionsByFilter(filter, val string, page models.PageRequest) (*models.QuestionPage, error) {
 
-	queryStmt := `SELECT q.id, q.user_id, u.username, c.category_name, q.title, q.content, q.upvotes, q.edit_count, q.pending_count, q.submitted_at FROM question q`
+	listing := "questions:" + filter
+	c, err := decodeCursor(listing, page.Cursor, intKey, idKey)
+	if err != nil {
+		return nil, err
+	}
+	keyset, orderBy, keyParams := keysetSQL(c, []string{"q.upvotes", "q.id"}, true, 3)
+
+	queryStmt := `SELECT ` + questionColumns + ` FROM question q`
 
 	switch {
 	case filter == "posted-by":
-		queryStmt += ` INNER JOIN ap_user u ON q.user_id = u.id INNER JOIN category c ON q.category_id = c.id WHERE u.username = $1 ORDER BY q.upvotes DESC`
+		queryStmt += ` INNER JOIN ap_user u ON q.user_id = u.id INNER JOIN category c ON q.category_id = c.id WHERE u.username = $1 AND q.removed_at IS NULL`
 	case filter == "answered-by":
-		queryStmt += ` JOIN ap_user answer_author ON answer_author.username = $1 JOIN answer a ON (answer_author.id = a.user_id AND a.question_id=q.id AND a.is_current_answer='true') INNER JOIN ap_user u ON q.user_id = u.id INNER JOIN category c ON q.category_id = c.id ORDER BY q.upvotes DESC`
+		queryStmt += ` JOIN ap_user answer_author ON answer_author.username = $1 JOIN answer a ON (answer_author.id = a.user_id AND a.question_id=q.id AND a.is_current_answer='true' AND a.removed_at IS NULL) INNER JOIN ap_user u ON q.user_id = u.id INNER JOIN category c ON q.category_id = c.id WHERE q.removed_at IS NULL`
 	case filter == "category":
-		queryStmt += ` INNER JOIN ap_user u ON q.user_id = u.id INNER JOIN category c ON q.category_id = c.id WHERE c.category_name = $1 ORDER BY q.upvotes DESC`
+		queryStmt += ` INNER JOIN ap_user u ON q.user_id = u.id INNER JOIN category c ON q.category_id = c.id WHERE c.category_name = $1 AND q.removed_at IS NULL`
+	default:
+		return nil, apierrors.New(apierrors.KindInvalid, apierrors.CodeInvalidField, "Could not recognize the filter")
+	}
+	if keyset != "" {
+		queryStmt += ` AND ` + keyset
 	}
+	queryStmt += orderBy + ` LIMIT $2`
 
-	rows, err := store.DB.Query(queryStmt, val)
+	rows, err := store.DB.Query(queryStmt, append([]interface{}{val, page.Size + 1}, keyParams...)...)
 	if err != nil {
-		log.Fatal(err)
-		return nil, InternalErr, http.StatusInternalServerError
+		return nil, evaluateSQLError(err)
 	}
 
-	return scanQuestions(rows)
+	questions, err := scanQuestions(rows, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	n, more := trimPage(questions, c, page.Size)
+	questions = questions[:n]
+
+	return &models.QuestionPage{Items: questions, Cursors: pageCursors(listing, c, n, more, func(i int) []interface{} {
+		return []interface{}{questions[i].Upvotes, questions[i].ID}
+	})}, nil
 }
 
-func (store *QuestionStore) SortQuestions(postComponent, filter, order, offset string) ([]*models.Question, error, int) {
+// SortQuestions lists a page of the questions, sorted by a column of either the questions or their current answers
+func (store *QuestionStore) SortQuestions(postComponent, filter, order string, page models.PageRequest) (*models.QuestionPage, error) {
 
 	var ok bool
+	var column sortColumn
 
 	// The following maps convert the  param "filter" into a valid database column name
-	questionFilters := map[string]string{
-		"upvotes": "q.upvotes",
-		"date":    "q.submitted_at",
-		"edits":   "q.edit_count",
+	questionFilters := map[string]sortColumn{
+		"upvotes": {"q.upvotes", intKey},
+		"date":    {"q.submitted_at", timeKey},
+		"edits":   {"q.edit_count", intKey},
 	}
-	answerFilters := map[string]string{
-		"upvotes": "a.upvotes",
-		"date":    "a.last_edited_at",
+	answerFilters := map[string]sortColumn{
+		"upvotes": {"a.upvotes", intKey},
+		"date":    {"a.last_edited_at", timeKey},
 	}
-	queryStmt := `SELECT q.id, q.user_id, u.username, c.category_name, q.title, q.content, q.upvotes, q.edit_count, q.pending_count, q.submitted_at FROM question q`
+	queryStmt := `SELECT ` + questionColumns
 	if postComponent == "question" {
-		queryStmt += ` INNER JOIN ap_user u ON q.user_id = u.id INNER JOIN category c ON q.category_id = c.id`
-		filter, ok = questionFilters[filter]
+		column, ok = questionFilters[filter]
+		queryStmt += `, ` + column.name + ` FROM question q INNER JOIN ap_user u ON q.user_id = u.id INNER JOIN category c ON q.category_id = c.id`
 	} else if postComponent == "answer" {
-		queryStmt += ` JOIN answer a ON (a.question_id=q.id AND a.is_current_answer='true') INNER JOIN ap_user u ON q.user_id = u.id INNER JOIN category c ON q.category_id = c.id`
-		filter, ok = answerFilters[filter]
+		column, ok = answerFilters[filter]
+		queryStmt += `, ` + column.name + ` FROM question q JOIN answer a ON (a.question_id=q.id AND a.is_current_answer='true' AND a.removed_at IS NULL) INNER JOIN ap_user u ON q.user_id = u.id INNER JOIN category c ON q.category_id = c.id`
+	}
+	descending, validOrder := sortOrders[strings.ToLower(order)]
+	if !ok || !validOrder { // Return nil if the url param "filter" can not be converted into a valid database column name
+		return nil, apierrors.New(apierrors.KindInvalid, apierrors.CodeInvalidSortCriteria, "Could not recognize the sorting criteria")
+	}
+
+	listing := "sorted:" + postComponent + ":" + filter + ":" + strings.ToLower(order)
+	c, err := decodeCursor(listing, page.Cursor, column.kind, idKey)
+	if err != nil {
+		return nil, err
 	}
-	if !ok { // Return nil if the url param "filter" can not be converted into a valid database column name
-		return nil, errors.New("Could not recognize the sorting criteria"), http.StatusBadRequest
+	keyset, orderBy, keyParams := keysetSQL(c, []string{column.name, "q.id"}, descending, 2)
+
+	// Removed questions are hidden, while their rows are kept for the moderation log
+	queryStmt += ` WHERE q.removed_at IS NULL`
+	if keyset != "" {
+		queryStmt += ` AND ` + keyset
 	}
+	queryStmt += orderBy + ` LIMIT $1`
 
-	queryStmt += ` ORDER BY ` + filter + ` ` + strings.ToUpper(order) + ` LIMIT 10 OFFSET $1`
+	rows, err := store.DB.Query(queryStmt, append([]interface{}{page.Size + 1}, keyParams...)...)
+	if err != nil {
+		return nil, evaluateSQLError(err)
+	}
 
-	rows, err := store.DB.Query(queryStmt, offset)
+	var keys []interface{}
+	questions, err := scanQuestions(rows, &keys)
 	if err != nil {
-		log.Fatal(err)
-		return nil, InternalErr, http.StatusInternalServerError
+		return nil, err
 	}
 
-	return scanQuestions(rows)
+	n, more := trimPage(questions, c, page.Size)
+	trimPage(keys, c, page.Size)
+	questions = questions[:n]
+
+	return &models.QuestionPage{Items: questions, Cursors: pageCursors(listing, c, n, more, func(i int) []interface{} {
+		return []interface{}{keys[i], questions[i].ID}
+	})}, nil
 }
 
-func (store *QuestionStore) StoreQuestion(userID, categoryID, title, content string) (error, int) {
+// QueryQuestions lists a page of the questions that match every filter of the query. Every value of the query is passed as a parameter,
+// while the sort column is looked up in questionQuerySorts
+func (store *QuestionStore) QueryQuestions(query *models.QuestionQuery, page models.PageRequest) (*models.QuestionPage, error) {
+
+	column, ok := questionQuerySorts[query.Sort]
+	if !ok {
+		return nil, apierrors.New(apierrors.KindInvalid, apierrors.CodeInvalidSortCriteria, "Could not recognize the sorting criteria")
+	}
+
+	listing := questionQueryListing(query)
+	c, err := decodeCursor(listing, page.Cursor, column.kind, idKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var where conditions
+	where.add(`q.removed_at IS NULL`)
+
+	if len(query.Categories) != 0 {
+		categories := make([]string, len(query.Categories))
+		for i, category := range query.Categories {
+			categories[i] = strings.ToLower(category)
+		}
+		where.add(`lower(c.category_name) = ANY(` + where.param(pq.Array(categories)) + `)`)
+	}
+	if query.Author != "" {
+		where.add(`u.username = ` + where.param(query.Author))
+	}
+	if query.Answered != nil {
+		answered := `EXISTS (SELECT 1 FROM answer a WHERE a.question_id = q.id AND a.is_current_answer = 'true' AND a.removed_at IS NULL)`
+		if !*query.Answered {
+			answered = `NOT ` + answered
+		}
+		where.add(answered)
+	}
+	if query.HasPending != nil {
+		pending := `EXISTS (SELECT 1 FROM answer a WHERE a.question_id = q.id AND a.is_current_answer = 'false' AND a.removed_at IS NULL)`
+		if !*query.HasPending {
+			pending = `NOT ` + pending
+		}
+		where.add(pending)
+	}
+	if !query.From.IsZero() {
+		where.add(`q.submitted_at >= ` + where.param(query.From.UTC()) + `::timestamp`)
+	}
+	if !query.To.IsZero() {
+		where.add(`q.submitted_at < ` + where.param(query.To.UTC()) + `::timestamp`)
+	}
+	if query.MinUpvotes != nil {
+		where.add(`q.upvotes >= ` + where.param(*query.MinUpvotes))
+	}
+
+	orderBy := where.addKeyset(c, []string{column.name, "q.id"}, query.Descending)
+	limit := where.param(page.Size + 1)
 
-	return transact(store.DB, func(tx *sql.Tx) (error, int) {
-		_, err := tx.Exec(`INSERT INTO question(user_id, category_id, title, content) values($1::uuid, $2::uuid, $3, $4)`, userID, categoryID, title, content)
+	rows, err := store.DB.Query(`SELECT `+questionColumns+`, `+column.name+` FROM question q INNER JOIN ap_user u ON q.user_id = u.id INNER JOIN category c ON q.category_id = c.id`+
+		where.String()+orderBy+` LIMIT `+limit, where.params...)
+	if err != nil {
+		return nil, evaluateSQLError(err)
+	}
+
+	var keys []interface{}
+	questions, err := scanQuestions(rows, &keys)
+	if err != nil {
+		return nil, err
+	}
+
+	n, more := trimPage(questions, c, page.Size)
+	trimPage(keys, c, page.Size)
+	questions = questions[:n]
+
+	return &models.QuestionPage{Items: questions, Cursors: pageCursors(listing, c, n, more, func(i int) []interface{} {
+		return []interface{}{keys[i], questions[i].ID}
+	})}, nil
+}
+
+// questionQueryListing names the listing of a query in its cursors, which can be used with any filters but not with another sort
+func questionQueryListing(query *models.QuestionQuery) string {
+	if query.Descending {
+		return "query:" + query.Sort + ":desc"
+	}
+	return "query:" + query.Sort + ":asc"
+}
+
+// StoreQuestion stores a question, which is linked as a duplicate of the question with the id of duplicateOf unless it is empty.
+// Questions are only linked to questions of their own category
+func (store *QuestionStore) StoreQuestion(userID, categoryID, title, content, duplicateOf string) error {
+
+	return transact(store.DB, func(tx *sql.Tx) error {
+
+		if duplicateOf != "" {
+			err := tx.QueryRow(`SELECT id FROM question WHERE id = $1 AND category_id = $2 AND removed_at IS NULL`, duplicateOf, categoryID).Scan(&duplicateOf)
+			if err == sql.ErrNoRows {
+				return errDuplicateNotFound
+			} else if err != nil {
+				return evaluateSQLError(err)
+			}
+		}
+
+		_, err := tx.Exec(`INSERT INTO question(user_id, category_id, title, content, duplicate_of) values($1::uuid, $2::uuid, $3, $4, NULLIF($5, '')::uuid)`, userID, categoryID, title, content, duplicateOf)
 		if err != nil {
 			return evaluateSQLError(err)
 		}
 
-		return nil, http.StatusOK
+		return nil
 	})
 
 }
 
-func scanQuestions(rows *sql.Rows) ([]*models.Question, error, int) {
-	var questions []*models.Question
+// FindSimilarQuestions lists the questions of a category that a submitted question may duplicate, most similar first.
+// Questions are compared by the trigrams of their titles, which the trigrams of their contents may only add to
+func (store *QuestionStore) FindSimilarQuestions(categoryID, title, content string) ([]*models.DuplicateCandidate, error) {
+
+	rows, err := store.DB.Query(`SELECT q.id, u.username, q.title, q.score FROM (SELECT id, user_id, title, GREATEST(similarity(title, $2), $4 * similarity(title, $2) + (1 - $4) * similarity(content, $3)) AS score
+FROM question WHERE category_id = $1 AND removed_at IS NULL AND title % $2) q INNER JOIN ap_user u ON q.user_id = u.id WHERE q.score >= $5 ORDER BY q.score DESC, q.id LIMIT $6`, categoryID, title, content, titleSimilarityWeight, duplicateThreshold, maxDuplicateCandidates)
+	if err != nil {
+		return nil, evaluateSQLError(err)
+	}
+	defer rows.Close()
+
+	candidates := make([]*models.DuplicateCandidate, 0)
+	for rows.Next() {
+		candidate := new(models.DuplicateCandidate)
+		if err = rows.Scan(&candidate.QuestionID, &candidate.Username, &candidate.Title, &candidate.Score); err != nil {
+			return nil, evaluateSQLError(err)
+		}
+		candidates = append(candidates, candidate)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, evaluateSQLError(err)
+	}
+
+	return candidates, nil
+}
+
+// CastVote records a user's vote on a question of category and returns the question's author along with the resulting change in upvotes
+// Each user holds at most one vote per question, so voting again replaces the previous vote rather than adding to it.
+// Questions of other categories are not found, since the vote is credited to the author's rep in category
+func (store *QuestionStore) CastVote(questionID, category, userID string, vote int) (string, int, error) {
+
+	var authorID string
+	var change int
+
+	err := transact(store.DB, func(tx *sql.Tx) error {
+
+		// Locking the question row serializes concurrent votes, so that the ledger and the upvote count can not drift apart
+		err := tx.QueryRow(`SELECT q.user_id FROM question q INNER JOIN category c ON c.id = q.category_id WHERE q.id = $1 AND lower(c.category_name) = lower($2) AND q.removed_at IS NULL FOR UPDATE OF q`, questionID, category).Scan(&authorID)
+		if err == sql.ErrNoRows {
+			return errQuestionNotFound
+		} else if err != nil {
+			return evaluateSQLError(err)
+		} else if authorID == userID {
+			return apierrors.New(apierrors.KindForbidden, apierrors.CodeSelfVote, "Users can not vote on their own questions")
+		}
+
+		var previousVote int
+
+		err = tx.QueryRow(`SELECT vote FROM question_vote WHERE user_id = $1 AND question_id = $2`, userID, questionID).Scan(&previousVote)
+		if err != nil && err != sql.ErrNoRows {
+			return evaluateSQLError(err)
+		}
+
+		change = vote - previousVote
+		if change == 0 {
+			return nil
+		}
+
+		_, err = tx.Exec(`INSERT INTO question_vote(user_id, question_id, vote) VALUES($1, $2, $3) ON CONFLICT (user_id, question_id) WHERE user_id <> '00000000-0000-0000-0000-000000000000' DO UPDATE SET vote = EXCLUDED.vote, cast_at = (CURRENT_TIMESTAMP AT TIME ZONE 'UTC')`, userID, questionID, vote)
+		if err != nil {
+			return evaluateSQLError(err)
+		}
+
+		_, err = tx.Exec(`UPDATE question SET upvotes = upvotes + $1 WHERE id = $2`, change, questionID)
+		if err != nil {
+			return evaluateSQLError(err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return "", 0, err
+	}
+
+	return authorID, change, nil
+}
+
+// scanQuestions scans rows of questionColumns. If keys is not nil, every row ends in the sort key of its question, which is appended to keys
+func scanQuestions(rows *sql.Rows, keys *[]interface{}) ([]*models.Question, error) {
+	defer rows.Close()
+
+	questions := make([]*models.Question, 0)
 
 	for rows.Next() {
 		tempQuestion := new(models.Question)
-		err := rows.Scan(&tempQuestion.ID, &tempQuestion.UserID, &tempQuestion.Username, &tempQuestion.Category, &tempQuestion.Title, &tempQuestion.Content, &tempQuestion.Upvotes, &tempQuestion.EditCount, &tempQuestion.PendingCount, &tempQuestion.SubmittedAt)
+		fields := questionFields(tempQuestion)
+		var key interface{}
+		if keys != nil {
+			fields = append(fields, &key)
+		}
+		err := rows.Scan(fields...)
 		if err != nil {
-			log.Fatal(err)
-			return nil, InternalErr, http.StatusInternalServerError
+			return nil, evaluateSQLError(err)
 		}
 		questions = append(questions, tempQuestion)
+		if keys != nil {
+			if k, ok := key.(int64); ok {
+				key = int(k)
+			}
+			*keys = append(*keys, key)
+		}
 	}
-
-	if len(questions) == 0 {
-		return nil, errors.New("No question(s) founds"), http.StatusBadRequest
+	if err := rows.Err(); err != nil {
+		return nil, evaluateSQLError(err)
 	}
-	return questions, nil, http.StatusOK
+
+	return questions, nil
+}
+
+// questionFields returns the destinations of questionColumns
+func questionFields(question *models.Question) []interface{} {
+	return []interface{}{&question.ID, &question.UserID, &question.Username, &question.Category, &question.Title, &question.Content, &question.Upvotes, &question.EditCount, &question.PendingCount, &question.SubmittedAt, &question.DuplicateOf}
 }
@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/mangoslicer/answer-patch/apierrors"
 	"github.com/mangoslicer/answer-patch/models"
 	"github.com/mangoslicer/answer-patch/settings"
 )
@@ -13,7 +14,7 @@ var GlobalQuestionStore *QuestionStore
 
 func init() {
 	settings.SetPreproductionEnv()
-	GlobalQuestionStore = &QuestionStore{ConnectToPostgres()}
+	GlobalQuestionStore = &QuestionStore{mustConnectToPostgres()}
 }
 
 func TestFindPostByID(t *testing.T) {
@@ -22,7 +23,7 @@ func TestFindPostByID(t *testing.T) {
 
 	expectedAnswer := &models.Answer{ID: "b50f0224-3fda-435b-a8a6-8257fcbf5aa7", QuestionID: "0a24c4cd-4c73-42e4-bcca-3844d088de85", UserID: "baeee18f-45db-4e68-81c4-25671beaab5f", Username: "Tester6", IsCurrentAnswer: true, Content: "Yeah, get the ones with the neon laces", Upvotes: 26, ReqUpvotes: 20}
 
-	retreivedQuestion, retreivedAnswer, err, _ := GlobalQuestionStore.FindPostByID(expectedQuestion.ID)
+	retreivedQuestion, retreivedAnswer, err := GlobalQuestionStore.FindPostByID(expectedQuestion.ID)
 	if err != nil {
 		t.Error(err)
 	}
@@ -46,24 +47,24 @@ func TestFindQuestionsByPostedBy(t *testing.T) {
 
 	expectedQuestions := []*models.Question{&models.Question{ID: "526c4576-0e49-4e90-b760-e6976c698574", UserID: "0c1b2b91-9164-4d52-87b0-9c4b444ee62d", Username: "Tester1", Category: "City Dining", Title: "Where is the best sushi place?", Content: "I have cravings", Upvotes: 15, EditCount: 8, PendingCount: 7}, &models.Question{ID: "38681976-4d2d-4581-8a68-1e4acfadcfa0", UserID: "0c1b2b91-9164-4d52-87b0-9c4b444ee62d", Username: "Tester1", Category: "Gains", Title: "What should my squat to bench ratio be?", Content: "I need gains", Upvotes: 13, EditCount: 7, PendingCount: 6}}
 
-	retreivedQuestions, err, _ := GlobalQuestionStore.FindQuestionsByFilter("posted-by", "Tester1")
+	retreivedQuestions, err := GlobalQuestionStore.FindQuestionsByFilter("posted-by", "Tester1", models.PageRequest{Size: 10})
 	if err != nil {
 		t.Error(err)
 	}
 
-	checkQuestionsForEquality(t, expectedQuestions, retreivedQuestions)
+	checkQuestionsForEquality(t, expectedQuestions, retreivedQuestions.Items)
 }
 
 func TestFindQuestionsByAnsweredBy(t *testing.T) {
 
 	expectedQuestions := []*models.Question{&models.Question{ID: "526c4576-0e49-4e90-b760-e6976c698574", UserID: "0c1b2b91-9164-4d52-87b0-9c4b444ee62d", Username: "Tester1", Category: "City Dining", Title: "Where is the best sushi place?", Content: "I have cravings", Upvotes: 15, EditCount: 8, PendingCount: 7}}
 
-	retreivedQuestions, err, _ := GlobalQuestionStore.FindQuestionsByFilter("answered-by", "Tester4")
+	retreivedQuestions, err := GlobalQuestionStore.FindQuestionsByFilter("answered-by", "Tester4", models.PageRequest{Size: 10})
 	if err != nil {
 		t.Error(err)
 	}
 
-	checkQuestionsForEquality(t, expectedQuestions, retreivedQuestions)
+	checkQuestionsForEquality(t, expectedQuestions, retreivedQuestions.Items)
 
 }
 
@@ -72,12 +73,12 @@ func TestSortQuestionsByUpvotes(t *testing.T) {
 	//Test postComponent: "question", filter: "upvotes", order: "desc"
 	expectedQuestions := []*models.Question{&models.Question{ID: "b19dc050-5ab2-417b-931c-d02445c27aca", UserID: "df38ea24-e67b-43c6-92bf-184cecee3003", Username: "Tester4", Category: "Gains", Title: "How can I convince people to skip leg day?", Content: "Please", Upvotes: 15, EditCount: 5, PendingCount: 4}, &models.Question{ID: "526c4576-0e49-4e90-b760-e6976c698574", UserID: "0c1b2b91-9164-4d52-87b0-9c4b444ee62d", Username: "Tester1", Category: "City Dining", Title: "Where is the best sushi place?", Content: "I have cravings", Upvotes: 15, EditCount: 8, PendingCount: 7}, &models.Question{ID: "38681976-4d2d-4581-8a68-1e4acfadcfa0", UserID: "0c1b2b91-9164-4d52-87b0-9c4b444ee62d", Username: "Tester1", Category: "Gains", Title: "What should my squat to bench ratio be?", Content: "I need gains", Upvotes: 13, EditCount: 7, PendingCount: 6}}
 
-	retreivedQuestions, err, _ := GlobalQuestionStore.SortQuestions("question", "upvotes", "DESC", "0")
+	retreivedQuestions, err := GlobalQuestionStore.SortQuestions("question", "upvotes", "DESC", models.PageRequest{Size: 10})
 	if err != nil {
 		t.Error(err)
 	}
 
-	checkQuestionsForEquality(t, expectedQuestions, retreivedQuestions)
+	checkQuestionsForEquality(t, expectedQuestions, retreivedQuestions.Items)
 
 }
 
@@ -86,17 +87,17 @@ func TestSortQuestionsByDate(t *testing.T) {
 	//Test postComponent: "answer", filter: "date", order: "asc"
 	expectedQuestions := []*models.Question{&models.Question{ID: "526c4576-0e49-4e90-b760-e6976c698574", UserID: "0c1b2b91-9164-4d52-87b0-9c4b444ee62d", Username: "Tester1", Category: "City Dining", Title: "Where is the best sushi place?", Content: "I have cravings", Upvotes: 15, EditCount: 8, PendingCount: 7}, &models.Question{ID: "0a24c4cd-4c73-42e4-bcca-3844d088de85", UserID: "85c3bdbc-5882-4571-aaee-e46a32713e91", Username: "Tester3", Category: "Balling", Title: "Can Jordans make me a sick baller?", Content: "I need to improve my game", Upvotes: 10, EditCount: 4, PendingCount: 3}, &models.Question{ID: "b19dc050-5ab2-417b-931c-d02445c27aca", UserID: "df38ea24-e67b-43c6-92bf-184cecee3003", Username: "Tester4", Category: "Gains", Title: "How can I convince people to skip leg day?", Content: "Please", Upvotes: 15, EditCount: 5, PendingCount: 4}}
 
-	retreivedQuestions, err, _ := GlobalQuestionStore.SortQuestions("answer", "date", "ASC", "0")
+	retreivedQuestions, err := GlobalQuestionStore.SortQuestions("answer", "date", "ASC", models.PageRequest{Size: 10})
 	if err != nil {
 		t.Error(err)
 	}
 
-	checkQuestionsForEquality(t, expectedQuestions, retreivedQuestions)
+	checkQuestionsForEquality(t, expectedQuestions, retreivedQuestions.Items)
 }
 
 func TestStoreQuestion(t *testing.T) {
 
-	err, _ := GlobalQuestionStore.StoreQuestion("{95954f28-a8c3-4e76-8c80-18de07931639}", "{33f6b77a-4564-4aa9-8cc8-50bb01c6a609}", "Title", "Content and stuff")
+	err := GlobalQuestionStore.StoreQuestion("{95954f28-a8c3-4e76-8c80-18de07931639}", "{33f6b77a-4564-4aa9-8cc8-50bb01c6a609}", "Title", "Content and stuff", "")
 	if err != nil {
 		t.Error(err)
 	}
@@ -112,7 +113,7 @@ func TestStoreQuestion(t *testing.T) {
 func TestStoreQuestionWithForeignKeyViolation(t *testing.T) {
 
 	//Nonexistent uuid provided for userID param
-	err, _ := GlobalQuestionStore.StoreQuestion("{89f0b6aa-0399-4b31-8f24-cc4989f60391}", "{33f6b77a-4564-4aa9-8cc8-50bb01c6a609}", "Different title", "Content and stuff")
+	err := GlobalQuestionStore.StoreQuestion("{89f0b6aa-0399-4b31-8f24-cc4989f60391}", "{33f6b77a-4564-4aa9-8cc8-50bb01c6a609}", "Different title", "Content and stuff", "")
 
 	expectedErrMessage := "The provided user_id does not exist"
 
@@ -124,7 +125,7 @@ func TestStoreQuestionWithForeignKeyViolation(t *testing.T) {
 func TestStoreQuestionWithUniqueConstraintViolation(t *testing.T) {
 
 	// Title is not unique
-	err, _ := GlobalQuestionStore.StoreQuestion("{89f0b6aa-0399-4b31-8f24-cc4989f60391}", "{33f6b77a-4564-4aa9-8cc8-50bb01c6a609}", "Title", "Content and stuff")
+	err := GlobalQuestionStore.StoreQuestion("{89f0b6aa-0399-4b31-8f24-cc4989f60391}", "{33f6b77a-4564-4aa9-8cc8-50bb01c6a609}", "Title", "Content and stuff", "")
 
 	expectedErrMessage := "The provided title is not unique"
 
@@ -146,3 +147,69 @@ func checkQuestionsForEquality(t *testing.T, expected []*models.Question, receiv
 		}
 	}
 }
+
+func TestCastVoteOnQuestion(t *testing.T) {
+
+	var originalUpvotes, retrievedUpvotes int
+
+	questionID := "bf8111f3-e75f-40d7-8d5a-813ce3a429fe"
+	voterID := "0c1b2b91-9164-4d52-87b0-9c4b444ee62d"
+
+	err := GlobalQuestionStore.DB.QueryRow(`SELECT upvotes FROM question WHERE id = $1`, questionID).Scan(&originalUpvotes)
+	if err != nil {
+		t.Error(err)
+	}
+
+	voteTests := []struct {
+		vote           int
+		expectedChange int
+	}{
+		{1, 1},   // First vote
+		{1, 0},   // Repeating the vote does not stack
+		{-1, -2}, // Switching the vote reverses the upvote
+	}
+
+	for _, vt := range voteTests {
+		authorID, change, err := GlobalQuestionStore.CastVote(questionID, "balling", voterID, vt.vote)
+		if err != nil {
+			t.Error(err)
+		} else if change != vt.expectedChange {
+			t.Errorf("Expected a vote of %d to change the upvotes by %d, but the upvotes changed by %d", vt.vote, vt.expectedChange, change)
+		} else if authorID != "baeee18f-45db-4e68-81c4-25671beaab5f" {
+			t.Errorf("Expected the author of the question to be Tester6, but recieved the user ID of %s", authorID)
+		}
+	}
+
+	err = GlobalQuestionStore.DB.QueryRow(`SELECT upvotes FROM question WHERE id = $1`, questionID).Scan(&retrievedUpvotes)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if retrievedUpvotes != originalUpvotes-1 {
+		t.Errorf("Expected the question to have %d upvotes after the vote was switched to a downvote, but the question has %d upvotes", originalUpvotes-1, retrievedUpvotes)
+	}
+}
+
+func TestCastVoteOnOwnQuestion(t *testing.T) {
+
+	_, _, err := GlobalQuestionStore.CastVote("bf8111f3-e75f-40d7-8d5a-813ce3a429fe", "balling", "baeee18f-45db-4e68-81c4-25671beaab5f", 1)
+	if apierrors.KindOf(err) != apierrors.KindForbidden {
+		t.Errorf("Expected a forbidden error when voting on one's own question, but recieved %v", err)
+	}
+}
+
+func TestFindPostByIDWithMalformedID(t *testing.T) {
+
+	_, _, err := GlobalQuestionStore.FindPostByID("not-a-uuid")
+	if apierrors.KindOf(err) != apierrors.KindInvalid {
+		t.Errorf("Expected an invalid input error for an id that is not a uuid, but recieved %v", err)
+	}
+}
+
+func TestFindPostByIDWithNonexistentID(t *testing.T) {
+
+	_, _, err := GlobalQuestionStore.FindPostByID("1da8f5f3-271e-4f35-a0dc-d2935effc524")
+	if apierrors.KindOf(err) != apierrors.KindNotFound {
+		t.Errorf("Expected a not found error, but recieved %v", err)
+	}
+}
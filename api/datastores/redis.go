@@ -1,30 +1,28 @@
 package datastores
 
 import (
-	"log"
-
 	"github.com/garyburd/redigo/redis"
 	"github.com/mangoslicer/answer-patch/settings"
 )
 
-func ConnectToRedis() redis.Conn {
+func ConnectToRedis() (redis.Conn, error) {
 
 	dsn := settings.GetRedisDSN()
 
 	conn, err := redis.Dial("tcp", dsn.Addr)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
-	_, err = conn.Do("AUTH", dsn.Password)
-	if err != nil {
-		log.Fatal(err)
+	if _, err = conn.Do("AUTH", dsn.Password); err != nil {
+		conn.Close()
+		return nil, err
 	}
 
 	if _, err = conn.Do("PING"); err != nil {
 		conn.Close()
-		log.Fatal(err)
+		return nil, err
 	}
 
-	return conn
+	return conn, nil
 }
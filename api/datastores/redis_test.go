@@ -1,11 +1,26 @@
 package datastores
 
 import (
+	"log"
 	"testing"
+
+	"github.com/garyburd/redigo/redis"
 )
 
 func TestConnectToRedis(t *testing.T) {
-	if _, err := ConnectToRedis().Do("PING"); err != nil {
+	conn, err := ConnectToRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = conn.Do("PING"); err != nil {
 		t.Error(err)
 	}
 }
+
+func mustConnectToRedis() redis.Conn {
+	conn, err := ConnectToRedis()
+	if err != nil {
+		log.Fatal(err)
+	}
+	return conn
+}
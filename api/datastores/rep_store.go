@@ -1,8 +1,7 @@
 package datastores
 
 import (
-	"log"
-
+	"github.com/mangoslicer/answer-patch/apierrors"
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 )
@@ -10,8 +9,13 @@ import (
 type RepStoreServices interface {
 	FindRep(string, string) (int, error)
 	UpdateRep(string, string, int) error
+	FindTotalRep(string) (int, error)
+	RenameCategory(string, string) error
 }
 
+// Rep that every user starts out with in each category
+const startingRep = 5
+
 type RepStore struct {
 	Col *mgo.Collection
 }
@@ -26,10 +30,12 @@ func (store *RepStore) FindRep(category, userID string) (int, error) {
 
 	err := store.Col.Find(bson.M{"_id": bson.M{"category": category, "userID": userID}}).One(retrieved)
 	if err == mgo.ErrNotFound {
-		store.Col.Insert(bson.M{"_id": bson.M{"category": category, "userID": userID}, "rep": 5})
+		if err = store.Col.Insert(bson.M{"_id": bson.M{"category": category, "userID": userID}, "rep": startingRep}); err != nil {
+			return 0, evaluateMongoError(err)
+		}
+		retrieved.Rep = startingRep
 	} else if err != nil {
-		log.Fatal(err)
-		return 0, InternalErr
+		return 0, evaluateMongoError(err)
 	}
 
 	return retrieved.Rep, nil
@@ -40,11 +46,74 @@ func (store *RepStore) UpdateRep(category, userID string, rep int) error {
 	err := store.Col.Update(bson.M{"_id": bson.M{"category": category, "userID": userID}}, bson.M{"$inc": bson.M{"rep": rep}})
 
 	if err == mgo.ErrNotFound {
-		store.Col.Insert(bson.M{"_id": bson.M{"category": category, "userID": userID}, "rep": (5 + rep)})
+		if err = store.Col.Insert(bson.M{"_id": bson.M{"category": category, "userID": userID}, "rep": (startingRep + rep)}); err != nil {
+			return evaluateMongoError(err)
+		}
 	} else if err != nil {
-		log.Fatal(err)
-		return InternalErr
+		return evaluateMongoError(err)
 	}
 
 	return nil
 }
+
+// FindTotalRep sums the rep that a user has earned across every category. The starting rep of each category is left out,
+// since a document is inserted for every category that the rep of a user is looked up in, whether or not they took part in it
+func (store *RepStore) FindTotalRep(userID string) (int, error) {
+
+	retrieved := new(RepStruct)
+
+	err := store.Col.Pipe([]bson.M{
+		{"$match": bson.M{"_id.userID": userID}},
+		{"$group": bson.M{"_id": nil, "rep": bson.M{"$sum": bson.M{"$subtract": []interface{}{"$rep", startingRep}}}}},
+	}).One(retrieved)
+	if err == mgo.ErrNotFound {
+		return 0, nil
+	} else if err != nil {
+		return 0, evaluateMongoError(err)
+	}
+
+	return retrieved.Rep, nil
+}
+
+// RenameCategory moves the rep that users have in a category over to its new name, since rep is keyed by the names of categories
+func (store *RepStore) RenameCategory(name, newName string) error {
+
+	if name == newName {
+		return nil
+	}
+
+	var docs []struct {
+		ID struct {
+			UserID string `bson:"userID"`
+		} `bson:"_id"`
+		Rep int `bson:"rep"`
+	}
+
+	err := store.Col.Find(bson.M{"_id.category": name}).All(&docs)
+	if err != nil {
+		return evaluateMongoError(err)
+	}
+
+	// Documents are keyed by category, so they are copied over to the new name before the old ones are removed
+	for _, doc := range docs {
+		_, err = store.Col.Upsert(bson.M{"_id": bson.M{"category": newName, "userID": doc.ID.UserID}}, bson.M{"$set": bson.M{"rep": doc.Rep}})
+		if err != nil {
+			return evaluateMongoError(err)
+		}
+	}
+
+	_, err = store.Col.RemoveAll(bson.M{"_id.category": name})
+	if err != nil {
+		return evaluateMongoError(err)
+	}
+
+	return nil
+}
+
+// mgo reports unreachable servers with an unexported error, which can only be recognized by its message
+func evaluateMongoError(err error) error {
+	if err.Error() == "no reachable servers" {
+		return apierrors.Unavailable(err)
+	}
+	return evaluateConnError(err)
+}
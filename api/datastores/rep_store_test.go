@@ -11,7 +11,7 @@ var GlobalRepStore *RepStore
 func init() {
 
 	settings.SetPreproductionEnv()
-	GlobalRepStore = &RepStore{ConnectToMongoCol()}
+	GlobalRepStore = &RepStore{mustConnectToMongoCol()}
 
 	populateMongoCol(GlobalRepStore.Col)
 }
@@ -68,3 +68,27 @@ func TestUpdateRepWithNewUserID(t *testing.T) {
 	}
 
 }
+
+func TestFindTotalRep(t *testing.T) {
+
+	expectedRep := 10
+
+	// Gives the userID of 3 the starting rep of 5 in two categories
+	err := GlobalRepStore.UpdateRep("other", "3", 0)
+	if err != nil {
+		t.Error(err)
+	}
+	err = GlobalRepStore.UpdateRep("testing", "3", 0)
+	if err != nil {
+		t.Error(err)
+	}
+
+	retrievedRep, err := GlobalRepStore.FindTotalRep("3")
+	if err != nil {
+		t.Error(err)
+	}
+
+	if expectedRep != retrievedRep {
+		t.Errorf("Expected the total rep of the userID of 3 to be %d, but the FindTotalRep method returned %d", expectedRep, retrievedRep)
+	}
+}
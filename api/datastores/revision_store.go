@@ -0,0 +1,166 @@
+package datastores
+
+import (
+	"database/sql"
+	"strconv"
+
+	"github.com/mangoslicer/answer-patch/apierrors"
+	"github.com/mangoslicer/answer-patch/models"
+)
+
+type RevisionStoreServices interface {
+	FindRevisions(string, models.PageRequest) (*models.AnswerRevisionPage, error)
+	FindRevision(string, int) (*models.AnswerRevision, error)
+	RollbackToRevision(string, string, int) error
+}
+
+type RevisionStore struct {
+	DB *sql.DB
+}
+
+const revisionColumns = `r.id, r.question_id, r.revision, COALESCE(r.answer_id::text, ''), r.user_id, u.username, r.content, r.upvotes, r.became_current_at`
+
+// Revisions of removed questions and answers drop out of the history, while those of deleted answers are kept without their answer id
+const revisionTables = `answer_revision r INNER JOIN ap_user u ON r.user_id = u.id INNER JOIN question q ON q.id = r.question_id LEFT JOIN answer a ON a.id = r.answer_id`
+
+const revisionVisible = `q.removed_at IS NULL AND a.removed_at IS NULL`
+
+// FindRevisions lists a page of the answers that have been the current answer of a question, oldest first.
+// Questions that have not had a current answer yet have an empty history, while questions that do not exist are not found
+func (store *RevisionStore) FindRevisions(questionID string, page models.PageRequest) (*models.AnswerRevisionPage, error) {
+
+	c, err := decodeCursor("revisions", page.Cursor, intKey, idKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var where conditions
+	where.add(`r.question_id = ` + where.param(questionID) + `::uuid`)
+	where.add(revisionVisible)
+
+	orderBy := where.addKeyset(c, []string{"r.revision", "r.id"}, false)
+	limit := where.param(page.Size + 1)
+
+	rows, err := store.DB.Query(`SELECT `+revisionColumns+` FROM `+revisionTables+where.String()+orderBy+` LIMIT `+limit, where.params...)
+	if err != nil {
+		return nil, evaluateSQLError(err)
+	}
+	defer rows.Close()
+
+	revisions := make([]*models.AnswerRevision, 0)
+
+	for rows.Next() {
+		revision := new(models.AnswerRevision)
+		err = scanRevision(rows, revision)
+		if err != nil {
+			return nil, evaluateSQLError(err)
+		}
+		revisions = append(revisions, revision)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, evaluateSQLError(err)
+	}
+
+	if len(revisions) == 0 {
+		var exists bool
+		err = store.DB.QueryRow(`SELECT EXISTS (SELECT 1 FROM question WHERE id = $1::uuid AND removed_at IS NULL)`, questionID).Scan(&exists)
+		if err != nil {
+			return nil, evaluateSQLError(err)
+		} else if !exists {
+			return nil, errQuestionNotFound
+		}
+	}
+
+	n, more := trimPage(revisions, c, page.Size)
+	revisions = revisions[:n]
+
+	return &models.AnswerRevisionPage{Items: revisions, Cursors: pageCursors("revisions", c, n, more, func(i int) []interface{} {
+		return []interface{}{revisions[i].Revision, revisions[i].ID}
+	})}, nil
+}
+
+func (store *RevisionStore) FindRevision(questionID string, revisionNumber int) (*models.AnswerRevision, error) {
+
+	row, err := store.DB.Query(`SELECT `+revisionColumns+` FROM `+revisionTables+` WHERE r.question_id = $1 AND r.revision = $2 AND `+revisionVisible, questionID, revisionNumber)
+	if err != nil {
+		return nil, evaluateSQLError(err)
+	}
+	defer row.Close()
+
+	if !row.Next() {
+		return nil, apierrors.New(apierrors.KindNotFound, apierrors.CodeRevisionNotFound, "No revision "+strconv.Itoa(revisionNumber)+" exists for the question with the id of "+questionID)
+	}
+
+	revision := new(models.AnswerRevision)
+	err = scanRevision(row, revision)
+	if err != nil {
+		return nil, evaluateSQLError(err)
+	}
+
+	return revision, nil
+}
+
+// RollbackToRevision makes the answer of a past revision the current answer again, which is itself recorded as a new revision
+// Questions of other categories are not found, since moderators may only roll back the answers of the categories they moderate
+func (store *RevisionStore) RollbackToRevision(questionID, category string, revisionNumber int) error {
+
+	revision, err := store.FindRevision(questionID, revisionNumber)
+	if err != nil {
+		return err
+	} else if revision.AnswerID == "" {
+		return apierrors.New(apierrors.KindGone, apierrors.CodeRevisionAnswerGone, "The answer of revision "+strconv.Itoa(revisionNumber)+" no longer exists")
+	}
+
+	return transact(store.DB, func(tx *sql.Tx) error {
+
+		var id string
+		err := tx.QueryRow(`SELECT q.id FROM question q INNER JOIN category c ON c.id = q.category_id WHERE q.id = $1 AND lower(c.category_name) = lower($2) AND q.removed_at IS NULL FOR UPDATE OF q`, questionID, category).Scan(&id)
+		if err == sql.ErrNoRows {
+			return errQuestionNotFound
+		} else if err != nil {
+			return evaluateSQLError(err)
+		}
+
+		// Answers removed by moderators are gone as far as rollbacks are concerned, even though their rows are kept
+		var removed bool
+		err = tx.QueryRow(`SELECT removed_at IS NOT NULL FROM answer WHERE id = $1 FOR UPDATE`, revision.AnswerID).Scan(&removed)
+		if err != nil {
+			return evaluateSQLError(err)
+		} else if removed {
+			return apierrors.New(apierrors.KindGone, apierrors.CodeRevisionAnswerGone, "The answer of revision "+strconv.Itoa(revisionNumber)+" has been removed")
+		}
+
+		_, err = tx.Exec(`UPDATE answer SET is_current_answer = 'false' WHERE question_id = $1 AND is_current_answer = 'true'`, questionID)
+		if err != nil {
+			return evaluateSQLError(err)
+		}
+
+		_, err = tx.Exec(`UPDATE answer SET is_current_answer = 'true' WHERE id = $1`, revision.AnswerID)
+		if err != nil {
+			return evaluateSQLError(err)
+		}
+
+		return recordRevision(tx, revision.AnswerID)
+	})
+}
+
+// recordRevision snapshots an answer that has just become the current answer. It must be called within the same transaction that promoted the answer
+func recordRevision(tx *sql.Tx, answerID string) error {
+
+	// Locking the question row serializes concurrent promotions, so that no two revisions of a question receive the same number
+	_, err := tx.Exec(`SELECT q.id FROM question q INNER JOIN answer a ON a.question_id = q.id WHERE a.id = $1 FOR UPDATE OF q`, answerID)
+	if err != nil {
+		return evaluateSQLError(err)
+	}
+
+	_, err = tx.Exec(`INSERT INTO answer_revision(question_id, revision, answer_id, user_id, content, upvotes) SELECT a.question_id, COALESCE((SELECT MAX(r.revision) FROM answer_revision r WHERE r.question_id = a.question_id), 0) + 1, a.id, a.user_id, a.content, a.upvotes FROM answer a WHERE a.id = $1`, answerID)
+	if err != nil {
+		return evaluateSQLError(err)
+	}
+
+	return nil
+}
+
+func scanRevision(rows *sql.Rows, revision *models.AnswerRevision) error {
+	return rows.Scan(&revision.ID, &revision.QuestionID, &revision.Revision, &revision.AnswerID, &revision.UserID, &revision.Username, &revision.Content, &revision.Upvotes, &revision.BecameCurrentAt)
+}
@@ -0,0 +1,58 @@
+package datastores
+
+import (
+	"testing"
+
+	"github.com/mangoslicer/answer-patch/models"
+	"github.com/mangoslicer/answer-patch/settings"
+)
+
+var GlobalRevisionStore *RevisionStore
+
+func init() {
+	settings.SetPreproductionEnv()
+	GlobalRevisionStore = &RevisionStore{mustConnectToPostgres()}
+}
+
+func TestFindRevisions(t *testing.T) {
+
+	// TestAssessAnswersWithNewlyQualifiedCurrentAnswer promoted Tester4's answer to be the first current answer of this question
+	questionID := "28a12532-bc7a-427c-8f55-b72b18df7c02"
+	expectedUserID := "df38ea24-e67b-43c6-92bf-184cecee3003"
+
+	revisions, err := GlobalRevisionStore.FindRevisions(questionID, models.PageRequest{Size: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(revisions.Items) != 1 {
+		t.Errorf("Expected the question with an ID of %s to have 1 revision, but recieved %d revisions", questionID, len(revisions.Items))
+	} else if revisions.Items[0].Revision != 1 || revisions.Items[0].UserID != expectedUserID {
+		t.Errorf("Expected revision 1 to be authored by the user with an ID of %s, but recieved revision %d authored by %s", expectedUserID, revisions.Items[0].Revision, revisions.Items[0].UserID)
+	}
+}
+
+func TestFindRevisionWithNonexistentRevision(t *testing.T) {
+
+	_, err := GlobalRevisionStore.FindRevision("28a12532-bc7a-427c-8f55-b72b18df7c02", 100)
+	if err == nil {
+		t.Errorf("Expected FindRevision to return an error for a revision that does not exist")
+	}
+}
+
+func TestRollbackToRevision(t *testing.T) {
+
+	questionID := "28a12532-bc7a-427c-8f55-b72b18df7c02"
+
+	err := GlobalRevisionStore.RollbackToRevision(questionID, "City Dining", 1)
+	if err != nil {
+		t.Error(err)
+	}
+
+	revision, err := GlobalRevisionStore.FindRevision(questionID, 2)
+	if err != nil {
+		t.Error(err)
+	} else if revision.UserID != "df38ea24-e67b-43c6-92bf-184cecee3003" {
+		t.Errorf("Expected the rollback to be recorded as revision 2 with the answer of revision 1, but revision 2 was authored by %s", revision.UserID)
+	}
+}
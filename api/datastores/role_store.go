@@ -0,0 +1,150 @@
+package datastores
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/mangoslicer/answer-patch/models"
+)
+
+type RoleStoreServices interface {
+	FindRoles(string) (*models.Roles, error)
+	FindRoleGrants(page models.PageRequest) (*models.RoleGrantPage, error)
+	SetRole(string, string, models.Role) error
+	BanUser(string, string, string) error
+	UnbanUser(string) error
+}
+
+type RoleStore struct {
+	DB *sql.DB
+}
+
+// FindRoles returns the roles of a user. Users without any granted role have the user role everywhere
+func (store *RoleStore) FindRoles(userID string) (*models.Roles, error) {
+
+	roles := &models.Roles{Role: models.RoleUser}
+
+	rows, err := store.DB.Query(`SELECT ur.role, COALESCE(lower(c.category_name), '') FROM user_role ur LEFT OUTER JOIN category c ON c.id = ur.category_id WHERE ur.user_id = $1::uuid`, userID)
+	if err != nil {
+		return nil, evaluateSQLError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var role models.Role
+		var category string
+		if err = rows.Scan(&role, &category); err != nil {
+			return nil, evaluateSQLError(err)
+		}
+
+		if category == "" {
+			roles.Role = role
+			continue
+		}
+		if roles.CategoryRoles == nil {
+			roles.CategoryRoles = make(map[string]models.Role)
+		}
+		roles.CategoryRoles[category] = role
+	}
+	if err = rows.Err(); err != nil {
+		return nil, evaluateSQLError(err)
+	}
+
+	if err = store.DB.QueryRow(`SELECT EXISTS(SELECT 1 FROM user_ban WHERE user_id = $1::uuid)`, userID).Scan(&roles.Banned); err != nil {
+		return nil, evaluateSQLError(err)
+	}
+
+	return roles, nil
+}
+
+// FindRoleGrants lists a page of the granted roles, oldest first. A user holds one role per category, so the category breaks the ties of a user's grants
+func (store *RoleStore) FindRoleGrants(page models.PageRequest) (*models.RoleGrantPage, error) {
+
+	c, err := decodeCursor("role_grants", page.Cursor, timeKey, textKey, idKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var where conditions
+	orderBy := where.addKeyset(c, []string{"ur.granted_at", `COALESCE(c.category_name, '') COLLATE "C"`, "ur.user_id"}, false)
+	limit := where.param(page.Size + 1)
+
+	rows, err := store.DB.Query(`SELECT ur.user_id, u.username, ur.role, COALESCE(c.category_name, ''), ur.granted_at FROM user_role ur INNER JOIN ap_user u ON u.id = ur.user_id LEFT OUTER JOIN category c ON c.id = ur.category_id`+where.String()+orderBy+` LIMIT `+limit, where.params...)
+	if err != nil {
+		return nil, evaluateSQLError(err)
+	}
+	defer rows.Close()
+
+	grants := make([]*models.RoleGrant, 0)
+	for rows.Next() {
+		grant := new(models.RoleGrant)
+		if err = rows.Scan(&grant.UserID, &grant.Username, &grant.Role, &grant.Category, &grant.GrantedAt); err != nil {
+			return nil, evaluateSQLError(err)
+		}
+		grants = append(grants, grant)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, evaluateSQLError(err)
+	}
+
+	n, more := trimPage(grants, c, page.Size)
+	grants = grants[:n]
+
+	return &models.RoleGrantPage{Items: grants, Cursors: pageCursors("role_grants", c, n, more, func(i int) []interface{} {
+		return []interface{}{grants[i].GrantedAt, grants[i].Category, grants[i].UserID}
+	})}, nil
+}
+
+// SetRole sets the role of a user within a category, or everywhere if category is empty. Setting the user role revokes the granted role
+func (store *RoleStore) SetRole(userID, category string, role models.Role) error {
+
+	return transact(store.DB, func(tx *sql.Tx) error {
+
+		var categoryID sql.NullString
+		if category != "" {
+			err := tx.QueryRow(`SELECT id FROM category WHERE lower(category_name) = $1`, strings.ToLower(category)).Scan(&categoryID)
+			if err == sql.ErrNoRows {
+				return errCategoryNotFound
+			} else if err != nil {
+				return evaluateSQLError(err)
+			}
+		}
+
+		if role == models.RoleUser {
+			if _, err := tx.Exec(`DELETE FROM user_role WHERE user_id = $1::uuid AND category_id IS NOT DISTINCT FROM $2::uuid`, userID, categoryID); err != nil {
+				return evaluateSQLError(err)
+			}
+			return nil
+		}
+
+		// The conflict target is the expression of the unique index on user_role, which treats the roles that apply everywhere as one scope
+		_, err := tx.Exec(`INSERT INTO user_role(user_id, category_id, role) VALUES($1::uuid, $2::uuid, $3)
+ON CONFLICT (user_id, COALESCE(category_id, '00000000-0000-0000-0000-000000000000'::uuid)) DO UPDATE SET role = EXCLUDED.role, granted_at = EXCLUDED.granted_at`, userID, categoryID, string(role))
+		if err != nil {
+			return evaluateSQLError(err)
+		}
+
+		return nil
+	})
+}
+
+// BanUser bans a user. Banning a user that is already banned keeps the original ban
+func (store *RoleStore) BanUser(userID, bannedBy, reason string) error {
+
+	_, err := store.DB.Exec(`INSERT INTO user_ban(user_id, banned_by, reason) VALUES($1::uuid, $2::uuid, $3) ON CONFLICT (user_id) DO NOTHING`, userID, bannedBy, reason)
+	if err != nil {
+		return evaluateSQLError(err)
+	}
+
+	return nil
+}
+
+// UnbanUser lifts the ban of a user. Users that are not banned are left as they are
+func (store *RoleStore) UnbanUser(userID string) error {
+
+	if _, err := store.DB.Exec(`DELETE FROM user_ban WHERE user_id = $1::uuid`, userID); err != nil {
+		return evaluateSQLError(err)
+	}
+
+	return nil
+}
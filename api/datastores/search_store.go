@@ -0,0 +1,92 @@
+package datastores
+
+import (
+	"database/sql"
+	"html"
+	"strconv"
+	"strings"
+
+	"github.com/mangoslicer/answer-patch/models"
+)
+
+type SearchStoreServices interface {
+	SearchQuestions(*models.SearchQuery, models.PageRequest) (*models.SearchResultPage, error)
+}
+
+// Ranks are rounded to as many decimals, so that they can be compared exactly with the ranks of cursors
+const rankDecimals = 6
+
+// Matching words are wrapped in these private use characters rather than in <mark> tags, so that the text around them can be escaped first
+const (
+	highlightStart = "\uE000"
+	highlightStop  = "\uE001"
+)
+
+// Options of ts_headline. Titles are highlighted in full, while snippets are cut down to the fragments around the matching words
+const (
+	titleHeadlineOptions   = `StartSel=` + highlightStart + `, StopSel=` + highlightStop + `, HighlightAll=TRUE`
+	snippetHeadlineOptions = `StartSel=` + highlightStart + `, StopSel=` + highlightStop + `, MaxWords=35, MinWords=15, MaxFragments=2, FragmentDelimiter=" ... "`
+)
+
+type SearchStore struct {
+	DB *sql.DB
+}
+
+// SearchQuestions ranks the questions that contain every word of the query, by the search_vector column that the triggers of migration 12 keep up to date.
+// Words in titles weigh more than words in questions, which weigh more than words in current answers
+func (store *SearchStore) SearchQuestions(query *models.SearchQuery, page models.PageRequest) (*models.SearchResultPage, error) {
+
+	c, err := decodeCursor("search", page.Cursor, floatKey, intKey, idKey)
+	if err != nil {
+		return nil, err
+	}
+	rank := `round(ts_rank(q.search_vector, query)::numeric, ` + strconv.Itoa(rankDecimals) + `)`
+	keyset, orderBy, keyParams := keysetSQL(c, []string{rank, "q.upvotes", "q.id"}, true, 7)
+	if keyset != "" {
+		keyset = ` AND ` + keyset
+	}
+
+	rows, err := store.DB.Query(`SELECT q.id, q.user_id, u.username, c.category_name, q.title, q.upvotes, q.submitted_at, `+rank+`,
+ts_headline('english', q.title, query, $4), ts_headline('english', q.content || E'\n' || COALESCE(a.content, ''), query, $5)
+FROM question q INNER JOIN ap_user u ON q.user_id = u.id INNER JOIN category c ON q.category_id = c.id LEFT OUTER JOIN answer a ON (a.question_id = q.id AND a.is_current_answer = 'true' AND a.removed_at IS NULL) CROSS JOIN plainto_tsquery('english', $1) query
+WHERE q.search_vector @@ query AND q.removed_at IS NULL AND ($2 = '' OR lower(c.category_name) = lower($2)) AND ($3 = '' OR u.username = $3)`+keyset+orderBy+` LIMIT $6`,
+		append([]interface{}{query.Terms, query.Category, query.Author, titleHeadlineOptions, snippetHeadlineOptions, page.Size + 1}, keyParams...)...)
+	if err != nil {
+		return nil, evaluateSQLError(err)
+	}
+	defer rows.Close()
+
+	results := make([]*models.SearchResult, 0)
+	for rows.Next() {
+		result := new(models.SearchResult)
+		err = rows.Scan(&result.QuestionID, &result.UserID, &result.Username, &result.Category, &result.Title, &result.Upvotes, &result.SubmittedAt, &result.Rank, &result.TitleHighlight, &result.Snippet)
+		if err != nil {
+			return nil, evaluateSQLError(err)
+		}
+		result.TitleHighlight = formatHighlight(result.TitleHighlight)
+		result.Snippet = formatHighlight(result.Snippet)
+		results = append(results, result)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, evaluateSQLError(err)
+	}
+
+	n, more := trimPage(results, c, page.Size)
+	results = results[:n]
+
+	return &models.SearchResultPage{Items: results, Cursors: pageCursors("search", c, n, more, func(i int) []interface{} { return searchResultKeys(results[i]) })}, nil
+}
+
+func searchResultKeys(result *models.SearchResult) []interface{} {
+	return []interface{}{result.Rank, result.Upvotes, result.QuestionID}
+}
+
+// formatHighlight escapes a headline, and then turns its markers into <mark> tags
+func formatHighlight(headline string) string {
+
+	escaped := html.EscapeString(headline)
+	escaped = strings.Replace(escaped, highlightStart, "<mark>", -1)
+	escaped = strings.Replace(escaped, highlightStop, "</mark>", -1)
+
+	return escaped
+}
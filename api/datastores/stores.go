@@ -0,0 +1,87 @@
+package datastores
+
+import (
+	"database/sql"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/mangoslicer/answer-patch/settings"
+	"gopkg.in/mgo.v2"
+)
+
+// Stores bundles an implementation of every store interface, so that the handlers do not depend on any particular backend
+type Stores struct {
+	Questions  QuestionStoreServices
+	Answers    AnswerStoreServices
+	Users      UserStoreServices
+	Categories CategoryStoreServices
+	Revisions  RevisionStoreServices
+	Roles      RoleStoreServices
+	Moderation ModerationStoreServices
+	Search     SearchStoreServices
+	Rep        RepStoreServices
+	Tokens     TokenStoreServices
+}
+
+// NewDatabaseStores backs every store with postgres, except for rep and tokens, which are kept in mongodb and redis
+func NewDatabaseStores(db *sql.DB, conn redis.Conn, col *mgo.Collection) *Stores {
+	return &Stores{
+		Questions:  &QuestionStore{db},
+		Answers:    &AnswerStore{db},
+		Users:      &UserStore{db},
+		Categories: &CategoryStore{db},
+		Revisions:  &RevisionStore{db},
+		Roles:      &RoleStore{db},
+		Moderation: &ModerationStore{db},
+		Search:     &SearchStore{db},
+		Rep:        &RepStore{col},
+		Tokens:     &JWTStore{conn},
+	}
+}
+
+// NewMemoryStores backs every store with db, except for rep and tokens, which are kept in memory of their own. Nothing outlives the process
+func NewMemoryStores(db *MemoryDB) *Stores {
+	return &Stores{
+		Questions:  &MemoryQuestionStore{db},
+		Answers:    &MemoryAnswerStore{db},
+		Users:      &MemoryUserStore{db},
+		Categories: &MemoryCategoryStore{db},
+		Revisions:  &MemoryRevisionStore{db},
+		Roles:      &MemoryRoleStore{db},
+		Moderation: &MemoryModerationStore{db},
+		Search:     &MemorySearchStore{db},
+		Rep:        NewMemoryRepStore(),
+		Tokens:     NewMemoryTokenStore(),
+	}
+}
+
+// OpenStores creates the stores of the backend that the config selects. The postgres schema is migrated before the stores are returned
+func OpenStores(cfg *settings.Config) (*Stores, error) {
+
+	if cfg.Backend == settings.MemoryBackend {
+		return NewMemoryStores(NewMemoryDB()), nil
+	}
+
+	db, err := ConnectToPostgres()
+	if err != nil {
+		return nil, err
+	}
+	if err = MigrateUp(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	conn, err := ConnectToRedis()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	col, err := ConnectToMongoCol()
+	if err != nil {
+		db.Close()
+		conn.Close()
+		return nil, err
+	}
+
+	return NewDatabaseStores(db, conn, col), nil
+}
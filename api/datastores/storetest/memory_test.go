@@ -0,0 +1,215 @@
+package storetest
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/mangoslicer/answer-patch/apierrors"
+	"github.com/mangoslicer/answer-patch/datastores"
+	"github.com/mangoslicer/answer-patch/datastores/fixtures"
+	"github.com/mangoslicer/answer-patch/models"
+)
+
+// memoryBackend creates memory stores that hold the fixtures
+func memoryBackend(t *testing.T) *datastores.Stores {
+	db := datastores.NewMemoryDB()
+	if err := db.Populate(); err != nil {
+		t.Fatal(err)
+	}
+	return datastores.NewMemoryStores(db)
+}
+
+func TestMemoryStores(t *testing.T) {
+	Run(t, memoryBackend)
+}
+
+// The tests below cover behaviour that is particular to the memory stores. They are kept out of the datastores package,
+// whose tests connect to postgres
+
+// newMemoryFixture registers the users voter0 through voter<n-1> along with an author, who has asked one question in one category
+func newMemoryFixture(t *testing.T, voters int) (*datastores.Stores, string, []string, string) {
+
+	stores := datastores.NewMemoryStores(datastores.NewMemoryDB())
+
+	mustStoreUser := func(username string) string {
+		if err := stores.Users.StoreUser(username, "hash of "+username, ""); err != nil {
+			t.Fatal(err)
+		}
+		user, err := stores.Users.FindUser("username", username)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return user.ID
+	}
+
+	authorID := mustStoreUser("author")
+	voterIDs := make([]string, voters)
+	for i := range voterIDs {
+		voterIDs[i] = mustStoreUser("voter" + strconv.Itoa(i))
+	}
+
+	if err := stores.Categories.StoreCategory(authorID, "gains", ""); err != nil {
+		t.Fatal(err)
+	}
+	category, err := stores.Categories.FindCategory("gains")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = stores.Questions.StoreQuestion(authorID, category.ID, "What should my squat to bench ratio be?", "I need gains", ""); err != nil {
+		t.Fatal(err)
+	}
+	questions, err := stores.Questions.FindQuestionsByFilter("posted-by", "author", models.PageRequest{Size: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return stores, authorID, voterIDs, questions.Items[0].ID
+}
+
+func TestMemoryStoresWithMalformedID(t *testing.T) {
+
+	stores := datastores.NewMemoryStores(datastores.NewMemoryDB())
+
+	_, _, err := stores.Questions.FindPostByID("38681976")
+	if apierrors.KindOf(err) != apierrors.KindInvalid || apierrors.CodeOf(err) != apierrors.CodeMalformedID {
+		t.Errorf("Expected a malformed id error, but recieved %v", err)
+	}
+
+	// Postgres accepts uuids in braces, in uppercase and without hyphens, so the memory stores must as well
+	_, _, err = stores.Questions.FindPostByID("{38681976-4D2D-4581-8A68-1E4ACFADCFA0}")
+	if apierrors.KindOf(err) != apierrors.KindNotFound {
+		t.Errorf("Expected a not found error, but recieved %v", err)
+	}
+}
+
+func TestMemoryStoreUserWithDuplicateUsername(t *testing.T) {
+
+	stores, _, _, _ := newMemoryFixture(t, 0)
+
+	err := stores.Users.StoreUser("author", "another hash", "")
+	if apierrors.KindOf(err) != apierrors.KindConflict {
+		t.Errorf("Expected a conflict, but recieved %v", err)
+	}
+}
+
+func TestMemoryStoreQuestionWithUnknownCategory(t *testing.T) {
+
+	stores, authorID, _, _ := newMemoryFixture(t, 0)
+
+	err := stores.Questions.StoreQuestion(authorID, unknownID, "Is ball really life?", "", "")
+	if apierrors.CodeOf(err) != apierrors.CodeReferenceNotFound || err.Error() != "The provided category_id does not exist" {
+		t.Errorf("Expected the category_id to be reported as missing, but recieved %v", err)
+	}
+}
+
+func TestMemoryAnswerSlots(t *testing.T) {
+
+	stores, _, voterIDs, questionID := newMemoryFixture(t, 6)
+
+	for i, voterID := range voterIDs {
+		isSlotAvailable, err := stores.Answers.IsAnswerSlotAvailable(questionID)
+		if err != nil {
+			t.Fatal(err)
+		} else if isSlotAvailable != (i < 5) {
+			t.Errorf("Expected the availability of a slot after %d answers to be %t", i, i < 5)
+		}
+
+		if err = stores.Answers.StoreAnswer(questionID, voterID, "Answer "+strconv.Itoa(i), 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := stores.Answers.StoreAnswer(questionID, voterIDs[0], "Answer 0", 1)
+	if apierrors.CodeOf(err) != apierrors.CodeDuplicateAnswer {
+		t.Errorf("Expected a duplicate answer to be rejected, but recieved %v", err)
+	}
+}
+
+func TestMemoryAssessAnswersRecordsRevisions(t *testing.T) {
+
+	stores := memoryBackend(t)
+	ballAnswer := fixtures.Answers[11] // Answer by tester1 that wins the tie of ballQuestion
+
+	if err := stores.Answers.AssessAnswers(ballQuestion.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	_, current, err := stores.Questions.FindPostByID(ballQuestion.ID)
+	if err != nil {
+		t.Fatal(err)
+	} else if current == nil || current.ID != ballAnswer.ID {
+		t.Fatalf("Expected %s to have become the current answer, but recieved %+v", ballAnswer.ID, current)
+	}
+
+	revision, err := stores.Revisions.FindRevision(ballQuestion.ID, 1)
+	if err != nil {
+		t.Fatal(err)
+	} else if revision.AnswerID != ballAnswer.ID || revision.Upvotes != ballAnswer.Upvotes || revision.Username != tester1.Username {
+		t.Errorf("Expected the first revision to snapshot the promoted answer, but recieved %+v", revision)
+	}
+}
+
+func TestMemoryConcurrentVotes(t *testing.T) {
+
+	stores, authorID, voterIDs, questionID := newMemoryFixture(t, 50)
+
+	startingRep, err := stores.Rep.FindRep("gains", authorID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+
+	for _, voterID := range voterIDs {
+		wg.Add(1)
+		go func(voterID string) {
+			defer wg.Done()
+			// Voting twice must not count twice
+			for i := 0; i < 2; i++ {
+				if _, _, err := stores.Questions.CastVote(questionID, "gains", voterID, 1); err != nil {
+					t.Error(err)
+				}
+			}
+			if err := stores.Rep.UpdateRep("gains", authorID, 1); err != nil {
+				t.Error(err)
+			}
+		}(voterID)
+	}
+
+	wg.Wait()
+
+	question, _, err := stores.Questions.FindPostByID(questionID)
+	if err != nil {
+		t.Fatal(err)
+	} else if question.Upvotes != len(voterIDs) {
+		t.Errorf("Expected %d upvotes, but recieved %d", len(voterIDs), question.Upvotes)
+	}
+
+	rep, err := stores.Rep.FindRep("gains", authorID)
+	if err != nil {
+		t.Fatal(err)
+	} else if rep != startingRep+len(voterIDs) {
+		t.Errorf("Expected a rep of %d, but recieved %d", startingRep+len(voterIDs), rep)
+	}
+}
+
+func TestMemoryTokenStoreExpiry(t *testing.T) {
+
+	store := datastores.NewMemoryTokenStore()
+
+	if err := store.StoreToken("live", "token", 100); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.StoreToken("expired", "token", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if isStored, _ := store.IsTokenStored("live"); !isStored {
+		t.Error("Expected the live token to be stored")
+	}
+	if isStored, _ := store.IsTokenStored("expired"); isStored {
+		t.Error("Expected the expired token to no longer be stored")
+	}
+}
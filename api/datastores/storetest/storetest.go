@@ -0,0 +1,1630 @@
+// Package storetest is a conformance suite for the store interfaces of the datastores package. Every backend is run
+// against the same tests, which proves that the backends behave the same way
+package storetest
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mangoslicer/answer-patch/apierrors"
+	"github.com/mangoslicer/answer-patch/datastores"
+	"github.com/mangoslicer/answer-patch/datastores/fixtures"
+	"github.com/mangoslicer/answer-patch/models"
+	"github.com/mangoslicer/answer-patch/settings"
+)
+
+// Backend creates stores that hold exactly the fixtures. It is called once per test, so no test observes the changes of another
+type Backend func(t *testing.T) *datastores.Stores
+
+/**
+ * Fixtures that the tests refer to
+ */
+var (
+	tester1 = fixtures.Users[0]
+	tester2 = fixtures.Users[1]
+	tester3 = fixtures.Users[2]
+	tester4 = fixtures.Users[3]
+	tester5 = fixtures.Users[4]
+	tester6 = fixtures.Users[5]
+
+	gains      = fixtures.Categories[0]
+	cityDining = fixtures.Categories[1]
+
+	squatQuestion   = fixtures.Questions[0] // Has no current answer, nor any qualified answer
+	sushiQuestion   = fixtures.Questions[1] // Has a current answer that remains the best answer
+	jordanQuestion  = fixtures.Questions[2] // Has no free answer slots, and a current answer that ties with another answer
+	legDayQuestion  = fixtures.Questions[3] // Has a current answer that is outvoted by another qualified answer
+	grouponQuestion = fixtures.Questions[4] // Has no current answer, but one qualified answer
+	ballQuestion    = fixtures.Questions[5] // Has no current answer, but two qualified answers that tie
+
+	squatAnswer      = fixtures.Answers[0] // Answer by tester5 to squatQuestion
+	sushiOtherAnswer = fixtures.Answers[4] // Only answer to sushiQuestion besides its current answer
+	jordanAnswer     = fixtures.Answers[5] // Current answer of jordanQuestion
+	jordanTiedAnswer = fixtures.Answers[6] // Answer to jordanQuestion that ties with jordanAnswer
+)
+
+const unknownID = "00000000-0000-4000-8000-000000000000"
+
+// Every listing of the fixtures fits on a page of this size
+var firstPage = models.PageRequest{Size: 10}
+
+// Run runs the tests of every store interface
+func Run(t *testing.T, backend Backend) {
+	t.Run("QuestionStore", func(t *testing.T) { RunQuestionStoreTests(t, backend) })
+	t.Run("AnswerStore", func(t *testing.T) { RunAnswerStoreTests(t, backend) })
+	t.Run("UserStore", func(t *testing.T) { RunUserStoreTests(t, backend) })
+	t.Run("CategoryStore", func(t *testing.T) { RunCategoryStoreTests(t, backend) })
+	t.Run("RoleStore", func(t *testing.T) { RunRoleStoreTests(t, backend) })
+	t.Run("ModerationStore", func(t *testing.T) { RunModerationStoreTests(t, backend) })
+	t.Run("SearchStore", func(t *testing.T) { RunSearchStoreTests(t, backend) })
+	t.Run("RepStore", func(t *testing.T) { RunRepStoreTests(t, backend) })
+	t.Run("TokenStore", func(t *testing.T) { RunTokenStoreTests(t, backend) })
+	t.Run("TokenSet", func(t *testing.T) { RunTokenSetTests(t, backend) })
+	t.Run("TokenCounter", func(t *testing.T) { RunTokenCounterTests(t, backend) })
+	t.Run("TokenBucket", func(t *testing.T) { RunTokenBucketTests(t, backend) })
+}
+
+func RunQuestionStoreTests(t *testing.T, backend Backend) {
+
+	t.Run("FindPostByID", func(t *testing.T) {
+		stores := backend(t)
+
+		question, answer, err := stores.Questions.FindPostByID(sushiQuestion.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if question.Title != sushiQuestion.Title || question.Username != tester1.Username || question.Category != "City Dining" || question.PendingCount != sushiQuestion.PendingCount {
+			t.Errorf("Expected the sushi question, but recieved %+v", question)
+		}
+		if answer == nil || answer.UserID != tester4.ID || answer.Username != tester4.Username || !answer.IsCurrentAnswer {
+			t.Errorf("Expected the current answer of %s, but recieved %+v", tester4.Username, answer)
+		}
+	})
+
+	t.Run("FindPostByIDWithoutCurrentAnswer", func(t *testing.T) {
+		stores := backend(t)
+
+		question, answer, err := stores.Questions.FindPostByID(squatQuestion.ID)
+		if err != nil {
+			t.Fatal(err)
+		} else if question.ID != squatQuestion.ID || answer != nil {
+			t.Errorf("Expected only the squat question, but recieved %+v and %+v", question, answer)
+		}
+	})
+
+	t.Run("FindPostByIDWithUnknownID", func(t *testing.T) {
+		stores := backend(t)
+
+		_, _, err := stores.Questions.FindPostByID(unknownID)
+		expectCode(t, err, apierrors.CodeQuestionNotFound)
+
+		_, _, err = stores.Questions.FindPostByID("38681976")
+		expectCode(t, err, apierrors.CodeMalformedID)
+	})
+
+	t.Run("FindQuestionsByFilter", func(t *testing.T) {
+		stores := backend(t)
+
+		filterTests := []struct {
+			filter, val string
+			expectedIDs []string
+		}{
+			{"posted-by", tester1.Username, []string{sushiQuestion.ID, squatQuestion.ID}},
+			{"category", gains.Name, []string{legDayQuestion.ID, squatQuestion.ID}},
+			{"answered-by", tester4.Username, []string{sushiQuestion.ID}},
+		}
+
+		for _, ft := range filterTests {
+			questions, err := stores.Questions.FindQuestionsByFilter(ft.filter, ft.val, firstPage)
+			if err != nil {
+				t.Errorf("%s %s: %v", ft.filter, ft.val, err)
+				continue
+			}
+			if ids := questionIDs(questions.Items); !equalIDs(ids, ft.expectedIDs) {
+				t.Errorf("Expected the questions.Items %s %s to be %v, but recieved %v", ft.filter, ft.val, ft.expectedIDs, ids)
+			}
+		}
+
+		// Filters that no question satisfies list an empty page, like the pages past the end of a list
+		if questions, err := stores.Questions.FindQuestionsByFilter("posted-by", "nobody", firstPage); err != nil {
+			t.Fatal(err)
+		} else if questions.Items == nil || len(questions.Items) != 0 || questions.Next != "" || questions.Prev != "" {
+			t.Errorf("Expected an empty page, but recieved %+v", questions)
+		}
+	})
+
+	t.Run("SortQuestions", func(t *testing.T) {
+		stores := backend(t)
+
+		questions, err := stores.Questions.SortQuestions("question", "upvotes", "desc", firstPage)
+		if err != nil {
+			t.Fatal(err)
+		} else if len(questions.Items) != len(fixtures.Questions) {
+			t.Fatalf("Expected %d questions, but recieved %d", len(fixtures.Questions), len(questions.Items))
+		}
+		for i := 1; i < len(questions.Items); i++ {
+			if questions.Items[i-1].Upvotes < questions.Items[i].Upvotes {
+				t.Errorf("Expected the questions to be sorted by descending upvotes, but recieved %d before %d", questions.Items[i-1].Upvotes, questions.Items[i].Upvotes)
+			}
+		}
+
+		questions, err = stores.Questions.SortQuestions("answer", "upvotes", "asc", firstPage)
+		if err != nil {
+			t.Fatal(err)
+		} else if ids := questionIDs(questions.Items); !equalIDs(ids, []string{jordanQuestion.ID, sushiQuestion.ID, legDayQuestion.ID}) {
+			// The current answers of the sushi and leg day questions tie, so they are ordered by their ids
+			t.Errorf("Expected the questions with current answers sorted by the upvotes of their answers, but recieved %v", questionIDs(questions.Items))
+		}
+
+		_, err = stores.Questions.SortQuestions("question", "views", "desc", firstPage)
+		expectCode(t, err, apierrors.CodeInvalidSortCriteria)
+	})
+
+	t.Run("SortQuestionsWithCursors", func(t *testing.T) {
+		stores := backend(t)
+
+		all, err := stores.Questions.SortQuestions("question", "upvotes", "desc", firstPage)
+		if err != nil {
+			t.Fatal(err)
+		} else if all.Next != "" || all.Prev != "" {
+			t.Errorf("Expected a page that holds every question to lack cursors, but recieved %+v", all.Cursors)
+		}
+
+		// Following the next cursors visits every question once, in the same order as a single page
+		var ids []string
+		var pages []*models.QuestionPage
+		page := models.PageRequest{Size: 2}
+		for {
+			questions, err := stores.Questions.SortQuestions("question", "upvotes", "desc", page)
+			if err != nil {
+				t.Fatal(err)
+			} else if len(questions.Items) > page.Size {
+				t.Fatalf("Expected at most %d questions, but recieved %d", page.Size, len(questions.Items))
+			}
+			ids = append(ids, questionIDs(questions.Items)...)
+			pages = append(pages, questions)
+			if questions.Next == "" {
+				break
+			}
+			page.Cursor = questions.Next
+		}
+		if expected := questionIDs(all.Items); !equalIDs(ids, expected) {
+			t.Errorf("Expected the pages to list %v, but recieved %v", expected, ids)
+		}
+		if pages[0].Prev != "" {
+			t.Errorf("Expected the first page to lack a prev cursor, but recieved %s", pages[0].Prev)
+		}
+
+		// The prev cursor of the last page leads back to the page before it
+		last := len(pages) - 1
+		questions, err := stores.Questions.SortQuestions("question", "upvotes", "desc", models.PageRequest{Cursor: pages[last].Prev, Size: 2})
+		if err != nil {
+			t.Fatal(err)
+		} else if ids, expected := questionIDs(questions.Items), questionIDs(pages[last-1].Items); !equalIDs(ids, expected) {
+			t.Errorf("Expected the prev cursor to lead to %v, but recieved %v", expected, ids)
+		} else if questions.Next != pages[last-1].Next {
+			t.Errorf("Expected the page before the last to lead to the last page again")
+		}
+
+		// Questions that are voted past the cursor are left out of the next pages, without shifting the other questions of those pages
+		first, err := stores.Questions.SortQuestions("question", "upvotes", "desc", models.PageRequest{Size: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, voterID := range []string{tester2.ID, tester3.ID, tester4.ID} {
+			if _, _, err = stores.Questions.CastVote(squatQuestion.ID, "gains", voterID, 1); err != nil {
+				t.Fatal(err)
+			}
+		}
+		rest, err := stores.Questions.SortQuestions("question", "upvotes", "desc", models.PageRequest{Cursor: first.Next, Size: len(fixtures.Questions)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var expected []string
+		for _, question := range all.Items[len(first.Items):] {
+			if question.ID != squatQuestion.ID {
+				expected = append(expected, question.ID)
+			}
+		}
+		if ids := questionIDs(rest.Items); !equalIDs(ids, expected) {
+			t.Errorf("Expected the next pages to list %v, but recieved %v", expected, ids)
+		}
+
+		// Cursors are only accepted by the listing that issued them
+		_, err = stores.Questions.SortQuestions("question", "upvotes", "asc", models.PageRequest{Cursor: first.Next, Size: 2})
+		expectCode(t, err, apierrors.CodeInvalidField)
+		_, err = stores.Questions.SortQuestions("question", "upvotes", "desc", models.PageRequest{Cursor: "bm90LWEtY3Vyc29y", Size: 2})
+		expectCode(t, err, apierrors.CodeInvalidField)
+	})
+
+	t.Run("QueryQuestions", func(t *testing.T) {
+		stores := backend(t)
+
+		yes, no := true, false
+		minUpvotes := 14
+
+		queryTests := []struct {
+			name        string
+			query       models.QuestionQuery
+			expectedIDs []string
+		}{
+			{"categories", models.QuestionQuery{Categories: []string{"Gains", "balling"}, Sort: "upvotes", Descending: true}, []string{legDayQuestion.ID, squatQuestion.ID, ballQuestion.ID, jordanQuestion.ID}},
+			{"answered", models.QuestionQuery{Answered: &yes, Sort: "upvotes", Descending: true}, []string{legDayQuestion.ID, sushiQuestion.ID, jordanQuestion.ID}},
+			{"unanswered", models.QuestionQuery{Answered: &no, Sort: "upvotes"}, []string{grouponQuestion.ID, ballQuestion.ID, squatQuestion.ID}},
+			{"author and upvotes", models.QuestionQuery{Author: tester1.Username, MinUpvotes: &minUpvotes, Sort: "date"}, []string{sushiQuestion.ID}},
+			{"pending", models.QuestionQuery{Sort: "pending", Descending: true}, []string{jordanQuestion.ID, legDayQuestion.ID, sushiQuestion.ID, ballQuestion.ID, squatQuestion.ID, grouponQuestion.ID}},
+			{"unanswered in a category from the last week", models.QuestionQuery{Categories: []string{"city dining"}, Answered: &no, From: time.Now().AddDate(0, 0, -7), Sort: "pending", Descending: true}, []string{grouponQuestion.ID}},
+			{"future", models.QuestionQuery{From: time.Now().Add(time.Hour), Sort: "date"}, []string{}},
+			{"before", models.QuestionQuery{To: time.Now().Add(-time.Hour), Sort: "date"}, []string{}},
+			{"without pending answers", models.QuestionQuery{HasPending: &no, Sort: "date"}, []string{}},
+		}
+
+		for _, qt := range queryTests {
+			questions, err := stores.Questions.QueryQuestions(&qt.query, firstPage)
+			if err != nil {
+				t.Errorf("%s: %v", qt.name, err)
+				continue
+			}
+			if ids := questionIDs(questions.Items); !equalIDs(ids, qt.expectedIDs) {
+				t.Errorf("Expected the questions of %s to be %v, but recieved %v", qt.name, qt.expectedIDs, ids)
+			}
+		}
+
+		// Removing the only pending answer of the sushi question leaves it without pending answers
+		if _, _, err := stores.Moderation.RemovePost(models.PostRef{Type: models.PostAnswer, ID: sushiOtherAnswer.ID, Category: "city dining"}, tester2.ID, "Spam"); err != nil {
+			t.Fatal(err)
+		}
+		if questions, err := stores.Questions.QueryQuestions(&models.QuestionQuery{HasPending: &no, Sort: "date"}, firstPage); err != nil {
+			t.Fatal(err)
+		} else if ids := questionIDs(questions.Items); !equalIDs(ids, []string{sushiQuestion.ID}) {
+			t.Errorf("Expected only the sushi question to lack pending answers, but recieved %v", ids)
+		}
+
+		// Cursors page through the filtered questions
+		query := &models.QuestionQuery{Categories: []string{"gains", "balling"}, Sort: "upvotes", Descending: true}
+		first, err := stores.Questions.QueryQuestions(query, models.PageRequest{Size: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		second, err := stores.Questions.QueryQuestions(query, models.PageRequest{Cursor: first.Next, Size: 2})
+		if err != nil {
+			t.Fatal(err)
+		} else if ids := questionIDs(second.Items); !equalIDs(ids, []string{ballQuestion.ID, jordanQuestion.ID}) || second.Next != "" || second.Prev == "" {
+			t.Errorf("Expected the second page to hold the ball and jordan questions, but recieved %v with %+v", ids, second.Cursors)
+		}
+
+		_, err = stores.Questions.QueryQuestions(&models.QuestionQuery{Sort: "upvotes"}, models.PageRequest{Cursor: first.Next, Size: 2})
+		expectCode(t, err, apierrors.CodeInvalidField)
+
+		_, err = stores.Questions.QueryQuestions(&models.QuestionQuery{Sort: "views"}, firstPage)
+		expectCode(t, err, apierrors.CodeInvalidSortCriteria)
+	})
+
+	t.Run("StoreQuestion", func(t *testing.T) {
+		stores := backend(t)
+
+		err := stores.Questions.StoreQuestion(tester2.ID, gains.ID, "Is creatine safe?", "Asking for a friend", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		questions, err := stores.Questions.FindQuestionsByFilter("posted-by", tester2.Username, firstPage)
+		if err != nil {
+			t.Fatal(err)
+		} else if len(questions.Items) != 1 || questions.Items[0].Title != "Is creatine safe?" || questions.Items[0].Upvotes != 0 {
+			t.Errorf("Expected the stored question, but recieved %+v", questions.Items)
+		}
+
+		err = stores.Questions.StoreQuestion(tester2.ID, gains.ID, squatQuestion.Title, "", "")
+		expectKind(t, err, apierrors.KindConflict)
+
+		err = stores.Questions.StoreQuestion(tester2.ID, unknownID, "Is rest day a myth?", "", "")
+		expectCode(t, err, apierrors.CodeReferenceNotFound)
+	})
+
+	t.Run("StoreQuestionAsDuplicate", func(t *testing.T) {
+		stores := backend(t)
+
+		err := stores.Questions.StoreQuestion(tester2.ID, cityDining.ID, "Where is the best sushi restaurant?", "", sushiQuestion.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		questions, err := stores.Questions.FindQuestionsByFilter("posted-by", tester2.Username, firstPage)
+		if err != nil {
+			t.Fatal(err)
+		} else if len(questions.Items) != 1 || questions.Items[0].DuplicateOf != sushiQuestion.ID {
+			t.Errorf("Expected the stored question to be linked to the sushi question, but recieved %+v", questions.Items)
+		}
+
+		// Questions are only linked to existing questions of their own category
+		err = stores.Questions.StoreQuestion(tester2.ID, cityDining.ID, "Is sushi overrated?", "", squatQuestion.ID)
+		expectCode(t, err, apierrors.CodeInvalidField)
+
+		err = stores.Questions.StoreQuestion(tester2.ID, cityDining.ID, "Is sushi overrated?", "", unknownID)
+		expectCode(t, err, apierrors.CodeInvalidField)
+	})
+
+	t.Run("FindSimilarQuestions", func(t *testing.T) {
+		stores := backend(t)
+
+		candidates, err := stores.Questions.FindSimilarQuestions(cityDining.ID, "Where is the best sushi restaurant?", "")
+		if err != nil {
+			t.Fatal(err)
+		} else if len(candidates) != 1 || candidates[0].QuestionID != sushiQuestion.ID || candidates[0].Username != tester1.Username || candidates[0].Score < 0.55 || candidates[0].Score >= 1 {
+			t.Errorf("Expected the sushi question as the only candidate, but recieved %+v", candidates)
+		}
+
+		// Only questions of the same category are compared
+		if candidates, err = stores.Questions.FindSimilarQuestions(gains.ID, "Where is the best sushi restaurant?", ""); err != nil {
+			t.Fatal(err)
+		} else if len(candidates) != 0 {
+			t.Errorf("Expected no candidates in %s, but recieved %+v", gains.Name, candidates)
+		}
+
+		// Titles that only share some words are not similar enough by themselves, but similar contents make up for it
+		if candidates, err = stores.Questions.FindSimilarQuestions(cityDining.ID, "What is the best ramen place?", ""); err != nil {
+			t.Fatal(err)
+		} else if len(candidates) != 0 {
+			t.Errorf("Expected no candidates for the ramen question, but recieved %+v", candidates)
+		}
+		if candidates, err = stores.Questions.FindSimilarQuestions(cityDining.ID, "What is the best ramen place?", sushiQuestion.Content); err != nil {
+			t.Fatal(err)
+		} else if len(candidates) != 1 || candidates[0].QuestionID != sushiQuestion.ID {
+			t.Errorf("Expected the sushi question as the only candidate, but recieved %+v", candidates)
+		}
+	})
+
+	t.Run("CastVote", func(t *testing.T) {
+		stores := backend(t)
+
+		voteTests := []struct {
+			vote, expectedChange int
+		}{
+			{1, 1},
+			{1, 0}, // Voting again replaces the vote rather than adding to it
+			{-1, -2},
+		}
+
+		for _, vt := range voteTests {
+			authorID, change, err := stores.Questions.CastVote(squatQuestion.ID, "gains", tester2.ID, vt.vote)
+			if err != nil {
+				t.Fatal(err)
+			} else if authorID != tester1.ID || change != vt.expectedChange {
+				t.Errorf("Expected a vote of %d to change the upvotes of %s's question by %d, but recieved a change of %d on %s's question", vt.vote, tester1.ID, vt.expectedChange, change, authorID)
+			}
+		}
+
+		question, _, err := stores.Questions.FindPostByID(squatQuestion.ID)
+		if err != nil {
+			t.Fatal(err)
+		} else if question.Upvotes != squatQuestion.Upvotes-1 {
+			t.Errorf("Expected %d upvotes, but recieved %d", squatQuestion.Upvotes-1, question.Upvotes)
+		}
+
+		_, _, err = stores.Questions.CastVote(squatQuestion.ID, "gains", tester1.ID, 1)
+		expectCode(t, err, apierrors.CodeSelfVote)
+
+		_, _, err = stores.Questions.CastVote(unknownID, "gains", tester2.ID, 1)
+		expectCode(t, err, apierrors.CodeQuestionNotFound)
+
+		// The vote is credited to rep in the category of the route, so questions of other categories are not found there
+		_, _, err = stores.Questions.CastVote(squatQuestion.ID, "balling", tester3.ID, 1)
+		expectCode(t, err, apierrors.CodeQuestionNotFound)
+	})
+}
+
+func RunAnswerStoreTests(t *testing.T, backend Backend) {
+
+	t.Run("IsAnswerSlotAvailable", func(t *testing.T) {
+		stores := backend(t)
+
+		slotTests := []struct {
+			questionID string
+			expected   bool
+		}{
+			{squatQuestion.ID, true},
+			{jordanQuestion.ID, false}, // Holds the maximum of 5 pending answers
+		}
+
+		for _, st := range slotTests {
+			isSlotAvailable, err := stores.Answers.IsAnswerSlotAvailable(st.questionID)
+			if err != nil {
+				t.Error(err)
+			} else if isSlotAvailable != st.expected {
+				t.Errorf("Expected the availability of an answer slot of %s to be %t", st.questionID, st.expected)
+			}
+		}
+
+		_, err := stores.Answers.IsAnswerSlotAvailable(unknownID)
+		expectCode(t, err, apierrors.CodeQuestionNotFound)
+	})
+
+	t.Run("IsAnswerSlotAvailableAfterStoreAnswer", func(t *testing.T) {
+		stores := backend(t)
+
+		if settings.Get().Rules.MaxPendingAnswers != 5 {
+			t.Skip("The fixtures assume the default cap of 5 pending answers")
+		}
+
+		// The squat question holds 1 pending answer, so 4 more answers fill its slots
+		for i, user := range []fixtures.User{tester2, tester3, tester4, tester6} {
+			isSlotAvailable, err := stores.Answers.IsAnswerSlotAvailable(squatQuestion.ID)
+			if err != nil {
+				t.Fatal(err)
+			} else if !isSlotAvailable {
+				t.Fatalf("Expected a free answer slot after %d answers", i)
+			}
+			if err = stores.Answers.StoreAnswer(squatQuestion.ID, user.ID, "Answer "+strconv.Itoa(i), 15); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		isSlotAvailable, err := stores.Answers.IsAnswerSlotAvailable(squatQuestion.ID)
+		if err != nil {
+			t.Fatal(err)
+		} else if isSlotAvailable {
+			t.Error("Expected the answer slots to be full after 5 pending answers")
+		}
+	})
+
+	t.Run("StoreAnswer", func(t *testing.T) {
+		stores := backend(t)
+
+		err := stores.Answers.StoreAnswer(squatQuestion.ID, tester2.ID, "Two to one", 15)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		question, _, err := stores.Questions.FindPostByID(squatQuestion.ID)
+		if err != nil {
+			t.Fatal(err)
+		} else if question.PendingCount != squatQuestion.PendingCount+1 {
+			t.Errorf("Expected %d pending answers, but recieved %d", squatQuestion.PendingCount+1, question.PendingCount)
+		}
+
+		err = stores.Answers.StoreAnswer(squatQuestion.ID, tester2.ID, "Two to one", 15)
+		expectCode(t, err, apierrors.CodeDuplicateAnswer)
+
+		err = stores.Answers.StoreAnswer(unknownID, tester2.ID, "Two to one", 15)
+		expectCode(t, err, apierrors.CodeReferenceNotFound)
+	})
+
+	t.Run("FindVotesWithCursors", func(t *testing.T) {
+		stores := backend(t)
+
+		// The first voter backs an upvote of every answer that has one, and cast them all at once, so the pages are told apart by answer ids
+		voter, err := stores.Users.FindUser("username", fixtures.VoterUsername(1))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		all, err := stores.Answers.FindVotes(voter.ID, "", models.PageRequest{Size: len(fixtures.Answers)})
+		if err != nil {
+			t.Fatal(err)
+		} else if len(all.Items) < 3 {
+			t.Fatalf("Expected the voter to hold several votes, but recieved %+v", all.Items)
+		}
+
+		var ids []string
+		page := models.PageRequest{Size: 2}
+		for {
+			votes, err := stores.Answers.FindVotes(voter.ID, "", page)
+			if err != nil {
+				t.Fatal(err)
+			} else if len(votes.Items) > page.Size {
+				t.Fatalf("Expected at most %d votes, but recieved %d", page.Size, len(votes.Items))
+			}
+			for _, vote := range votes.Items {
+				ids = append(ids, vote.AnswerID)
+			}
+			if votes.Next == "" {
+				break
+			}
+			page.Cursor = votes.Next
+		}
+
+		var expected []string
+		for _, vote := range all.Items {
+			expected = append(expected, vote.AnswerID)
+		}
+		if !equalIDs(ids, expected) {
+			t.Errorf("Expected the pages to list %v, but recieved %v", expected, ids)
+		}
+
+		_, err = stores.Answers.FindVotes(voter.ID, "", models.PageRequest{Cursor: "bm90LWEtY3Vyc29y", Size: 2})
+		expectCode(t, err, apierrors.CodeInvalidField)
+	})
+
+	t.Run("CastAndRetractVote", func(t *testing.T) {
+		stores := backend(t)
+
+		answer, change, err := stores.Answers.CastVote(squatAnswer.ID, "gains", tester1.ID, 1)
+		if err != nil {
+			t.Fatal(err)
+		} else if change != 1 || answer.UserID != tester5.ID || answer.QuestionID != squatQuestion.ID {
+			t.Errorf("Expected a change of 1 on %s's answer to %s, but recieved a change of %d on %+v", tester5.ID, squatQuestion.ID, change, answer)
+		}
+
+		if _, change, err = stores.Answers.CastVote(squatAnswer.ID, "gains", tester1.ID, -1); err != nil {
+			t.Fatal(err)
+		} else if change != -2 {
+			t.Errorf("Expected switching the vote to change the upvotes by -2, but recieved %d", change)
+		}
+
+		votes, err := stores.Answers.FindVotes(tester1.ID, squatQuestion.ID, firstPage)
+		if err != nil {
+			t.Fatal(err)
+		} else if len(votes.Items) != 1 || votes.Items[0].AnswerID != squatAnswer.ID || votes.Items[0].Vote != -1 {
+			t.Errorf("Expected a single downvote on %s, but recieved %+v", squatAnswer.ID, votes.Items)
+		}
+
+		if _, change, err = stores.Answers.RetractVote(squatAnswer.ID, "gains", tester1.ID); err != nil {
+			t.Fatal(err)
+		} else if change != 1 {
+			t.Errorf("Expected retracting the downvote to change the upvotes by 1, but recieved %d", change)
+		}
+
+		if _, change, err = stores.Answers.RetractVote(squatAnswer.ID, "gains", tester1.ID); err != nil {
+			t.Fatal(err)
+		} else if change != 0 {
+			t.Errorf("Expected retracting a missing vote to change nothing, but recieved %d", change)
+		}
+
+		votes, err = stores.Answers.FindVotes(tester1.ID, "", firstPage)
+		if err != nil {
+			t.Fatal(err)
+		} else if len(votes.Items) != 0 {
+			t.Errorf("Expected no votes, but recieved %+v", votes.Items)
+		}
+
+		_, _, err = stores.Answers.CastVote(squatAnswer.ID, "gains", tester5.ID, 1)
+		expectCode(t, err, apierrors.CodeSelfVote)
+
+		_, _, err = stores.Answers.CastVote(unknownID, "gains", tester1.ID, 1)
+		expectCode(t, err, apierrors.CodeAnswerNotFound)
+
+		// The vote is credited to rep in the category of the route, so answers to questions of other categories are not found there
+		_, _, err = stores.Answers.CastVote(squatAnswer.ID, "balling", tester1.ID, 1)
+		expectCode(t, err, apierrors.CodeAnswerNotFound)
+		_, _, err = stores.Answers.RetractVote(squatAnswer.ID, "balling", tester1.ID)
+		expectCode(t, err, apierrors.CodeAnswerNotFound)
+	})
+
+	t.Run("AssessAnswers", func(t *testing.T) {
+
+		assessTests := []struct {
+			name                  string
+			questionID            string
+			expectedCurrentUserID string // Empty if the question should not have a current answer
+		}{
+			{"WithNoQualifiedCurrentAnswers", squatQuestion.ID, ""},
+			{"WithNewlyQualifiedCurrentAnswer", grouponQuestion.ID, tester4.ID},
+			{"WithNoNewCurrentAnswer", sushiQuestion.ID, tester4.ID},
+			{"WithNewCurrentAnswer", legDayQuestion.ID, tester3.ID},
+			{"WithSameUpvotesAndCurrentAnswer", jordanQuestion.ID, tester6.ID},
+			{"WithSameUpvotesAndNoCurrentAnswer", ballQuestion.ID, tester1.ID}, // The answer that was submitted first wins the tie
+		}
+
+		for _, at := range assessTests {
+			t.Run(at.name, func(t *testing.T) {
+				stores := backend(t)
+
+				if err := stores.Answers.AssessAnswers(at.questionID); err != nil {
+					t.Fatal(err)
+				}
+
+				_, answer, err := stores.Questions.FindPostByID(at.questionID)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				var currentUserID string
+				if answer != nil {
+					currentUserID = answer.UserID
+				}
+				if currentUserID != at.expectedCurrentUserID {
+					t.Errorf("Expected the current answer of %s to be posted by %q, but it was posted by %q", at.questionID, at.expectedCurrentUserID, currentUserID)
+				}
+			})
+		}
+	})
+
+	t.Run("FindRevisionsWithCursors", func(t *testing.T) {
+		stores := backend(t)
+
+		// Rolling back to the first revision records the same answer as the second
+		if err := stores.Answers.AssessAnswers(legDayQuestion.ID); err != nil {
+			t.Fatal(err)
+		}
+		if err := stores.Revisions.RollbackToRevision(legDayQuestion.ID, "gains", 1); err != nil {
+			t.Fatal(err)
+		}
+
+		first, err := stores.Revisions.FindRevisions(legDayQuestion.ID, models.PageRequest{Size: 1})
+		if err != nil {
+			t.Fatal(err)
+		} else if len(first.Items) != 1 || first.Items[0].Revision != 1 || first.Next == "" || first.Prev != "" {
+			t.Fatalf("Expected the first revision and a next cursor, but recieved %+v", first)
+		}
+
+		second, err := stores.Revisions.FindRevisions(legDayQuestion.ID, models.PageRequest{Cursor: first.Next, Size: 1})
+		if err != nil {
+			t.Fatal(err)
+		} else if len(second.Items) != 1 || second.Items[0].Revision != 2 || second.Next != "" || second.Prev == "" {
+			t.Errorf("Expected the second revision to end the history, but recieved %+v", second)
+		}
+
+		_, err = stores.Revisions.FindRevisions(legDayQuestion.ID, models.PageRequest{Cursor: "bm90LWEtY3Vyc29y", Size: 1})
+		expectCode(t, err, apierrors.CodeInvalidField)
+	})
+
+	t.Run("AssessAnswersRecordsRevision", func(t *testing.T) {
+		stores := backend(t)
+
+		if err := stores.Answers.AssessAnswers(legDayQuestion.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		revisions, err := stores.Revisions.FindRevisions(legDayQuestion.ID, firstPage)
+		if err != nil {
+			t.Fatal(err)
+		} else if len(revisions.Items) != 1 || revisions.Items[0].Revision != 1 || revisions.Items[0].UserID != tester3.ID {
+			t.Errorf("Expected the promotion of %s's answer to be recorded as the first revision, but recieved %+v", tester3.ID, revisions.Items)
+		}
+
+		// Questions that were not assessed must be left untouched
+		question, _, err := stores.Questions.FindPostByID(squatQuestion.ID)
+		if err != nil {
+			t.Fatal(err)
+		} else if question.EditCount != squatQuestion.EditCount || question.PendingCount != squatQuestion.PendingCount {
+			t.Errorf("Expected the squat question to be unchanged, but recieved %+v", question)
+		}
+	})
+}
+
+func RunUserStoreTests(t *testing.T, backend Backend) {
+
+	t.Run("FindUser", func(t *testing.T) {
+		stores := backend(t)
+
+		user, err := stores.Users.FindUser("username", tester1.Username)
+		if err != nil {
+			t.Fatal(err)
+		} else if user.ID != tester1.ID || user.HashedPassword != tester1.HashedPassword {
+			t.Errorf("Expected %+v, but recieved %+v", tester1, user)
+		}
+
+		user, err = stores.Users.FindUser("id", tester2.ID)
+		if err != nil {
+			t.Fatal(err)
+		} else if user.Username != tester2.Username {
+			t.Errorf("Expected %s, but recieved %s", tester2.Username, user.Username)
+		}
+
+		_, err = stores.Users.FindUser("username", "nobody")
+		if !errors.Is(err, datastores.ErrUserNotFound) {
+			t.Errorf("Expected ErrUserNotFound, but recieved %v", err)
+		}
+	})
+
+	t.Run("StoreUser", func(t *testing.T) {
+		stores := backend(t)
+
+		if err := stores.Users.StoreUser("Tester7", "$2a$10$Tester7", "tester7@example.com"); err != nil {
+			t.Fatal(err)
+		}
+
+		user, err := stores.Users.FindUser("username", "Tester7")
+		if err != nil {
+			t.Fatal(err)
+		} else if user.ID == "" || user.CreatedAt.IsZero() {
+			t.Errorf("Expected the stored user to be assigned an id and a creation time, but recieved %+v", user)
+		} else if user.Email != "tester7@example.com" || user.EmailVerified {
+			t.Errorf("Expected the stored user to have an unverified email, but recieved %+v", user)
+		}
+	})
+
+	t.Run("StoreUserWithDuplicateEmail", func(t *testing.T) {
+		stores := backend(t)
+
+		if err := stores.Users.StoreUser("Tester7", "$2a$10$Tester7", "tester7@example.com"); err != nil {
+			t.Fatal(err)
+		}
+
+		err := stores.Users.StoreUser("Tester8", "$2a$10$Tester8", "tester7@example.com")
+		expectKind(t, err, apierrors.KindConflict)
+
+		var apiErr *apierrors.Error
+		if errors.As(err, &apiErr) && (len(apiErr.Fields) != 1 || apiErr.Fields[0].Field != "email") {
+			t.Errorf("Expected the email field to be reported, but recieved %+v", apiErr.Fields)
+		}
+	})
+
+	t.Run("VerifyEmail", func(t *testing.T) {
+		stores := backend(t)
+
+		if err := stores.Users.StoreUser("Tester7", "$2a$10$Tester7", "tester7@example.com"); err != nil {
+			t.Fatal(err)
+		}
+
+		user, err := stores.Users.FindUser("email", "tester7@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Verifying an email that is no longer the user's email must fail
+		if err = stores.Users.VerifyEmail(user.ID, "previous@example.com"); !errors.Is(err, datastores.ErrUserNotFound) {
+			t.Errorf("Expected ErrUserNotFound, but recieved %v", err)
+		}
+
+		if err = stores.Users.VerifyEmail(user.ID, "tester7@example.com"); err != nil {
+			t.Fatal(err)
+		}
+		if user, err = stores.Users.FindUser("id", user.ID); err != nil {
+			t.Fatal(err)
+		} else if !user.EmailVerified {
+			t.Errorf("Expected the email to be verified, but recieved %+v", user)
+		}
+	})
+
+	t.Run("UpdatePassword", func(t *testing.T) {
+		stores := backend(t)
+
+		// Argon2id hashes are longer than the char(60) that bcrypt hashes fit in
+		hash := "$argon2id$v=19$m=65536,t=1,p=4$c2FsdHNhbHRzYWx0c2FsdA$" + strings.Repeat("a", 43)
+		if err := stores.Users.UpdatePassword(tester1.ID, hash); err != nil {
+			t.Fatal(err)
+		}
+
+		user, err := stores.Users.FindUser("id", tester1.ID)
+		if err != nil {
+			t.Fatal(err)
+		} else if user.HashedPassword != hash {
+			t.Errorf("Expected the hashed password to be %s, but recieved %s", hash, user.HashedPassword)
+		}
+
+		if err = stores.Users.UpdatePassword(unknownID, hash+"b"); !errors.Is(err, datastores.ErrUserNotFound) {
+			t.Errorf("Expected ErrUserNotFound, but recieved %v", err)
+		}
+	})
+
+	t.Run("UpdateUsername", func(t *testing.T) {
+		stores := backend(t)
+
+		if err := stores.Users.UpdateUsername(tester1.ID, "Tester1Renamed"); err != nil {
+			t.Fatal(err)
+		}
+
+		user, err := stores.Users.FindUser("username", "Tester1Renamed")
+		if err != nil {
+			t.Fatal(err)
+		} else if user.ID != tester1.ID {
+			t.Errorf("Expected %s to be renamed, but recieved %+v", tester1.ID, user)
+		}
+
+		err = stores.Users.UpdateUsername(tester2.ID, "Tester1Renamed")
+		expectKind(t, err, apierrors.KindConflict)
+
+		if err = stores.Users.UpdateUsername(unknownID, "Nobody"); !errors.Is(err, datastores.ErrUserNotFound) {
+			t.Errorf("Expected ErrUserNotFound, but recieved %v", err)
+		}
+	})
+
+	t.Run("DeleteUser", func(t *testing.T) {
+		stores := backend(t)
+
+		if err := stores.Users.DeleteUser(tester1.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := stores.Users.FindUser("id", tester1.ID); !errors.Is(err, datastores.ErrUserNotFound) {
+			t.Errorf("Expected the deleted user to be gone, but recieved %v", err)
+		}
+
+		// The content of the deleted user is kept, but no longer attributed to them
+		question, answer, err := stores.Questions.FindPostByID(sushiQuestion.ID)
+		if err != nil {
+			t.Fatal(err)
+		} else if question.Username != datastores.DeletedUsername {
+			t.Errorf("Expected the question of the deleted user to be anonymized, but recieved %+v", question)
+		} else if answer == nil {
+			t.Error("Expected the answer of the question to be kept")
+		}
+
+		if err = stores.Users.DeleteUser(tester1.ID); !errors.Is(err, datastores.ErrUserNotFound) {
+			t.Errorf("Expected deleting a deleted user to fail with ErrUserNotFound, but recieved %v", err)
+		}
+		if err = stores.Users.DeleteUser(datastores.DeletedUserID); !errors.Is(err, datastores.ErrUserNotFound) {
+			t.Errorf("Expected the placeholder user to be undeletable, but recieved %v", err)
+		}
+	})
+
+	t.Run("DeleteUserKeepsVotes", func(t *testing.T) {
+		stores := backend(t)
+
+		// Voters are ordered by username, so the 41st to 45th voters back only the 5 upvotes that put tester3's answer ahead of the current answer
+		usernames := make([]string, fixtures.Voters)
+		for n := 1; n <= fixtures.Voters; n++ {
+			usernames[n-1] = fixtures.VoterUsername(n)
+		}
+		sort.Strings(usernames)
+
+		for _, username := range usernames[40:45] {
+			voter, err := stores.Users.FindUser("username", username)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = stores.Users.DeleteUser(voter.ID); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if err := stores.Answers.AssessAnswers(legDayQuestion.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		revisions, err := stores.Revisions.FindRevisions(legDayQuestion.ID, firstPage)
+		if err != nil {
+			t.Fatal(err)
+		} else if len(revisions.Items) != 1 || revisions.Items[0].UserID != tester3.ID {
+			t.Errorf("Expected the votes of the deleted voters to still count towards the answer of %s, but recieved %+v", tester3.ID, revisions.Items)
+		}
+	})
+
+	t.Run("LinkIdentity", func(t *testing.T) {
+		stores := backend(t)
+
+		if _, err := stores.Users.FindUserByIdentity("example", "subject-1"); !errors.Is(err, datastores.ErrUserNotFound) {
+			t.Errorf("Expected an unlinked identity to have no user, but recieved %v", err)
+		}
+
+		if err := stores.Users.LinkIdentity(tester1.ID, "example", "subject-1"); err != nil {
+			t.Fatal(err)
+		}
+		// Linking again is harmless, while linking the identity to another user is refused
+		if err := stores.Users.LinkIdentity(tester1.ID, "example", "subject-1"); err != nil {
+			t.Errorf("Expected relinking the identity to the same user to succeed, but recieved %v", err)
+		}
+		if err := stores.Users.LinkIdentity(tester2.ID, "example", "subject-1"); !errors.Is(err, datastores.ErrIdentityLinked) {
+			t.Errorf("Expected ErrIdentityLinked, but recieved %v", err)
+		}
+
+		// Subjects are only unique within their provider
+		if err := stores.Users.LinkIdentity(tester2.ID, "other", "subject-1"); err != nil {
+			t.Fatal(err)
+		}
+
+		user, err := stores.Users.FindUserByIdentity("example", "subject-1")
+		if err != nil {
+			t.Fatal(err)
+		} else if user.ID != tester1.ID {
+			t.Errorf("Expected the identity to be linked to %s, but recieved %+v", tester1.ID, user)
+		}
+
+		err = stores.Users.LinkIdentity(unknownID, "example", "subject-2")
+		expectCode(t, err, apierrors.CodeReferenceNotFound)
+
+		// Identities are unlinked along with the users they are linked to
+		if err = stores.Users.DeleteUser(tester1.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = stores.Users.FindUserByIdentity("example", "subject-1"); !errors.Is(err, datastores.ErrUserNotFound) {
+			t.Errorf("Expected the identity of a deleted user to be unlinked, but recieved %v", err)
+		}
+	})
+
+	t.Run("StoreUserWithDuplicateUsername", func(t *testing.T) {
+		stores := backend(t)
+
+		err := stores.Users.StoreUser(tester1.Username, "$2a$10$AnotherHash", "")
+		expectKind(t, err, apierrors.KindConflict)
+
+		var apiErr *apierrors.Error
+		if errors.As(err, &apiErr) && (len(apiErr.Fields) != 1 || apiErr.Fields[0].Field != "username") {
+			t.Errorf("Expected the username field to be reported, but recieved %+v", apiErr.Fields)
+		}
+	})
+}
+
+func RunCategoryStoreTests(t *testing.T, backend Backend) {
+
+	t.Run("FindCategoriesWithCursors", func(t *testing.T) {
+		stores := backend(t)
+
+		first, err := stores.Categories.FindCategories(models.PageRequest{Size: 2})
+		if err != nil {
+			t.Fatal(err)
+		} else if names := categoryNames(first.Items); strings.Join(names, "|") != "Balling|City Dining" || first.Next == "" || first.Prev != "" {
+			t.Fatalf("Expected the first two categories by name and a next cursor, but recieved %v and %+v", names, first.Cursors)
+		}
+
+		second, err := stores.Categories.FindCategories(models.PageRequest{Cursor: first.Next, Size: 2})
+		if err != nil {
+			t.Fatal(err)
+		} else if names := categoryNames(second.Items); strings.Join(names, "|") != "Gains" || second.Next != "" || second.Prev == "" {
+			t.Fatalf("Expected the last category and a prev cursor, but recieved %v and %+v", names, second.Cursors)
+		}
+
+		if prev, err := stores.Categories.FindCategories(models.PageRequest{Cursor: second.Prev, Size: 2}); err != nil {
+			t.Fatal(err)
+		} else if names := categoryNames(prev.Items); strings.Join(names, "|") != "Balling|City Dining" {
+			t.Errorf("Expected the prev cursor to lead back to the first page, but recieved %v", names)
+		}
+
+		_, err = stores.Categories.FindCategories(models.PageRequest{Cursor: "bm90LWEtY3Vyc29y", Size: 2})
+		expectCode(t, err, apierrors.CodeInvalidField)
+	})
+}
+
+func RunRoleStoreTests(t *testing.T, backend Backend) {
+
+	t.Run("SetRole", func(t *testing.T) {
+		stores := backend(t)
+
+		roles, err := stores.Roles.FindRoles(tester1.ID)
+		if err != nil {
+			t.Fatal(err)
+		} else if roles.Role != models.RoleUser || len(roles.CategoryRoles) != 0 || roles.Banned {
+			t.Errorf("Expected a user without granted roles to have the user role everywhere, but recieved %+v", roles)
+		}
+
+		if err = stores.Roles.SetRole(tester1.ID, "", models.RoleModerator); err != nil {
+			t.Fatal(err)
+		}
+		// Category names are matched case-insensitively and reported in lower case
+		if err = stores.Roles.SetRole(tester1.ID, "Gains", models.RoleModerator); err != nil {
+			t.Fatal(err)
+		}
+		// Granting another role in the same scope replaces the role
+		if err = stores.Roles.SetRole(tester1.ID, "", models.RoleAdmin); err != nil {
+			t.Fatal(err)
+		}
+
+		roles, err = stores.Roles.FindRoles(tester1.ID)
+		if err != nil {
+			t.Fatal(err)
+		} else if roles.Role != models.RoleAdmin || roles.CategoryRoles[strings.ToLower(gains.Name)] != models.RoleModerator || len(roles.CategoryRoles) != 1 {
+			t.Errorf("Expected the admin role everywhere and the moderator role in %s, but recieved %+v", gains.Name, roles)
+		}
+
+		grants, err := stores.Roles.FindRoleGrants(firstPage)
+		if err != nil {
+			t.Fatal(err)
+		} else if len(grants.Items) != 2 || grants.Items[0].UserID != tester1.ID || grants.Items[0].Username != tester1.Username {
+			t.Errorf("Expected both grants of %s to be listed, but recieved %+v", tester1.Username, grants.Items)
+		}
+
+		// Both grants of the user can be paged through one at a time
+		if first, err := stores.Roles.FindRoleGrants(models.PageRequest{Size: 1}); err != nil {
+			t.Fatal(err)
+		} else if len(first.Items) != 1 || first.Next == "" {
+			t.Errorf("Expected a single grant and a next cursor, but recieved %+v", first)
+		} else if second, err := stores.Roles.FindRoleGrants(models.PageRequest{Cursor: first.Next, Size: 1}); err != nil {
+			t.Fatal(err)
+		} else if len(second.Items) != 1 || second.Next != "" || second.Items[0].Category == first.Items[0].Category {
+			t.Errorf("Expected the other grant and no next cursor, but recieved %+v", second)
+		}
+
+		// Setting the user role revokes the role of its scope only
+		if err = stores.Roles.SetRole(tester1.ID, "", models.RoleUser); err != nil {
+			t.Fatal(err)
+		}
+		if roles, err = stores.Roles.FindRoles(tester1.ID); err != nil {
+			t.Fatal(err)
+		} else if roles.Role != models.RoleUser || roles.CategoryRoles[strings.ToLower(gains.Name)] != models.RoleModerator {
+			t.Errorf("Expected only the role everywhere to be revoked, but recieved %+v", roles)
+		}
+
+		err = stores.Roles.SetRole(tester1.ID, "nonexistent", models.RoleModerator)
+		expectCode(t, err, apierrors.CodeCategoryNotFound)
+
+		err = stores.Roles.SetRole(unknownID, "", models.RoleModerator)
+		expectCode(t, err, apierrors.CodeReferenceNotFound)
+
+		// Roles are revoked along with the users they were granted to
+		if err = stores.Users.DeleteUser(tester1.ID); err != nil {
+			t.Fatal(err)
+		}
+		if grants, err = stores.Roles.FindRoleGrants(firstPage); err != nil {
+			t.Fatal(err)
+		} else if len(grants.Items) != 0 {
+			t.Errorf("Expected the roles of a deleted user to be revoked, but recieved %+v", grants.Items)
+		}
+	})
+
+	t.Run("BanUser", func(t *testing.T) {
+		stores := backend(t)
+
+		if err := stores.Roles.BanUser(tester2.ID, tester1.ID, "spam"); err != nil {
+			t.Fatal(err)
+		}
+		// Banning again keeps the original ban
+		if err := stores.Roles.BanUser(tester2.ID, tester3.ID, "more spam"); err != nil {
+			t.Fatal(err)
+		}
+
+		if roles, err := stores.Roles.FindRoles(tester2.ID); err != nil {
+			t.Fatal(err)
+		} else if !roles.Banned {
+			t.Errorf("Expected %s to be banned, but recieved %+v", tester2.Username, roles)
+		}
+
+		if err := stores.Roles.UnbanUser(tester2.ID); err != nil {
+			t.Fatal(err)
+		}
+		if roles, err := stores.Roles.FindRoles(tester2.ID); err != nil {
+			t.Fatal(err)
+		} else if roles.Banned {
+			t.Errorf("Expected the ban of %s to be lifted, but recieved %+v", tester2.Username, roles)
+		}
+
+		err := stores.Roles.BanUser(unknownID, tester1.ID, "spam")
+		expectCode(t, err, apierrors.CodeReferenceNotFound)
+
+		_, err = stores.Roles.FindRoles("malformed")
+		expectCode(t, err, apierrors.CodeMalformedID)
+	})
+}
+
+func RunModerationStoreTests(t *testing.T, backend Backend) {
+
+	squatPost := models.PostRef{Type: models.PostQuestion, ID: squatQuestion.ID, Category: "gains"}
+
+	t.Run("FlagAndRemovePost", func(t *testing.T) {
+		stores := backend(t)
+
+		if err := stores.Moderation.FlagPost(squatPost, tester2.ID, models.FlagSpam, ""); err != nil {
+			t.Fatal(err)
+		}
+		if err := stores.Moderation.FlagPost(squatPost, tester3.ID, models.FlagAbusive, "Rude"); err != nil {
+			t.Fatal(err)
+		}
+
+		err := stores.Moderation.FlagPost(squatPost, tester2.ID, models.FlagOther, "")
+		expectCode(t, err, apierrors.CodeAlreadyFlagged)
+
+		// Posts are only found within the category they were posted in
+		err = stores.Moderation.FlagPost(models.PostRef{Type: models.PostQuestion, ID: squatQuestion.ID, Category: "balling"}, tester2.ID, models.FlagSpam, "")
+		expectCode(t, err, apierrors.CodeQuestionNotFound)
+
+		posts, err := stores.Moderation.FindFlaggedPosts("", firstPage)
+		if err != nil {
+			t.Fatal(err)
+		} else if len(posts.Items) != 1 || posts.Items[0].QuestionID != squatQuestion.ID || posts.Items[0].Type != models.PostQuestion || posts.Items[0].FlagCount != 2 || posts.Items[0].Reasons[models.FlagSpam] != 1 || posts.Items[0].Username != tester1.Username {
+			t.Errorf("Expected the squat question with 2 flags in the review queue, but recieved %+v", posts.Items)
+		}
+		if posts, err = stores.Moderation.FindFlaggedPosts("Balling", firstPage); err != nil {
+			t.Fatal(err)
+		} else if len(posts.Items) != 0 {
+			t.Errorf("Expected the review queue of Balling to be empty, but recieved %+v", posts.Items)
+		}
+
+		if _, _, err = stores.Moderation.RemovePost(squatPost, tester2.ID, "Spam"); err != nil {
+			t.Fatal(err)
+		}
+
+		// Removed questions are hidden everywhere
+		_, _, err = stores.Questions.FindPostByID(squatQuestion.ID)
+		expectCode(t, err, apierrors.CodeQuestionNotFound)
+
+		questions, err := stores.Questions.FindQuestionsByFilter("category", gains.Name, firstPage)
+		if err != nil {
+			t.Fatal(err)
+		} else if ids := questionIDs(questions.Items); !equalIDs(ids, []string{legDayQuestion.ID}) {
+			t.Errorf("Expected only the leg day question in %s, but recieved %v", gains.Name, ids)
+		}
+
+		questions, err = stores.Questions.SortQuestions("question", "upvotes", "desc", firstPage)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, question := range questions.Items {
+			if question.ID == squatQuestion.ID {
+				t.Error("Expected the removed squat question to be left out of the sorted questions")
+			}
+		}
+
+		if posts, err = stores.Moderation.FindFlaggedPosts("", firstPage); err != nil {
+			t.Fatal(err)
+		} else if len(posts.Items) != 0 {
+			t.Errorf("Expected the flags of a removed post to be resolved, but recieved %+v", posts.Items)
+		}
+
+		actions, err := stores.Moderation.FindModerationActions(firstPage)
+		if err != nil {
+			t.Fatal(err)
+		} else if len(actions.Items) != 1 || actions.Items[0].Action != models.ActionRemove || actions.Items[0].ModeratorUsername != tester2.Username || actions.Items[0].QuestionID != squatQuestion.ID || actions.Items[0].Reason != "Spam" {
+			t.Errorf("Expected the removal to be logged, but recieved %+v", actions.Items)
+		}
+
+		_, _, err = stores.Moderation.RemovePost(squatPost, tester2.ID, "Spam")
+		expectCode(t, err, apierrors.CodeQuestionNotFound)
+	})
+
+	t.Run("RemoveCurrentAnswer", func(t *testing.T) {
+		stores := backend(t)
+
+		questionID, wasCurrent, err := stores.Moderation.RemovePost(models.PostRef{Type: models.PostAnswer, ID: jordanAnswer.ID, Category: "Balling"}, tester2.ID, "Abusive")
+		if err != nil {
+			t.Fatal(err)
+		} else if questionID != jordanQuestion.ID || !wasCurrent {
+			t.Fatalf("Expected the removed answer to be reported as the current answer of the jordan question, but recieved %s and %t", questionID, wasCurrent)
+		}
+
+		if _, answer, err := stores.Questions.FindPostByID(jordanQuestion.ID); err != nil {
+			t.Fatal(err)
+		} else if answer != nil {
+			t.Errorf("Expected the jordan question to lack a current answer until its answers are reassessed, but recieved %+v", answer)
+		}
+
+		if err = stores.Answers.AssessAnswers(jordanQuestion.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, answer, err := stores.Questions.FindPostByID(jordanQuestion.ID); err != nil {
+			t.Fatal(err)
+		} else if answer == nil || answer.ID != jordanTiedAnswer.ID {
+			t.Errorf("Expected the tied answer to become the current answer, but recieved %+v", answer)
+		}
+
+		_, _, err = stores.Answers.CastVote(jordanAnswer.ID, "balling", tester1.ID, 1)
+		expectCode(t, err, apierrors.CodeAnswerNotFound)
+
+		// Revision 1 was recorded when the tied answer became current, and can not be rolled back to from another category or once the answer is removed along with it
+		err = stores.Revisions.RollbackToRevision(jordanQuestion.ID, "gains", 1)
+		expectCode(t, err, apierrors.CodeQuestionNotFound)
+
+		if _, _, err = stores.Moderation.RemovePost(models.PostRef{Type: models.PostAnswer, ID: jordanTiedAnswer.ID, Category: "balling"}, tester2.ID, "Abusive"); err != nil {
+			t.Fatal(err)
+		}
+		err = stores.Revisions.RollbackToRevision(jordanQuestion.ID, "balling", 1)
+		expectCode(t, err, apierrors.CodeRevisionNotFound)
+	})
+
+	t.Run("RemovedAnswerLeavesHistory", func(t *testing.T) {
+		stores := backend(t)
+
+		if err := stores.Answers.AssessAnswers(legDayQuestion.ID); err != nil {
+			t.Fatal(err)
+		}
+		revision, err := stores.Revisions.FindRevision(legDayQuestion.ID, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, _, err = stores.Moderation.RemovePost(models.PostRef{Type: models.PostAnswer, ID: revision.AnswerID, Category: "gains"}, tester2.ID, "Abusive"); err != nil {
+			t.Fatal(err)
+		}
+
+		if revisions, err := stores.Revisions.FindRevisions(legDayQuestion.ID, firstPage); err != nil {
+			t.Fatal(err)
+		} else if len(revisions.Items) != 0 {
+			t.Errorf("Expected the revision of the removed answer to leave the history, but recieved %+v", revisions.Items)
+		}
+
+		_, err = stores.Revisions.FindRevision(legDayQuestion.ID, 1)
+		expectCode(t, err, apierrors.CodeRevisionNotFound)
+	})
+
+	t.Run("RemovedQuestionLeavesHistory", func(t *testing.T) {
+		stores := backend(t)
+
+		if err := stores.Answers.AssessAnswers(legDayQuestion.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := stores.Moderation.RemovePost(models.PostRef{Type: models.PostQuestion, ID: legDayQuestion.ID, Category: "gains"}, tester2.ID, "Spam"); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := stores.Revisions.FindRevisions(legDayQuestion.ID, firstPage)
+		expectCode(t, err, apierrors.CodeQuestionNotFound)
+
+		_, err = stores.Revisions.FindRevision(legDayQuestion.ID, 1)
+		expectCode(t, err, apierrors.CodeRevisionNotFound)
+	})
+
+	t.Run("DismissFlags", func(t *testing.T) {
+		stores := backend(t)
+
+		answerPost := models.PostRef{Type: models.PostAnswer, ID: squatAnswer.ID, Category: "gains"}
+
+		if err := stores.Moderation.FlagPost(answerPost, tester1.ID, models.FlagOffTopic, ""); err != nil {
+			t.Fatal(err)
+		}
+		if err := stores.Moderation.DismissFlags(answerPost, tester2.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		err := stores.Moderation.DismissFlags(answerPost, tester2.ID)
+		expectCode(t, err, apierrors.CodeFlagNotFound)
+
+		if posts, err := stores.Moderation.FindFlaggedPosts("gains", firstPage); err != nil {
+			t.Fatal(err)
+		} else if len(posts.Items) != 0 {
+			t.Errorf("Expected dismissed flags to leave the review queue, but recieved %+v", posts.Items)
+		}
+
+		if actions, err := stores.Moderation.FindModerationActions(firstPage); err != nil {
+			t.Fatal(err)
+		} else if len(actions.Items) != 1 || actions.Items[0].Action != models.ActionDismissFlags || actions.Items[0].AnswerID != squatAnswer.ID || actions.Items[0].PostType != models.PostAnswer {
+			t.Errorf("Expected the dismissal to be logged, but recieved %+v", actions.Items)
+		}
+
+		// The flags of deleted users are deleted along with them, while the actions of deleted moderators are kept
+		if err = stores.Users.DeleteUser(tester2.ID); err != nil {
+			t.Fatal(err)
+		}
+		if actions, err := stores.Moderation.FindModerationActions(firstPage); err != nil {
+			t.Fatal(err)
+		} else if len(actions.Items) != 1 || actions.Items[0].ModeratorID != "" {
+			t.Errorf("Expected the dismissal to outlive its moderator, but recieved %+v", actions.Items)
+		}
+	})
+}
+
+func RunSearchStoreTests(t *testing.T, backend Backend) {
+
+	t.Run("SearchQuestions", func(t *testing.T) {
+		stores := backend(t)
+
+		results, err := stores.Search.SearchQuestions(&models.SearchQuery{Terms: "Sushi"}, firstPage)
+		if err != nil {
+			t.Fatal(err)
+		} else if ids := searchResultIDs(results.Items); !equalIDs(ids, []string{sushiQuestion.ID}) {
+			t.Fatalf("Expected the sushi question, but recieved %v", ids)
+		}
+		if result := results.Items[0]; result.Username != tester1.Username || result.Category != "City Dining" || result.TitleHighlight != "Where is the best <mark>sushi</mark> place?" || result.Rank <= 0 {
+			t.Errorf("Expected the highlighted sushi question, but recieved %+v", result)
+		}
+
+		// The words of current answers are searched as well, unlike those of other answers
+		if results, err = stores.Search.SearchQuestions(&models.SearchQuery{Terms: "utah"}, firstPage); err != nil {
+			t.Fatal(err)
+		} else if ids := searchResultIDs(results.Items); !equalIDs(ids, []string{sushiQuestion.ID}) || !strings.Contains(results.Items[0].Snippet, "<mark>Utah</mark>") {
+			t.Errorf("Expected the sushi question with its current answer in the snippet, but recieved %+v", results.Items)
+		}
+		if results, err = stores.Search.SearchQuestions(&models.SearchQuery{Terms: "massachusetts"}, firstPage); err != nil {
+			t.Fatal(err)
+		} else if len(results.Items) != 0 {
+			t.Errorf("Expected no results for an answer that is not current, but recieved %v", searchResultIDs(results.Items))
+		}
+
+		// Queries that consist only of stop words match nothing
+		if results, err = stores.Search.SearchQuestions(&models.SearchQuery{Terms: "the"}, firstPage); err != nil {
+			t.Fatal(err)
+		} else if len(results.Items) != 0 {
+			t.Errorf("Expected no results for a stop word, but recieved %v", searchResultIDs(results.Items))
+		}
+	})
+
+	t.Run("SearchQuestionsWithFilters", func(t *testing.T) {
+		stores := backend(t)
+
+		// Equally ranked questions are ordered by their upvotes
+		results, err := stores.Search.SearchQuestions(&models.SearchQuery{Terms: "need"}, firstPage)
+		if err != nil {
+			t.Fatal(err)
+		} else if ids := searchResultIDs(results.Items); !equalIDs(ids, []string{squatQuestion.ID, jordanQuestion.ID}) {
+			t.Errorf("Expected the squat and jordan questions, but recieved %v", ids)
+		}
+
+		if results, err = stores.Search.SearchQuestions(&models.SearchQuery{Terms: "need", Category: "balling"}, firstPage); err != nil {
+			t.Fatal(err)
+		} else if ids := searchResultIDs(results.Items); !equalIDs(ids, []string{jordanQuestion.ID}) {
+			t.Errorf("Expected only the jordan question in Balling, but recieved %v", ids)
+		}
+
+		if results, err = stores.Search.SearchQuestions(&models.SearchQuery{Terms: "need", Author: tester1.Username}, firstPage); err != nil {
+			t.Fatal(err)
+		} else if ids := searchResultIDs(results.Items); !equalIDs(ids, []string{squatQuestion.ID}) {
+			t.Errorf("Expected only the squat question of %s, but recieved %v", tester1.Username, ids)
+		}
+
+		if results, err = stores.Search.SearchQuestions(&models.SearchQuery{Terms: "need"}, models.PageRequest{Size: 1}); err != nil {
+			t.Fatal(err)
+		} else if results, err = stores.Search.SearchQuestions(&models.SearchQuery{Terms: "need"}, models.PageRequest{Cursor: results.Next, Size: 1}); err != nil {
+			t.Fatal(err)
+		} else if ids := searchResultIDs(results.Items); !equalIDs(ids, []string{jordanQuestion.ID}) || results.Next != "" || results.Prev == "" {
+			t.Errorf("Expected the second page to hold only the jordan question, but recieved %v with %+v", ids, results.Cursors)
+		}
+	})
+
+	t.Run("SearchQuestionsFollowsCurrentAnswer", func(t *testing.T) {
+		stores := backend(t)
+
+		results, err := stores.Search.SearchQuestions(&models.SearchQuery{Terms: "gains"}, firstPage)
+		if err != nil {
+			t.Fatal(err)
+		} else if ids := searchResultIDs(results.Items); !equalIDs(ids, []string{squatQuestion.ID}) {
+			t.Fatalf("Expected only the squat question, but recieved %v", ids)
+		}
+
+		// The answer that outvotes the current answer of the leg day question mentions gains
+		if err = stores.Answers.AssessAnswers(legDayQuestion.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		// Words of the question outweigh those of its current answer
+		if results, err = stores.Search.SearchQuestions(&models.SearchQuery{Terms: "gains"}, firstPage); err != nil {
+			t.Fatal(err)
+		} else if ids := searchResultIDs(results.Items); !equalIDs(ids, []string{squatQuestion.ID, legDayQuestion.ID}) {
+			t.Errorf("Expected the squat and leg day questions, but recieved %v", ids)
+		}
+	})
+
+	t.Run("SearchQuestionsHidesRemovedQuestions", func(t *testing.T) {
+		stores := backend(t)
+
+		if _, _, err := stores.Moderation.RemovePost(models.PostRef{Type: models.PostQuestion, ID: squatQuestion.ID, Category: "gains"}, tester2.ID, "Spam"); err != nil {
+			t.Fatal(err)
+		}
+
+		results, err := stores.Search.SearchQuestions(&models.SearchQuery{Terms: "squat"}, firstPage)
+		if err != nil {
+			t.Fatal(err)
+		} else if len(results.Items) != 0 {
+			t.Errorf("Expected the removed squat question to be hidden, but recieved %v", searchResultIDs(results.Items))
+		}
+	})
+}
+
+func RunRepStoreTests(t *testing.T, backend Backend) {
+
+	stores := backend(t)
+
+	// Keys are unique to each run, since some backends keep rep beyond the lifetime of the stores
+	userID := "storetest-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+
+	rep, err := stores.Rep.FindRep("gains", userID)
+	if err != nil {
+		t.Fatal(err)
+	} else if rep != 5 {
+		t.Errorf("Expected a new user to start with 5 rep, but recieved %d", rep)
+	}
+
+	// Looking up rep stores the starting rep, which the user has not earned
+	if rep, err = stores.Rep.FindTotalRep(userID); err != nil {
+		t.Fatal(err)
+	} else if rep != 0 {
+		t.Errorf("Expected a new user to have earned no rep, but recieved %d", rep)
+	}
+
+	if err = stores.Rep.UpdateRep("gains", userID, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err = stores.Rep.UpdateRep("balling", userID, -1); err != nil {
+		t.Fatal(err)
+	}
+
+	if rep, err = stores.Rep.FindRep("gains", userID); err != nil {
+		t.Fatal(err)
+	} else if rep != 7 {
+		t.Errorf("Expected 7 rep in gains, but recieved %d", rep)
+	}
+
+	if rep, err = stores.Rep.FindTotalRep(userID); err != nil {
+		t.Fatal(err)
+	} else if rep != 1 {
+		t.Errorf("Expected 1 rep to have been earned across every category, but recieved %d", rep)
+	}
+
+	// Renaming a category carries the rep in it over to the new name
+	if err = stores.Rep.RenameCategory("gains", "lifting"); err != nil {
+		t.Fatal(err)
+	}
+	if rep, err = stores.Rep.FindRep("lifting", userID); err != nil {
+		t.Fatal(err)
+	} else if rep != 7 {
+		t.Errorf("Expected the 7 rep in gains to be carried over to lifting, but recieved %d", rep)
+	}
+	if rep, err = stores.Rep.FindTotalRep(userID); err != nil {
+		t.Fatal(err)
+	} else if rep != 1 {
+		t.Errorf("Expected the rename to leave the earned rep unchanged, but recieved %d", rep)
+	}
+}
+
+func RunTokenStoreTests(t *testing.T, backend Backend) {
+
+	stores := backend(t)
+
+	key := "storetest-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+
+	isStored, err := stores.Tokens.IsTokenStored(key)
+	if err != nil {
+		t.Fatal(err)
+	} else if isStored {
+		t.Error("Expected an unknown token not to be stored")
+	}
+
+	if err = stores.Tokens.StoreToken(key, "token", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if isStored, err = stores.Tokens.IsTokenStored(key); err != nil {
+		t.Fatal(err)
+	} else if !isStored {
+		t.Error("Expected the token to be stored")
+	}
+
+	if val, err := stores.Tokens.FindToken(key); err != nil {
+		t.Fatal(err)
+	} else if val != "token" {
+		t.Errorf("Expected the stored value to be \"token\", but recieved %q", val)
+	}
+
+	if isNew, err := stores.Tokens.StoreTokenOnce(key, "another token", 100); err != nil {
+		t.Fatal(err)
+	} else if isNew {
+		t.Error("Expected StoreTokenOnce not to replace a stored token")
+	}
+
+	time.Sleep(1100 * time.Millisecond)
+
+	if isStored, err = stores.Tokens.IsTokenStored(key); err != nil {
+		t.Fatal(err)
+	} else if isStored {
+		t.Error("Expected the token to expire")
+	}
+
+	_, err = stores.Tokens.FindToken(key)
+	if !errors.Is(err, datastores.ErrTokenNotFound) {
+		t.Errorf("Expected ErrTokenNotFound, but recieved %v", err)
+	}
+
+	// An expired key is free to be stored again
+	if isNew, err := stores.Tokens.StoreTokenOnce(key, "another token", 1); err != nil {
+		t.Fatal(err)
+	} else if !isNew {
+		t.Error("Expected StoreTokenOnce to store a token under an expired key")
+	}
+}
+
+func RunTokenSetTests(t *testing.T, backend Backend) {
+
+	stores := backend(t)
+
+	key := "storetest-set-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+
+	for _, member := range []string{"a", "b", "a"} {
+		if err := stores.Tokens.AddToTokenSet(key, member, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := stores.Tokens.RemoveFromTokenSet(key, "b"); err != nil {
+		t.Fatal(err)
+	}
+
+	members, err := stores.Tokens.FindTokenSet(key)
+	if err != nil {
+		t.Fatal(err)
+	} else if !equalIDs(members, []string{"a"}) {
+		t.Errorf("Expected the set to hold only \"a\", but recieved %v", members)
+	}
+
+	time.Sleep(1100 * time.Millisecond)
+
+	if members, err = stores.Tokens.FindTokenSet(key); err != nil {
+		t.Fatal(err)
+	} else if len(members) != 0 {
+		t.Errorf("Expected the set to expire, but recieved %v", members)
+	}
+}
+
+func RunTokenCounterTests(t *testing.T, backend Backend) {
+
+	stores := backend(t)
+
+	key := "storetest-counter-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+
+	for want := 1; want <= 3; want++ {
+		if count, err := stores.Tokens.IncrementToken(key, 100); err != nil {
+			t.Fatal(err)
+		} else if count != want {
+			t.Errorf("Expected the count to be %d, but recieved %d", want, count)
+		}
+	}
+
+	if err := stores.Tokens.RemoveToken(key); err != nil {
+		t.Fatal(err)
+	}
+	if count, err := stores.Tokens.IncrementToken(key, 1); err != nil {
+		t.Fatal(err)
+	} else if count != 1 {
+		t.Errorf("Expected a removed counter to start over, but recieved %d", count)
+	}
+
+	time.Sleep(1100 * time.Millisecond)
+
+	if isStored, err := stores.Tokens.IsTokenStored(key); err != nil {
+		t.Fatal(err)
+	} else if isStored {
+		t.Error("Expected the counter to expire")
+	}
+}
+
+func RunTokenBucketTests(t *testing.T, backend Backend) {
+
+	stores := backend(t)
+
+	key := "storetest-bucket-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+
+	// The bucket starts out full, and refills at 2 tokens a second
+	for i := 0; i < 3; i++ {
+		if taken, remaining, err := stores.Tokens.TakeFromBucket(key, 3, 2); err != nil {
+			t.Fatal(err)
+		} else if !taken {
+			t.Errorf("Expected token %d of the burst to be taken", i)
+		} else if remaining > float64(2-i)+0.1 {
+			t.Errorf("Expected about %d tokens to remain, but recieved %f", 2-i, remaining)
+		}
+	}
+
+	if taken, _, err := stores.Tokens.TakeFromBucket(key, 3, 2); err != nil {
+		t.Fatal(err)
+	} else if taken {
+		t.Error("Expected an empty bucket to refuse tokens")
+	}
+
+	time.Sleep(600 * time.Millisecond)
+
+	if taken, _, err := stores.Tokens.TakeFromBucket(key, 3, 2); err != nil {
+		t.Fatal(err)
+	} else if !taken {
+		t.Error("Expected the bucket to refill over time")
+	}
+}
+
+func expectCode(t *testing.T, err error, code apierrors.Code) {
+	t.Helper()
+	if apierrors.CodeOf(err) != code {
+		t.Errorf("Expected an error with the code %s, but recieved %v", code, err)
+	}
+}
+
+func expectKind(t *testing.T, err error, kind apierrors.Kind) {
+	t.Helper()
+	if apierrors.KindOf(err) != kind {
+		t.Errorf("Expected an error of kind %d, but recieved %v", kind, err)
+	}
+}
+
+func questionIDs(questions []*models.Question) []string {
+	ids := make([]string, len(questions))
+	for i, question := range questions {
+		ids[i] = question.ID
+	}
+	return ids
+}
+
+func searchResultIDs(results []*models.SearchResult) []string {
+	ids := make([]string, len(results))
+	for i, result := range results {
+		ids[i] = result.QuestionID
+	}
+	return ids
+}
+
+func categoryNames(categories []*models.Category) []string {
+	names := make([]string, len(categories))
+	for i, category := range categories {
+		names[i] = category.Name
+	}
+	return names
+}
+
+func equalIDs(x, y []string) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+	return true
+}
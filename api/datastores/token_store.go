@@ -1,16 +1,30 @@
 package datastores
 
 import (
-	"log"
+	"math"
+	"strconv"
+	"time"
 
 	"github.com/garyburd/redigo/redis"
+	"github.com/mangoslicer/answer-patch/apierrors"
 )
 
 type TokenStoreServices interface {
 	StoreToken(string, string, int) error
+	StoreTokenOnce(string, string, int) (bool, error)
 	IsTokenStored(string) (bool, error)
+	FindToken(string) (string, error)
+	AddToTokenSet(string, string, int) error
+	FindTokenSet(string) ([]string, error)
+	RemoveFromTokenSet(string, string) error
+	RemoveToken(string) error
+	IncrementToken(string, int) (int, error)
+	TakeFromBucket(string, int, float64) (bool, float64, error)
 }
 
+// ErrTokenNotFound is returned by FindToken for keys that were never stored or have expired
+var ErrTokenNotFound = apierrors.New(apierrors.KindNotFound, apierrors.CodeTokenNotFound, "No token is stored under the provided key")
+
 type JWTStore struct {
 	Conn redis.Conn
 }
@@ -18,28 +32,158 @@ type JWTStore struct {
 func (store *JWTStore) StoreToken(userID, signedToken string, exp int) error {
 	_, err := store.Conn.Do("SET", userID, signedToken)
 	if err != nil {
-		log.Fatal(err)
-		return InternalErr
+		return evaluateConnError(err)
 	}
 
 	_, err = store.Conn.Do("EXPIRE", userID, exp)
 	if err != nil {
-		log.Fatal(err)
-		return InternalErr
+		return evaluateConnError(err)
 	}
 
 	return nil
 }
 
+// StoreTokenOnce stores the value only if the key is not already stored, and reports whether it did. Setting and expiring the key is atomic,
+// so of several concurrent calls with the same key only one succeeds
+func (store *JWTStore) StoreTokenOnce(key, val string, exp int) (bool, error) {
+
+	reply, err := store.Conn.Do("SET", key, val, "EX", exp, "NX")
+	if err != nil {
+		return false, evaluateConnError(err)
+	}
+
+	return reply != nil, nil
+}
+
 func (store *JWTStore) IsTokenStored(userID string) (bool, error) {
 
 	val, err := store.Conn.Do("GET", userID)
 	if err != nil {
-		log.Fatal(err)
-		return false, InternalErr
+		return false, evaluateConnError(err)
 	} else if val == nil {
 		return false, nil
 	}
 
 	return true, nil
 }
+
+func (store *JWTStore) FindToken(key string) (string, error) {
+
+	val, err := redis.String(store.Conn.Do("GET", key))
+	if err == redis.ErrNil {
+		return "", ErrTokenNotFound
+	} else if err != nil {
+		return "", evaluateConnError(err)
+	}
+
+	return val, nil
+}
+
+// AddToTokenSet adds a member to the set stored under key, and resets the expiry of the whole set
+func (store *JWTStore) AddToTokenSet(key, member string, exp int) error {
+
+	_, err := store.Conn.Do("SADD", key, member)
+	if err != nil {
+		return evaluateConnError(err)
+	}
+
+	_, err = store.Conn.Do("EXPIRE", key, exp)
+	if err != nil {
+		return evaluateConnError(err)
+	}
+
+	return nil
+}
+
+// FindTokenSet lists the members of the set stored under key in no particular order. Unknown keys hold empty sets
+func (store *JWTStore) FindTokenSet(key string) ([]string, error) {
+
+	members, err := redis.Strings(store.Conn.Do("SMEMBERS", key))
+	if err != nil {
+		return nil, evaluateConnError(err)
+	}
+
+	return members, nil
+}
+
+func (store *JWTStore) RemoveFromTokenSet(key, member string) error {
+
+	_, err := store.Conn.Do("SREM", key, member)
+	if err != nil {
+		return evaluateConnError(err)
+	}
+
+	return nil
+}
+
+func (store *JWTStore) RemoveToken(key string) error {
+
+	_, err := store.Conn.Do("DEL", key)
+	if err != nil {
+		return evaluateConnError(err)
+	}
+
+	return nil
+}
+
+// IncrementToken increments the counter stored under key, which starts from 0, and resets its expiry. The incremented count is returned
+func (store *JWTStore) IncrementToken(key string, exp int) (int, error) {
+
+	count, err := redis.Int(store.Conn.Do("INCR", key))
+	if err != nil {
+		return 0, evaluateConnError(err)
+	}
+
+	_, err = store.Conn.Do("EXPIRE", key, exp)
+	if err != nil {
+		return 0, evaluateConnError(err)
+	}
+
+	return count, nil
+}
+
+// takeScript refills the bucket for the time that passed since it was last updated, and takes a token from it if one is left.
+// The bucket expires once it would be full again, since a full bucket is the same as no bucket
+var takeScript = redis.NewScript(1, `
+local burst = tonumber(ARGV[1])
+local perMs = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local bucket = redis.call("HMGET", KEYS[1], "tokens", "updatedAt")
+local tokens = tonumber(bucket[1]) or burst
+local updatedAt = tonumber(bucket[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - updatedAt) * perMs)
+local taken = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	taken = 1
+end
+redis.call("HMSET", KEYS[1], "tokens", tostring(tokens), "updatedAt", now)
+redis.call("PEXPIRE", KEYS[1], math.max(1, math.ceil((burst - tokens) / perMs)))
+return {taken, tostring(tokens)}
+`)
+
+// TakeFromBucket takes a token from the token bucket stored under key, which holds up to burst tokens and refills at perSecond tokens per second.
+// It reports whether a token was taken, and how many tokens are left. Refilling and taking is atomic, so concurrent requests never share a token
+func (store *JWTStore) TakeFromBucket(key string, burst int, perSecond float64) (bool, float64, error) {
+
+	// The clock of the api is used rather than the clock of redis, since scripts that read the time can not be replicated by older versions of redis
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+
+	reply, err := redis.Values(takeScript.Do(store.Conn, key, burst, strconv.FormatFloat(perSecond/1000, 'g', -1, 64), now))
+	if err != nil {
+		return false, 0, evaluateConnError(err)
+	}
+
+	var taken int
+	var tokens string
+	if _, err = redis.Scan(reply, &taken, &tokens); err != nil {
+		return false, 0, evaluateConnError(err)
+	}
+
+	remaining, err := strconv.ParseFloat(tokens, 64)
+	if err != nil {
+		return false, 0, apierrors.Internal(err)
+	}
+
+	return taken == 1, math.Max(0, remaining), nil
+}
@@ -11,7 +11,7 @@ var GlobalTokenStore *JWTStore
 
 func init() {
 	settings.SetPreproductionEnv()
-	GlobalTokenStore = &JWTStore{ConnectToRedis()}
+	GlobalTokenStore = &JWTStore{mustConnectToRedis()}
 }
 
 func TestStoreToken(t *testing.T) {
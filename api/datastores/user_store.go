@@ -2,16 +2,20 @@ package datastores
 
 import (
 	"database/sql"
-	"errors"
-	"log"
-	"net/http"
 
+	"github.com/mangoslicer/answer-patch/apierrors"
 	"github.com/mangoslicer/answer-patch/models"
 )
 
 type UserStoreServices interface {
-	FindUser(string, string) (*models.User, error, int)
-	StoreUser(string, string) (error, int)
+	FindUser(string, string) (*models.User, error)
+	StoreUser(string, string, string) error
+	UpdatePassword(string, string) error
+	VerifyEmail(string, string) error
+	UpdateUsername(string, string) error
+	DeleteUser(string) error
+	FindUserByIdentity(string, string) (*models.User, error)
+	LinkIdentity(string, string, string) error
 	//	IsUsernameRegistered(string) (bool, error, int)
 }
 
@@ -19,31 +23,37 @@ type UserStore struct {
 	DB *sql.DB
 }
 
-func (store *UserStore) FindUser(filter, searchVal string) (*models.User, error, int) {
+// Content of deleted users is reassigned to this placeholder user, which can not log in, rather than being deleted along with them
+const (
+	DeletedUserID   = "00000000-0000-0000-0000-000000000000"
+	DeletedUsername = "[deleted]"
+)
 
-	queryStmt := `SELECT id, username, hashed_password, created_at FROM  ap_user WHERE ` + filter + ` =$1`
+// ErrUserNotFound is exported so that the login handler can tell unknown usernames apart from failed queries
+var ErrUserNotFound = apierrors.New(apierrors.KindNotFound, apierrors.CodeUserNotFound, "No user exists with the provided credential")
 
-	row, err := store.DB.Query(queryStmt, searchVal)
-	if err != nil {
-		log.Fatal(err)
-		return nil, InternalErr, http.StatusInternalServerError
-	} else if !row.Next() {
-		return nil, errors.New("No user exists with the provided credential"), http.StatusOK
-	}
+// ErrIdentityLinked is returned by LinkIdentity for identities that are already linked to another user
+var ErrIdentityLinked = apierrors.New(apierrors.KindConflict, apierrors.CodeIdentityLinked, "The identity is already linked to another user")
+
+func (store *UserStore) FindUser(filter, searchVal string) (*models.User, error) {
+
+	queryStmt := `SELECT id, username, hashed_password, COALESCE(email, ''), email_verified, created_at FROM  ap_user WHERE ` + filter + ` =$1`
 
 	user := new(models.User)
 
-	err = row.Scan(&user.ID, &user.Username, &user.HashedPassword, &user.CreatedAt)
-	if err != nil {
-		log.Fatal(err)
-		return nil, InternalErr, http.StatusInternalServerError
+	err := store.DB.QueryRow(queryStmt, searchVal).Scan(&user.ID, &user.Username, &user.HashedPassword, &user.Email, &user.EmailVerified, &user.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrUserNotFound
+	} else if err != nil {
+		return nil, evaluateSQLError(err)
 	}
 
-	return user, nil, http.StatusOK
+	return user, nil
 
 }
 
-func (store *UserStore) StoreUser(username, hashedpassword string) (error, int) {
+// StoreUser registers a user, whose email stays unverified until VerifyEmail is called
+func (store *UserStore) StoreUser(username, hashedpassword, email string) error {
 	/*
 		row, err := store.DB.Query(`SELECT id FROM ap_user WHERE username = $1 AND hashed_password = $2`, username, hashedpassword)
 		if err != nil {
@@ -53,12 +63,143 @@ func (store *UserStore) StoreUser(username, hashedpassword string) (error, int)
 			return nil, http.StatusOK
 		}
 	*/
-	return transact(store.DB, func(tx *sql.Tx) (error, int) {
-		_, err := tx.Exec(`INSERT INTO ap_user(username, hashed_password) values($1, $2)`, username, hashedpassword)
+	return transact(store.DB, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`INSERT INTO ap_user(username, hashed_password, email) values($1, $2, NULLIF($3, ''))`, username, hashedpassword, email)
+		if err != nil {
+			return evaluateSQLError(err)
+		}
+		return nil
+	})
+}
+
+// UpdatePassword replaces the hashed password of the user with the provided id
+func (store *UserStore) UpdatePassword(userID, hashedPassword string) error {
+
+	return transact(store.DB, func(tx *sql.Tx) error {
+		result, err := tx.Exec(`UPDATE ap_user SET hashed_password = $2 WHERE id = $1::uuid`, userID, hashedPassword)
+		if err != nil {
+			return evaluateSQLError(err)
+		}
+
+		updated, err := result.RowsAffected()
+		if err != nil {
+			return evaluateSQLError(err)
+		} else if updated == 0 {
+			return ErrUserNotFound
+		}
+
+		return nil
+	})
+}
+
+// VerifyEmail marks the email of a user as verified, provided that it is still the email of the user
+func (store *UserStore) VerifyEmail(userID, email string) error {
+
+	return transact(store.DB, func(tx *sql.Tx) error {
+		result, err := tx.Exec(`UPDATE ap_user SET email_verified = true WHERE id = $1::uuid AND email = $2`, userID, email)
+		if err != nil {
+			return evaluateSQLError(err)
+		}
+
+		updated, err := result.RowsAffected()
+		if err != nil {
+			return evaluateSQLError(err)
+		} else if updated == 0 {
+			return ErrUserNotFound
+		}
+
+		return nil
+	})
+}
+
+// UpdateUsername renames the user with the provided id. Usernames must stay unique
+func (store *UserStore) UpdateUsername(userID, username string) error {
+
+	return transact(store.DB, func(tx *sql.Tx) error {
+		result, err := tx.Exec(`UPDATE ap_user SET username = $2 WHERE id = $1::uuid AND id <> $3::uuid`, userID, username, DeletedUserID)
 		if err != nil {
 			return evaluateSQLError(err)
 		}
-		return nil, http.StatusOK
+
+		updated, err := result.RowsAffected()
+		if err != nil {
+			return evaluateSQLError(err)
+		} else if updated == 0 {
+			return ErrUserNotFound
+		}
+
+		return nil
+	})
+}
+
+// DeleteUser deletes a user and anonymizes their content by reassigning it to the placeholder user. Their votes are reassigned as well,
+// so that the upvotes that AssessAnswers totals from the ledger stay in line with the cached upvotes
+func (store *UserStore) DeleteUser(userID string) error {
+
+	return transact(store.DB, func(tx *sql.Tx) error {
+
+		stmts := []string{
+			`UPDATE question_vote SET user_id = $2::uuid WHERE user_id = $1::uuid`,
+			`UPDATE answer_vote SET user_id = $2::uuid WHERE user_id = $1::uuid`,
+			`UPDATE category SET user_id = $2::uuid WHERE user_id = $1::uuid`,
+			`UPDATE question SET user_id = $2::uuid WHERE user_id = $1::uuid`,
+			`UPDATE answer SET user_id = $2::uuid WHERE user_id = $1::uuid`,
+			`UPDATE answer_revision SET user_id = $2::uuid WHERE user_id = $1::uuid`,
+		}
+		for _, stmt := range stmts {
+			if _, err := tx.Exec(stmt, userID, DeletedUserID); err != nil {
+				return evaluateSQLError(err)
+			}
+		}
+
+		result, err := tx.Exec(`DELETE FROM ap_user WHERE id = $1::uuid AND id <> $2::uuid`, userID, DeletedUserID)
+		if err != nil {
+			return evaluateSQLError(err)
+		}
+
+		deleted, err := result.RowsAffected()
+		if err != nil {
+			return evaluateSQLError(err)
+		} else if deleted == 0 {
+			return ErrUserNotFound
+		}
+
+		return nil
+	})
+}
+
+// FindUserByIdentity looks up the user that the subject of an identity provider is linked to
+func (store *UserStore) FindUserByIdentity(provider, subject string) (*models.User, error) {
+
+	user := new(models.User)
+
+	err := store.DB.QueryRow(`SELECT u.id, u.username, u.hashed_password, COALESCE(u.email, ''), u.email_verified, u.created_at FROM user_identity i INNER JOIN ap_user u ON u.id = i.user_id WHERE i.provider = $1 AND i.subject = $2`, provider, subject).Scan(&user.ID, &user.Username, &user.HashedPassword, &user.Email, &user.EmailVerified, &user.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrUserNotFound
+	} else if err != nil {
+		return nil, evaluateSQLError(err)
+	}
+
+	return user, nil
+}
+
+// LinkIdentity links the subject of an identity provider to a user. Linking an identity to the user it is already linked to does nothing
+func (store *UserStore) LinkIdentity(userID, provider, subject string) error {
+
+	return transact(store.DB, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`INSERT INTO user_identity(provider, subject, user_id) VALUES($1, $2, $3::uuid) ON CONFLICT (provider, subject) DO NOTHING`, provider, subject, userID)
+		if err != nil {
+			return evaluateSQLError(err)
+		}
+
+		var isLinkedToUser bool
+		if err = tx.QueryRow(`SELECT user_id = $3::uuid FROM user_identity WHERE provider = $1 AND subject = $2`, provider, subject, userID).Scan(&isLinkedToUser); err != nil {
+			return evaluateSQLError(err)
+		} else if !isLinkedToUser {
+			return ErrIdentityLinked
+		}
+
+		return nil
 	})
 }
 
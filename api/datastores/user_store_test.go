@@ -12,7 +12,7 @@ var GlobalUserStore *UserStore
 
 func init() {
 	settings.SetPreproductionEnv()
-	GlobalUserStore = &UserStore{DB: ConnectToPostgres()}
+	GlobalUserStore = &UserStore{DB: mustConnectToPostgres()}
 
 }
 
@@ -20,7 +20,7 @@ func TestFindUserByID(t *testing.T) {
 
 	expectedUser := &models.User{ID: "0c1b2b91-9164-4d52-87b0-9c4b444ee62d", Username: "Tester1", HashedPassword: "$2a$10$lWqqb7MhwH7YryO4DyjdeOsFQ9hK7qxZ8PPcm6qjuNlM47KNInHMK"}
 
-	retrievedUser, err, _ := GlobalUserStore.FindUser("id", "0c1b2b91-9164-4d52-87b0-9c4b444ee62d")
+	retrievedUser, err := GlobalUserStore.FindUser("id", "0c1b2b91-9164-4d52-87b0-9c4b444ee62d")
 	if err != nil {
 		t.Error(err)
 	}
@@ -36,7 +36,7 @@ func TestFindUserByUsername(t *testing.T) {
 
 	expectedUser := &models.User{ID: "0c1b2b91-9164-4d52-87b0-9c4b444ee62d", Username: "Tester1", HashedPassword: "$2a$10$lWqqb7MhwH7YryO4DyjdeOsFQ9hK7qxZ8PPcm6qjuNlM47KNInHMK"}
 
-	retrievedUser, err, _ := GlobalUserStore.FindUser("username", "Tester1")
+	retrievedUser, err := GlobalUserStore.FindUser("username", "Tester1")
 	if err != nil {
 		t.Error(err)
 	}
@@ -50,7 +50,7 @@ func TestFindUserByUsername(t *testing.T) {
 
 func TestStoreUserWithNewCredentials(t *testing.T) {
 
-	err, _ := GlobalUserStore.StoreUser("TestUser", "$2a$10$iziTEDykz1SgOVWhLuBxeeBiZFJdD6GfTO0vA06IJTafiPfSu4QYq")
+	err := GlobalUserStore.StoreUser("TestUser", "$2a$10$iziTEDykz1SgOVWhLuBxeeBiZFJdD6GfTO0vA06IJTafiPfSu4QYq", "")
 	if err != nil {
 		t.Error(err)
 	}
@@ -58,7 +58,7 @@ func TestStoreUserWithNewCredentials(t *testing.T) {
 
 func TestStoreUserWithExistingUserCredentials(t *testing.T) {
 
-	err, _ := GlobalUserStore.StoreUser("Tester1", "$2a$10$iziTEEyoz1SgOVWhLuBxeeBiZFJdD6GfTO0vA06IJTafiPfSu4QYq")
+	err := GlobalUserStore.StoreUser("Tester1", "$2a$10$iziTEEyoz1SgOVWhLuBxeeBiZFJdD6GfTO0vA06IJTafiPfSu4QYq", "")
 	if err.Error() != "The provided username is not unique" {
 		t.Error(err)
 	}
@@ -4,10 +4,12 @@ import (
 	"net/http"
 
 	"github.com/gorilla/mux"
+	"github.com/mangoslicer/answer-patch/apierrors"
 	"github.com/mangoslicer/answer-patch/datastores"
 	m "github.com/mangoslicer/answer-patch/middleware"
 	"github.com/mangoslicer/answer-patch/models"
 	"github.com/mangoslicer/answer-patch/services"
+	"github.com/mangoslicer/answer-patch/settings"
 )
 
 func ServeSubmitAnswer(store datastores.AnswerStoreServices) m.HandlerFunc {
@@ -16,22 +18,23 @@ func ServeSubmitAnswer(store datastores.AnswerStoreServices) m.HandlerFunc {
 		questionID := mux.Vars(r)["questionID"]
 		isSlotAvailable, err := store.IsAnswerSlotAvailable(questionID)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			services.PrintError(w, err)
 			return
 		} else if !isSlotAvailable {
-			http.Error(w, "Maximum capacity for answers has been reached", http.StatusForbidden)
+			services.PrintError(w, apierrors.New(apierrors.KindForbidden, apierrors.CodeAnswerSlotsFull, "Maximum capacity for answers has been reached"))
 			return
 		}
 
 		newAnswer := c.ParsedModel.(*models.Answer)
 		requiredRep, err := c.RepStore.FindRep(mux.Vars(r)["category"], c.UserID)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			services.PrintError(w, err)
+			return
 		}
 
-		err, statusCode := store.StoreAnswer(questionID, c.UserID, newAnswer.Content, services.CalculateCurrentAnswerEligibilityRep(requiredRep))
+		err = store.StoreAnswer(questionID, c.UserID, newAnswer.Content, services.CalculateCurrentAnswerEligibilityRep(requiredRep))
 		if err != nil {
-			http.Error(w, err.Error(), statusCode)
+			services.PrintError(w, err)
 			return
 		}
 
@@ -43,41 +46,79 @@ func ServeCastAnswerVote(store datastores.AnswerStoreServices) m.HandlerFunc {
 	return func(c *m.Context, w http.ResponseWriter, r *http.Request) {
 
 		routeVars := mux.Vars(r)
-
-		/*
-			if !store.IsCategoryRegistered(routeVars["category"]) {
-				http.Error(w, "The provided category does not exist", http.StatusBadRequest)
-				return
-			}
-		*/
 		vote := 1
 
-		if routeVars["vote"] == "downvote" {
+		if routeVars["vote"] == "-1" {
 			vote = -1
 		}
 
-		voteRecipient, err, statusCode := store.CastVote(routeVars["answerID"], vote)
+		answer, change, err := store.CastVote(routeVars["answerID"], routeVars["category"], c.UserID, vote)
 		if err != nil {
-			http.Error(w, err.Error(), statusCode)
+			services.PrintError(w, err)
 			return
 		}
 
-		rep, err := c.RepStore.FindRep(routeVars["category"], voteRecipient)
+		settleAnswerVote(store, c, w, routeVars["category"], answer, change)
+	}
+}
+
+func ServeRetractAnswerVote(store datastores.AnswerStoreServices) m.HandlerFunc {
+	return func(c *m.Context, w http.ResponseWriter, r *http.Request) {
+
+		routeVars := mux.Vars(r)
+
+		answer, change, err := store.RetractVote(routeVars["answerID"], routeVars["category"], c.UserID)
+		if err != nil {
+			services.PrintError(w, err)
+			return
+		}
+
+		settleAnswerVote(store, c, w, routeVars["category"], answer, change)
+	}
+}
+
+// ServeAnswerVotes lists the caller's own votes on answers, optionally limited to the answers of the question in the route
+func ServeAnswerVotes(store datastores.AnswerStoreServices) m.HandlerFunc {
+	return func(c *m.Context, w http.ResponseWriter, r *http.Request) {
+
+		page, err := parsePageRequest(r)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			services.PrintError(w, err)
 			return
 		}
-		if rep <= MAX_REP {
-			err = c.RepStore.UpdateRep(routeVars["cateogry"], c.UserID, vote)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
+
+		votes, err := store.FindVotes(c.UserID, mux.Vars(r)["questionID"], page)
+		if err != nil {
+			services.PrintError(w, err)
+			return
 		}
-		err, statusCode = store.AssessAnswers(routeVars["answerID"])
+		services.PrintJSON(w, votes)
+	}
+}
+
+// settleAnswerVote passes the change in upvotes of an answer on to its author's rep and reassesses which answer is current
+func settleAnswerVote(store datastores.AnswerStoreServices, c *m.Context, w http.ResponseWriter, category string, answer *models.Answer, change int) {
+
+	if change == 0 { // The vote was already in the requested state
+		return
+	}
+
+	rep, err := c.RepStore.FindRep(category, answer.UserID)
+	if err != nil {
+		services.PrintError(w, err)
+		return
+	}
+	if rep <= settings.Get().Rules.MaxRep {
+		err = c.RepStore.UpdateRep(category, answer.UserID, change)
 		if err != nil {
-			http.Error(w, err.Error(), statusCode)
+			services.PrintError(w, err)
 			return
 		}
 	}
+
+	err = store.AssessAnswers(answer.QuestionID)
+	if err != nil {
+		services.PrintError(w, err)
+		return
+	}
 }
@@ -1,11 +1,12 @@
 package handlers
 
 import (
-	"errors"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/mangoslicer/answer-patch/apierrors"
 	m "github.com/mangoslicer/answer-patch/middleware"
 	"github.com/mangoslicer/answer-patch/models"
 	auth "github.com/mangoslicer/answer-patch/services"
@@ -19,16 +20,24 @@ func (store *MockAnswerStore) IsAnswerSlotAvailable(questionID string) (bool, er
 	return store.AnswerSlotAvailable, nil
 }
 
-func (store *MockAnswerStore) StoreAnswer(questionID, userID, content string, reqUpvotes int) (error, int) {
-	return nil, 0
+func (store *MockAnswerStore) StoreAnswer(questionID, userID, content string, reqUpvotes int) error {
+	return nil
 }
 
-func (store *MockAnswerStore) CastVote(answerID string, vote int) (string, error, int) {
-	return "", errors.New("No answer exists with the provided answer id"), http.StatusBadRequest
+func (store *MockAnswerStore) CastVote(answerID, category, userID string, vote int) (*models.Answer, int, error) {
+	return nil, 0, apierrors.New(apierrors.KindNotFound, apierrors.CodeAnswerNotFound, "No answer exists with the provided answer id")
 }
 
-func (store *MockAnswerStore) AssessAnswers(questionID string) (error, int) {
-	return nil, 0
+func (store *MockAnswerStore) RetractVote(answerID, category, userID string) (*models.Answer, int, error) {
+	return &models.Answer{ID: answerID}, 0, nil
+}
+
+func (store *MockAnswerStore) FindVotes(userID, questionID string, page models.PageRequest) (*models.AnswerVotePage, error) {
+	return &models.AnswerVotePage{Items: []*models.AnswerVote{&models.AnswerVote{AnswerID: "b50f0224-3fda-435b-a8a6-8257fcbf5aa7", Vote: 1}}}, nil
+}
+
+func (store *MockAnswerStore) AssessAnswers(questionID string) error {
+	return nil
 }
 
 func TestServeSubmitAnswerWithNoAvailableAnswerSlots(t *testing.T) {
@@ -46,8 +55,8 @@ func TestServeSubmitAnswerWithNoAvailableAnswerSlots(t *testing.T) {
 
 	if w.Code != http.StatusForbidden {
 		t.Errorf("Expected a status code of 401 due to the fact that there were no answer slots available, but recieved a status code of %d", w.Code)
-	} else if w.Body.String() != "Maximum capacity for answers has been reached\n" {
-		t.Errorf("Expected the responsewriter body to contain \"Maximum capacity for answers has been reached\", but the responsewriter body contains \"%s\"", w.Body.String())
+	} else if decodeProblem(t, w).Code != apierrors.CodeAnswerSlotsFull {
+		t.Errorf("Expected the error code %s, but the responsewriter body contains \"%s\"", apierrors.CodeAnswerSlotsFull, w.Body.String())
 	}
 }
 
@@ -79,11 +88,50 @@ func TestServeCastAnswerVote(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	mockStore := &MockAnswerStore{}
-	ServeCastAnswerVote(mockStore)(nil, w, r)
+	ServeCastAnswerVote(mockStore)(m.NewContext(), w, r)
 
-	if w.Code != http.StatusBadRequest {
-		t.Errorf("Expected a status code of 400, but recieved a status code of %d", w.Code)
-	} else if w.Body.String() != "No answer exists with the provided answer id\n" {
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected a status code of 404, but recieved a status code of %d", w.Code)
+	} else if decodeProblem(t, w).Detail != "No answer exists with the provided answer id" {
 		t.Errorf("Expected the responsewriter body to contain \"No answer exists with the provided answer id\", but the responsewriter body contains \"%s\"", w.Body.String())
 	}
 }
+
+func TestServeRetractAnswerVoteWithNoVote(t *testing.T) {
+
+	r, err := http.NewRequest("DELETE", "api/gains/answer/b50f0224-3fda-435b-a8a6-8257fcbf5aa7/vote", nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	w := httptest.NewRecorder()
+
+	// A nil RepStore would cause a panic if the handler attempted to settle a retraction that changed nothing
+	ServeRetractAnswerVote(&MockAnswerStore{})(&m.Context{&auth.AuthContext{UserID: "0"}, nil, nil}, w, r)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected a status code of 200, but recieved a status code of %d", w.Code)
+	}
+}
+
+func TestServeAnswerVotes(t *testing.T) {
+
+	r, err := http.NewRequest("GET", "api/votes/answers", nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	w := httptest.NewRecorder()
+
+	ServeAnswerVotes(&MockAnswerStore{})(&m.Context{&auth.AuthContext{UserID: "0"}, nil, nil}, w, r)
+
+	votes := new(models.AnswerVotePage)
+	err = json.Unmarshal(w.Body.Bytes(), votes)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(votes.Items) != 1 || votes.Items[0].Vote != 1 {
+		t.Errorf("Expected the caller's single upvote to be listed, but recieved %+v", votes.Items)
+	}
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"net/http"
+	"regexp"
+
+	"github.com/gorilla/mux"
+	"github.com/mangoslicer/answer-patch/apierrors"
+	"github.com/mangoslicer/answer-patch/datastores"
+	m "github.com/mangoslicer/answer-patch/middleware"
+	"github.com/mangoslicer/answer-patch/models"
+	"github.com/mangoslicer/answer-patch/services"
+	"github.com/mangoslicer/answer-patch/settings"
+)
+
+// Category names must be matchable by the {category:[a-z]+} route variables and fit the category_name column
+var categoryNameRegex = regexp.MustCompile("^[a-z]{1,15}$")
+
+var errInvalidCategoryName = apierrors.New(apierrors.KindInvalid, apierrors.CodeInvalidField, "Category names must consist of 1 to 15 lowercase letters", apierrors.FieldError{Field: "categoryName", Reason: "format"})
+
+func ServeCategories(store datastores.CategoryStoreServices) m.HandlerFunc {
+	return func(c *m.Context, w http.ResponseWriter, r *http.Request) {
+
+		page, err := parsePageRequest(r)
+		if err != nil {
+			services.PrintError(w, err)
+			return
+		}
+
+		categories, err := store.FindCategories(page)
+		if err != nil {
+			services.PrintError(w, err)
+			return
+		}
+		services.PrintJSON(w, categories)
+	}
+}
+
+func ServeCategory(store datastores.CategoryStoreServices) m.HandlerFunc {
+	return func(c *m.Context, w http.ResponseWriter, r *http.Request) {
+
+		category, err := store.FindCategory(mux.Vars(r)["category"])
+		if err != nil {
+			services.PrintError(w, err)
+			return
+		}
+		services.PrintJSON(w, category)
+	}
+}
+
+func ServeCreateCategory(store datastores.CategoryStoreServices) m.HandlerFunc {
+	return func(c *m.Context, w http.ResponseWriter, r *http.Request) {
+
+		newCategory := c.ParsedModel.(*models.Category)
+		if !categoryNameRegex.MatchString(newCategory.Name) {
+			services.PrintError(w, errInvalidCategoryName)
+			return
+		}
+
+		// Categories are created by users with enough rep, and by admins regardless of their rep
+		if !c.Can(services.PermCreateCategory, "") {
+			rep, err := c.RepStore.FindTotalRep(c.UserID)
+			if err != nil {
+				services.PrintError(w, err)
+				return
+			} else if rep < settings.Get().Rules.MinRepForCreatingCategory {
+				services.PrintError(w, apierrors.New(apierrors.KindForbidden, apierrors.CodeInsufficientRep, "Not enough reputation in order to complete the request"))
+				return
+			}
+		}
+
+		err := store.StoreCategory(c.UserID, newCategory.Name, newCategory.Description)
+		if err != nil {
+			services.PrintError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+func ServeUpdateCategory(store datastores.CategoryStoreServices) m.HandlerFunc {
+	return func(c *m.Context, w http.ResponseWriter, r *http.Request) {
+
+		edit := c.ParsedModel.(*models.CategoryEdit)
+		if edit.Name != "" && !categoryNameRegex.MatchString(edit.Name) {
+			services.PrintError(w, errInvalidCategoryName)
+			return
+		}
+
+		category, err := store.FindCategory(mux.Vars(r)["category"])
+		if err != nil {
+			services.PrintError(w, err)
+			return
+		} else if category.UserID != c.UserID && !c.Can(services.PermEditCategory, category.Name) {
+			services.PrintError(w, apierrors.New(apierrors.KindForbidden, apierrors.CodeNotCreator, "Only the creator of a category or an admin can edit it"))
+			return
+		}
+
+		err = store.UpdateCategory(category.Name, edit.Name, edit.Description)
+		if err != nil {
+			services.PrintError(w, err)
+			return
+		}
+
+		// Rep is keyed by the category of the route, so it has to follow the category to its new name
+		if edit.Name != "" {
+			err = c.RepStore.RenameCategory(mux.Vars(r)["category"], edit.Name)
+			if err != nil {
+				services.PrintError(w, err)
+				return
+			}
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/mangoslicer/answer-patch/apierrors"
+	m "github.com/mangoslicer/answer-patch/middleware"
+	"github.com/mangoslicer/answer-patch/models"
+	auth "github.com/mangoslicer/answer-patch/services"
+	"github.com/mangoslicer/answer-patch/settings"
+)
+
+type MockCategoryStore struct {
+	Category *models.Category
+}
+
+func (store *MockCategoryStore) FindCategories(page models.PageRequest) (*models.CategoryPage, error) {
+	return &models.CategoryPage{Items: []*models.Category{store.Category}}, nil
+}
+
+func (store *MockCategoryStore) FindCategory(name string) (*models.Category, error) {
+	if store.Category == nil {
+		return nil, apierrors.New(apierrors.KindNotFound, apierrors.CodeCategoryNotFound, "The provided category does not exist")
+	}
+	return store.Category, nil
+}
+
+func (store *MockCategoryStore) IsCategoryRegistered(name string) (bool, error) {
+	return store.Category != nil, nil
+}
+
+func (store *MockCategoryStore) StoreCategory(userID, name, description string) error {
+	return nil
+}
+
+func (store *MockCategoryStore) UpdateCategory(name, newName, newDescription string) error {
+	return nil
+}
+
+func TestServeCreateCategoryWithInsufficientRep(t *testing.T) {
+
+	r, err := http.NewRequest("POST", "api/categories", nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	w := httptest.NewRecorder()
+
+	c := &m.Context{&auth.AuthContext{UserID: "0"}, &MockRepStore{TotalRep: settings.Get().Rules.MinRepForCreatingCategory - 1}, &models.Category{Name: "cooking"}}
+
+	ServeCreateCategory(&MockCategoryStore{})(c, w, r)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected a status code of 403, because the user lacks the rep required to create a category, but recieved a status code of %d", w.Code)
+	}
+}
+
+func TestServeCreateCategoryWithInvalidName(t *testing.T) {
+
+	r, err := http.NewRequest("POST", "api/categories", nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	w := httptest.NewRecorder()
+
+	c := &m.Context{&auth.AuthContext{UserID: "0"}, &MockRepStore{TotalRep: settings.Get().Rules.MinRepForCreatingCategory}, &models.Category{Name: "City Dining"}}
+
+	ServeCreateCategory(&MockCategoryStore{})(c, w, r)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected a status code of 400, because category names may only contain lowercase letters, but recieved a status code of %d", w.Code)
+	}
+}
+
+func TestServeCreateCategory(t *testing.T) {
+
+	r, err := http.NewRequest("POST", "api/categories", nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	w := httptest.NewRecorder()
+
+	c := &m.Context{&auth.AuthContext{UserID: "0"}, &MockRepStore{TotalRep: settings.Get().Rules.MinRepForCreatingCategory}, &models.Category{Name: "cooking"}}
+
+	ServeCreateCategory(&MockCategoryStore{})(c, w, r)
+
+	if w.Code != http.StatusCreated {
+		t.Errorf("Expected a status code of 201, but recieved a status code of %d", w.Code)
+	}
+}
+
+func TestServeCreateCategoryByAdmin(t *testing.T) {
+
+	r, err := http.NewRequest("POST", "api/categories", nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	w := httptest.NewRecorder()
+
+	// Admins create categories regardless of their rep
+	ac := &auth.AuthContext{UserID: "0", Roles: models.Roles{Role: models.RoleAdmin}}
+	c := &m.Context{ac, &MockRepStore{TotalRep: 0}, &models.Category{Name: "cooking"}}
+
+	ServeCreateCategory(&MockCategoryStore{})(c, w, r)
+
+	if w.Code != http.StatusCreated {
+		t.Errorf("Expected a status code of 201, but recieved a status code of %d", w.Code)
+	}
+}
+
+func TestServeUpdateCategoryByNonCreator(t *testing.T) {
+
+	r, err := http.NewRequest("PUT", "api/categories/gains", nil)
+	if err != nil {
+		t.Error(err)
+	}
+	r = mux.SetURLVars(r, map[string]string{"category": "gains"})
+
+	w := httptest.NewRecorder()
+
+	c := &m.Context{&auth.AuthContext{UserID: "0"}, nil, &models.CategoryEdit{Description: "Lifting"}}
+
+	ServeUpdateCategory(&MockCategoryStore{Category: &models.Category{Name: "Gains", UserID: "1"}})(c, w, r)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected a status code of 403, because only the creator of a category can edit it, but recieved a status code of %d", w.Code)
+	}
+}
+
+func TestServeUpdateCategoryByAdmin(t *testing.T) {
+
+	r, err := http.NewRequest("PUT", "api/categories/gains", nil)
+	if err != nil {
+		t.Error(err)
+	}
+	r = mux.SetURLVars(r, map[string]string{"category": "gains"})
+
+	w := httptest.NewRecorder()
+
+	c := &m.Context{&auth.AuthContext{UserID: "0", Roles: models.Roles{Role: models.RoleAdmin}}, nil, &models.CategoryEdit{Description: "Lifting"}}
+
+	ServeUpdateCategory(&MockCategoryStore{Category: &models.Category{Name: "Gains", UserID: "1"}})(c, w, r)
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Expected a status code of 204, because admins can edit every category, but recieved a status code of %d", w.Code)
+	}
+}
+
+func TestServeUpdateCategoryRenamesRep(t *testing.T) {
+
+	r, err := http.NewRequest("PUT", "api/categories/gains", nil)
+	if err != nil {
+		t.Error(err)
+	}
+	r = mux.SetURLVars(r, map[string]string{"category": "gains"})
+
+	w := httptest.NewRecorder()
+
+	repStore := &MockRepStore{}
+	c := &m.Context{&auth.AuthContext{UserID: "1"}, repStore, &models.CategoryEdit{Name: "lifting"}}
+
+	ServeUpdateCategory(&MockCategoryStore{Category: &models.Category{Name: "Gains", UserID: "1"}})(c, w, r)
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Expected a status code of 204, but recieved a status code of %d", w.Code)
+	} else if repStore.RenamedTo != "lifting" {
+		t.Errorf("Expected the rep of the category to be moved over to its new name, but recieved %q", repStore.RenamedTo)
+	}
+}
@@ -1,51 +1,218 @@
 package handlers
 
 import (
-	"database/sql"
-
 	"github.com/gorilla/mux"
 	"github.com/mangoslicer/answer-patch/datastores"
 	m "github.com/mangoslicer/answer-patch/middleware"
 	"github.com/mangoslicer/answer-patch/models"
 	"github.com/mangoslicer/answer-patch/router"
+	"github.com/mangoslicer/answer-patch/services"
 )
 
-func AssignHandlersToRoutes(c *m.Context, db *sql.DB) *mux.Router {
+func AssignHandlersToRoutes(c *m.Context, stores *datastores.Stores, mailer services.MailSender, rl *m.RateLimiter, providers map[string]*services.OIDCProvider) *mux.Router {
 
 	r := router.InitRouter()
-	r = AssignHandlersToQuestionRoutes(r, c, db)
-	r = AssignHandlersToUserRoutes(r, c, db)
+	r = AssignHandlersToQuestionRoutes(r, c, stores)
+	r = AssignHandlersToAnswerRoutes(r, c, stores)
+	r = AssignHandlersToUserRoutes(r, c, stores, mailer, rl)
+	r = AssignHandlersToRevisionRoutes(r, c, stores)
+	r = AssignHandlersToCategoryRoutes(r, c, stores)
+	r = AssignHandlersToSessionRoutes(r, c)
+	r = AssignHandlersToPasswordRoutes(r, c, stores, mailer, rl)
+	r = AssignHandlersToOIDCRoutes(r, c, stores, providers, rl)
+	r = AssignHandlersToRoleRoutes(r, c, stores)
+	r = AssignHandlersToModerationRoutes(r, c, stores)
+	r = AssignHandlersToSearchRoutes(r, c, stores)
+	r = AssignHandlersToWellKnownRoutes(r)
+
+	return r
+}
+
+func AssignHandlersToQuestionRoutes(r *mux.Router, c *m.Context, stores *datastores.Stores) *mux.Router {
+
+	questionStore := stores.Questions
+	categoryStore := stores.Categories
+
+	r.Get(router.ReadPost).Handler(m.AuthenticateToken(c, ServePostByID(questionStore)))
+
+	r.Get(router.ReadQuestionsByFilter).Handler(m.AuthenticateToken(c, ServeQuestionsByFilter(questionStore)))
+
+	r.Get(router.ReadSortedQuestions).Handler(m.AuthenticateToken(c, ServeSortedQuestions(questionStore)))
+
+	r.Get(router.ReadQuestions).Handler(m.AuthenticateToken(c, ServeQueryQuestions(questionStore)))
+
+	r.Get(router.CreateQuestion).Handler(m.AuthenticateToken(c, m.RequireAuth(m.CheckCategory(categoryStore, m.CheckRep(m.ParseRequestBody(new(models.Question), ServeSubmitQuestion(questionStore, categoryStore)))))))
+
+	r.Get(router.UpdateQuestionVote).Handler(m.AuthenticateToken(c, m.RequireAuth(m.CheckCategory(categoryStore, ServeCastQuestionVote(questionStore)))))
+
+	return r
+}
+
+func AssignHandlersToAnswerRoutes(r *mux.Router, c *m.Context, stores *datastores.Stores) *mux.Router {
+
+	answerStore := stores.Answers
+	categoryStore := stores.Categories
+
+	r.Get(router.CreatePendingAnswer).Handler(m.AuthenticateToken(c, m.RequireAuth(m.CheckCategory(categoryStore, m.ParseRequestBody(new(models.Answer), ServeSubmitAnswer(answerStore))))))
+
+	r.Get(router.UpdateAnswerVote).Handler(m.AuthenticateToken(c, m.RequireAuth(m.CheckCategory(categoryStore, ServeCastAnswerVote(answerStore)))))
+
+	r.Get(router.DeleteAnswerVote).Handler(m.AuthenticateToken(c, m.RequireAuth(m.CheckCategory(categoryStore, ServeRetractAnswerVote(answerStore)))))
+
+	r.Get(router.ReadAnswerVotes).Handler(m.AuthenticateToken(c, m.RequireAuth(ServeAnswerVotes(answerStore))))
+
+	r.Get(router.ReadAnswerVotesByQuestion).Handler(m.AuthenticateToken(c, m.RequireAuth(ServeAnswerVotes(answerStore))))
+
+	return r
+}
+
+func AssignHandlersToUserRoutes(r *mux.Router, c *m.Context, stores *datastores.Stores, mailer services.MailSender, rl *m.RateLimiter) *mux.Router {
+
+	userStore := stores.Users
+
+	r.Get(router.ReadUser).Handler(m.AuthenticateToken(c, ServeFindUser(userStore)))
+
+	r.Get(router.CreateUser).Handler(rl.LimitRoute(router.CreateUser, m.ServeHTTPWithStores(c, m.ParseRequestBody(new(models.Registration), ServeRegisterUser(userStore, mailer)))))
+
+	r.Get(router.Login).Handler(rl.LimitRoute(router.Login, m.ServeHTTPWithStores(c, m.ParseRequestBody(new(models.UnauthUser), rl.LimitAccount(router.Login, loginAccount, ServeLogin(userStore))))))
+
+	r.Get(router.Logout).Handler(m.AuthenticateToken(c, m.RequireAuth(ServeLogout())))
+
+	r.Get(router.RefreshToken).Handler(m.ServeHTTPWithStores(c, m.ParseRequestBody(new(models.RefreshRequest), ServeRefreshToken())))
+
+	r.Get(router.UpdatePassword).Handler(m.AuthenticateToken(c, m.RequireAuth(rl.LimitAccount(router.UpdatePassword, m.AuthenticatedAccount, m.ParseRequestBody(new(models.PasswordChange), ServeChangePassword(userStore))))))
+
+	r.Get(router.UpdateUsername).Handler(m.AuthenticateToken(c, m.RequireAuth(m.ParseRequestBody(new(models.UsernameChange), ServeChangeUsername(userStore)))))
+
+	r.Get(router.DeleteUser).Handler(m.AuthenticateToken(c, m.RequireAuth(ServeDeleteUser(userStore))))
+
+	r.Get(router.VerifyEmail).Handler(m.ServeHTTPWithStores(c, m.ParseRequestBody(new(models.EmailVerification), ServeVerifyEmail(userStore))))
+
+	r.Get(router.ResendVerification).Handler(m.AuthenticateToken(c, m.RequireAuth(ServeResendVerification(userStore, mailer))))
+
+	return r
+}
+
+func AssignHandlersToRevisionRoutes(r *mux.Router, c *m.Context, stores *datastores.Stores) *mux.Router {
+
+	revisionStore := stores.Revisions
+	categoryStore := stores.Categories
+
+	r.Get(router.ReadAnswerHistory).Handler(m.AuthenticateToken(c, ServeAnswerHistory(revisionStore)))
+
+	r.Get(router.ReadRevisionDiff).Handler(m.AuthenticateToken(c, ServeRevisionDiff(revisionStore)))
+
+	r.Get(router.RollbackRevision).Handler(m.AuthenticateToken(c, m.RequirePermission(services.PermRollbackAnswer, m.CheckCategory(categoryStore, ServeRollbackRevision(revisionStore)))))
+
+	return r
+}
+
+func AssignHandlersToCategoryRoutes(r *mux.Router, c *m.Context, stores *datastores.Stores) *mux.Router {
+
+	categoryStore := stores.Categories
+
+	r.Get(router.ReadCategories).Handler(m.AuthenticateToken(c, ServeCategories(categoryStore)))
+
+	r.Get(router.ReadCategory).Handler(m.AuthenticateToken(c, ServeCategory(categoryStore)))
+
+	r.Get(router.CreateCategory).Handler(m.AuthenticateToken(c, m.RequireAuth(m.ParseRequestBody(new(models.Category), ServeCreateCategory(categoryStore)))))
+
+	r.Get(router.UpdateCategory).Handler(m.AuthenticateToken(c, m.RequireAuth(m.ParseRequestBody(new(models.CategoryEdit), ServeUpdateCategory(categoryStore)))))
+
+	return r
+}
+
+func AssignHandlersToSessionRoutes(r *mux.Router, c *m.Context) *mux.Router {
+
+	r.Get(router.ReadSessions).Handler(m.AuthenticateToken(c, m.RequireAuth(ServeSessions())))
+
+	r.Get(router.DeleteSession).Handler(m.AuthenticateToken(c, m.RequireAuth(ServeRevokeSession())))
+
+	r.Get(router.DeleteSessions).Handler(m.AuthenticateToken(c, m.RequireAuth(ServeRevokeSessions())))
+
+	return r
+}
+
+func AssignHandlersToPasswordRoutes(r *mux.Router, c *m.Context, stores *datastores.Stores, mailer services.MailSender, rl *m.RateLimiter) *mux.Router {
+
+	userStore := stores.Users
+
+	r.Get(router.ForgotPassword).Handler(rl.LimitRoute(router.ForgotPassword, m.ServeHTTPWithStores(c, m.ParseRequestBody(new(models.ForgottenPassword), ServeForgotPassword(userStore, mailer)))))
+
+	r.Get(router.ResetPassword).Handler(rl.LimitRoute(router.ResetPassword, m.ServeHTTPWithStores(c, m.ParseRequestBody(new(models.PasswordReset), ServeResetPassword(userStore)))))
+
+	return r
+}
+
+// Logins are begun without a JWT, while links are begun by the user that the identity is linked to
+func AssignHandlersToOIDCRoutes(r *mux.Router, c *m.Context, stores *datastores.Stores, providers map[string]*services.OIDCProvider, rl *m.RateLimiter) *mux.Router {
+
+	userStore := stores.Users
+
+	r.Get(router.ReadOIDCProviders).Handler(m.ServeHTTP(ServeOIDCProviders(providers)))
+
+	r.Get(router.AuthorizeOIDC).Handler(m.ServeHTTPWithStores(c, ServeOIDCAuthorize(providers)))
+
+	r.Get(router.LinkOIDC).Handler(m.AuthenticateToken(c, m.RequireAuth(ServeOIDCAuthorize(providers))))
+
+	r.Get(router.OIDCCallback).Handler(rl.LimitRoute(router.OIDCCallback, m.AuthenticateToken(c, m.ParseRequestBody(new(models.OIDCCallback), ServeOIDCCallback(userStore, providers)))))
 
 	return r
 }
 
-func AssignHandlersToQuestionRoutes(r *mux.Router, c *m.Context, db *sql.DB) *mux.Router {
+func AssignHandlersToRoleRoutes(r *mux.Router, c *m.Context, stores *datastores.Stores) *mux.Router {
 
-	questionStore := &datastores.QuestionStore{db}
-	//	answerStore := &datastores.AnswerStore{db}
+	roleStore := stores.Roles
+	categoryStore := stores.Categories
 
-	r.Get(router.ReadPost).Handler(m.AuthenticateToken(c, m.RefreshExpiringToken(ServePostByID(questionStore))))
+	r.Get(router.ReadRoles).Handler(m.AuthenticateToken(c, m.RequirePermission(services.PermManageRoles, ServeRoleGrants(roleStore))))
 
-	r.Get(router.ReadQuestionsByFilter).Handler(m.AuthenticateToken(c, m.RefreshExpiringToken(ServeQuestionsByFilter(questionStore))))
+	r.Get(router.UpdateRole).Handler(m.AuthenticateToken(c, m.RequirePermission(services.PermManageRoles, m.ParseRequestBody(new(models.RoleChange), ServeSetRole(roleStore, categoryStore)))))
 
-	r.Get(router.ReadSortedQuestions).Handler(m.AuthenticateToken(c, m.RefreshExpiringToken(ServeSortedQuestions(questionStore))))
+	r.Get(router.CreateBan).Handler(m.AuthenticateToken(c, m.RequirePermission(services.PermBanUsers, m.ParseRequestBody(new(models.BanRequest), ServeBanUser(roleStore)))))
 
-	r.Get(router.CreateQuestion).Handler(m.AuthenticateToken(c, m.RefreshExpiringToken(ServeSubmitQuestion(questionStore))))
+	r.Get(router.DeleteBan).Handler(m.AuthenticateToken(c, m.RequirePermission(services.PermBanUsers, ServeUnbanUser(roleStore))))
 
 	return r
 }
 
-func AssignHandlersToUserRoutes(r *mux.Router, c *m.Context, db *sql.DB) *mux.Router {
+// Any user may flag posts, while the review queue and removals are left to the moderators of the category in the route
+func AssignHandlersToModerationRoutes(r *mux.Router, c *m.Context, stores *datastores.Stores) *mux.Router {
+
+	moderationStore := stores.Moderation
+	categoryStore := stores.Categories
+
+	r.Get(router.ReadFlaggedPosts).Handler(m.AuthenticateToken(c, m.RequirePermission(services.PermReviewFlags, ServeFlaggedPosts(moderationStore))))
+
+	r.Get(router.ReadCategoryFlaggedPosts).Handler(m.AuthenticateToken(c, m.RequirePermission(services.PermReviewFlags, ServeFlaggedPosts(moderationStore))))
+
+	r.Get(router.ReadModerationActions).Handler(m.AuthenticateToken(c, m.RequirePermission(services.PermReviewFlags, ServeModerationActions(moderationStore))))
 
-	userStore := &datastores.UserStore{db}
+	r.Get(router.CreateQuestionFlag).Handler(m.AuthenticateToken(c, m.RequireAuth(m.CheckCategory(categoryStore, m.ParseRequestBody(new(models.FlagRequest), ServeFlagPost(moderationStore, models.PostQuestion))))))
 
-	r.Get(router.ReadUser).Handler(m.AuthenticateToken(c, m.RefreshExpiringToken(ServeFindUser(userStore))))
+	r.Get(router.CreateAnswerFlag).Handler(m.AuthenticateToken(c, m.RequireAuth(m.CheckCategory(categoryStore, m.ParseRequestBody(new(models.FlagRequest), ServeFlagPost(moderationStore, models.PostAnswer))))))
 
-	r.Get(router.CreateUser).Handler(m.ServeHTTP(m.ParseRequestBody(new(models.UnauthUser), ServeRegisterUser(userStore))))
+	r.Get(router.DeleteQuestionFlags).Handler(m.AuthenticateToken(c, m.RequirePermission(services.PermReviewFlags, ServeDismissFlags(moderationStore, models.PostQuestion))))
+
+	r.Get(router.DeleteAnswerFlags).Handler(m.AuthenticateToken(c, m.RequirePermission(services.PermReviewFlags, ServeDismissFlags(moderationStore, models.PostAnswer))))
+
+	r.Get(router.DeleteQuestion).Handler(m.AuthenticateToken(c, m.RequirePermission(services.PermRemoveContent, m.ParseRequestBody(new(models.Removal), ServeRemovePost(moderationStore, stores.Answers, models.PostQuestion)))))
+
+	r.Get(router.DeleteAnswer).Handler(m.AuthenticateToken(c, m.RequirePermission(services.PermRemoveContent, m.ParseRequestBody(new(models.Removal), ServeRemovePost(moderationStore, stores.Answers, models.PostAnswer)))))
+
+	return r
+}
+
+func AssignHandlersToSearchRoutes(r *mux.Router, c *m.Context, stores *datastores.Stores) *mux.Router {
+
+	r.Get(router.Search).Handler(m.AuthenticateToken(c, ServeSearch(stores.Search)))
+
+	return r
+}
 
-	r.Get(router.Login).Handler(m.ServeHTTP(m.ParseRequestBody(new(models.UnauthUser), ServeLogin(userStore))))
+func AssignHandlersToWellKnownRoutes(r *mux.Router) *mux.Router {
 
-	r.Get(router.Logout).Handler(m.AuthenticateToken(c, ServeLogout()))
+	r.Get(router.ReadJWKS).Handler(m.ServeHTTP(ServeJWKS()))
 
 	return r
 }
@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mangoslicer/answer-patch/apierrors"
+)
+
+// decodeProblem parses the problem details document that services.PrintError writes to the responsewriter
+func decodeProblem(t *testing.T, w *httptest.ResponseRecorder) *apierrors.Problem {
+
+	problem := new(apierrors.Problem)
+	if contentType := w.Header().Get("Content-Type"); contentType != "application/problem+json" {
+		t.Errorf("Expected the Content-Type of the error response to be application/problem+json, but recieved %s", contentType)
+	} else if err := json.Unmarshal(w.Body.Bytes(), problem); err != nil {
+		t.Error(err)
+	}
+
+	return problem
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/mangoslicer/answer-patch/apierrors"
+	"github.com/mangoslicer/answer-patch/datastores"
+	m "github.com/mangoslicer/answer-patch/middleware"
+	"github.com/mangoslicer/answer-patch/models"
+	"github.com/mangoslicer/answer-patch/services"
+)
+
+var errInvalidFlagReason = apierrors.New(apierrors.KindInvalid, apierrors.CodeInvalidField, "The reason must be spam, abusive, off_topic or other", apierrors.FieldError{Field: "reason", Reason: "invalid"})
+
+// ServeFlagPost reports the question or answer of the route to the moderators of its category
+func ServeFlagPost(store datastores.ModerationStoreServices, postType models.PostType) m.HandlerFunc {
+	return func(c *m.Context, w http.ResponseWriter, r *http.Request) {
+
+		flag := c.ParsedModel.(*models.FlagRequest)
+		if !flag.Reason.IsValid() {
+			services.PrintError(w, errInvalidFlagReason)
+			return
+		}
+
+		if err := store.FlagPost(routePost(r, postType), c.UserID, flag.Reason, flag.Detail); err != nil {
+			services.PrintError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+// ServeFlaggedPosts lists a page of the review queue, limited to the category of the route if it has one
+func ServeFlaggedPosts(store datastores.ModerationStoreServices) m.HandlerFunc {
+	return func(c *m.Context, w http.ResponseWriter, r *http.Request) {
+
+		page, err := parsePageRequest(r)
+		if err != nil {
+			services.PrintError(w, err)
+			return
+		}
+
+		posts, err := store.FindFlaggedPosts(mux.Vars(r)["category"], page)
+		if err != nil {
+			services.PrintError(w, err)
+			return
+		}
+		services.PrintJSON(w, posts)
+	}
+}
+
+// ServeDismissFlags resolves the flags of the post of the route without removing the post
+func ServeDismissFlags(store datastores.ModerationStoreServices, postType models.PostType) m.HandlerFunc {
+	return func(c *m.Context, w http.ResponseWriter, r *http.Request) {
+
+		if err := store.DismissFlags(routePost(r, postType), c.UserID); err != nil {
+			services.PrintError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// ServeRemovePost hides the post of the route. Removing the current answer of a question leaves the question without one,
+// so its answers are reassessed for the next most qualified answer
+func ServeRemovePost(store datastores.ModerationStoreServices, answerStore datastores.AnswerStoreServices, postType models.PostType) m.HandlerFunc {
+	return func(c *m.Context, w http.ResponseWriter, r *http.Request) {
+
+		removal := c.ParsedModel.(*models.Removal)
+
+		questionID, wasCurrent, err := store.RemovePost(routePost(r, postType), c.UserID, removal.Reason)
+		if err != nil {
+			services.PrintError(w, err)
+			return
+		}
+
+		if wasCurrent {
+			if err = answerStore.AssessAnswers(questionID); err != nil {
+				services.PrintError(w, err)
+				return
+			}
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// ServeModerationActions lists a page of the moderation log
+func ServeModerationActions(store datastores.ModerationStoreServices) m.HandlerFunc {
+	return func(c *m.Context, w http.ResponseWriter, r *http.Request) {
+
+		page, err := parsePageRequest(r)
+		if err != nil {
+			services.PrintError(w, err)
+			return
+		}
+
+		actions, err := store.FindModerationActions(page)
+		if err != nil {
+			services.PrintError(w, err)
+			return
+		}
+		services.PrintJSON(w, actions)
+	}
+}
+
+// routePost refers to the question or answer in the route, within the category of the route
+func routePost(r *http.Request, postType models.PostType) models.PostRef {
+
+	routeVars := mux.Vars(r)
+
+	post := models.PostRef{Type: postType, ID: routeVars["questionID"], Category: routeVars["category"]}
+	if postType == models.PostAnswer {
+		post.ID = routeVars["answerID"]
+	}
+
+	return post
+}
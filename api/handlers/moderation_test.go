@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/mangoslicer/answer-patch/apierrors"
+	"github.com/mangoslicer/answer-patch/datastores"
+	"github.com/mangoslicer/answer-patch/datastores/fixtures"
+	m "github.com/mangoslicer/answer-patch/middleware"
+	"github.com/mangoslicer/answer-patch/models"
+	auth "github.com/mangoslicer/answer-patch/services"
+)
+
+func TestServeFlagPostWithInvalidReason(t *testing.T) {
+
+	r, err := http.NewRequest("POST", "api/gains/question/38681976-4d2d-4581-8a68-1e4acfadcfa0/flag", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r = mux.SetURLVars(r, map[string]string{"category": "gains", "questionID": "38681976-4d2d-4581-8a68-1e4acfadcfa0"})
+
+	w := httptest.NewRecorder()
+	c := &m.Context{&auth.AuthContext{UserID: "0"}, nil, &models.FlagRequest{Reason: "boring"}}
+
+	ServeFlagPost(datastores.NewMemoryStores(datastores.NewMemoryDB()).Moderation, models.PostQuestion)(c, w, r)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected a status code of 400, because the reason is unknown, but recieved a status code of %d", w.Code)
+	} else if code := decodeProblem(t, w).Code; code != apierrors.CodeInvalidField {
+		t.Errorf("Expected the error code %s, but recieved %s", apierrors.CodeInvalidField, code)
+	}
+}
+
+func TestServeRemovePostReassessesCurrentAnswer(t *testing.T) {
+
+	db := datastores.NewMemoryDB()
+	if err := db.Populate(); err != nil {
+		t.Fatal(err)
+	}
+	stores := datastores.NewMemoryStores(db)
+
+	// The current answer of the jordan question ties with another answer, which takes its place once it is removed
+	question, current, tied := fixtures.Questions[2], fixtures.Answers[5], fixtures.Answers[6]
+
+	r, err := http.NewRequest("DELETE", "api/balling/answer/"+current.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r = mux.SetURLVars(r, map[string]string{"category": "balling", "answerID": current.ID})
+
+	w := httptest.NewRecorder()
+	c := &m.Context{&auth.AuthContext{UserID: fixtures.Users[1].ID, Roles: models.Roles{Role: models.RoleModerator}}, nil, &models.Removal{Reason: "Abusive"}}
+
+	ServeRemovePost(stores.Moderation, stores.Answers, models.PostAnswer)(c, w, r)
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected a status code of 204, but recieved a status code of %d with the body %s", w.Code, w.Body.String())
+	}
+
+	if _, answer, err := stores.Questions.FindPostByID(question.ID); err != nil {
+		t.Fatal(err)
+	} else if answer == nil || answer.ID != tied.ID {
+		t.Errorf("Expected the tied answer to become the current answer, but recieved %+v", answer)
+	}
+}
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/mangoslicer/answer-patch/apierrors"
+	"github.com/mangoslicer/answer-patch/datastores"
+	m "github.com/mangoslicer/answer-patch/middleware"
+	"github.com/mangoslicer/answer-patch/models"
+	"github.com/mangoslicer/answer-patch/services"
+)
+
+// Length of the username column of ap_user
+const maxUsernameLength = 20
+
+// Characters that are dropped from the usernames suggested by providers
+var invalidUsernameChars = regexp.MustCompile("[^a-z0-9_]+")
+
+// ServeOIDCProviders lists the names of the providers that users can log in with
+func ServeOIDCProviders(providers map[string]*services.OIDCProvider) m.HandlerFunc {
+	return func(c *m.Context, w http.ResponseWriter, r *http.Request) {
+		services.PrintJSON(w, services.OIDCProviderNames(providers))
+	}
+}
+
+// ServeOIDCAuthorize begins a login with a provider, and returns the URL of the provider's login page, to which the client redirects the user.
+// A logged in user begins a login that links the identity to their account instead
+func ServeOIDCAuthorize(providers map[string]*services.OIDCProvider) m.HandlerFunc {
+	return func(c *m.Context, w http.ResponseWriter, r *http.Request) {
+
+		provider, ok := providers[mux.Vars(r)["provider"]]
+		if !ok {
+			services.PrintError(w, services.ErrUnknownOIDCProvider)
+			return
+		}
+
+		authorizationURL, err := c.BeginOIDCLogin(provider)
+		if err != nil {
+			services.PrintError(w, err)
+			return
+		}
+
+		services.PrintJSON(w, map[string]string{"authorizationURL": authorizationURL})
+	}
+}
+
+// ServeOIDCCallback completes a login with the code and state that the provider redirected back with. Users are logged in with the same tokens
+// as a password login, and are registered upon their first login. Logins begun to link an identity only link it, and only for the user whose JWT
+// began them, so that a leaked state can not link an identity to someone else's account
+func ServeOIDCCallback(store datastores.UserStoreServices, providers map[string]*services.OIDCProvider) m.HandlerFunc {
+	return func(c *m.Context, w http.ResponseWriter, r *http.Request) {
+
+		provider, ok := providers[mux.Vars(r)["provider"]]
+		if !ok {
+			services.PrintError(w, services.ErrUnknownOIDCProvider)
+			return
+		}
+
+		callback := c.ParsedModel.(*models.OIDCCallback)
+
+		claims, linkUserID, err := c.CompleteOIDCLogin(provider, callback.Code, callback.State)
+		if err != nil {
+			services.PrintError(w, err)
+			return
+		}
+
+		if linkUserID != "" {
+			if c.UserID != linkUserID {
+				services.PrintError(w, services.ErrOIDCLinkUser)
+				return
+			}
+			if err = store.LinkIdentity(linkUserID, provider.Name, claims.Subject); err != nil {
+				services.PrintError(w, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		user, err := store.FindUserByIdentity(provider.Name, claims.Subject)
+		if errors.Is(err, datastores.ErrUserNotFound) {
+			user, err = registerOIDCUser(store, provider.Name, claims)
+		}
+		if err != nil {
+			services.PrintError(w, err)
+			return
+		}
+
+		c.UserID = user.ID
+		token, err := c.IssueSession()
+		if err != nil {
+			services.PrintError(w, err)
+			return
+		}
+
+		services.PrintJSON(w, token)
+	}
+}
+
+// registerOIDCUser registers the user of an identity that is not linked yet. An identity whose email the provider verified is linked to the user
+// that verified the same email, since both belong to the owner of the email. Emails that another user registered without verifying are not taken over
+func registerOIDCUser(store datastores.UserStoreServices, provider string, claims *services.OIDCClaims) (*models.User, error) {
+
+	var email string
+	if normalized, err := normalizeEmail(claims.Email); claims.Email != "" && err == nil {
+
+		existing, err := store.FindUser("email", normalized)
+		if err == nil && existing.EmailVerified && claims.EmailVerified {
+			return linkOIDCUser(store, existing, false, provider, claims.Subject)
+		} else if errors.Is(err, datastores.ErrUserNotFound) {
+			email = normalized
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	// Users of providers log in through the provider, so they have no password until they reset one through their email
+	hashedPassword, err := services.UnusablePassword()
+	if err != nil {
+		return nil, err
+	}
+
+	username, err := storeOIDCUser(store, oidcUsername(claims), hashedPassword, email)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := store.FindUser("username", username)
+	if err != nil {
+		return nil, err
+	}
+
+	if email != "" && claims.EmailVerified {
+		if err = store.VerifyEmail(user.ID, email); err != nil {
+			log.Printf("Failed to verify the email of %s, which %s verified: %v", user.ID, provider, err)
+		}
+	}
+
+	return linkOIDCUser(store, user, true, provider, claims.Subject)
+}
+
+// linkOIDCUser links an identity to user. If a concurrent login linked the identity first, the user of the identity is returned instead,
+// and user is deleted if it was registered for the identity
+func linkOIDCUser(store datastores.UserStoreServices, user *models.User, registered bool, provider, subject string) (*models.User, error) {
+
+	err := store.LinkIdentity(user.ID, provider, subject)
+	if errors.Is(err, datastores.ErrIdentityLinked) {
+		if registered {
+			if err := store.DeleteUser(user.ID); err != nil {
+				log.Printf("Failed to delete %s, which lost the race to register an identity of %s: %v", user.ID, provider, err)
+			}
+		}
+		return store.FindUserByIdentity(provider, subject)
+	} else if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// storeOIDCUser stores a user under username, or under username with a random suffix if another user already has it
+func storeOIDCUser(store datastores.UserStoreServices, username, hashedPassword, email string) (string, error) {
+
+	base := username
+	if len(base) > maxUsernameLength-7 {
+		base = base[:maxUsernameLength-7]
+	}
+
+	for attempt := 0; ; attempt++ {
+
+		err := store.StoreUser(username, hashedPassword, email)
+		if err == nil {
+			return username, nil
+		}
+
+		var apiErr *apierrors.Error
+		if attempt == 4 || !errors.As(err, &apiErr) || apiErr.Code != apierrors.CodeNotUnique || len(apiErr.Fields) == 0 || apiErr.Fields[0].Field != "username" {
+			return "", err
+		}
+
+		// UnusablePassword prefixes the random hex with "!"
+		suffix, err := services.UnusablePassword()
+		if err != nil {
+			return "", err
+		}
+		username = base + "_" + suffix[1:7]
+	}
+}
+
+// oidcUsername suggests a username from the preferred username of the identity, or else the local part of its email
+func oidcUsername(claims *services.OIDCClaims) string {
+
+	username := claims.PreferredUsername
+	if username == "" {
+		username = strings.SplitN(claims.Email, "@", 2)[0]
+	}
+
+	username = invalidUsernameChars.ReplaceAllString(strings.ToLower(username), "")
+	if len(username) > maxUsernameLength {
+		username = username[:maxUsernameLength]
+	}
+	if len(username) < 3 {
+		username = "user"
+	}
+
+	return username
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/mangoslicer/answer-patch/datastores"
+	m "github.com/mangoslicer/answer-patch/middleware"
+	"github.com/mangoslicer/answer-patch/models"
+	auth "github.com/mangoslicer/answer-patch/services"
+	"github.com/mangoslicer/answer-patch/services/oidctest"
+	"github.com/mangoslicer/answer-patch/settings"
+)
+
+// oidcLogin logs in as subject through the handlers, and returns the response of the callback, which is sent by the user of ac
+func oidcLogin(t *testing.T, fake *oidctest.Provider, store datastores.UserStoreServices, providers map[string]*auth.OIDCProvider, ac *auth.AuthContext, subject string, claims map[string]interface{}) *httptest.ResponseRecorder {
+
+	r, err := http.NewRequest("POST", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r = mux.SetURLVars(r, map[string]string{"provider": "fake"})
+
+	w := httptest.NewRecorder()
+	ServeOIDCAuthorize(providers)(&m.Context{ac, nil, nil}, w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected the login to begin, but recieved a status code of %d", w.Code)
+	}
+
+	var body map[string]string
+	if err = json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+
+	code, state, err := fake.Authorize(body["authorizationURL"], subject, claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w = httptest.NewRecorder()
+	callbackContext := &m.Context{auth.NewAuthContext(ac.TokenStore), nil, &models.OIDCCallback{Code: code, State: state}}
+	callbackContext.UserID = ac.UserID
+	ServeOIDCCallback(store, providers)(callbackContext, w, r)
+
+	return w
+}
+
+func TestServeOIDCCallback(t *testing.T) {
+
+	cfg := settings.Defaults()
+	cfg.Keys = settings.KeyPaths{PrivateKey: "../settings/preproduction/private_key", PublicKey: "../settings/preproduction/public_key.pub"}
+	settings.Set(cfg)
+	defer settings.Set(settings.Defaults())
+
+	fake, err := oidctest.NewProvider()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fake.Close()
+
+	providers := auth.NewOIDCProviders(map[string]settings.OIDCProvider{"fake": fake.Config()})
+	store := datastores.NewMemoryStores(datastores.NewMemoryDB()).Users
+	ac := auth.NewAuthContext(datastores.NewMemoryTokenStore())
+	claims := map[string]interface{}{"email": "Tester@Example.com", "email_verified": true, "preferred_username": "Tester.One"}
+
+	// The first login registers the user
+	w := oidcLogin(t, fake, store, providers, ac, "subject-1", claims)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected a status code of 200, but recieved %d: %s", w.Code, w.Body)
+	}
+
+	token := new(auth.Token)
+	if err = json.NewDecoder(w.Body).Decode(token); err != nil || token.SignedToken == "" || token.RefreshToken == "" {
+		t.Fatalf("Expected the tokens of a password login, but recieved %+v (%v)", token, err)
+	}
+
+	user, err := store.FindUserByIdentity("fake", "subject-1")
+	if err != nil {
+		t.Fatal(err)
+	} else if user.Username != "testerone" || user.Email != "tester@example.com" || !user.EmailVerified {
+		t.Errorf("Expected a user with the verified email of the identity, but recieved %+v", user)
+	} else if auth.VerifyPassword(user.HashedPassword, "") {
+		t.Error("Expected the user to have no usable password")
+	}
+
+	// Later logins find the same user
+	w = oidcLogin(t, fake, store, providers, ac, "subject-1", claims)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected a status code of 200, but recieved %d", w.Code)
+	} else if again, _ := store.FindUserByIdentity("fake", "subject-1"); again.ID != user.ID {
+		t.Errorf("Expected the login to find user %s, but recieved %s", user.ID, again.ID)
+	}
+
+	// Another subject with the same verified email belongs to the same owner
+	w = oidcLogin(t, fake, store, providers, ac, "subject-2", claims)
+	if linked, err := store.FindUserByIdentity("fake", "subject-2"); err != nil || linked.ID != user.ID {
+		t.Errorf("Expected the identity to be linked to user %s, but recieved %+v (%v)", user.ID, linked, err)
+	}
+
+	// An unverified email is never matched, and the username is made unique
+	w = oidcLogin(t, fake, store, providers, ac, "subject-3", map[string]interface{}{"email": "tester@example.com", "preferred_username": "testerone"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected a status code of 200, but recieved %d: %s", w.Code, w.Body)
+	}
+	if other, err := store.FindUserByIdentity("fake", "subject-3"); err != nil {
+		t.Fatal(err)
+	} else if other.ID == user.ID || other.Email != "" || len(other.Username) != len("testerone_")+6 {
+		t.Errorf("Expected a new user without an email and with a suffixed username, but recieved %+v", other)
+	}
+}
+
+func TestServeOIDCCallbackLinksIdentity(t *testing.T) {
+
+	fake, err := oidctest.NewProvider()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fake.Close()
+
+	providers := auth.NewOIDCProviders(map[string]settings.OIDCProvider{"fake": fake.Config()})
+	store := datastores.NewMemoryStores(datastores.NewMemoryDB()).Users
+	if err = store.StoreUser("tester", "hash", ""); err != nil {
+		t.Fatal(err)
+	}
+	user, err := store.FindUser("username", "tester")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ac := auth.NewAuthContext(datastores.NewMemoryTokenStore())
+	ac.UserID = user.ID
+
+	w := oidcLogin(t, fake, store, providers, ac, "subject-1", nil)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected a status code of 204, but recieved %d: %s", w.Code, w.Body)
+	}
+
+	if linked, err := store.FindUserByIdentity("fake", "subject-1"); err != nil || linked.ID != user.ID {
+		t.Errorf("Expected the identity to be linked to user %s, but recieved %+v (%v)", user.ID, linked, err)
+	}
+
+	// The callback of a link must be sent with the JWT of the user that began it
+	r, err := http.NewRequest("POST", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r = mux.SetURLVars(r, map[string]string{"provider": "fake"})
+
+	w = httptest.NewRecorder()
+	ServeOIDCAuthorize(providers)(&m.Context{ac, nil, nil}, w, r)
+	var body map[string]string
+	if err = json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	code, state, err := fake.Authorize(body["authorizationURL"], "subject-2", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w = httptest.NewRecorder()
+	ServeOIDCCallback(store, providers)(&m.Context{auth.NewAuthContext(ac.TokenStore), nil, &models.OIDCCallback{Code: code, State: state}}, w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected a status code of 401, but recieved %d: %s", w.Code, w.Body)
+	} else if _, err = store.FindUserByIdentity("fake", "subject-2"); err == nil {
+		t.Error("Expected the identity to remain unlinked")
+	}
+
+	// The identity can not be linked to another user
+	if err = store.StoreUser("other", "other hash", ""); err != nil {
+		t.Fatal(err)
+	}
+	other, _ := store.FindUser("username", "other")
+	ac.UserID = other.ID
+
+	w = oidcLogin(t, fake, store, providers, ac, "subject-1", nil)
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected a status code of 409, but recieved %d", w.Code)
+	}
+}
+
+func TestServeOIDCAuthorizeWithUnknownProvider(t *testing.T) {
+
+	r, err := http.NewRequest("POST", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r = mux.SetURLVars(r, map[string]string{"provider": "unknown"})
+
+	w := httptest.NewRecorder()
+	ServeOIDCAuthorize(map[string]*auth.OIDCProvider{})(m.NewContext(), w, r)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected a status code of 404, but recieved %d", w.Code)
+	}
+}
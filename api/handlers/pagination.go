@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/mangoslicer/answer-patch/apierrors"
+	"github.com/mangoslicer/answer-patch/models"
+	"github.com/mangoslicer/answer-patch/settings"
+)
+
+// parsePageRequest reads the cursor and limit parameters of a list request. The limit defaults to rules.defaultPageSize and can not exceed rules.maxPageSize
+func parsePageRequest(r *http.Request) (models.PageRequest, error) {
+
+	params := r.URL.Query()
+	rules := settings.Get().Rules
+
+	page := models.PageRequest{Cursor: params.Get("cursor"), Size: rules.DefaultPageSize}
+
+	if limit := params.Get("limit"); limit != "" {
+		size, err := strconv.Atoi(limit)
+		if err != nil || size < 1 || size > rules.MaxPageSize {
+			return page, apierrors.New(apierrors.KindInvalid, apierrors.CodeInvalidField, "The limit must be between 1 and "+strconv.Itoa(rules.MaxPageSize), apierrors.FieldError{Field: "limit", Reason: "invalid"})
+		}
+		page.Size = size
+	}
+
+	return page, nil
+}
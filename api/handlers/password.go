@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/mangoslicer/answer-patch/datastores"
+	m "github.com/mangoslicer/answer-patch/middleware"
+	"github.com/mangoslicer/answer-patch/models"
+	"github.com/mangoslicer/answer-patch/services"
+	"github.com/mangoslicer/answer-patch/settings"
+)
+
+// resetMails tracks the password reset mails that are being sent in the background
+var resetMails sync.WaitGroup
+
+// ServeForgotPassword mails a password reset link to a verified email. The response is the same whether or not a user has the email,
+// so the endpoint can not be used to find out which emails are registered
+func ServeForgotPassword(store datastores.UserStoreServices, mailer services.MailSender) m.HandlerFunc {
+	return func(c *m.Context, w http.ResponseWriter, r *http.Request) {
+
+		forgotten := c.ParsedModel.(*models.ForgottenPassword)
+
+		email, err := normalizeEmail(forgotten.Email)
+		if err != nil {
+			services.PrintError(w, err)
+			return
+		}
+
+		// The user is looked up and mailed in the background, since the response would otherwise take longer for verified emails
+		resetMails.Add(1)
+		go func() {
+			defer resetMails.Done()
+			if err := sendPasswordResetMail(c, store, mailer, email); err != nil {
+				log.Printf("Failed to mail a password reset link: %v", err)
+			}
+		}()
+
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+// ServeResetPassword sets a new password with the token of a password reset link, and logs the user out everywhere
+func ServeResetPassword(store datastores.UserStoreServices) m.HandlerFunc {
+	return func(c *m.Context, w http.ResponseWriter, r *http.Request) {
+
+		reset := c.ParsedModel.(*models.PasswordReset)
+
+		token, err := c.ParseActionToken(services.PasswordResetPurpose, reset.Token)
+		if err != nil {
+			services.PrintError(w, err)
+			return
+		}
+
+		// Reset tokens are bound to the password they reset, so they are invalid once the password has changed in any way
+		user, err := store.FindUser("id", token.UserID)
+		if errors.Is(err, datastores.ErrUserNotFound) {
+			services.PrintError(w, services.ErrInvalidActionToken)
+			return
+		} else if err != nil {
+			services.PrintError(w, err)
+			return
+		} else if services.PasswordFingerprint(user.HashedPassword) != token.Binding {
+			services.PrintError(w, services.ErrInvalidActionToken)
+			return
+		}
+
+		// The password is checked before the token is consumed, so a rejected password does not use up the link
+		if err = services.CheckPassword("newPassword", user.Username, reset.NewPassword); err != nil {
+			services.PrintError(w, err)
+			return
+		}
+
+		if err = c.ConsumeActionToken(token); err != nil {
+			services.PrintError(w, err)
+			return
+		}
+
+		hashedPassword, err := services.HashPassword(reset.NewPassword)
+		if err != nil {
+			services.PrintError(w, err)
+			return
+		}
+
+		if err = store.UpdatePassword(user.ID, hashedPassword); err != nil {
+			services.PrintError(w, err)
+			return
+		}
+
+		// Whoever knew the old password is logged out
+		c.UserID = user.ID
+		if err = c.RevokeAllSessions(); err != nil {
+			services.PrintError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// sendPasswordResetMail mails a reset link if a user has verified the email. Unknown and unverified emails are silently skipped
+func sendPasswordResetMail(c *m.Context, store datastores.UserStoreServices, mailer services.MailSender, email string) error {
+
+	user, err := store.FindUser("email", email)
+	if errors.Is(err, datastores.ErrUserNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	} else if !user.EmailVerified {
+		return nil
+	}
+
+	life := time.Duration(settings.Get().Rules.PasswordResetLifeMinutes) * time.Minute
+	token, err := c.IssueActionToken(services.PasswordResetPurpose, user.ID, services.PasswordFingerprint(user.HashedPassword), life)
+	if err != nil {
+		return err
+	}
+
+	return mailer.Send(services.NewPasswordResetMail(user.Email, user.Username, token))
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mangoslicer/answer-patch/datastores"
+	m "github.com/mangoslicer/answer-patch/middleware"
+	"github.com/mangoslicer/answer-patch/models"
+	auth "github.com/mangoslicer/answer-patch/services"
+	"github.com/mangoslicer/answer-patch/settings"
+)
+
+func TestServeForgotPassword(t *testing.T) {
+
+	cfg := settings.Defaults()
+	cfg.Keys = settings.KeyPaths{PrivateKey: "../settings/preproduction/private_key", PublicKey: "../settings/preproduction/public_key.pub"}
+	settings.Set(cfg)
+	defer settings.Set(settings.Defaults())
+
+	r, err := http.NewRequest("", "", nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	var forgotTests = []struct {
+		store *MockUserStore
+		sent  int
+	}{
+		{&MockUserStore{FindUserErr: datastores.ErrUserNotFound}, 0},
+		{&MockUserStore{User: &models.User{ID: "ID", Username: "Tester", Email: "tester@example.com"}}, 0},
+		{&MockUserStore{User: &models.User{ID: "ID", Username: "Tester", Email: "tester@example.com", EmailVerified: true}}, 1},
+	}
+
+	for i, ft := range forgotTests {
+
+		mailer := &MockMailSender{}
+		ac := auth.NewAuthContext(datastores.NewMemoryTokenStore())
+		w := httptest.NewRecorder()
+		ServeForgotPassword(ft.store, mailer)(&m.Context{ac, nil, &models.ForgottenPassword{Email: "Tester@example.com"}}, w, r)
+		resetMails.Wait()
+
+		// Every email recieves the same response, so that registered emails can not be told apart
+		if w.Code != http.StatusAccepted {
+			t.Errorf("Expected a status code of 202 for test %d, but recieved %d", i, w.Code)
+		} else if len(mailer.Sent) != ft.sent {
+			t.Errorf("Expected %d mails to be sent for test %d, but recieved %d", ft.sent, i, len(mailer.Sent))
+		} else if ft.sent > 0 && !strings.Contains(mailer.Sent[0].Body, "/reset-password?token=") {
+			t.Errorf("Expected the mail to contain a reset link, but recieved %s", mailer.Sent[0].Body)
+		}
+	}
+}
+
+// BlockingMailSender holds every mail until it is released, like a slow mail server
+type BlockingMailSender struct {
+	MockMailSender
+	Release chan struct{}
+}
+
+func (sender *BlockingMailSender) Send(mail *auth.Mail) error {
+	<-sender.Release
+	return sender.MockMailSender.Send(mail)
+}
+
+/**
+ * Tests that the response does not wait for the reset mail, which would reveal that a verified user has the email
+ */
+func TestServeForgotPasswordDoesNotWaitForMail(t *testing.T) {
+
+	cfg := settings.Defaults()
+	cfg.Keys = settings.KeyPaths{PrivateKey: "../settings/preproduction/private_key", PublicKey: "../settings/preproduction/public_key.pub"}
+	settings.Set(cfg)
+	defer settings.Set(settings.Defaults())
+
+	r, err := http.NewRequest("", "", nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	store := &MockUserStore{User: &models.User{ID: "ID", Username: "Tester", Email: "tester@example.com", EmailVerified: true}}
+	mailer := &BlockingMailSender{Release: make(chan struct{})}
+	ac := auth.NewAuthContext(datastores.NewMemoryTokenStore())
+
+	w := httptest.NewRecorder()
+	ServeForgotPassword(store, mailer)(&m.Context{ac, nil, &models.ForgottenPassword{Email: "tester@example.com"}}, w, r)
+
+	if w.Code != http.StatusAccepted {
+		t.Errorf("Expected a status code of 202 while the mail is being sent, but recieved %d", w.Code)
+	}
+
+	close(mailer.Release)
+	resetMails.Wait()
+	if len(mailer.Sent) != 1 {
+		t.Errorf("Expected the mail to be sent once released, but recieved %d mails", len(mailer.Sent))
+	}
+}
+
+func TestServeResetPassword(t *testing.T) {
+
+	cfg := settings.Defaults()
+	cfg.Keys = settings.KeyPaths{PrivateKey: "../settings/preproduction/private_key", PublicKey: "../settings/preproduction/public_key.pub"}
+	cfg.Passwords.Argon2MemoryKiB = 1024
+	settings.Set(cfg)
+	defer settings.Set(settings.Defaults())
+
+	r, err := http.NewRequest("", "", nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	oldHash := "$2a$10$XgxVoZidTuxugFcAkBipBeSYxFJSLv/w0t1Lt7ihTOu0cThCBHgA."
+	store := &MockUserStore{User: &models.User{ID: "ID", Username: "Username", HashedPassword: oldHash, Email: "tester@example.com", EmailVerified: true}}
+	mailer := &MockMailSender{}
+	ac := auth.NewAuthContext(datastores.NewMemoryTokenStore())
+
+	ServeForgotPassword(store, mailer)(&m.Context{ac, nil, &models.ForgottenPassword{Email: "tester@example.com"}}, httptest.NewRecorder(), r)
+	resetMails.Wait()
+	if len(mailer.Sent) != 1 {
+		t.Fatalf("Expected a reset mail to be sent, but recieved %d mails", len(mailer.Sent))
+	}
+	body := mailer.Sent[0].Body
+	token := strings.Fields(body[strings.Index(body, "token=")+len("token="):])[0]
+
+	var resetTests = []struct {
+		reset *models.PasswordReset
+		code  int
+	}{
+		{&models.PasswordReset{Token: "invalid", NewPassword: "New-Passw0rd"}, http.StatusBadRequest},
+		// A rejected password does not use up the token
+		{&models.PasswordReset{Token: token, NewPassword: "weak"}, http.StatusBadRequest},
+		{&models.PasswordReset{Token: token, NewPassword: "New-Passw0rd"}, http.StatusNoContent},
+	}
+
+	for _, rt := range resetTests {
+
+		w := httptest.NewRecorder()
+		ServeResetPassword(store)(&m.Context{ac, nil, rt.reset}, w, r)
+
+		if w.Code != rt.code {
+			t.Errorf("Expected a status code of %d for %+v, but recieved %d", rt.code, rt.reset, w.Code)
+		}
+	}
+
+	if !auth.VerifyPassword(store.UpdatedPassword, "New-Passw0rd") {
+		t.Fatalf("Expected the new password to be stored, but recieved %q", store.UpdatedPassword)
+	}
+
+	w := httptest.NewRecorder()
+	ServeResetPassword(store)(&m.Context{ac, nil, &models.PasswordReset{Token: token, NewPassword: "Other-Passw0rd"}}, w, r)
+	if w.Code != http.StatusGone {
+		t.Errorf("Expected a used token to be rejected with a status code of 410, but recieved %d", w.Code)
+	}
+
+	// Tokens are bound to the password they reset, so an unused token is invalid once the password has changed
+	unused, err := ac.IssueActionToken(auth.PasswordResetPurpose, "ID", auth.PasswordFingerprint(oldHash), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.User.HashedPassword = store.UpdatedPassword
+	w = httptest.NewRecorder()
+	ServeResetPassword(store)(&m.Context{ac, nil, &models.PasswordReset{Token: unused, NewPassword: "Other-Passw0rd"}}, w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected a token of the old password to be rejected with a status code of 400, but recieved %d", w.Code)
+	}
+}
@@ -2,25 +2,25 @@ package handlers
 
 import (
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/mangoslicer/answer-patch/apierrors"
 	"github.com/mangoslicer/answer-patch/datastores"
 	m "github.com/mangoslicer/answer-patch/middleware"
 	"github.com/mangoslicer/answer-patch/models"
 	"github.com/mangoslicer/answer-patch/services"
-)
-
-const (
-	MAX_REP             = 25
-	QUESTION_ASKING_FEE = -2
+	"github.com/mangoslicer/answer-patch/settings"
 )
 
 func ServePostByID(store datastores.QuestionStoreServices) m.HandlerFunc {
 	return func(c *m.Context, w http.ResponseWriter, r *http.Request) {
 		var post []models.ModelServices
-		question, answer, err, statusCode := store.FindPostByID(mux.Vars(r)["questionId"])
+		question, answer, err := store.FindPostByID(mux.Vars(r)["questionId"])
 		if err != nil {
-			http.Error(w, err.Error(), statusCode)
+			services.PrintError(w, err)
 			return
 		}
 
@@ -35,9 +35,15 @@ func ServePostByID(store datastores.QuestionStoreServices) m.HandlerFunc {
 func ServeQuestionsByFilter(store datastores.QuestionStoreServices) m.HandlerFunc {
 	return func(c *m.Context, w http.ResponseWriter, r *http.Request) {
 
-		questions, err, statusCode := store.FindQuestionsByFilter(mux.Vars(r)["filter"], mux.Vars(r)["val"])
+		page, err := parsePageRequest(r)
+		if err != nil {
+			services.PrintError(w, err)
+			return
+		}
+
+		questions, err := store.FindQuestionsByFilter(mux.Vars(r)["filter"], mux.Vars(r)["val"], page)
 		if err != nil {
-			http.Error(w, err.Error(), statusCode)
+			services.PrintError(w, err)
 			return
 		}
 		services.PrintJSON(w, questions)
@@ -48,29 +54,176 @@ func ServeQuestionsByFilter(store datastores.QuestionStoreServices) m.HandlerFun
 func ServeSortedQuestions(store datastores.QuestionStoreServices) m.HandlerFunc {
 	return func(c *m.Context, w http.ResponseWriter, r *http.Request) {
 		routeVars := mux.Vars(r)
-		questions, err, statusCode := store.SortQuestions(routeVars["postComponent"], routeVars["sortedBy"], routeVars["order"], routeVars["offset"])
+
+		page, err := parsePageRequest(r)
+		if err != nil {
+			services.PrintError(w, err)
+			return
+		}
+
+		// The route names the post component in plural, while the store expects it in singular
+		postComponent := strings.TrimSuffix(routeVars["postComponent"], "s")
+
+		questions, err := store.SortQuestions(postComponent, routeVars["sortedBy"], routeVars["order"], page)
+		if err != nil {
+			services.PrintError(w, err)
+			return
+		}
+		services.PrintJSON(w, questions)
+	}
+}
+
+// ServeQueryQuestions lists a page of the questions that match the filters of the query string, which can be combined freely:
+// category (repeated or comma separated), author, answered, hasPending, from and to (RFC 3339 times or dates) and minUpvotes.
+// The questions are sorted by sort, one of upvotes, date, edits or pending, in the order of order. The newest questions are listed first by default
+func ServeQueryQuestions(store datastores.QuestionStoreServices) m.HandlerFunc {
+	return func(c *m.Context, w http.ResponseWriter, r *http.Request) {
+
+		query, err := parseQuestionQuery(r)
+		if err != nil {
+			services.PrintError(w, err)
+			return
+		}
+
+		page, err := parsePageRequest(r)
 		if err != nil {
-			http.Error(w, err.Error(), statusCode)
+			services.PrintError(w, err)
+			return
+		}
+
+		questions, err := store.QueryQuestions(query, page)
+		if err != nil {
+			services.PrintError(w, err)
 			return
 		}
 		services.PrintJSON(w, questions)
 	}
 }
 
-func ServeSubmitQuestion(store datastores.QuestionStoreServices) m.HandlerFunc {
+func parseQuestionQuery(r *http.Request) (*models.QuestionQuery, error) {
+
+	params := r.URL.Query()
+	query := &models.QuestionQuery{Author: params.Get("author"), Sort: "date", Descending: true}
+
+	for _, param := range params["category"] {
+		for _, category := range strings.Split(param, ",") {
+			if category = strings.TrimSpace(category); category != "" {
+				query.Categories = append(query.Categories, category)
+			}
+		}
+	}
+
+	var err error
+	if query.Answered, err = parseBoolParam(params.Get("answered"), "answered"); err != nil {
+		return nil, err
+	}
+	if query.HasPending, err = parseBoolParam(params.Get("hasPending"), "hasPending"); err != nil {
+		return nil, err
+	}
+	if query.From, err = parseTimeParam(params.Get("from"), "from"); err != nil {
+		return nil, err
+	}
+	if query.To, err = parseTimeParam(params.Get("to"), "to"); err != nil {
+		return nil, err
+	}
+
+	if minUpvotes := params.Get("minUpvotes"); minUpvotes != "" {
+		n, err := strconv.Atoi(minUpvotes)
+		if err != nil {
+			return nil, errInvalidParam("minUpvotes", "an integer")
+		}
+		query.MinUpvotes = &n
+	}
+
+	if sort := params.Get("sort"); sort != "" {
+		query.Sort = sort
+	}
+	if order := params.Get("order"); order != "" {
+		switch strings.ToLower(order) {
+		case "asc":
+			query.Descending = false
+		case "desc":
+			query.Descending = true
+		default:
+			return nil, errInvalidParam("order", "asc or desc")
+		}
+	}
+
+	return query, nil
+}
+
+// parseBoolParam returns nil for an empty parameter, which leaves its filter out
+func parseBoolParam(param, field string) (*bool, error) {
+
+	if param == "" {
+		return nil, nil
+	}
+
+	b, err := strconv.ParseBool(param)
+	if err != nil {
+		return nil, errInvalidParam(field, "true or false")
+	}
+
+	return &b, nil
+}
+
+// parseTimeParam returns the zero time for an empty parameter, which leaves its filter out. Dates stand for midnight UTC
+func parseTimeParam(param, field string) (time.Time, error) {
+
+	if param == "" {
+		return time.Time{}, nil
+	}
+
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.Parse(layout, param); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, errInvalidParam(field, "an RFC 3339 time or a date")
+}
+
+func errInvalidParam(field, expected string) error {
+	return apierrors.New(apierrors.KindInvalid, apierrors.CodeInvalidField, "The "+field+" parameter must be "+expected, apierrors.FieldError{Field: field, Reason: "invalid"})
+}
+
+// ServeSubmitQuestion stores a question, unless it resembles questions of its category. The candidate duplicates are then reported instead,
+// so that the client can either resubmit the question with force, or link it as a duplicate of one of them
+func ServeSubmitQuestion(store datastores.QuestionStoreServices, categoryStore datastores.CategoryStoreServices) m.HandlerFunc {
 	return func(c *m.Context, w http.ResponseWriter, r *http.Request) {
 
 		newQuestion := c.ParsedModel.(*models.Question)
-		category := mux.Vars(r)["category"]
+		categoryName := mux.Vars(r)["category"]
+
+		category, err := categoryStore.FindCategory(categoryName)
+		if err != nil {
+			services.PrintError(w, err)
+			return
+		}
 
-		err := c.RepStore.UpdateRep(category, c.UserID, QUESTION_ASKING_FEE)
+		if !newQuestion.Force && newQuestion.DuplicateOf == "" {
+			candidates, err := store.FindSimilarQuestions(category.ID, newQuestion.Title, newQuestion.Content)
+			if err != nil {
+				services.PrintError(w, err)
+				return
+			} else if len(candidates) != 0 {
+				duplicateErr := apierrors.New(apierrors.KindConflict, apierrors.CodePossibleDuplicate, "The question may duplicate the listed questions. Submit it with force, or link it as a duplicate of one of them")
+				duplicateErr.Data = candidates
+				services.PrintError(w, duplicateErr)
+				return
+			}
+		}
+
+		// StoreQuestion rejects titles that are taken and duplicates that do not exist, so the fee is only charged once the question is stored
+		err = store.StoreQuestion(newQuestion.UserID, category.ID, newQuestion.Title, newQuestion.Content, newQuestion.DuplicateOf)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			services.PrintError(w, err)
+			return
 		}
 
-		err, statusCode := store.StoreQuestion(newQuestion.UserID, category, newQuestion.Title, newQuestion.Content)
+		err = c.RepStore.UpdateRep(categoryName, c.UserID, settings.Get().Rules.QuestionAskingFee)
 		if err != nil {
-			http.Error(w, err.Error(), statusCode)
+			services.PrintError(w, err)
 			return
 		}
 
@@ -79,31 +232,34 @@ func ServeSubmitQuestion(store datastores.QuestionStoreServices) m.HandlerFunc {
 	}
 }
 
-func ServeCastQuestionVote(store datastores.AnswerStoreServices) m.HandlerFunc {
+func ServeCastQuestionVote(store datastores.QuestionStoreServices) m.HandlerFunc {
 	return func(c *m.Context, w http.ResponseWriter, r *http.Request) {
 		vote := 1
 		urlParams := mux.Vars(r)
 
-		if urlParams["vote"] == "downvote" {
+		if urlParams["vote"] == "-1" {
 			vote = -1
 		}
 
-		voteRecipient, err, statusCode := store.CastVote(urlParams["questionID"], vote)
+		voteRecipient, change, err := store.CastVote(urlParams["questionID"], urlParams["category"], c.UserID, vote)
 		if err != nil {
-			http.Error(w, err.Error(), statusCode)
+			services.PrintError(w, err)
+			return
+		} else if change == 0 { // The user had already cast the same vote
+			return
 		}
 
 		rep, err := c.RepStore.FindRep(urlParams["category"], voteRecipient)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-		}
-		if rep <= MAX_REP {
-			c.RepStore.UpdateRep(urlParams["category"], voteRecipient, 1)
+			services.PrintError(w, err)
+			return
 		}
-
-		err, statusCode = store.AssessAnswers(urlParams["questionID"])
-		if err != nil {
-			http.Error(w, err.Error(), statusCode)
+		if rep <= settings.Get().Rules.MaxRep {
+			err = c.RepStore.UpdateRep(urlParams["category"], voteRecipient, change)
+			if err != nil {
+				services.PrintError(w, err)
+				return
+			}
 		}
 	}
 }
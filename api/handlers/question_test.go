@@ -1,11 +1,15 @@
 package handlers
 
 import (
-	"errors"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/mangoslicer/answer-patch/apierrors"
+	"github.com/mangoslicer/answer-patch/datastores"
 	m "github.com/mangoslicer/answer-patch/middleware"
 	"github.com/mangoslicer/answer-patch/models"
 	auth "github.com/mangoslicer/answer-patch/services"
@@ -13,27 +17,45 @@ import (
 
 type MockQuestionStore struct {
 	ExistingID string
+	VoteChange int
+	Similar    []*models.DuplicateCandidate
+	Query      *models.QuestionQuery // The query that QueryQuestions was last called with
 }
 
 type MockRepStore struct {
+	TotalRep  int
+	Updates   int    // Number of times that UpdateRep was called
+	RenamedTo string // Name that RenameCategory was last called with
 }
 
-func (store *MockQuestionStore) FindPostByID(id string) (*models.Question, *models.Answer, error, int) {
-	return nil, nil, errors.New("No question exists with the provided id"), http.StatusBadRequest
+func (store *MockQuestionStore) FindPostByID(id string) (*models.Question, *models.Answer, error) {
+	return nil, nil, apierrors.New(apierrors.KindNotFound, apierrors.CodeQuestionNotFound, "No question exists with the provided id")
 
 }
 
-func (store *MockQuestionStore) FindQuestionsByFilter(filter, val string) ([]*models.Question, error, int) {
-	return nil, errors.New("No question(s) found with the provided query"), http.StatusBadRequest
+func (store *MockQuestionStore) FindQuestionsByFilter(filter, val string, page models.PageRequest) (*models.QuestionPage, error) {
+	return &models.QuestionPage{Items: []*models.Question{}}, nil
+}
+
+func (store *MockQuestionStore) SortQuestions(postComponent, filter, order string, page models.PageRequest) (*models.QuestionPage, error) {
+	return &models.QuestionPage{Items: []*models.Question{}}, nil
+}
+
+func (store *MockQuestionStore) QueryQuestions(query *models.QuestionQuery, page models.PageRequest) (*models.QuestionPage, error) {
+	store.Query = query
+	return &models.QuestionPage{Items: []*models.Question{}}, nil
+}
 
+func (store *MockQuestionStore) StoreQuestion(user_id, title, content, category, duplicateOf string) error {
+	return apierrors.New(apierrors.KindConflict, apierrors.CodeNotUnique, "The provided title is not unique")
 }
 
-func (store *MockQuestionStore) SortQuestions(postComponent, filter, order, offset string) ([]*models.Question, error, int) {
-	return nil, errors.New("No questions match the specifications in the url"), http.StatusBadRequest
+func (store *MockQuestionStore) FindSimilarQuestions(categoryID, title, content string) ([]*models.DuplicateCandidate, error) {
+	return store.Similar, nil
 }
 
-func (store *MockQuestionStore) StoreQuestion(user_id, title, content, category string) (error, int) {
-	return errors.New("The provided title is not unique"), http.StatusBadRequest
+func (store *MockQuestionStore) CastVote(questionID, category, userID string, vote int) (string, int, error) {
+	return "1", store.VoteChange, nil
 }
 
 func (store *MockRepStore) FindRep(category, userID string) (int, error) {
@@ -41,6 +63,16 @@ func (store *MockRepStore) FindRep(category, userID string) (int, error) {
 }
 
 func (store *MockRepStore) UpdateRep(category, userID string, rep int) error {
+	store.Updates++
+	return nil
+}
+
+func (store *MockRepStore) FindTotalRep(userID string) (int, error) {
+	return store.TotalRep, nil
+}
+
+func (store *MockRepStore) RenameCategory(name, newName string) error {
+	store.RenamedTo = newName
 	return nil
 }
 
@@ -55,9 +87,9 @@ func TestServePostByIDWithInvalidID(t *testing.T) {
 
 	ServePostByID(new(MockQuestionStore))(m.NewContext(), w, r)
 
-	if w.Code != http.StatusBadRequest {
-		t.Errorf("Expected a status code of 400, but recieved an http status code of %d", w.Code)
-	} else if w.Body.String() != "No question exists with the provided id\n" {
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected a status code of 404, but recieved an http status code of %d", w.Code)
+	} else if decodeProblem(t, w).Detail != "No question exists with the provided id" {
 		t.Errorf("Expected the content of the responsewriter to be \"No question exists with the provided id\", but instead the responsewriter contains %s", w.Body.String())
 	}
 }
@@ -74,10 +106,10 @@ func TestServeQuestionsByFilterWithInvalidAuthor(t *testing.T) {
 
 	ServeQuestionsByFilter(new(MockQuestionStore))(m.NewContext(), w, r)
 
-	if w.Code != http.StatusBadRequest {
-		t.Errorf("Expected a status code of 400, because the MockQuestionStore's FindQuestionsByAuthor method always returns nil as a result, but recieved an http status code of %d", w.Code)
-	} else if w.Body.String() != "No question(s) found with the provided query\n" {
-		t.Errorf("Expected the content of the responsewriter to be \"No question(s) found with the provided query\", but instead the responsewriter contains %s", w.Body.String())
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected a status code of 200, because an author without questions is listed as an empty page, but recieved an http status code of %d", w.Code)
+	} else if page := new(models.QuestionPage); json.Unmarshal(w.Body.Bytes(), page) != nil || page.Items == nil || len(page.Items) != 0 {
+		t.Errorf("Expected the responsewriter to contain an empty page, but instead the responsewriter contains %s", w.Body.String())
 	}
 }
 
@@ -93,10 +125,76 @@ func TestServeSortedQuestions(t *testing.T) {
 
 	ServeSortedQuestions(new(MockQuestionStore))(m.NewContext(), w, r)
 
-	if w.Code != http.StatusBadRequest {
-		t.Errorf("Expected a status code of 400, because the MockQuestionStore's FindQuestionsByFilter method always returns nil as a result, but recieved an http status code of %d", w.Code)
-	} else if w.Body.String() != "No questions match the specifications in the url\n" {
-		t.Errorf("Expected the content of the responsewriter to be \"No questions match the specifications in the url\", but instead the responsewriter contains %s", w.Body.String())
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected a status code of 200, because filters that no questions satisfy list an empty page, but recieved an http status code of %d", w.Code)
+	} else if page := new(models.QuestionPage); json.Unmarshal(w.Body.Bytes(), page) != nil || page.Items == nil || len(page.Items) != 0 {
+		t.Errorf("Expected the responsewriter to contain an empty page, but instead the responsewriter contains %s", w.Body.String())
+	}
+}
+
+func TestServeQueryQuestions(t *testing.T) {
+
+	r, err := http.NewRequest("GET", "api/questions?category=Gains,balling&category=city%20dining&answered=false&from=2024-01-01&minUpvotes=3&sort=pending&order=asc", nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	w := httptest.NewRecorder()
+	store := new(MockQuestionStore)
+
+	ServeQueryQuestions(store)(m.NewContext(), w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected a status code of 200, but recieved an http status code of %d", w.Code)
+	}
+
+	query := store.Query
+	if strings.Join(query.Categories, "|") != "Gains|balling|city dining" {
+		t.Errorf("Expected the categories Gains, balling and city dining, but recieved %v", query.Categories)
+	}
+	if query.Answered == nil || *query.Answered || query.HasPending != nil {
+		t.Errorf("Expected only the answered filter to be set to false, but recieved %v and %v", query.Answered, query.HasPending)
+	}
+	if !query.From.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) || !query.To.IsZero() {
+		t.Errorf("Expected the questions from the start of 2024 on, but recieved %s to %s", query.From, query.To)
+	}
+	if query.MinUpvotes == nil || *query.MinUpvotes != 3 || query.Sort != "pending" || query.Descending {
+		t.Errorf("Expected at least 3 upvotes sorted by ascending pending answers, but recieved %+v", query)
+	}
+}
+
+func TestServeQueryQuestionsWithInvalidQuery(t *testing.T) {
+
+	query := ServeQueryQuestions(datastores.NewMemoryStores(datastores.NewMemoryDB()).Questions)
+
+	cases := []struct {
+		url  string
+		code apierrors.Code
+	}{
+		{"api/questions?answered=maybe", apierrors.CodeInvalidField},
+		{"api/questions?hasPending=2", apierrors.CodeInvalidField},
+		{"api/questions?from=yesterday", apierrors.CodeInvalidField},
+		{"api/questions?to=2024-13-01", apierrors.CodeInvalidField},
+		{"api/questions?minUpvotes=many", apierrors.CodeInvalidField},
+		{"api/questions?order=up", apierrors.CodeInvalidField},
+		{"api/questions?limit=0", apierrors.CodeInvalidField},
+		{"api/questions?sort=views", apierrors.CodeInvalidSortCriteria},
+	}
+
+	for _, tc := range cases {
+		r, err := http.NewRequest("GET", tc.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		query(&m.Context{}, w, r)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected a status code of 400 for %s, but recieved a status code of %d", tc.url, w.Code)
+		} else if code := decodeProblem(t, w).Code; code != tc.code {
+			t.Errorf("Expected the error code %s for %s, but recieved %s", tc.code, tc.url, code)
+		}
 	}
 }
 
@@ -104,7 +202,8 @@ func TestServeSubmitQuestionWithExistingQuestion(t *testing.T) {
 
 	existingQuestion := &models.Question{UserID: "0c1b2b91-9164-4d52-87b0-9c4b444ee62d", Username: "Tester1", Title: "Where is the best sushi place?", Content: "I have cravings"}
 
-	c := &m.Context{auth.NewAuthContext(nil), &MockRepStore{}, existingQuestion}
+	repStore := &MockRepStore{}
+	c := &m.Context{auth.NewAuthContext(nil), repStore, existingQuestion}
 
 	r, err := http.NewRequest("POST", "api/question/TestCategory", nil)
 	if err != nil {
@@ -113,12 +212,71 @@ func TestServeSubmitQuestionWithExistingQuestion(t *testing.T) {
 
 	w := httptest.NewRecorder()
 
-	ServeSubmitQuestion(&MockQuestionStore{ExistingID: "526c4576-0e49-4e90-b760-e6976c698574"})(c, w, r)
+	ServeSubmitQuestion(&MockQuestionStore{ExistingID: "526c4576-0e49-4e90-b760-e6976c698574"}, &MockCategoryStore{Category: &models.Category{ID: "7d2b570d-54c6-48b1-8f46-68304f163d6a", Name: "TestCategory"}})(c, w, r)
 
-	if w.Code != http.StatusBadRequest {
-		t.Errorf("Expected a status code of 400 due to the existence of a question with the same title as that of the question recieved in the request body, recieved a status code of %d", w.Code)
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected a status code of 409 due to the existence of a question with the same title as that of the question recieved in the request body, recieved a status code of %d", w.Code)
 
-	} else if w.Body.String() != "The provided title is not unique\n" {
+	} else if decodeProblem(t, w).Detail != "The provided title is not unique" {
 		t.Errorf("Expected the content of the responsewriter to be \"The provided title is not unique\", but instead the responsewriter contains %s", w.Body.String())
 	}
+
+	if repStore.Updates != 0 {
+		t.Errorf("Expected no fee to be charged for a question that was not stored, but the rep was updated %d times", repStore.Updates)
+	}
+}
+
+func TestServeSubmitQuestionWithPossibleDuplicate(t *testing.T) {
+
+	similar := []*models.DuplicateCandidate{{QuestionID: "526c4576-0e49-4e90-b760-e6976c698574", Username: "Tester1", Title: "Where is the best sushi place?", Score: 0.6}}
+	category := &models.Category{ID: "7d2b570d-54c6-48b1-8f46-68304f163d6a", Name: "City Dining"}
+
+	r, err := http.NewRequest("POST", "api/question/TestCategory", nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	newQuestion := &models.Question{UserID: "95954f28-a8c3-4e76-8c80-18de07931639", Username: "Tester2", Title: "Where is the best sushi restaurant?"}
+	w := httptest.NewRecorder()
+
+	ServeSubmitQuestion(&MockQuestionStore{Similar: similar}, &MockCategoryStore{Category: category})(&m.Context{auth.NewAuthContext(nil), &MockRepStore{}, newQuestion}, w, r)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected a status code of 409, because the question resembles an existing question, but recieved a status code of %d", w.Code)
+	} else if problem := decodeProblem(t, w); problem.Code != apierrors.CodePossibleDuplicate {
+		t.Errorf("Expected the error code %s, but recieved %s", apierrors.CodePossibleDuplicate, problem.Code)
+	} else if candidates, ok := problem.Data.([]interface{}); !ok || len(candidates) != 1 {
+		t.Errorf("Expected the candidate duplicate in the data of the problem, but recieved %v", problem.Data)
+	}
+
+	// Forcing the submission skips the comparison, so the question reaches the MockQuestionStore's StoreQuestion method
+	forcedQuestion := &models.Question{UserID: "95954f28-a8c3-4e76-8c80-18de07931639", Username: "Tester2", Title: "Where is the best sushi restaurant?", Force: true}
+	w = httptest.NewRecorder()
+
+	ServeSubmitQuestion(&MockQuestionStore{Similar: similar}, &MockCategoryStore{Category: category})(&m.Context{auth.NewAuthContext(nil), &MockRepStore{}, forcedQuestion}, w, r)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected a status code of 409 from the MockQuestionStore's StoreQuestion method, but recieved a status code of %d", w.Code)
+	} else if code := decodeProblem(t, w).Code; code != apierrors.CodeNotUnique {
+		t.Errorf("Expected the error code %s, but recieved %s", apierrors.CodeNotUnique, code)
+	}
+}
+
+func TestServeCastQuestionVoteWithRepeatedVote(t *testing.T) {
+
+	r, err := http.NewRequest("PUT", "api/gains/question/38681976-4d2d-4581-8a68-1e4acfadcfa0/vote/1", nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	w := httptest.NewRecorder()
+
+	// A nil RepStore would cause a panic if the handler attempted to award rep for a vote that changed nothing
+	c := &m.Context{&auth.AuthContext{UserID: "0"}, nil, nil}
+
+	ServeCastQuestionVote(&MockQuestionStore{VoteChange: 0})(c, w, r)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected a status code of 200, but recieved a status code of %d", w.Code)
+	}
 }
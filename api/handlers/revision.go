@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/mangoslicer/answer-patch/apierrors"
+	"github.com/mangoslicer/answer-patch/datastores"
+	m "github.com/mangoslicer/answer-patch/middleware"
+	"github.com/mangoslicer/answer-patch/services"
+)
+
+var errInvalidRevision = apierrors.New(apierrors.KindInvalid, apierrors.CodeInvalidRevision, "Invalid revision number")
+
+func ServeAnswerHistory(store datastores.RevisionStoreServices) m.HandlerFunc {
+	return func(c *m.Context, w http.ResponseWriter, r *http.Request) {
+
+		page, err := parsePageRequest(r)
+		if err != nil {
+			services.PrintError(w, err)
+			return
+		}
+
+		revisions, err := store.FindRevisions(mux.Vars(r)["questionId"], page)
+		if err != nil {
+			services.PrintError(w, err)
+			return
+		}
+		services.PrintJSON(w, revisions)
+	}
+}
+
+func ServeRevisionDiff(store datastores.RevisionStoreServices) m.HandlerFunc {
+	return func(c *m.Context, w http.ResponseWriter, r *http.Request) {
+
+		routeVars := mux.Vars(r)
+
+		// The route only matches digits, so the conversions can only fail on overflow
+		from, err := strconv.Atoi(routeVars["from"])
+		if err != nil {
+			services.PrintError(w, errInvalidRevision)
+			return
+		}
+		to, err := strconv.Atoi(routeVars["to"])
+		if err != nil {
+			services.PrintError(w, errInvalidRevision)
+			return
+		}
+
+		fromRevision, err := store.FindRevision(routeVars["questionId"], from)
+		if err != nil {
+			services.PrintError(w, err)
+			return
+		}
+		toRevision, err := store.FindRevision(routeVars["questionId"], to)
+		if err != nil {
+			services.PrintError(w, err)
+			return
+		}
+
+		services.PrintJSON(w, &services.Diff{From: from, To: to, Lines: services.DiffLines(fromRevision.Content, toRevision.Content)})
+	}
+}
+
+func ServeRollbackRevision(store datastores.RevisionStoreServices) m.HandlerFunc {
+	return func(c *m.Context, w http.ResponseWriter, r *http.Request) {
+
+		routeVars := mux.Vars(r)
+
+		revision, err := strconv.Atoi(routeVars["revision"])
+		if err != nil {
+			services.PrintError(w, errInvalidRevision)
+			return
+		}
+
+		err = store.RollbackToRevision(routeVars["questionId"], routeVars["category"], revision)
+		if err != nil {
+			services.PrintError(w, err)
+			return
+		}
+	}
+}
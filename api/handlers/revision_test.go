@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/mangoslicer/answer-patch/apierrors"
+	m "github.com/mangoslicer/answer-patch/middleware"
+	"github.com/mangoslicer/answer-patch/models"
+	"github.com/mangoslicer/answer-patch/services"
+)
+
+type MockRevisionStore struct {
+	Revisions    map[int]*models.AnswerRevision
+	RolledBackIn string
+}
+
+func (store *MockRevisionStore) FindRevisions(questionID string, page models.PageRequest) (*models.AnswerRevisionPage, error) {
+	return nil, apierrors.New(apierrors.KindNotFound, apierrors.CodeQuestionNotFound, "No question exists with the provided question id")
+}
+
+func (store *MockRevisionStore) FindRevision(questionID string, revision int) (*models.AnswerRevision, error) {
+	if found, ok := store.Revisions[revision]; ok {
+		return found, nil
+	}
+	return nil, apierrors.New(apierrors.KindNotFound, apierrors.CodeRevisionNotFound, "No revision exists")
+}
+
+func (store *MockRevisionStore) RollbackToRevision(questionID, category string, revision int) error {
+	store.RolledBackIn = category
+	return nil
+}
+
+func TestServeAnswerHistoryWithUnknownQuestion(t *testing.T) {
+
+	r, err := http.NewRequest("GET", "api/post/0a24c4cd-4c73-42e4-bcca-3844d088de85/history", nil)
+	if err != nil {
+		t.Error(err)
+	}
+	r = mux.SetURLVars(r, map[string]string{"questionId": "0a24c4cd-4c73-42e4-bcca-3844d088de85"})
+
+	w := httptest.NewRecorder()
+
+	ServeAnswerHistory(&MockRevisionStore{})(m.NewContext(), w, r)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected a status code of 404, because the MockRevisionStore's FindRevisions method never finds the question, but recieved a status code of %d", w.Code)
+	}
+}
+
+func TestServeRevisionDiff(t *testing.T) {
+
+	mockStore := &MockRevisionStore{Revisions: map[int]*models.AnswerRevision{
+		1: &models.AnswerRevision{Revision: 1, Content: "Not Utah"},
+		2: &models.AnswerRevision{Revision: 2, Content: "Not Utah\nNot Massachusetts"},
+	}}
+
+	r, err := http.NewRequest("GET", "api/post/526c4576-0e49-4e90-b760-e6976c698574/history/1/diff/2", nil)
+	if err != nil {
+		t.Error(err)
+	}
+	r = mux.SetURLVars(r, map[string]string{"questionId": "526c4576-0e49-4e90-b760-e6976c698574", "from": "1", "to": "2"})
+
+	w := httptest.NewRecorder()
+
+	ServeRevisionDiff(mockStore)(m.NewContext(), w, r)
+
+	retrieved := new(services.Diff)
+	err = json.Unmarshal(w.Body.Bytes(), retrieved)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(retrieved.Lines) != 2 || retrieved.Lines[1].Op != services.DiffInsert || retrieved.Lines[1].Text != "Not Massachusetts" {
+		t.Errorf("Expected the diff to insert \"Not Massachusetts\" after an unchanged line, but recieved %+v", retrieved.Lines)
+	}
+}
+
+func TestServeRollbackRevisionRequiresModerator(t *testing.T) {
+
+	roles := []struct {
+		roles models.Roles
+		code  int
+	}{
+		{models.Roles{Role: models.RoleUser}, http.StatusForbidden},
+		{models.Roles{Role: models.RoleUser, CategoryRoles: map[string]models.Role{"gains": models.RoleModerator}}, http.StatusForbidden},
+		{models.Roles{Role: models.RoleUser, CategoryRoles: map[string]models.Role{"balling": models.RoleModerator}}, http.StatusOK},
+	}
+
+	for _, st := range roles {
+
+		r, err := http.NewRequest("PUT", "api/balling/post/0a24c4cd-4c73-42e4-bcca-3844d088de85/history/1/rollback", nil)
+		if err != nil {
+			t.Error(err)
+		}
+		r = mux.SetURLVars(r, map[string]string{"category": "balling", "questionId": "0a24c4cd-4c73-42e4-bcca-3844d088de85", "revision": "1"})
+
+		w := httptest.NewRecorder()
+		mockStore := &MockRevisionStore{}
+		c := &m.Context{&services.AuthContext{UserID: "0", Roles: st.roles}, nil, nil}
+
+		m.RequirePermission(services.PermRollbackAnswer, ServeRollbackRevision(mockStore))(c, w, r)
+
+		if w.Code != st.code {
+			t.Errorf("Expected a status code of %d for a user with the roles %+v, but recieved a status code of %d", st.code, st.roles, w.Code)
+		} else if st.code == http.StatusOK && mockStore.RolledBackIn != "balling" {
+			t.Errorf("Expected the rollback to be limited to the route category, but recieved %q", mockStore.RolledBackIn)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/mangoslicer/answer-patch/apierrors"
+	"github.com/mangoslicer/answer-patch/datastores"
+	m "github.com/mangoslicer/answer-patch/middleware"
+	"github.com/mangoslicer/answer-patch/models"
+	"github.com/mangoslicer/answer-patch/services"
+)
+
+var (
+	errInvalidRole = apierrors.New(apierrors.KindInvalid, apierrors.CodeInvalidField, "The role must be user, moderator or admin", apierrors.FieldError{Field: "role", Reason: "invalid"})
+	errOwnRole     = apierrors.New(apierrors.KindForbidden, apierrors.CodeInsufficientRole, "Users can not change their own role")
+	errOwnBan      = apierrors.New(apierrors.KindForbidden, apierrors.CodeInsufficientRole, "Users can not ban themselves")
+	errOutranked   = apierrors.New(apierrors.KindForbidden, apierrors.CodeOutranked, "Users can only ban users whose role is below their own")
+)
+
+// ServeRoleGrants lists a page of the roles that have been granted
+func ServeRoleGrants(store datastores.RoleStoreServices) m.HandlerFunc {
+	return func(c *m.Context, w http.ResponseWriter, r *http.Request) {
+
+		page, err := parsePageRequest(r)
+		if err != nil {
+			services.PrintError(w, err)
+			return
+		}
+
+		grants, err := store.FindRoleGrants(page)
+		if err != nil {
+			services.PrintError(w, err)
+			return
+		}
+		services.PrintJSON(w, grants)
+	}
+}
+
+// ServeSetRole grants a role to a user, either everywhere or within a category. The user gets the role once their access token is refreshed
+func ServeSetRole(store datastores.RoleStoreServices, categoryStore datastores.CategoryStoreServices) m.HandlerFunc {
+	return func(c *m.Context, w http.ResponseWriter, r *http.Request) {
+
+		change := c.ParsedModel.(*models.RoleChange)
+		if !change.Role.IsValid() {
+			services.PrintError(w, errInvalidRole)
+			return
+		}
+
+		// Admins could otherwise demote themselves, and leave nobody to grant roles
+		userID := mux.Vars(r)["userID"]
+		if userID == c.UserID {
+			services.PrintError(w, errOwnRole)
+			return
+		}
+
+		if change.Category != "" {
+			if _, err := categoryStore.FindCategory(change.Category); err != nil {
+				services.PrintError(w, err)
+				return
+			}
+		}
+
+		if err := store.SetRole(userID, change.Category, change.Role); err != nil {
+			services.PrintError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// ServeBanUser bans a user whose role is below the role of the current user, and logs them out everywhere.
+// Bans apply everywhere, so the user is ranked by their highest role, including the roles they hold within categories
+func ServeBanUser(store datastores.RoleStoreServices) m.HandlerFunc {
+	return func(c *m.Context, w http.ResponseWriter, r *http.Request) {
+
+		ban := c.ParsedModel.(*models.BanRequest)
+
+		userID := mux.Vars(r)["userID"]
+		if userID == c.UserID {
+			services.PrintError(w, errOwnBan)
+			return
+		}
+
+		roles, err := store.FindRoles(userID)
+		if err != nil {
+			services.PrintError(w, err)
+			return
+		} else if roles.Highest().AtLeast(c.RoleIn("")) {
+			services.PrintError(w, errOutranked)
+			return
+		}
+
+		if err = store.BanUser(userID, c.UserID, ban.Reason); err != nil {
+			services.PrintError(w, err)
+			return
+		}
+
+		// Banned users can not refresh their tokens, but the access tokens they hold stay valid until they are revoked
+		banned := services.NewAuthContext(c.TokenStore)
+		banned.UserID = userID
+		if err = banned.RevokeAllSessions(); err != nil {
+			services.PrintError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// ServeUnbanUser lifts the ban of a user whose role is below the role of the current user, who can then log in again.
+// Users are ranked like they are by ServeBanUser, so that moderators can not lift the bans that admins placed on other moderators
+func ServeUnbanUser(store datastores.RoleStoreServices) m.HandlerFunc {
+	return func(c *m.Context, w http.ResponseWriter, r *http.Request) {
+
+		userID := mux.Vars(r)["userID"]
+
+		roles, err := store.FindRoles(userID)
+		if err != nil {
+			services.PrintError(w, err)
+			return
+		} else if roles.Highest().AtLeast(c.RoleIn("")) {
+			services.PrintError(w, errOutranked)
+			return
+		}
+
+		if err = store.UnbanUser(userID); err != nil {
+			services.PrintError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/mangoslicer/answer-patch/apierrors"
+	"github.com/mangoslicer/answer-patch/datastores"
+	m "github.com/mangoslicer/answer-patch/middleware"
+	"github.com/mangoslicer/answer-patch/models"
+	auth "github.com/mangoslicer/answer-patch/services"
+)
+
+/**
+ * Stores a moderator and a user in memory, and returns the stores along with the ids of both
+ */
+func newRoleTestStores(t *testing.T) (*datastores.Stores, string, string) {
+
+	stores := datastores.NewMemoryStores(datastores.NewMemoryDB())
+
+	var ids []string
+	for _, username := range []string{"moderator", "tester"} {
+		if err := stores.Users.StoreUser(username, "!"+username, ""); err != nil {
+			t.Fatal(err)
+		}
+		user, err := stores.Users.FindUser("username", username)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, user.ID)
+	}
+
+	if err := stores.Roles.SetRole(ids[0], "", models.RoleModerator); err != nil {
+		t.Fatal(err)
+	}
+
+	return stores, ids[0], ids[1]
+}
+
+func serveBanUser(stores *datastores.Stores, moderatorID, userID string) *httptest.ResponseRecorder {
+
+	r, _ := http.NewRequest("POST", "api/users/"+userID+"/ban", nil)
+	r = mux.SetURLVars(r, map[string]string{"userID": userID})
+
+	w := httptest.NewRecorder()
+	ac := auth.NewAuthContext(datastores.NewMemoryTokenStore())
+	ac.UserID = moderatorID
+	ac.Roles = models.Roles{Role: models.RoleModerator}
+
+	ServeBanUser(stores.Roles)(&m.Context{ac, nil, &models.BanRequest{Reason: "spam"}}, w, r)
+	return w
+}
+
+func TestServeBanUser(t *testing.T) {
+
+	stores, moderatorID, userID := newRoleTestStores(t)
+
+	if w := serveBanUser(stores, moderatorID, userID); w.Code != http.StatusNoContent {
+		t.Fatalf("Expected a status code of 204, but recieved a status code of %d with the body %s", w.Code, w.Body.String())
+	}
+
+	roles, err := stores.Roles.FindRoles(userID)
+	if err != nil {
+		t.Fatal(err)
+	} else if !roles.Banned {
+		t.Error("Expected the user to be banned")
+	}
+}
+
+func TestServeBanUserWithOutrankingUser(t *testing.T) {
+
+	stores, moderatorID, userID := newRoleTestStores(t)
+	if err := stores.Roles.SetRole(userID, "", models.RoleModerator); err != nil {
+		t.Fatal(err)
+	}
+
+	// Users are outranked by their roles within categories as well
+	if err := stores.Users.StoreUser("categoryadmin", "!categoryadmin", ""); err != nil {
+		t.Fatal(err)
+	}
+	categoryAdmin, err := stores.Users.FindUser("username", "categoryadmin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = stores.Categories.StoreCategory(moderatorID, "gains", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err = stores.Roles.SetRole(categoryAdmin.ID, "gains", models.RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+
+	var banTests = []struct {
+		userID string
+		code   apierrors.Code
+	}{
+		{userID, apierrors.CodeOutranked},
+		{categoryAdmin.ID, apierrors.CodeOutranked},
+		{moderatorID, apierrors.CodeInsufficientRole},
+	}
+
+	for _, bt := range banTests {
+		w := serveBanUser(stores, moderatorID, bt.userID)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("Expected a status code of 403, but recieved a status code of %d", w.Code)
+		} else if code := decodeProblem(t, w).Code; code != bt.code {
+			t.Errorf("Expected the error code %s, but recieved %s", bt.code, code)
+		}
+	}
+}
+
+func TestServeUnbanUserWithOutrankingUser(t *testing.T) {
+
+	stores, moderatorID, userID := newRoleTestStores(t)
+
+	// An admin banned a moderator, whose ban only an admin can lift
+	if err := stores.Users.StoreUser("bannedmoderator", "!bannedmoderator", ""); err != nil {
+		t.Fatal(err)
+	}
+	bannedModerator, err := stores.Users.FindUser("username", "bannedmoderator")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = stores.Roles.SetRole(bannedModerator.ID, "", models.RoleModerator); err != nil {
+		t.Fatal(err)
+	}
+
+	var unbanTests = []struct {
+		userID string
+		status int
+	}{
+		{bannedModerator.ID, http.StatusForbidden},
+		{userID, http.StatusNoContent},
+	}
+
+	for _, ut := range unbanTests {
+		if err = stores.Roles.BanUser(ut.userID, moderatorID, "spam"); err != nil {
+			t.Fatal(err)
+		}
+
+		r, _ := http.NewRequest("DELETE", "api/users/"+ut.userID+"/ban", nil)
+		r = mux.SetURLVars(r, map[string]string{"userID": ut.userID})
+
+		w := httptest.NewRecorder()
+		ac := auth.NewAuthContext(datastores.NewMemoryTokenStore())
+		ac.UserID = moderatorID
+		ac.Roles = models.Roles{Role: models.RoleModerator}
+
+		ServeUnbanUser(stores.Roles)(&m.Context{ac, nil, nil}, w, r)
+
+		if w.Code != ut.status {
+			t.Errorf("Expected a status code of %d, but recieved a status code of %d", ut.status, w.Code)
+		}
+
+		roles, err := stores.Roles.FindRoles(ut.userID)
+		if err != nil {
+			t.Fatal(err)
+		} else if roles.Banned != (ut.status != http.StatusNoContent) {
+			t.Errorf("Expected the ban to be lifted only when the user is outranked, but recieved %+v", roles)
+		}
+	}
+}
+
+func TestServeSetRole(t *testing.T) {
+
+	stores, adminID, userID := newRoleTestStores(t)
+
+	var setRoleTests = []struct {
+		userID string
+		role   models.Role
+		status int
+	}{
+		{userID, "overlord", http.StatusBadRequest},
+		{adminID, models.RoleUser, http.StatusForbidden},
+		{userID, models.RoleAdmin, http.StatusNoContent},
+	}
+
+	for _, st := range setRoleTests {
+
+		r, _ := http.NewRequest("PUT", "api/users/"+st.userID+"/role", nil)
+		r = mux.SetURLVars(r, map[string]string{"userID": st.userID})
+
+		w := httptest.NewRecorder()
+		c := &m.Context{&auth.AuthContext{UserID: adminID, Roles: models.Roles{Role: models.RoleAdmin}}, nil, &models.RoleChange{Role: st.role}}
+
+		ServeSetRole(stores.Roles, stores.Categories)(c, w, r)
+
+		if w.Code != st.status {
+			t.Errorf("Expected a status code of %d when setting the role %s, but recieved a status code of %d", st.status, st.role, w.Code)
+		}
+	}
+
+	if roles, err := stores.Roles.FindRoles(userID); err != nil {
+		t.Fatal(err)
+	} else if roles.Role != models.RoleAdmin {
+		t.Errorf("Expected the user to be an admin, but recieved %s", roles.Role)
+	}
+}
+
+func TestServeSetRoleWithUnknownCategory(t *testing.T) {
+
+	stores, adminID, userID := newRoleTestStores(t)
+
+	r, _ := http.NewRequest("PUT", "api/users/"+userID+"/role", nil)
+	r = mux.SetURLVars(r, map[string]string{"userID": userID})
+
+	w := httptest.NewRecorder()
+	c := &m.Context{&auth.AuthContext{UserID: adminID, Roles: models.Roles{Role: models.RoleAdmin}}, nil, &models.RoleChange{Role: models.RoleModerator, Category: "cooking"}}
+
+	ServeSetRole(stores.Roles, stores.Categories)(c, w, r)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected a status code of 404, because the category does not exist, but recieved a status code of %d", w.Code)
+	} else if code := decodeProblem(t, w).Code; code != apierrors.CodeCategoryNotFound {
+		t.Errorf("Expected the error code %s, but recieved %s", apierrors.CodeCategoryNotFound, code)
+	}
+}
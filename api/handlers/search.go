@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/mangoslicer/answer-patch/apierrors"
+	"github.com/mangoslicer/answer-patch/datastores"
+	m "github.com/mangoslicer/answer-patch/middleware"
+	"github.com/mangoslicer/answer-patch/models"
+	"github.com/mangoslicer/answer-patch/services"
+)
+
+var errMissingSearchTerms = apierrors.New(apierrors.KindInvalid, apierrors.CodeMissingFields, "The following fields were not recieved: q", apierrors.FieldError{Field: "q", Reason: "missing"})
+
+// ServeSearch lists a page of the questions that match the q parameter, best match first.
+// The category and author parameters narrow the search, and the cursor and limit parameters page through it
+func ServeSearch(store datastores.SearchStoreServices) m.HandlerFunc {
+	return func(c *m.Context, w http.ResponseWriter, r *http.Request) {
+
+		params := r.URL.Query()
+
+		query := &models.SearchQuery{
+			Terms:    strings.TrimSpace(params.Get("q")),
+			Category: params.Get("category"),
+			Author:   params.Get("author"),
+		}
+		if query.Terms == "" {
+			services.PrintError(w, errMissingSearchTerms)
+			return
+		}
+
+		page, err := parsePageRequest(r)
+		if err != nil {
+			services.PrintError(w, err)
+			return
+		}
+
+		results, err := store.SearchQuestions(query, page)
+		if err != nil {
+			services.PrintError(w, err)
+			return
+		}
+		services.PrintJSON(w, results)
+	}
+}
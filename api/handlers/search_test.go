@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mangoslicer/answer-patch/apierrors"
+	"github.com/mangoslicer/answer-patch/datastores"
+	m "github.com/mangoslicer/answer-patch/middleware"
+)
+
+func TestServeSearchWithInvalidQuery(t *testing.T) {
+
+	search := ServeSearch(datastores.NewMemoryStores(datastores.NewMemoryDB()).Search)
+
+	cases := []struct {
+		url  string
+		code apierrors.Code
+	}{
+		{"api/search", apierrors.CodeMissingFields},
+		{"api/search?q=%20%20", apierrors.CodeMissingFields},
+		{"api/search?q=sushi&limit=0", apierrors.CodeInvalidField},
+		{"api/search?q=sushi&limit=next", apierrors.CodeInvalidField},
+		{"api/search?q=sushi&limit=51", apierrors.CodeInvalidField},
+		{"api/search?q=sushi&cursor=not-a-cursor", apierrors.CodeInvalidField},
+	}
+
+	for _, tc := range cases {
+		r, err := http.NewRequest("GET", tc.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		search(&m.Context{}, w, r)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected a status code of 400 for %s, but recieved a status code of %d", tc.url, w.Code)
+		} else if code := decodeProblem(t, w).Code; code != tc.code {
+			t.Errorf("Expected the error code %s for %s, but recieved %s", tc.code, tc.url, code)
+		}
+	}
+}
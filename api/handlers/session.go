@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	m "github.com/mangoslicer/answer-patch/middleware"
+	"github.com/mangoslicer/answer-patch/services"
+)
+
+func ServeSessions() m.HandlerFunc {
+	return func(c *m.Context, w http.ResponseWriter, r *http.Request) {
+
+		page, err := parsePageRequest(r)
+		if err != nil {
+			services.PrintError(w, err)
+			return
+		}
+
+		sessions, err := c.FindSessions(page)
+		if err != nil {
+			services.PrintError(w, err)
+			return
+		}
+		services.PrintJSON(w, sessions)
+	}
+}
+
+func ServeRevokeSession() m.HandlerFunc {
+	return func(c *m.Context, w http.ResponseWriter, r *http.Request) {
+
+		if err := c.RevokeSession(mux.Vars(r)["sessionID"]); err != nil {
+			services.PrintError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// ServeRevokeSessions logs the user out everywhere, including the session of the requesting token
+func ServeRevokeSessions() m.HandlerFunc {
+	return func(c *m.Context, w http.ResponseWriter, r *http.Request) {
+
+		if err := c.RevokeAllSessions(); err != nil {
+			services.PrintError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
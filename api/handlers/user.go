@@ -1,31 +1,64 @@
 package handlers
 
 import (
+	"errors"
+	"log"
 	"net/http"
+	"net/mail"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/mangoslicer/answer-patch/apierrors"
 	"github.com/mangoslicer/answer-patch/datastores"
 	m "github.com/mangoslicer/answer-patch/middleware"
 	"github.com/mangoslicer/answer-patch/models"
 	"github.com/mangoslicer/answer-patch/services"
+	"github.com/mangoslicer/answer-patch/settings"
+)
+
+// Logging in with an unknown username is an authentication failure rather than a missing resource
+var errUnknownUsername = apierrors.New(apierrors.KindUnauthorized, apierrors.CodeInvalidCredentials, "No user exists with the provided credential")
+
+// Errors of email verification
+var (
+	errInvalidEmail         = apierrors.New(apierrors.KindInvalid, apierrors.CodeInvalidField, "The email is not a valid email address", apierrors.FieldError{Field: "email", Reason: "invalid"})
+	errNoEmail              = apierrors.New(apierrors.KindConflict, apierrors.CodeInvalidField, "The user has no email to verify", apierrors.FieldError{Field: "email", Reason: "missing"})
+	errEmailAlreadyVerified = apierrors.New(apierrors.KindConflict, apierrors.CodeInvalidField, "The email has already been verified", apierrors.FieldError{Field: "email", Reason: "verified"})
+)
+
+// Length of the email column of ap_user
+const maxEmailLength = 254
+
+// Errors of password changes. The user is already authenticated, so an incorrect old password is forbidden rather than unauthorized
+var (
+	errIncorrectOldPassword = apierrors.New(apierrors.KindForbidden, apierrors.CodeInvalidCredentials, "The old password is incorrect", apierrors.FieldError{Field: "oldPassword", Reason: "incorrect"})
+	errUnchangedPassword    = apierrors.New(apierrors.KindInvalid, apierrors.CodeInvalidField, "The new password must differ from the old password", apierrors.FieldError{Field: "newPassword", Reason: "unchanged"})
 )
 
 func ServeFindUser(store datastores.UserStoreServices) m.HandlerFunc {
 	return func(c *m.Context, w http.ResponseWriter, r *http.Request) {
 
-		user, err, statusCode := store.FindUser(mux.Vars(r)["filter"], mux.Vars(r)["searchVal"])
+		user, err := store.FindUser(mux.Vars(r)["filter"], mux.Vars(r)["searchVal"])
 		if err != nil {
-			http.Error(w, err.Error(), statusCode)
+			services.PrintError(w, err)
 			return
 		}
 		services.PrintJSON(w, user)
 	}
 }
 
-func ServeRegisterUser(store datastores.UserStoreServices) m.HandlerFunc {
+// ServeRegisterUser registers a user and mails a link that verifies their email. Registration succeeds even if the link can not be mailed, since it can be mailed again later
+func ServeRegisterUser(store datastores.UserStoreServices, mailer services.MailSender) m.HandlerFunc {
 	return func(c *m.Context, w http.ResponseWriter, r *http.Request) {
 
-		newUser := c.ParsedModel.(*models.UnauthUser)
+		newUser := c.ParsedModel.(*models.Registration)
+
+		email, err := normalizeEmail(newUser.Email)
+		if err != nil {
+			services.PrintError(w, err)
+			return
+		}
 
 		/*
 			if store.IsUsernameRegistered(newUser.Username) {
@@ -34,41 +67,264 @@ func ServeRegisterUser(store datastores.UserStoreServices) m.HandlerFunc {
 			}
 		*/
 
-		err, statusCode := store.StoreUser(newUser.Username, newUser.HashPassword())
+		if err := services.CheckPassword("password", newUser.Username, newUser.Password); err != nil {
+			services.PrintError(w, err)
+			return
+		}
+
+		hashedPassword, err := services.HashPassword(newUser.Password)
+		if err != nil {
+			services.PrintError(w, err)
+			return
+		}
+
+		err = store.StoreUser(newUser.Username, hashedPassword, email)
 		if err != nil {
-			http.Error(w, err.Error(), statusCode)
+			services.PrintError(w, err)
 			return
 		}
 
+		if err = sendVerificationMail(c, store, mailer, newUser.Username); err != nil {
+			log.Printf("Failed to mail the email verification link of %s: %v", newUser.Username, err)
+		}
+
 		w.WriteHeader(http.StatusCreated)
 
 	}
 }
 
+// ServeResendVerification mails another email verification link to the current user, e.g. after the first one expired
+func ServeResendVerification(store datastores.UserStoreServices, mailer services.MailSender) m.HandlerFunc {
+	return func(c *m.Context, w http.ResponseWriter, r *http.Request) {
+
+		user, err := store.FindUser("id", c.UserID)
+		if err != nil {
+			services.PrintError(w, err)
+			return
+		}
+
+		if user.Email == "" {
+			services.PrintError(w, errNoEmail)
+			return
+		} else if user.EmailVerified {
+			services.PrintError(w, errEmailAlreadyVerified)
+			return
+		}
+
+		if err = sendVerificationMail(c, store, mailer, user.Username); err != nil {
+			services.PrintError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+func ServeVerifyEmail(store datastores.UserStoreServices) m.HandlerFunc {
+	return func(c *m.Context, w http.ResponseWriter, r *http.Request) {
+
+		verification := c.ParsedModel.(*models.EmailVerification)
+
+		token, err := c.ParseActionToken(services.EmailVerificationPurpose, verification.Token)
+		if err != nil {
+			services.PrintError(w, err)
+			return
+		}
+
+		if err = c.ConsumeActionToken(token); err != nil {
+			services.PrintError(w, err)
+			return
+		}
+
+		// The token is bound to the email it was mailed to, so it can not verify an email that the user has since replaced
+		err = store.VerifyEmail(token.UserID, token.Binding)
+		if errors.Is(err, datastores.ErrUserNotFound) {
+			services.PrintError(w, services.ErrInvalidActionToken)
+			return
+		} else if err != nil {
+			services.PrintError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// loginAccount names the account that a login attempts to log in to. The username is lowercased, so that changing its case does not evade the rate limiter
+func loginAccount(c *m.Context) string {
+	return strings.ToLower(c.ParsedModel.(*models.UnauthUser).Username)
+}
+
+func sendVerificationMail(c *m.Context, store datastores.UserStoreServices, mailer services.MailSender, username string) error {
+
+	user, err := store.FindUser("username", username)
+	if err != nil {
+		return err
+	}
+
+	life := time.Duration(settings.Get().Rules.EmailVerificationLifeHours) * time.Hour
+	token, err := c.IssueActionToken(services.EmailVerificationPurpose, user.ID, user.Email, life)
+	if err != nil {
+		return err
+	}
+
+	return mailer.Send(services.NewVerificationMail(user.Email, user.Username, token))
+}
+
+// normalizeEmail accepts bare addresses only, without display names, and lowercases them so that emails are unique regardless of case
+func normalizeEmail(email string) (string, error) {
+
+	email = strings.TrimSpace(email)
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email || len(email) > maxEmailLength {
+		return "", errInvalidEmail
+	}
+
+	return strings.ToLower(email), nil
+}
+
 func ServeLogin(store datastores.UserStoreServices) m.HandlerFunc {
 	return func(c *m.Context, w http.ResponseWriter, r *http.Request) {
 
 		credentials := c.ParsedModel.(*models.UnauthUser)
 
-		retrievedUser, err, statusCode := store.FindUser("username", credentials.Username)
-		if err != nil {
-			http.Error(w, err.Error(), statusCode)
+		retrievedUser, err := store.FindUser("username", credentials.Username)
+		if errors.Is(err, datastores.ErrUserNotFound) {
+			services.PrintError(w, errUnknownUsername)
+			return
+		} else if err != nil {
+			services.PrintError(w, err)
 			return
 		}
 
 		c.UserID = retrievedUser.ID
 		token, err := c.Login(credentials.Password, retrievedUser.HashedPassword)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			services.PrintError(w, err)
 			return
 		}
 
+		// The entered password is only known upon login, which is therefore when outdated hashes are upgraded
+		// The login has succeeded regardless, so a failed upgrade is only logged and retried upon the next login
+		if services.NeedsRehash(retrievedUser.HashedPassword) {
+			hashedPassword, err := services.HashPassword(credentials.Password)
+			if err == nil {
+				err = store.UpdatePassword(retrievedUser.ID, hashedPassword)
+			}
+			if err != nil {
+				log.Printf("Failed to rehash the password of user %s: %v", retrievedUser.ID, err)
+			}
+		}
+
 		services.PrintJSON(w, token)
 	}
 }
 
 func ServeLogout() m.HandlerFunc {
 	return func(c *m.Context, w http.ResponseWriter, r *http.Request) {
-		c.Logout(r.Header.Get("Authorization")[7:]) //Sends the signed token without the "BEARER:" prefix
+
+		// Tokens may also be sent as the access_token parameter, in which case there is no header to take the signed token from
+		signedToken := r.Header.Get("Authorization")
+		if len(signedToken) > len("BEARER:") {
+			signedToken = signedToken[len("BEARER:"):]
+		}
+
+		if err := c.Logout(signedToken); err != nil {
+			services.PrintError(w, err)
+			return
+		}
+	}
+}
+
+func ServeRefreshToken() m.HandlerFunc {
+	return func(c *m.Context, w http.ResponseWriter, r *http.Request) {
+
+		req := c.ParsedModel.(*models.RefreshRequest)
+
+		token, err := c.RefreshToken(req.RefreshToken)
+		if err != nil {
+			services.PrintError(w, err)
+			return
+		}
+
+		services.PrintJSON(w, token)
+	}
+}
+
+// ServeChangePassword replaces the password of the current user, and logs out every other session, which may have been opened with the old password
+func ServeChangePassword(store datastores.UserStoreServices) m.HandlerFunc {
+	return func(c *m.Context, w http.ResponseWriter, r *http.Request) {
+
+		change := c.ParsedModel.(*models.PasswordChange)
+
+		user, err := store.FindUser("id", c.UserID)
+		if err != nil {
+			services.PrintError(w, err)
+			return
+		}
+
+		if !services.VerifyPassword(user.HashedPassword, change.OldPassword) {
+			services.PrintError(w, errIncorrectOldPassword)
+			return
+		} else if change.NewPassword == change.OldPassword {
+			services.PrintError(w, errUnchangedPassword)
+			return
+		}
+
+		if err = services.CheckPassword("newPassword", user.Username, change.NewPassword); err != nil {
+			services.PrintError(w, err)
+			return
+		}
+
+		hashedPassword, err := services.HashPassword(change.NewPassword)
+		if err != nil {
+			services.PrintError(w, err)
+			return
+		}
+
+		if err = store.UpdatePassword(user.ID, hashedPassword); err != nil {
+			services.PrintError(w, err)
+			return
+		}
+
+		if err = c.RevokeOtherSessions(); err != nil {
+			services.PrintError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func ServeChangeUsername(store datastores.UserStoreServices) m.HandlerFunc {
+	return func(c *m.Context, w http.ResponseWriter, r *http.Request) {
+
+		change := c.ParsedModel.(*models.UsernameChange)
+
+		if err := store.UpdateUsername(c.UserID, change.Username); err != nil {
+			services.PrintError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// ServeDeleteUser deletes the account of the current user, whose questions and answers are kept anonymously
+// Every session is revoked first, so a failed deletion leaves the user logged out rather than a deleted user logged in
+func ServeDeleteUser(store datastores.UserStoreServices) m.HandlerFunc {
+	return func(c *m.Context, w http.ResponseWriter, r *http.Request) {
+
+		if err := c.RevokeAllSessions(); err != nil {
+			services.PrintError(w, err)
+			return
+		}
+
+		if err := store.DeleteUser(c.UserID); err != nil {
+			services.PrintError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
 	}
 }
@@ -1,28 +1,67 @@
 package handlers
 
 import (
-	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/mangoslicer/answer-patch/apierrors"
+	"github.com/mangoslicer/answer-patch/datastores"
 	m "github.com/mangoslicer/answer-patch/middleware"
 	"github.com/mangoslicer/answer-patch/models"
 	auth "github.com/mangoslicer/answer-patch/services"
+	"github.com/mangoslicer/answer-patch/settings"
 )
 
 type MockUserStore struct {
-	FindUserErr        error
-	FindUserStatusCode int
+	FindUserErr     error
+	User            *models.User
+	UpdatedPassword string // Records the hash that UpdatePassword recieved
 	//IsRegistered bool
 }
 
-func (store *MockUserStore) FindUser(filter, searchVal string) (*models.User, error, int) {
-	return nil, store.FindUserErr, store.FindUserStatusCode
+func (store *MockUserStore) FindUser(filter, searchVal string) (*models.User, error) {
+	return store.User, store.FindUserErr
 }
 
-func (store *MockUserStore) StoreUser(username, hashedpassword string) (error, int) {
-	return errors.New("Username already exists"), http.StatusConflict
+func (store *MockUserStore) StoreUser(username, hashedpassword, email string) error {
+	return apierrors.New(apierrors.KindConflict, apierrors.CodeNotUnique, "Username already exists")
+}
+
+func (store *MockUserStore) UpdatePassword(userID, hashedPassword string) error {
+	store.UpdatedPassword = hashedPassword
+	return nil
+}
+
+func (store *MockUserStore) UpdateUsername(userID, username string) error {
+	return nil
+}
+
+func (store *MockUserStore) DeleteUser(userID string) error {
+	return nil
+}
+
+func (store *MockUserStore) VerifyEmail(userID, email string) error {
+	return store.FindUserErr
+}
+
+func (store *MockUserStore) FindUserByIdentity(provider, subject string) (*models.User, error) {
+	return store.User, store.FindUserErr
+}
+
+func (store *MockUserStore) LinkIdentity(userID, provider, subject string) error {
+	return nil
+}
+
+type MockMailSender struct {
+	Sent []*auth.Mail
+}
+
+func (sender *MockMailSender) Send(mail *auth.Mail) error {
+	sender.Sent = append(sender.Sent, mail)
+	return nil
 }
 
 /*
@@ -40,11 +79,11 @@ func TestServeFindUserWithInvalidUser(t *testing.T) {
 
 	w := httptest.NewRecorder()
 
-	ServeFindUser(&MockUserStore{FindUserErr: errors.New("No user exists with the provided information"), FindUserStatusCode: http.StatusBadRequest})(m.NewContext(), w, r)
+	ServeFindUser(&MockUserStore{FindUserErr: apierrors.New(apierrors.KindNotFound, apierrors.CodeUserNotFound, "No user exists with the provided information")})(m.NewContext(), w, r)
 
-	if w.Code != http.StatusBadRequest {
-		t.Errorf("Expected a status code of 400, but recieved an http status code of %d", w.Code)
-	} else if w.Body.String() != "No user exists with the provided information\n" {
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected a status code of 404, but recieved an http status code of %d", w.Code)
+	} else if decodeProblem(t, w).Detail != "No user exists with the provided information" {
 		t.Errorf("Expected the content of the responsewriter to be \"No user exists with the provided information\", but instead the responsewriter contains %s", w.Body.String())
 	}
 }
@@ -58,17 +97,120 @@ func TestServeRegisterUserWithRegisteredUsername(t *testing.T) {
 
 	w := httptest.NewRecorder()
 
-	registeredUser := &models.UnauthUser{Username: "RegisteredUsername"}
+	registeredUser := &models.Registration{Username: "RegisteredUsername", Password: "Passw!rd", Email: "registered@example.com"}
 	c := &m.Context{ParsedModel: registeredUser}
-	ServeRegisterUser(&MockUserStore{})(c, w, r)
+	ServeRegisterUser(&MockUserStore{}, &MockMailSender{})(c, w, r)
 
 	if w.Code != http.StatusConflict {
 		t.Errorf("Expected a status code of 409 Conflict, but recieved an http status code of %d", w.Code)
-	} else if w.Body.String() != "Username already exists\n" {
+	} else if decodeProblem(t, w).Detail != "Username already exists" {
 		t.Errorf("Expected the content of the responsewriter to be \"Username already exists\", but instead the responsewriter contains %s", w.Body.String())
 	}
 }
 
+func TestServeRegisterUserWithWeakPassword(t *testing.T) {
+
+	r, err := http.NewRequest("", "", nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	w := httptest.NewRecorder()
+
+	c := &m.Context{ParsedModel: &models.Registration{Username: "Tester7", Password: "tester7", Email: "tester7@example.com"}}
+	ServeRegisterUser(&MockUserStore{}, &MockMailSender{})(c, w, r)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected a status code of 400 Bad Request, but recieved an http status code of %d", w.Code)
+	}
+
+	problem := decodeProblem(t, w)
+	if problem.Code != apierrors.CodeWeakPassword {
+		t.Errorf("Expected the code to be %s, but recieved %s", apierrors.CodeWeakPassword, problem.Code)
+	}
+
+	var reasons []string
+	for _, fieldErr := range problem.Errors {
+		reasons = append(reasons, fieldErr.Reason)
+	}
+	if strings.Join(reasons, ",") != "missing_uppercase,missing_symbol,similar_to_username" {
+		t.Errorf("Expected every broken rule of the password to be reported, but recieved %v", reasons)
+	}
+}
+
+func TestServeRegisterUserWithInvalidEmail(t *testing.T) {
+
+	r, err := http.NewRequest("", "", nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	for _, email := range []string{"not an email", "Tester <tester@example.com>", "tester@"} {
+
+		w := httptest.NewRecorder()
+		c := &m.Context{ParsedModel: &models.Registration{Username: "Tester", Password: "Passw!rd", Email: email}}
+		ServeRegisterUser(&MockUserStore{}, &MockMailSender{})(c, w, r)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected a status code of 400 Bad Request for %q, but recieved an http status code of %d", email, w.Code)
+		} else if problem := decodeProblem(t, w); len(problem.Errors) == 0 || problem.Errors[0].Field != "email" {
+			t.Errorf("Expected the email field to be reported for %q, but recieved %+v", email, problem.Errors)
+		}
+	}
+}
+
+func TestServeVerifyEmail(t *testing.T) {
+
+	cfg := settings.Defaults()
+	cfg.Keys = settings.KeyPaths{PrivateKey: "../settings/preproduction/private_key", PublicKey: "../settings/preproduction/public_key.pub"}
+	settings.Set(cfg)
+	defer settings.Set(settings.Defaults())
+
+	r, err := http.NewRequest("", "", nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	ac := auth.NewAuthContext(datastores.NewMemoryTokenStore())
+	token, err := ac.IssueActionToken(auth.EmailVerificationPurpose, "ID", "tester@example.com", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var verifyTests = []struct {
+		store *MockUserStore
+		token string
+		code  int
+	}{
+		// The email was replaced after the link was mailed
+		{&MockUserStore{FindUserErr: datastores.ErrUserNotFound}, token, http.StatusBadRequest},
+		// The link has been used up by the previous attempt
+		{&MockUserStore{}, token, http.StatusGone},
+		{&MockUserStore{}, "invalid", http.StatusBadRequest},
+	}
+
+	for i, vt := range verifyTests {
+
+		w := httptest.NewRecorder()
+		ServeVerifyEmail(vt.store)(&m.Context{ac, nil, &models.EmailVerification{Token: vt.token}}, w, r)
+
+		if w.Code != vt.code {
+			t.Errorf("Expected a status code of %d for test %d, but recieved %d", vt.code, i, w.Code)
+		}
+	}
+
+	fresh, err := ac.IssueActionToken(auth.EmailVerificationPurpose, "ID", "tester@example.com", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	ServeVerifyEmail(&MockUserStore{})(&m.Context{ac, nil, &models.EmailVerification{Token: fresh}}, w, r)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Expected a status code of 204, but recieved %d", w.Code)
+	}
+}
+
 func TestServeLoginWithIncorrectCredentials(t *testing.T) {
 
 	r, err := http.NewRequest("", "", nil)
@@ -82,11 +224,135 @@ func TestServeLoginWithIncorrectCredentials(t *testing.T) {
 	unauthUser := &models.UnauthUser{Username: "Username", Password: "Wrong Password"}
 	c := &m.Context{ac, nil, unauthUser}
 
-	ServeLogin(&MockUserStore{FindUserErr: errors.New("No user exists with the provided credential"), FindUserStatusCode: http.StatusUnauthorized})(c, w, r)
+	ServeLogin(&MockUserStore{FindUserErr: datastores.ErrUserNotFound})(c, w, r)
 
 	if w.Code != http.StatusUnauthorized {
 		t.Errorf("Expected a status code of 401 Unauthorized, but recieved an http status code of %d", w.Code)
-	} else if w.Body.String() != "No user exists with the provided credential\n" {
+	} else if decodeProblem(t, w).Detail != "No user exists with the provided credential" {
 		t.Errorf("Expected the content of the responsewriter to be \"No user exists with the provided credential\", but instead the responsewriter contains %s", w.Body.String())
 	}
 }
+
+func TestServeRefreshTokenWithUnknownToken(t *testing.T) {
+
+	r, err := http.NewRequest("", "", nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	w := httptest.NewRecorder()
+
+	ac := auth.NewAuthContext(datastores.NewMemoryTokenStore())
+	c := &m.Context{ac, nil, &models.RefreshRequest{RefreshToken: "unknown"}}
+
+	ServeRefreshToken()(c, w, r)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected a status code of 401 Unauthorized, but recieved an http status code of %d", w.Code)
+	} else if code := decodeProblem(t, w).Code; code != apierrors.CodeInvalidToken {
+		t.Errorf("Expected the error code %s, but recieved %s", apierrors.CodeInvalidToken, code)
+	}
+}
+
+func TestServeLoginRehashesOutdatedPassword(t *testing.T) {
+
+	cfg := settings.Defaults()
+	cfg.Keys = settings.KeyPaths{PrivateKey: "../settings/preproduction/private_key", PublicKey: "../settings/preproduction/public_key.pub"}
+	cfg.Passwords.Argon2MemoryKiB = 1024
+	settings.Set(cfg)
+	defer settings.Set(settings.Defaults())
+
+	r, err := http.NewRequest("", "", nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	// The stored hash is a bcrypt hash, while new hashes are argon2id hashes
+	store := &MockUserStore{User: &models.User{ID: "ID", Username: "Username", HashedPassword: "$2a$10$XgxVoZidTuxugFcAkBipBeSYxFJSLv/w0t1Lt7ihTOu0cThCBHgA."}}
+	ac := auth.NewAuthContext(datastores.NewMemoryTokenStore())
+	c := &m.Context{ac, nil, &models.UnauthUser{Username: "Username", Password: "Passw!rd"}}
+
+	w := httptest.NewRecorder()
+	ServeLogin(store)(c, w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected a status code of 200, but recieved an http status code of %d", w.Code)
+	} else if !strings.HasPrefix(store.UpdatedPassword, "$argon2id$") || !auth.VerifyPassword(store.UpdatedPassword, "Passw!rd") {
+		t.Errorf("Expected the password to be rehashed with argon2id, but recieved %q", store.UpdatedPassword)
+	}
+
+	// Logging in with the upgraded hash leaves it in place
+	store.User.HashedPassword, store.UpdatedPassword = store.UpdatedPassword, ""
+	ServeLogin(store)(c, httptest.NewRecorder(), r)
+	if store.UpdatedPassword != "" {
+		t.Error("Expected a current hash not to be rehashed")
+	}
+}
+
+func TestServeChangePassword(t *testing.T) {
+
+	previous := settings.Get()
+	defer settings.Set(previous)
+
+	cfg := *previous
+	cfg.Passwords.Argon2MemoryKiB = 1024
+	settings.Set(&cfg)
+
+	r, err := http.NewRequest("", "", nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	store := &MockUserStore{User: &models.User{ID: "ID", Username: "Username", HashedPassword: "$2a$10$XgxVoZidTuxugFcAkBipBeSYxFJSLv/w0t1Lt7ihTOu0cThCBHgA."}}
+
+	var changeTests = []struct {
+		change *models.PasswordChange
+		code   int
+		field  string
+	}{
+		{&models.PasswordChange{OldPassword: "Wrong!Password", NewPassword: "New-Passw0rd"}, http.StatusForbidden, "oldPassword"},
+		{&models.PasswordChange{OldPassword: "Passw!rd", NewPassword: "Passw!rd"}, http.StatusBadRequest, "newPassword"},
+		{&models.PasswordChange{OldPassword: "Passw!rd", NewPassword: "weak"}, http.StatusBadRequest, "newPassword"},
+		{&models.PasswordChange{OldPassword: "Passw!rd", NewPassword: "New-Passw0rd"}, http.StatusNoContent, ""},
+	}
+
+	for _, ct := range changeTests {
+
+		ac := &auth.AuthContext{UserID: "ID", TokenStore: datastores.NewMemoryTokenStore()}
+		w := httptest.NewRecorder()
+		ServeChangePassword(store)(&m.Context{ac, nil, ct.change}, w, r)
+
+		if w.Code != ct.code {
+			t.Errorf("Expected a status code of %d for %+v, but recieved %d", ct.code, ct.change, w.Code)
+		} else if ct.field != "" {
+			if problem := decodeProblem(t, w); len(problem.Errors) == 0 || problem.Errors[0].Field != ct.field {
+				t.Errorf("Expected the %s field to be reported for %+v, but recieved %+v", ct.field, ct.change, problem.Errors)
+			}
+		}
+	}
+
+	if !auth.VerifyPassword(store.UpdatedPassword, "New-Passw0rd") {
+		t.Errorf("Expected the new password to be stored, but recieved %q", store.UpdatedPassword)
+	}
+}
+
+func TestServeLogoutWithoutAuthorizationHeader(t *testing.T) {
+
+	r, err := http.NewRequest("POST", "api/logout?access_token=token", nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	w := httptest.NewRecorder()
+
+	ac := auth.NewAuthContext(datastores.NewMemoryTokenStore())
+	ac.UserID, ac.TokenID, ac.Exp = "0", "jti", time.Now().Add(time.Hour)
+
+	ServeLogout()(&m.Context{ac, nil, nil}, w, r)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected a status code of 200, but recieved a status code of %d", w.Code)
+	} else if err = ac.CheckToken("0", "jti", "", time.Now()); apierrors.CodeOf(err) != apierrors.CodeRevokedToken {
+		t.Errorf("Expected the token to be revoked, but recieved %v", err)
+	}
+}
@@ -0,0 +1,19 @@
+package handlers
+
+import (
+	"net/http"
+
+	m "github.com/mangoslicer/answer-patch/middleware"
+	"github.com/mangoslicer/answer-patch/services"
+	"github.com/mangoslicer/answer-patch/settings"
+)
+
+// ServeJWKS publishes the public keys that verify the JSON Web Tokens of the API, including the keys that were rotated out but still verify unexpired tokens
+func ServeJWKS() m.HandlerFunc {
+	return func(c *m.Context, w http.ResponseWriter, r *http.Request) {
+
+		// Clients should pick up newly added keys well before they sign any token
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		services.PrintJSON(w, settings.GetKeyring().JWKS())
+	}
+}
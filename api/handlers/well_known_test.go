@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mangoslicer/answer-patch/settings"
+)
+
+func TestServeJWKS(t *testing.T) {
+
+	cfg := settings.Defaults()
+	cfg.Keys = settings.KeyPaths{PrivateKey: "../settings/preproduction/private_key", PublicKey: "../settings/preproduction/public_key.pub"}
+	settings.Set(cfg)
+	defer settings.Set(settings.Defaults())
+
+	r, _ := http.NewRequest("GET", "/.well-known/jwks.json", nil)
+	w := httptest.NewRecorder()
+
+	ServeJWKS()(nil, w, r)
+
+	jwks := new(settings.JWKSet)
+	if err := json.Unmarshal(w.Body.Bytes(), jwks); err != nil {
+		t.Fatal(err)
+	}
+	if len(jwks.Keys) != 1 || jwks.Keys[0].Kid != settings.GetKeyring().Active.ID {
+		t.Errorf("Expected the active key to be published, but recieved %s", w.Body.String())
+	}
+	if w.Header().Get("Cache-Control") == "" {
+		t.Error("Expected the key set to be cacheable")
+	}
+}
@@ -2,7 +2,9 @@ package main
 
 import (
 	"fmt"
+	"log"
 	"net/http"
+	"os"
 
 	"github.com/gorilla/mux"
 	"github.com/mangoslicer/answer-patch/datastores"
@@ -33,16 +35,27 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 func main() {
 
-	settings.SetPreproductionEnv() // Set GO_ENV to "preproduction"
+	settings.DefaultEnv("preproduction")
 
-	db := datastores.ConnectToPostgres()
+	cfg, _, err := settings.Load(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	stores, err := datastores.OpenStores(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ac := auth.NewAuthContext(stores.Tokens)
+	ac.RoleStore = stores.Roles
+	c := &m.Context{ac, stores.Rep, nil}
 
-	ac := auth.NewAuthContext(&datastores.JWTStore{datastores.ConnectToRedis()})
-	c := &m.Context{ac, &datastores.RepStore{datastores.ConnectToMongoCol()}, nil}
+	rl := m.NewRateLimiter(stores.Tokens)
 
-	r := handlers.AssignHandlersToRoutes(c, db)
-	http.Handle("/", &Server{r})
+	r := handlers.AssignHandlersToRoutes(c, stores, auth.NewMailSender(cfg.Mail), rl, auth.NewOIDCProviders(cfg.OIDC))
+	http.Handle("/", m.RequestID(rl.LimitIP(&Server{r})))
 
-	fmt.Println("Listening on port 3030")
-	http.ListenAndServe(":3030", nil)
+	fmt.Println("Listening on " + cfg.ListenAddr)
+	log.Fatal(http.ListenAndServe(cfg.ListenAddr, nil))
 }
@@ -2,23 +2,23 @@ package middleware
 
 import (
 	"encoding/json"
-	"fmt"
 	"io"
 	"io/ioutil"
-	"log"
 	"net/http"
+	"reflect"
+	"strings"
 	"time"
 
 	jwt "github.com/dgrijalva/jwt-go"
 	"github.com/gorilla/mux"
+	"github.com/mangoslicer/answer-patch/apierrors"
+	"github.com/mangoslicer/answer-patch/datastores"
 	"github.com/mangoslicer/answer-patch/models"
 	"github.com/mangoslicer/answer-patch/services"
 	"github.com/mangoslicer/answer-patch/settings"
 )
 
-const (
-	MIN_REP_FOR_ASKING_QUESTION = 10
-)
+var errAuthRequired = apierrors.New(apierrors.KindUnauthorized, apierrors.CodeAuthRequired, "JWT authentication required in order to complete this request")
 
 type HandlerFunc func(*Context, http.ResponseWriter, *http.Request)
 
@@ -29,20 +29,32 @@ func ServeHTTP(fn HandlerFunc) http.HandlerFunc {
 	}
 }
 
+// newRequestContext copies the stores of the context that a route was registered with into a context of its own for r,
+// so that nothing about the user of one request carries over to the next
+func newRequestContext(c *Context, r *http.Request) *Context {
+	ac := services.NewAuthContext(c.TokenStore)
+	ac.RoleStore = c.RoleStore
+	ac.UserAgent = r.UserAgent()
+	return &Context{ac, c.RepStore, nil}
+}
+
+// ServeHTTPWithStores is ServeHTTP for routes that need the stores of c, but no JWT, such as the routes that issue tokens
+func ServeHTTPWithStores(c *Context, fn HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fn(newRequestContext(c, r), w, r)
+	}
+}
+
+// ParseRequestBody decodes the JSON request body into a copy of model. Routes that require a JWT must be wrapped in RequireAuth
 func ParseRequestBody(model models.ModelServices, fn HandlerFunc) HandlerFunc {
 	return func(c *Context, w http.ResponseWriter, r *http.Request) {
 
-		url := r.URL.RequestURI()
-		if (url != "/login") && (url != "/register") && (c.UserID == "") && (c.Exp == time.Time{}) {
-			http.Error(w, "JWT authentication required in order to complete this request", http.StatusUnauthorized)
-			return
-		}
 		//Checks whether the request body is in JSON format
 		if contentType := r.Header.Get("Content-Type"); contentType != "application/json" {
-			http.Error(w, "This api only accepts JSON payloads. Be sure to specify the \"Content-Type\" of the payload in the request header.", http.StatusBadRequest)
+			services.PrintError(w, apierrors.New(apierrors.KindInvalid, apierrors.CodeUnsupportedMediaType, "This api only accepts JSON payloads. Be sure to specify the \"Content-Type\" of the payload in the request header."))
 			return
 		} else if r.Body == nil {
-			http.Error(w, "No data recieved through the request", http.StatusBadRequest)
+			services.PrintError(w, apierrors.New(apierrors.KindInvalid, apierrors.CodeEmptyBody, "No data recieved through the request"))
 			return
 		}
 
@@ -50,41 +62,57 @@ func ParseRequestBody(model models.ModelServices, fn HandlerFunc) HandlerFunc {
 
 		body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1048576))
 		if err != nil {
-			log.Fatal(err)
+			services.PrintError(w, apierrors.New(apierrors.KindInvalid, apierrors.CodeMalformedJSON, "The request body could not be read"))
+			return
 		}
 
 		defer r.Body.Close()
 
-		err = json.Unmarshal(body, model)
+		// The model passed to ParseRequestBody is shared by every request to the route, so each request is decoded into a fresh copy
+		parsed := reflect.New(reflect.TypeOf(model).Elem()).Interface().(models.ModelServices)
+
+		err = json.Unmarshal(body, parsed)
 		if err != nil {
-			// 422 -unprocessable entity
-			http.Error(w, err.Error()+"\n", 422)
+			services.PrintError(w, apierrors.New(apierrors.KindUnprocessable, apierrors.CodeMalformedJSON, err.Error()))
 			return
 		}
 
-		missing := model.GetMissingFields()
-		if missing != "" {
-			http.Error(w, "The following fields were not recieved:\n"+missing, http.StatusBadRequest)
+		if missing := parsed.GetMissingFields(); len(missing) != 0 {
+			fields := make([]apierrors.FieldError, len(missing))
+			for i, field := range missing {
+				fields[i] = apierrors.FieldError{Field: field, Reason: "missing"}
+			}
+			services.PrintError(w, apierrors.New(apierrors.KindInvalid, apierrors.CodeMissingFields, "The following fields were not recieved: "+strings.Join(missing, ", "), fields...))
 			return
 		}
 
-		c.ParsedModel = model
+		c.ParsedModel = parsed
 
 		fn(c, w, r)
 	}
 }
 
-func CheckRep(fn HandlerFunc) HandlerFunc {
+// RequireAuth rejects requests that AuthenticateToken let through without a JWT
+func RequireAuth(fn HandlerFunc) HandlerFunc {
 	return func(c *Context, w http.ResponseWriter, r *http.Request) {
-		category := mux.Vars(r)["category"]
-		rep, err := c.RepStore.FindRep(category, c.UserID)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		if c.UserID == "" {
+			services.PrintError(w, errAuthRequired)
 			return
 		}
 
-		if rep < MIN_REP_FOR_ASKING_QUESTION {
-			http.Error(w, "Not enough reputation in order to complete the request", http.StatusForbidden)
+		fn(c, w, r)
+	}
+}
+
+// CheckCategory rejects requests whose {category} route variable does not refer to a registered category
+func CheckCategory(store datastores.CategoryStoreServices, fn HandlerFunc) HandlerFunc {
+	return func(c *Context, w http.ResponseWriter, r *http.Request) {
+		isRegistered, err := store.IsCategoryRegistered(mux.Vars(r)["category"])
+		if err != nil {
+			services.PrintError(w, err)
+			return
+		} else if !isRegistered {
+			services.PrintError(w, apierrors.New(apierrors.KindNotFound, apierrors.CodeCategoryNotFound, "The provided category does not exist"))
 			return
 		}
 
@@ -92,46 +120,47 @@ func CheckRep(fn HandlerFunc) HandlerFunc {
 	}
 }
 
-func RefreshExpiringToken(fn HandlerFunc) HandlerFunc {
+func CheckRep(fn HandlerFunc) HandlerFunc {
 	return func(c *Context, w http.ResponseWriter, r *http.Request) {
+		category := mux.Vars(r)["category"]
+		rep, err := c.RepStore.FindRep(category, c.UserID)
+		if err != nil {
+			services.PrintError(w, err)
+			return
+		}
 
-		//Refreshes token, if the token expires in less than 24 hours
-		if (c.Exp != time.Time{}) && (c.Exp.Sub(time.Now()) < (time.Duration(24) * time.Hour)) {
-
-			refreshedToken, err := c.RefreshToken()
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-
-			services.PrintJSON(w, refreshedToken)
+		if rep < settings.Get().Rules.MinRepForAskingQuestion {
+			services.PrintError(w, apierrors.New(apierrors.KindForbidden, apierrors.CodeInsufficientRep, "Not enough reputation in order to complete the request"))
+			return
 		}
+
 		fn(c, w, r)
 	}
 }
 
-func AuthenticateToken(c *Context, fn HandlerFunc) http.HandlerFunc {
+func AuthenticateToken(shared *Context, fn HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
-		token, err := jwt.ParseFromRequest(r, func(parsedToken *jwt.Token) (interface{}, error) {
-			if _, ok := parsedToken.Method.(*jwt.SigningMethodRSA); !ok {
-				return nil, fmt.Errorf("Unrecognized signing method: %v", parsedToken.Header["alg"])
+		c := newRequestContext(shared, r)
 
-			} else {
-				return settings.GetPublicKey(), nil
-			}
-		})
+		token, err := jwt.ParseFromRequest(r, services.VerificationKey)
 
 		if err == jwt.ErrNoTokenInRequest {
 			fn(c, w, r)
 			return
 		} else if err != nil {
-			http.Error(w, err.Error(), http.StatusUnauthorized)
+			services.PrintError(w, apierrors.New(apierrors.KindUnauthorized, apierrors.CodeInvalidToken, err.Error()))
 			return
 		}
 
 		if !token.Valid {
-			http.Error(w, "Invalid JWT", http.StatusUnauthorized)
+			services.PrintError(w, apierrors.New(apierrors.KindUnauthorized, apierrors.CodeInvalidToken, "Invalid JWT"))
+			return
+		}
+
+		// Action tokens, such as password reset tokens, are signed by the same keys, but must never authenticate requests
+		if _, ok := token.Claims["purpose"]; ok {
+			services.PrintError(w, apierrors.New(apierrors.KindUnauthorized, apierrors.CodeInvalidToken, "JWT is not an access token"))
 			return
 		}
 
@@ -139,23 +168,30 @@ func AuthenticateToken(c *Context, fn HandlerFunc) http.HandlerFunc {
 
 		c.UserID, ok = token.Claims["sub"].(string)
 		if !ok {
-			log.Fatal("The underlying type of sub is not string")
+			services.PrintError(w, apierrors.New(apierrors.KindUnauthorized, apierrors.CodeInvalidToken, "JWT lacks the sub claim"))
+			return
 		}
 
-		isStored, err := c.TokenStore.IsTokenStored(c.UserID)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		exp, ok := token.Claims["exp"].(float64)
+		if !ok {
+			services.PrintError(w, apierrors.New(apierrors.KindUnauthorized, apierrors.CodeInvalidToken, "JWT lacks the exp claim"))
 			return
 		}
+		c.Exp = time.Unix(int64(exp), 0)
 
-		if isStored {
-			http.Error(w, "Token is no longer valid", http.StatusUnauthorized)
+		// Tokens without a jti can not be revoked individually, so they are rejected
+		c.TokenID, _ = token.Claims["jti"].(string)
+		iat, _ := token.Claims["iat"].(float64)
+		if c.TokenID == "" || iat == 0 {
+			services.PrintError(w, apierrors.New(apierrors.KindUnauthorized, apierrors.CodeInvalidToken, "JWT lacks the jti or iat claim"))
 			return
 		}
+		c.FamilyID, _ = token.Claims["fam"].(string)
+		c.Roles = parseRoleClaims(token.Claims)
 
-		_, ok = token.Claims["exp"].(float64)
-		if !ok {
-			log.Fatal("The underlying type of exp is not float64")
+		if err = c.CheckToken(c.UserID, c.TokenID, c.FamilyID, time.Unix(int64(iat), 0)); err != nil {
+			services.PrintError(w, err)
+			return
 		}
 
 		fn(c, w, r)
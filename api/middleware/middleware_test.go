@@ -3,16 +3,26 @@ package middleware
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	//	"log"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
+	"github.com/mangoslicer/answer-patch/apierrors"
+	"github.com/mangoslicer/answer-patch/datastores"
+	"github.com/mangoslicer/answer-patch/models"
 	auth "github.com/mangoslicer/answer-patch/services"
 	"github.com/mangoslicer/answer-patch/settings"
 )
 
+// Credentials that Login accepts, which are used to sign JSON Web Tokens for the tests of AuthenticateToken
+const (
+	correctPassword     = "Passw!rd"
+	correctPasswordHash = "$2a$10$XgxVoZidTuxugFcAkBipBeSYxFJSLv/w0t1Lt7ihTOu0cThCBHgA."
+)
+
 type MockTokenStore struct {
 	IsStored bool
 }
@@ -37,15 +47,92 @@ func (store *MockRepStore) UpdateRep(category, userID string, rep int) error {
 	return nil
 }
 
+func (store *MockRepStore) FindTotalRep(userID string) (int, error) {
+	return store.Rep, nil
+}
+
+func (store *MockRepStore) RenameCategory(name, newName string) error {
+	return nil
+}
+
+type MockCategoryStore struct {
+	IsRegistered bool
+}
+
+func (store *MockCategoryStore) FindCategories(page models.PageRequest) (*models.CategoryPage, error) {
+	return nil, nil
+}
+
+func (store *MockCategoryStore) FindCategory(name string) (*models.Category, error) {
+	return nil, nil
+}
+
+func (store *MockCategoryStore) IsCategoryRegistered(name string) (bool, error) {
+	return store.IsRegistered, nil
+}
+
+func (store *MockCategoryStore) StoreCategory(userID, name, description string) error {
+	return nil
+}
+
+func (store *MockCategoryStore) UpdateCategory(name, newName, newDescription string) error {
+	return nil
+}
+
 func (store *MockTokenStore) IsTokenStored(key string) (bool, error) {
 	return store.IsStored, nil
 }
 
-func (model *MockModel) GetMissingFields() string {
+func (store *MockTokenStore) StoreTokenOnce(key, val string, exp int) (bool, error) {
+	return !store.IsStored, nil
+}
+
+func (store *MockTokenStore) FindToken(key string) (string, error) {
+	return "", datastores.ErrTokenNotFound
+}
+
+func (store *MockTokenStore) AddToTokenSet(key, member string, exp int) error {
+	return nil
+}
+
+func (store *MockTokenStore) FindTokenSet(key string) ([]string, error) {
+	return nil, nil
+}
+
+func (store *MockTokenStore) RemoveFromTokenSet(key, member string) error {
+	return nil
+}
+
+func (store *MockTokenStore) RemoveToken(key string) error {
+	return nil
+}
+
+func (store *MockTokenStore) IncrementToken(key string, exp int) (int, error) {
+	return 1, nil
+}
+
+func (store *MockTokenStore) TakeFromBucket(key string, burst int, perSecond float64) (bool, float64, error) {
+	return true, float64(burst - 1), nil
+}
+
+func (model *MockModel) GetMissingFields() []string {
 	if model.Field == "" {
-		return "Field"
+		return []string{"Field"}
 	}
-	return ""
+	return nil
+}
+
+// decodeProblem parses the problem details document that services.PrintError writes to the responsewriter
+func decodeProblem(t *testing.T, w *httptest.ResponseRecorder) *apierrors.Problem {
+
+	problem := new(apierrors.Problem)
+	if contentType := w.Header().Get("Content-Type"); contentType != "application/problem+json" {
+		t.Errorf("Expected the Content-Type of the error response to be application/problem+json, but recieved %s", contentType)
+	} else if err := json.Unmarshal(w.Body.Bytes(), problem); err != nil {
+		t.Error(err)
+	}
+
+	return problem
 }
 
 func init() {
@@ -104,41 +191,149 @@ func TestParseRequestBodyOnEmptyBody(t *testing.T) {
 
 	if w.Code != http.StatusBadRequest {
 		t.Errorf("Expected a status code of 400 due to the absence of a request body, recieved a status code of %d", w.Code)
-	} else if errMessage := w.Body.String(); errMessage != "No data recieved through the request\n" {
+	} else if errMessage := decodeProblem(t, w).Detail; errMessage != "No data recieved through the request" {
 		t.Errorf("Expected \"No data recieved through the request\" to be written to the responsewriter body, but the responsewriter body contains: %s", errMessage)
 	}
 }
 
-func TestCheckRepWithInsufficientRep(t *testing.T) {
+// failingReader fails every read, like the body of a request whose client hung up
+type failingReader struct{}
 
-	r, err := http.NewRequest("POST", "api/question/testing", nil)
+func (failingReader) Read(p []byte) (int, error) {
+	return 0, errors.New("connection reset by peer")
+}
+
+func TestParseRequestBodyOnUnreadableBody(t *testing.T) {
+
+	r, err := http.NewRequest("", "", failingReader{})
 	if err != nil {
 		t.Error(err)
 	}
+	r.Header.Set("Content-Type", "application/json")
 
 	w := httptest.NewRecorder()
 
-	c := &Context{&auth.AuthContext{UserID: ""}, &MockRepStore{Rep: 1}, nil}
+	ac := &auth.AuthContext{UserID: "0", Exp: time.Now()}
+	context := &Context{ac, nil, nil}
 
-	CheckRep(func(c *Context, w http.ResponseWriter, r *http.Request) {})(c, w, r)
+	ParseRequestBody(new(MockModel), func(c *Context, w http.ResponseWriter, r *http.Request) {
+		t.Error("Expected the handler not to be called when the request body can not be read")
+	})(context, w, r)
 
-	if w.Code != http.StatusForbidden {
-		t.Errorf("Expected a http status code of 403 Forbidden, because the rep requirement was not met, but recieved a status code of %d", w.Code)
-	} else if w.Body.String() != "Not enough reputation in order to complete the request\n" {
-		t.Errorf("Expected the response writer body to contain \"Not enough reputation in order to complete the request\", but instead the response writer body contains %s", w.Body.String())
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected a status code of 400 due to the unreadable request body, recieved a status code of %d", w.Code)
 	}
 }
 
-func TestRefreshToken(t *testing.T) {
+func TestParseRequestBodyWithMissingField(t *testing.T) {
+
+	r, err := http.NewRequest("", "", bytes.NewBufferString("{}"))
+	if err != nil {
+		t.Error(err)
+	}
+	r.Header.Set("Content-Type", "application/json")
 
 	w := httptest.NewRecorder()
 
-	RefreshExpiringToken(func(c *Context, w http.ResponseWriter, r *http.Request) {})(NewContext(), w, nil)
+	ac := &auth.AuthContext{UserID: "0", Exp: time.Now()}
+
+	ParseRequestBody(new(MockModel), func(c *Context, w http.ResponseWriter, r *http.Request) {})(&Context{ac, nil, nil}, w, r)
+
+	problem := decodeProblem(t, w)
+	if w.Code != http.StatusBadRequest || problem.Code != apierrors.CodeMissingFields {
+		t.Errorf("Expected a status code of 400 and an error code of %s, but recieved a status code of %d and an error code of %s", apierrors.CodeMissingFields, w.Code, problem.Code)
+	} else if len(problem.Errors) != 1 || problem.Errors[0].Field != "Field" {
+		t.Errorf("Expected the problem to list \"Field\" as the missing field, but recieved %+v", problem.Errors)
+	}
+}
+
+func TestRequestID(t *testing.T) {
+
+	handler := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth.PrintError(w, apierrors.New(apierrors.KindForbidden, apierrors.CodeInsufficientRep, "Not enough reputation in order to complete the request"))
+	}))
+
+	requestIDTests := []struct {
+		sentID       string
+		isIDReturned bool
+	}{
+		{"3f2c1a9e-trace", true},
+		{"bad id\r\nSet-Cookie: x", false}, // IDs that could inject headers are replaced
+	}
+
+	for _, rt := range requestIDTests {
+		r, err := http.NewRequest("GET", "/api/categories", nil)
+		if err != nil {
+			t.Error(err)
+		}
+		r.Header.Set("X-Request-ID", rt.sentID)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		id := w.Header().Get("X-Request-ID")
+		if (id == rt.sentID) != rt.isIDReturned || id == "" {
+			t.Errorf("Unexpected X-Request-ID of %q for a request sent with %q", id, rt.sentID)
+		} else if problem := decodeProblem(t, w); problem.RequestID != id {
+			t.Errorf("Expected the problem to carry the request ID %s, but recieved %s", id, problem.RequestID)
+		}
+	}
+}
+
+func TestRequireAuthWithoutToken(t *testing.T) {
 
-	if w.Body == nil {
-		t.Errorf("Expected RefreshToken to print a token to the responsewriter body")
+	r, err := http.NewRequest("PUT", "api/testing/post/0a24c4cd-4c73-42e4-bcca-3844d088de85/history/1/rollback", nil)
+	if err != nil {
+		t.Error(err)
 	}
 
+	w := httptest.NewRecorder()
+
+	RequireAuth(func(c *Context, w http.ResponseWriter, r *http.Request) {})(NewContext(), w, r)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected a http status code of 401 Unauthorized, because the context has no user ID, but recieved a status code of %d", w.Code)
+	} else if code := decodeProblem(t, w).Code; code != apierrors.CodeAuthRequired {
+		t.Errorf("Expected the error code %s, but recieved %s", apierrors.CodeAuthRequired, code)
+	}
+}
+
+func TestCheckCategoryWithUnregisteredCategory(t *testing.T) {
+
+	r, err := http.NewRequest("POST", "api/question/nonexistent", nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	w := httptest.NewRecorder()
+
+	CheckCategory(&MockCategoryStore{IsRegistered: false}, func(c *Context, w http.ResponseWriter, r *http.Request) {})(NewContext(), w, r)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected a http status code of 404, because the category is not registered, but recieved a status code of %d", w.Code)
+	} else if decodeProblem(t, w).Detail != "The provided category does not exist" {
+		t.Errorf("Expected the response writer body to contain \"The provided category does not exist\", but instead the response writer body contains %s", w.Body.String())
+	}
+}
+
+func TestCheckRepWithInsufficientRep(t *testing.T) {
+
+	r, err := http.NewRequest("POST", "api/question/testing", nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	w := httptest.NewRecorder()
+
+	c := &Context{&auth.AuthContext{UserID: ""}, &MockRepStore{Rep: 1}, nil}
+
+	CheckRep(func(c *Context, w http.ResponseWriter, r *http.Request) {})(c, w, r)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected a http status code of 403 Forbidden, because the rep requirement was not met, but recieved a status code of %d", w.Code)
+	} else if decodeProblem(t, w).Detail != "Not enough reputation in order to complete the request" {
+		t.Errorf("Expected the response writer body to contain \"Not enough reputation in order to complete the request\", but instead the response writer body contains %s", w.Body.String())
+	}
 }
 
 func TestAuthenticateTokenWithInvalidToken(t *testing.T) {
@@ -157,7 +352,7 @@ func TestAuthenticateTokenWithInvalidToken(t *testing.T) {
 
 	if w.Code != http.StatusUnauthorized {
 		t.Errorf("Expected the status code to be 401, because of the request contained an invalid JWT, but instead recieved a status code of %d", w.Code)
-	} else if w.Body.String() != "Unrecognized signing method: HS256\n" {
+	} else if decodeProblem(t, w).Detail != "Unrecognized signing method: HS256" {
 		t.Errorf("Expected the responsewriter body to be set to \"Unrecognized signing method: HS256\", but instead the responsewriter body is set to \"%s\"", w.Body.String())
 	}
 }
@@ -182,6 +377,24 @@ func TestAuthenticateTokenWithNoToken(t *testing.T) {
 	}
 }
 
+func TestAuthenticateTokenWithNoTokenAfterAnotherUser(t *testing.T) {
+
+	r, err := http.NewRequest("", "", nil)
+	if err != nil {
+		t.Error(err)
+	}
+	w := httptest.NewRecorder()
+
+	// The context that routes are registered with must never hand the user of one request to the next
+	ac := auth.NewAuthContext(&MockTokenStore{IsStored: false})
+	ac.UserID = "0"
+	AuthenticateToken(&Context{ac, nil, nil}, func(c *Context, w http.ResponseWriter, r *http.Request) { w.Write([]byte(c.UserID)) })(w, r)
+
+	if w.Body.String() != "" {
+		t.Errorf("Expected a request without a token to lack a UserID, but the UserID retrieved from the context struct was %s", w.Body.String())
+	}
+}
+
 func TestAuthenticateTokenParseOperations(t *testing.T) {
 
 	r, err := http.NewRequest("", "", nil)
@@ -193,7 +406,7 @@ func TestAuthenticateTokenParseOperations(t *testing.T) {
 
 	//JWT token with a "sub" claim set to "0"
 
-	refreshedToken, err := ac.RefreshToken()
+	refreshedToken, err := ac.Login(correctPassword, correctPasswordHash)
 	if err != nil {
 		t.Error(err)
 	}
@@ -218,7 +431,7 @@ func TestAuthenticateTokenWithExpiredToken(t *testing.T) {
 
 	c := &Context{auth.NewAuthContext(&MockTokenStore{IsStored: true}), nil, nil}
 
-	refreshedToken, err := c.RefreshToken()
+	refreshedToken, err := c.Login(correctPassword, correctPasswordHash)
 	if err != nil {
 		t.Error(err)
 	}
@@ -230,7 +443,7 @@ func TestAuthenticateTokenWithExpiredToken(t *testing.T) {
 
 	if w.Code != http.StatusUnauthorized {
 		t.Errorf("Expected the status code to be a 401, because AuthenticateToken recognized that the token is stored in Redis due to the mock IsTokenStored method always returning true, but recieved a status code of %d", w.Code)
-	} else if w.Body.String() != "Token is no longer valid\n" {
+	} else if decodeProblem(t, w).Detail != "Token is no longer valid" {
 		t.Errorf("Expected the responsewriter body to contain \"Token is no longer valid\", because AuthenticateToken recognized that the token is stored in Redis due to the mock IsTokenStored method always returning true, but the responsewriter contained %s", w.Body.String())
 	}
 }
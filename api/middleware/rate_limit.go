@@ -0,0 +1,216 @@
+package middleware
+
+import (
+	"errors"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mangoslicer/answer-patch/apierrors"
+	"github.com/mangoslicer/answer-patch/datastores"
+	"github.com/mangoslicer/answer-patch/services"
+	"github.com/mangoslicer/answer-patch/settings"
+)
+
+var (
+	errRateLimited   = apierrors.New(apierrors.KindTooManyRequests, apierrors.CodeRateLimited, "Too many requests, try again later")
+	errAccountLocked = apierrors.New(apierrors.KindTooManyRequests, apierrors.CodeAccountLocked, "Too many failed attempts, the account is temporarily locked")
+)
+
+// RateLimiter throttles requests with token buckets that are kept in the TokenStore, so that every instance of the api shares them.
+// Whenever the TokenStore fails, the buckets are kept in memory instead, which still throttles the clients of this instance rather than letting every request through
+type RateLimiter struct {
+	Store    datastores.TokenStoreServices
+	fallback *datastores.MemoryTokenStore
+}
+
+func NewRateLimiter(store datastores.TokenStoreServices) *RateLimiter {
+	return &RateLimiter{Store: store, fallback: datastores.NewMemoryTokenStore()}
+}
+
+// AccountFunc names the account that a request acts on, or returns "" if the request acts on none
+type AccountFunc func(*Context) string
+
+// AuthenticatedAccount names the account of the JWT that AuthenticateToken parsed
+func AuthenticatedAccount(c *Context) string {
+	return c.UserID
+}
+
+// LimitIP throttles every request of an IP, regardless of its route
+func (rl *RateLimiter) LimitIP(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !rl.allow(w, "rate:ip:"+ClientIP(r), settings.Get().RateLimits.IP) {
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+// LimitRoute throttles the requests of an IP to the route named route, according to the limit configured for the route
+func (rl *RateLimiter) LimitRoute(route string, fn http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !rl.allow(w, "rate:route:"+route+":"+ClientIP(r), settings.Get().RateLimits.Routes[route]) {
+			return
+		}
+
+		fn(w, r)
+	}
+}
+
+// LimitAccount throttles the requests to a route on behalf of an account from every IP together, which slows down guessing from many IPs at once.
+// Failures, which are 401 and 403 responses, lock the account out of the route for the IP they came from, for a period that doubles with every further failure.
+// Locking out per IP keeps others from locking the owner of an account out. Only routes whose 401 and 403 responses mean a wrong credential should be wrapped
+func (rl *RateLimiter) LimitAccount(route string, account AccountFunc, fn HandlerFunc) HandlerFunc {
+	return func(c *Context, w http.ResponseWriter, r *http.Request) {
+
+		name := account(c)
+		if name == "" {
+			fn(c, w, r)
+			return
+		}
+
+		lockoutKey := "lockout:" + route + ":" + name + ":" + ClientIP(r)
+		if retryAfter := rl.lockedFor(lockoutKey); retryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			services.PrintError(w, errAccountLocked)
+			return
+		}
+
+		if !rl.allow(w, "rate:account:"+route+":"+name, settings.Get().RateLimits.Account) {
+			return
+		}
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		fn(c, recorder, r)
+
+		if recorder.status == http.StatusUnauthorized || recorder.status == http.StatusForbidden {
+			rl.recordFailure(lockoutKey)
+		} else if recorder.status < http.StatusBadRequest {
+			rl.resetFailures(lockoutKey)
+		}
+	}
+}
+
+// allow takes a request from the bucket under key, and rejects the request if the bucket is empty. The X-RateLimit-* headers describe
+// the bucket with the fewest requests left, since it is the one that throttles the client first
+func (rl *RateLimiter) allow(w http.ResponseWriter, key string, limit settings.RateLimit) bool {
+
+	if limit.PerMinute <= 0 {
+		return true
+	}
+	perSecond := float64(limit.PerMinute) / 60
+
+	taken, remaining, err := rl.Store.TakeFromBucket(key, limit.Burst, perSecond)
+	if err != nil {
+		log.Printf("Rate limiting in memory, since the token store failed: %v", err)
+		taken, remaining, _ = rl.fallback.TakeFromBucket(key, limit.Burst, perSecond)
+	}
+
+	header := w.Header()
+	if previous, err := strconv.Atoi(header.Get("X-RateLimit-Remaining")); err != nil || int(remaining) <= previous {
+		header.Set("X-RateLimit-Limit", strconv.Itoa(limit.Burst))
+		header.Set("X-RateLimit-Remaining", strconv.Itoa(int(remaining)))
+		header.Set("X-RateLimit-Reset", strconv.Itoa(int(math.Ceil((float64(limit.Burst)-remaining)/perSecond))))
+	}
+
+	if !taken {
+		header.Set("Retry-After", strconv.Itoa(int(math.Ceil((1-remaining)/perSecond))))
+		services.PrintError(w, errRateLimited)
+		return false
+	}
+
+	return true
+}
+
+// lockedFor returns how long the lockout under key lasts, which is 0 if there is none
+func (rl *RateLimiter) lockedFor(key string) time.Duration {
+
+	until, err := rl.Store.FindToken(key)
+	if err != nil && !errors.Is(err, datastores.ErrTokenNotFound) {
+		log.Printf("Checking lockouts in memory, since the token store failed: %v", err)
+		until, err = rl.fallback.FindToken(key)
+	}
+	if err != nil {
+		return 0
+	}
+
+	// Lockouts are stored as the unix time in milliseconds at which they end
+	unixMs, err := strconv.ParseInt(until, 10, 64)
+	if err != nil {
+		return 0
+	}
+
+	return time.Until(time.Unix(0, unixMs*int64(time.Millisecond)))
+}
+
+// recordFailure counts a failure, and locks the account out once the failures reach the threshold
+func (rl *RateLimiter) recordFailure(lockoutKey string) {
+
+	cfg := settings.Get().RateLimits
+	if cfg.LockoutThreshold <= 0 {
+		return
+	}
+
+	var store datastores.TokenStoreServices = rl.Store
+	failures, err := store.IncrementToken("failures:"+lockoutKey, cfg.FailureWindowMinutes*60)
+	if err != nil {
+		log.Printf("Counting failures in memory, since the token store failed: %v", err)
+		store = rl.fallback
+		failures, _ = store.IncrementToken("failures:"+lockoutKey, cfg.FailureWindowMinutes*60)
+	}
+
+	if failures < cfg.LockoutThreshold {
+		return
+	}
+
+	// The shift is bounded, since the lockout reaches its maximum long before the duration could overflow
+	lockout := cfg.LockoutMaxSeconds
+	if shift := uint(failures - cfg.LockoutThreshold); shift < 31 && cfg.LockoutBaseSeconds<<shift < cfg.LockoutMaxSeconds {
+		lockout = cfg.LockoutBaseSeconds << shift
+	}
+
+	until := time.Now().Add(time.Duration(lockout)*time.Second).UnixNano() / int64(time.Millisecond)
+	if err = store.StoreToken(lockoutKey, strconv.FormatInt(until, 10), lockout); err != nil {
+		log.Printf("Failed to lock out %s: %v", lockoutKey, err)
+	}
+}
+
+func (rl *RateLimiter) resetFailures(lockoutKey string) {
+	if err := rl.Store.RemoveToken("failures:" + lockoutKey); err != nil {
+		rl.fallback.RemoveToken("failures:" + lockoutKey)
+	}
+}
+
+// ClientIP returns the IP of the client that sent r. The header configured as rateLimits.clientIPHeader is only trusted for its last entry,
+// which is the one added by the proxy in front of the api, since clients can put anything in the entries before it
+func ClientIP(r *http.Request) string {
+
+	if name := settings.Get().RateLimits.ClientIPHeader; name != "" {
+		if entries := strings.Split(r.Header.Get(name), ","); strings.TrimSpace(entries[len(entries)-1]) != "" {
+			return strings.TrimSpace(entries[len(entries)-1])
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// statusRecorder remembers the status code that a handler responded with
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (recorder *statusRecorder) WriteHeader(status int) {
+	recorder.status = status
+	recorder.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,184 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mangoslicer/answer-patch/apierrors"
+	"github.com/mangoslicer/answer-patch/datastores"
+	"github.com/mangoslicer/answer-patch/settings"
+)
+
+// UnavailableTokenStore fails every bucket operation, like a JWTStore whose redis is down
+type UnavailableTokenStore struct {
+	MockTokenStore
+}
+
+func (store *UnavailableTokenStore) TakeFromBucket(key string, burst int, perSecond float64) (bool, float64, error) {
+	return false, 0, apierrors.Unavailable(errors.New("connection refused"))
+}
+
+// useRateLimits installs limits that tests can exhaust quickly
+func useRateLimits() func() {
+
+	previous := settings.Get()
+
+	cfg := *previous
+	cfg.RateLimits = settings.RateLimits{
+		IP:                   settings.RateLimit{Burst: 3, PerMinute: 1},
+		Account:              settings.RateLimit{Burst: 100, PerMinute: 1},
+		Routes:               map[string]settings.RateLimit{"post:login": {Burst: 2, PerMinute: 1}},
+		LockoutThreshold:     2,
+		LockoutBaseSeconds:   30,
+		LockoutMaxSeconds:    60,
+		FailureWindowMinutes: 1,
+	}
+	settings.Set(&cfg)
+
+	return func() { settings.Set(previous) }
+}
+
+func requestFrom(remoteAddr string) *http.Request {
+	r, _ := http.NewRequest("POST", "/api/login", nil)
+	r.RemoteAddr = remoteAddr
+	return r
+}
+
+func TestLimitRoute(t *testing.T) {
+
+	defer useRateLimits()()
+
+	rl := NewRateLimiter(datastores.NewMemoryTokenStore())
+	handler := rl.LimitRoute("post:login", func(w http.ResponseWriter, r *http.Request) {})
+
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		handler(w, requestFrom("10.0.0.1:1234"))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected request %d to be let through, but recieved a status code of %d", i, w.Code)
+		} else if remaining := w.Header().Get("X-RateLimit-Remaining"); remaining != []string{"1", "0"}[i] {
+			t.Errorf("Expected X-RateLimit-Remaining to count down, but recieved %q for request %d", remaining, i)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	handler(w, requestFrom("10.0.0.1:1234"))
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected a status code of 429, but recieved %d", w.Code)
+	} else if retryAfter := w.Header().Get("Retry-After"); retryAfter == "" || retryAfter == "0" {
+		t.Errorf("Expected a Retry-After header, but recieved %q", retryAfter)
+	} else if w.Header().Get("X-RateLimit-Limit") != "2" {
+		t.Errorf("Expected X-RateLimit-Limit to be the burst of the route, but recieved %q", w.Header().Get("X-RateLimit-Limit"))
+	}
+
+	// Every IP has buckets of its own
+	w = httptest.NewRecorder()
+	handler(w, requestFrom("10.0.0.2:1234"))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected another IP to be let through, but recieved a status code of %d", w.Code)
+	}
+
+	// Routes without a configured limit are not throttled
+	unlimited := rl.LimitRoute("post:user", func(w http.ResponseWriter, r *http.Request) {})
+	w = httptest.NewRecorder()
+	unlimited(w, requestFrom("10.0.0.1:1234"))
+	if w.Code != http.StatusOK || w.Header().Get("X-RateLimit-Limit") != "" {
+		t.Errorf("Expected an unlimited route to be let through without headers, but recieved %d and %v", w.Code, w.Header())
+	}
+}
+
+func TestLimitIPFallsBackToMemory(t *testing.T) {
+
+	defer useRateLimits()()
+
+	rl := NewRateLimiter(&UnavailableTokenStore{})
+	handler := rl.LimitIP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	var codes []int
+	for i := 0; i < 4; i++ {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, requestFrom("10.0.0.1:1234"))
+		codes = append(codes, w.Code)
+	}
+
+	if codes[2] != http.StatusOK || codes[3] != http.StatusTooManyRequests {
+		t.Errorf("Expected the burst of 3 to be enforced in memory, but recieved %v", codes)
+	}
+}
+
+func TestLimitAccountLocksOutAfterFailures(t *testing.T) {
+
+	defer useRateLimits()()
+
+	status := http.StatusUnauthorized
+	rl := NewRateLimiter(datastores.NewMemoryTokenStore())
+	handler := rl.LimitAccount("post:login", func(c *Context) string { return "tester" }, func(c *Context, w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	})
+
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		handler(NewContext(), w, requestFrom("10.0.0.1:1234"))
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("Expected failure %d to reach the handler, but recieved a status code of %d", i, w.Code)
+		}
+	}
+
+	// The correct password is refused as well while the account is locked out
+	status = http.StatusOK
+	w := httptest.NewRecorder()
+	handler(NewContext(), w, requestFrom("10.0.0.1:1234"))
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected the account to be locked out, but recieved a status code of %d", w.Code)
+	} else if w.Header().Get("Retry-After") != "30" {
+		t.Errorf("Expected the first lockout to last 30 seconds, but recieved a Retry-After of %q", w.Header().Get("Retry-After"))
+	}
+
+	// The lockout only applies to the IP that the failures came from
+	w = httptest.NewRecorder()
+	handler(NewContext(), w, requestFrom("10.0.0.2:1234"))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected the owner of the account to be let through from another IP, but recieved a status code of %d", w.Code)
+	}
+}
+
+func TestLockoutDoubles(t *testing.T) {
+
+	defer useRateLimits()()
+
+	store := datastores.NewMemoryTokenStore()
+	rl := NewRateLimiter(store)
+
+	var lockouts []int
+	for i := 0; i < 4; i++ {
+		rl.recordFailure("lockout:test")
+		lockouts = append(lockouts, int(rl.lockedFor("lockout:test").Seconds()+0.5))
+	}
+
+	// The threshold is 2 failures, the base 30 seconds and the maximum 60 seconds
+	if lockouts[0] != 0 || lockouts[1] != 30 || lockouts[2] != 60 || lockouts[3] != 60 {
+		t.Errorf("Expected lockouts of 0, 30, 60 and 60 seconds, but recieved %v", lockouts)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+
+	defer useRateLimits()()
+
+	r := requestFrom("10.0.0.1:1234")
+	r.Header.Set("X-Forwarded-For", "1.2.3.4, 192.168.0.1")
+
+	if ip := ClientIP(r); ip != "10.0.0.1" {
+		t.Errorf("Expected the address of the connection to be used, but recieved %s", ip)
+	}
+
+	cfg := *settings.Get()
+	cfg.RateLimits.ClientIPHeader = "X-Forwarded-For"
+	settings.Set(&cfg)
+
+	if ip := ClientIP(r); ip != "192.168.0.1" {
+		t.Errorf("Expected the entry added by the proxy to be used, but recieved %s", ip)
+	}
+}
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+)
+
+// Request IDs supplied by clients or proxies are only echoed when they cannot be used to inject content into logs or headers
+var requestIDRegex = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// RequestID tags every response with an X-Request-ID header, reusing the ID of the request when one was provided, so that error responses can be correlated with the request that caused them
+func RequestID(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		id := r.Header.Get("X-Request-ID")
+		if !requestIDRegex.MatchString(id) {
+			id = newRequestID()
+			r.Header.Set("X-Request-ID", id)
+		}
+
+		w.Header().Set("X-Request-ID", id)
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
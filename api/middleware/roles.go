@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/mangoslicer/answer-patch/apierrors"
+	"github.com/mangoslicer/answer-patch/models"
+	"github.com/mangoslicer/answer-patch/services"
+)
+
+var errInsufficientRole = apierrors.New(apierrors.KindForbidden, apierrors.CodeInsufficientRole, "The role of the user does not allow this request")
+
+// RequireRole rejects requests of users whose role is below role. Roles within the category of the {category} route variable count as well,
+// so the moderators of a category pass RequireRole(models.RoleModerator) on the routes of their category. Like RequireAuth, it must be wrapped in AuthenticateToken
+func RequireRole(role models.Role, fn HandlerFunc) HandlerFunc {
+	return func(c *Context, w http.ResponseWriter, r *http.Request) {
+		if c.UserID == "" {
+			services.PrintError(w, errAuthRequired)
+			return
+		} else if !c.RoleIn(mux.Vars(r)["category"]).AtLeast(role) {
+			services.PrintError(w, errInsufficientRole)
+			return
+		}
+
+		fn(c, w, r)
+	}
+}
+
+// RequirePermission rejects requests of users whose roles do not grant perm, within the category of the {category} route variable if the route has one
+func RequirePermission(perm services.Permission, fn HandlerFunc) HandlerFunc {
+	return func(c *Context, w http.ResponseWriter, r *http.Request) {
+		if c.UserID == "" {
+			services.PrintError(w, errAuthRequired)
+			return
+		} else if !c.Can(perm, mux.Vars(r)["category"]) {
+			services.PrintError(w, errInsufficientRole)
+			return
+		}
+
+		fn(c, w, r)
+	}
+}
+
+// parseRoleClaims reads the roles that setTokenClaims put into the claims of a token. Tokens without them belong to users with the user role
+func parseRoleClaims(claims map[string]interface{}) models.Roles {
+
+	roles := models.Roles{Role: models.RoleUser}
+
+	if role, ok := claims["role"].(string); ok && models.Role(role).IsValid() {
+		roles.Role = models.Role(role)
+	}
+
+	if categoryRoles, ok := claims["croles"].(map[string]interface{}); ok {
+		roles.CategoryRoles = make(map[string]models.Role, len(categoryRoles))
+		for category, role := range categoryRoles {
+			if role, ok := role.(string); ok && models.Role(role).IsValid() {
+				roles.CategoryRoles[category] = models.Role(role)
+			}
+		}
+	}
+
+	return roles
+}
@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/mangoslicer/answer-patch/apierrors"
+	"github.com/mangoslicer/answer-patch/models"
+	auth "github.com/mangoslicer/answer-patch/services"
+)
+
+func TestRequireRole(t *testing.T) {
+
+	var requireRoleTests = []struct {
+		userID   string
+		roles    models.Roles
+		category string
+		code     int
+	}{
+		{"", models.Roles{Role: models.RoleAdmin}, "", http.StatusUnauthorized},
+		{"0", models.Roles{Role: models.RoleUser}, "", http.StatusForbidden},
+		{"0", models.Roles{Role: models.RoleUser, CategoryRoles: map[string]models.Role{"gains": models.RoleModerator}}, "sushi", http.StatusForbidden},
+		{"0", models.Roles{Role: models.RoleUser, CategoryRoles: map[string]models.Role{"gains": models.RoleModerator}}, "gains", http.StatusOK},
+		{"0", models.Roles{Role: models.RoleAdmin}, "", http.StatusOK},
+	}
+
+	for _, rt := range requireRoleTests {
+
+		r, err := http.NewRequest("DELETE", "api/"+rt.category, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = mux.SetURLVars(r, map[string]string{"category": rt.category})
+
+		w := httptest.NewRecorder()
+		c := &Context{&auth.AuthContext{UserID: rt.userID, Roles: rt.roles}, nil, nil}
+
+		RequireRole(models.RoleModerator, func(c *Context, w http.ResponseWriter, r *http.Request) {})(c, w, r)
+
+		if w.Code != rt.code {
+			t.Errorf("Expected a http status code of %d for a user with the roles %v in %q, but recieved a status code of %d", rt.code, rt.roles, rt.category, w.Code)
+		} else if rt.code == http.StatusForbidden {
+			if code := decodeProblem(t, w).Code; code != apierrors.CodeInsufficientRole {
+				t.Errorf("Expected the error code %s, but recieved %s", apierrors.CodeInsufficientRole, code)
+			}
+		}
+	}
+}
+
+func TestRequirePermission(t *testing.T) {
+
+	r, err := http.NewRequest("POST", "api/category", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	c := &Context{&auth.AuthContext{UserID: "0", Roles: models.Roles{Role: models.RoleModerator}}, nil, nil}
+
+	RequirePermission(auth.PermCreateCategory, func(c *Context, w http.ResponseWriter, r *http.Request) {})(c, w, r)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected a http status code of 403 Forbidden, because moderators can not create categories, but recieved a status code of %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	c.Roles.Role = models.RoleAdmin
+
+	RequirePermission(auth.PermCreateCategory, func(c *Context, w http.ResponseWriter, r *http.Request) {})(c, w, r)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected a http status code of 200, because admins can create categories, but recieved a status code of %d", w.Code)
+	}
+}
+
+func TestParseRoleClaims(t *testing.T) {
+
+	roles := parseRoleClaims(map[string]interface{}{
+		"sub":    "0",
+		"role":   "moderator",
+		"croles": map[string]interface{}{"gains": "admin", "sushi": "overlord"},
+	})
+
+	if roles.Role != models.RoleModerator {
+		t.Errorf("Expected the role moderator, but recieved %s", roles.Role)
+	}
+	if roles.CategoryRoles["gains"] != models.RoleAdmin {
+		t.Errorf("Expected the role admin in gains, but recieved %s", roles.CategoryRoles["gains"])
+	}
+	if _, ok := roles.CategoryRoles["sushi"]; ok {
+		t.Error("Expected the invalid role in sushi to be left out")
+	}
+
+	if roles = parseRoleClaims(map[string]interface{}{"role": "overlord"}); roles.Role != models.RoleUser {
+		t.Errorf("Expected tokens with an invalid role to get the role user, but recieved %s", roles.Role)
+	}
+}
+
+func TestAuthenticateTokenDoesNotShareContext(t *testing.T) {
+
+	r, err := http.NewRequest("GET", "api/category", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	shared := NewContext()
+	shared.UserID = "0"
+	shared.Roles = models.Roles{Role: models.RoleAdmin}
+
+	AuthenticateToken(shared, func(c *Context, w http.ResponseWriter, r *http.Request) {
+		if c == shared || c.UserID != "" || c.RoleIn("") == models.RoleAdmin {
+			t.Error("Expected requests without a token to be handled with a context of their own")
+		}
+	})(w, r)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/mangoslicer/answer-patch/datastores"
+	"github.com/mangoslicer/answer-patch/models"
+	"github.com/mangoslicer/answer-patch/settings"
+)
+
+const usage = `Usage: migrate [settings flags] <command>
+
+Commands:
+  up        Applies every pending migration
+  down [n]  Reverts the last n applied migrations (default 1)
+  status    Lists every migration and whether it has been applied
+  grant <username> <role> [category]
+            Sets the role of a user, everywhere or within a category, e.g. to appoint the first admin`
+
+func main() {
+
+	settings.DefaultEnv("preproduction")
+
+	_, args, err := settings.Load(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if len(args) < 1 {
+		fmt.Println(usage)
+		os.Exit(2)
+	}
+
+	db, err := datastores.ConnectToPostgres()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	switch args[0] {
+	case "up":
+		if err := datastores.MigrateUp(db); err != nil {
+			log.Fatal(err)
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				log.Fatalf("Expected a positive number of migrations to revert, but recieved %s", args[1])
+			}
+			steps = n
+		}
+		if err := datastores.MigrateDown(db, steps); err != nil {
+			log.Fatal(err)
+		}
+	case "status":
+	case "grant":
+		if len(args) < 3 || len(args) > 4 {
+			fmt.Println(usage)
+			os.Exit(2)
+		}
+		category := ""
+		if len(args) == 4 {
+			category = args[3]
+		}
+		if err := grant(db, args[1], models.Role(args[2]), category); err != nil {
+			log.Fatal(err)
+		}
+		return
+	default:
+		fmt.Println(usage)
+		os.Exit(2)
+	}
+
+	printStatus(db)
+}
+
+func grant(db *sql.DB, username string, role models.Role, category string) error {
+
+	if !role.IsValid() {
+		return fmt.Errorf("Expected a role of user, moderator or admin, but recieved %s", role)
+	}
+
+	userStore := &datastores.UserStore{DB: db}
+	user, err := userStore.FindUser("username", username)
+	if err != nil {
+		return err
+	}
+
+	roleStore := &datastores.RoleStore{DB: db}
+	if err = roleStore.SetRole(user.ID, category, role); err != nil {
+		return err
+	}
+
+	if category == "" {
+		fmt.Printf("%s is now %s everywhere\n", user.Username, role)
+	} else {
+		fmt.Printf("%s is now %s in %s\n", user.Username, role, category)
+	}
+	return nil
+}
+
+func printStatus(db *sql.DB) {
+
+	states, err := datastores.MigrationStatus(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, state := range states {
+		if state.Applied {
+			fmt.Printf("%s\tapplied at %s\n", state.String(), state.AppliedAt.Format("2006-01-02 15:04:05"))
+		} else {
+			fmt.Printf("%s\tpending\n", state.String())
+		}
+	}
+}
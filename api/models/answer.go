@@ -20,11 +20,11 @@ func NewAnswer() *Answer {
 	return &Answer{}
 }
 
-func (answer *Answer) GetMissingFields() string {
+func (answer *Answer) GetMissingFields() []string {
 
 	if answer.Content == "" {
-		return "Content\n"
+		return []string{"answerContent"}
 	}
 
-	return ""
+	return nil
 }
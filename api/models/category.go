@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+)
+
+type Category struct {
+	ID          string    `json:"categoryID"`
+	Name        string    `json:"categoryName"`
+	Description string    `json:"categoryDescription"`
+	UserID      string    `json:"categoryUserID"`
+	Username    string    `json:"categoryUsername"`
+	CreatedAt   time.Time `json:"categoryCreatedAt"`
+}
+
+// CategoryEdit holds the fields of a category that can be changed after its creation. Empty fields are left unchanged
+type CategoryEdit struct {
+	Name        string `json:"categoryName"`
+	Description string `json:"categoryDescription"`
+}
+
+func (category *Category) GetMissingFields() []string {
+
+	if category.Name == "" {
+		return []string{"categoryName"}
+	}
+
+	return nil
+}
+
+// Either field may be omitted, but not both, so both are reported when neither is recieved
+func (edit *CategoryEdit) GetMissingFields() []string {
+
+	if edit.Name == "" && edit.Description == "" {
+		return []string{"categoryName", "categoryDescription"}
+	}
+
+	return nil
+}
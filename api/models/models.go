@@ -1,5 +1,6 @@
 package models
 
 type ModelServices interface {
-	GetMissingFields() string
+	// GetMissingFields lists the JSON names of the required fields that were not recieved
+	GetMissingFields() []string
 }
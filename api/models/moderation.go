@@ -0,0 +1,101 @@
+package models
+
+import (
+	"time"
+)
+
+// PostType tells questions and answers apart wherever either of them may be moderated
+type PostType string
+
+const (
+	PostQuestion PostType = "question"
+	PostAnswer   PostType = "answer"
+)
+
+// PostRef identifies a question or an answer along with the category of the route it was referred to by.
+// Posts are only found within that category, so that moderators of one category can not act on the posts of another
+type PostRef struct {
+	Type     PostType
+	ID       string
+	Category string
+}
+
+// FlagReason is the reason a user reports a post for
+type FlagReason string
+
+const (
+	FlagSpam     FlagReason = "spam"
+	FlagAbusive  FlagReason = "abusive"
+	FlagOffTopic FlagReason = "off_topic"
+	FlagOther    FlagReason = "other"
+)
+
+// IsValid reports whether reason is one of the known reasons
+func (reason FlagReason) IsValid() bool {
+	switch reason {
+	case FlagSpam, FlagAbusive, FlagOffTopic, FlagOther:
+		return true
+	}
+	return false
+}
+
+// FlagRequest is the body of a request to report a post to the moderators
+type FlagRequest struct {
+	Reason FlagReason `json:"reason"`
+	Detail string     `json:"detail"`
+}
+
+func (request *FlagRequest) GetMissingFields() []string {
+	if request.Reason == "" {
+		return []string{"reason"}
+	}
+	return nil
+}
+
+// FlaggedPost is a post in the review queue, along with the flags that have not yet been resolved
+type FlaggedPost struct {
+	Type           PostType           `json:"postType"`
+	QuestionID     string             `json:"questionID"`
+	AnswerID       string             `json:"answerID,omitempty"`
+	Category       string             `json:"category"`
+	Title          string             `json:"questionTitle"`
+	Content        string             `json:"content"`
+	UserID         string             `json:"userID"`
+	Username       string             `json:"username"`
+	FlagCount      int                `json:"flagCount"`
+	Reasons        map[FlagReason]int `json:"reasons"`
+	FirstFlaggedAt time.Time          `json:"firstFlaggedAt"`
+}
+
+// Removal is the body of a request to remove a post. Removed posts are hidden rather than deleted, so that the removal can be audited
+type Removal struct {
+	Reason string `json:"reason"`
+}
+
+func (removal *Removal) GetMissingFields() []string {
+	if removal.Reason == "" {
+		return []string{"reason"}
+	}
+	return nil
+}
+
+// ModerationActionType is the kind of action that a moderator took on a post
+type ModerationActionType string
+
+const (
+	ActionRemove       ModerationActionType = "remove"
+	ActionDismissFlags ModerationActionType = "dismiss_flags"
+)
+
+// ModerationAction is an entry of the moderation log. ModeratorID is empty once the moderator deletes their account
+type ModerationAction struct {
+	ID                string               `json:"id"`
+	ModeratorID       string               `json:"moderatorID,omitempty"`
+	ModeratorUsername string               `json:"moderatorUsername,omitempty"`
+	Action            ModerationActionType `json:"action"`
+	PostType          PostType             `json:"postType"`
+	QuestionID        string               `json:"questionID"`
+	AnswerID          string               `json:"answerID,omitempty"`
+	Reason            string               `json:"reason,omitempty"`
+	TakenAt           time.Time            `json:"takenAt"`
+}
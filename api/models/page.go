@@ -0,0 +1,58 @@
+package models
+
+// PageRequest selects a page of a list. Cursor is empty for the first page, and otherwise one of the cursors of another page of the list
+type PageRequest struct {
+	Cursor string
+	Size   int
+}
+
+// Cursors lead from a page to the pages next to it. They are opaque to clients, and left out at either end of the list
+type Cursors struct {
+	Next string `json:"next,omitempty"`
+	Prev string `json:"prev,omitempty"`
+}
+
+type QuestionPage struct {
+	Items []*Question `json:"items"`
+	Cursors
+}
+
+type SearchResultPage struct {
+	Items []*SearchResult `json:"items"`
+	Cursors
+}
+
+type FlaggedPostPage struct {
+	Items []*FlaggedPost `json:"items"`
+	Cursors
+}
+
+type ModerationActionPage struct {
+	Items []*ModerationAction `json:"items"`
+	Cursors
+}
+
+type CategoryPage struct {
+	Items []*Category `json:"items"`
+	Cursors
+}
+
+type AnswerRevisionPage struct {
+	Items []*AnswerRevision `json:"items"`
+	Cursors
+}
+
+type AnswerVotePage struct {
+	Items []*AnswerVote `json:"items"`
+	Cursors
+}
+
+type RoleGrantPage struct {
+	Items []*RoleGrant `json:"items"`
+	Cursors
+}
+
+type SessionPage struct {
+	Items []*Session `json:"items"`
+	Cursors
+}
@@ -15,21 +15,46 @@ type Question struct {
 	EditCount    int       `json:"answerEditCount"`
 	PendingCount int       `json:"pendingAnswerCount"`
 	SubmittedAt  time.Time `json:"questionSubmittedAt"`
+	DuplicateOf  string    `json:"duplicateOfID,omitempty"` // Id of the question that the question was linked to as a duplicate
+	Force        bool      `json:"force,omitempty"`         // Only read from submissions, which are stored in spite of candidate duplicates if it is set
 }
 
-func (question *Question) GetMissingFields() string {
+// DuplicateCandidate is an existing question that a submitted question may duplicate. Score is the similarity of the two, from 0 to 1
+type DuplicateCandidate struct {
+	QuestionID string  `json:"questionID"`
+	Username   string  `json:"questionUsername"`
+	Title      string  `json:"questionTitle"`
+	Score      float64 `json:"score"`
+}
+
+// QuestionQuery combines the filters and the sort of a listing of questions. Filters that are left at their zero value match every question
+type QuestionQuery struct {
+	Categories []string // Matches the questions of any of the categories
+	Author     string
+	Answered   *bool     // Matches the questions that have a current answer if true, and those that lack one if false
+	HasPending *bool     // Matches the questions that have answers besides their current answer if true, and those that have none if false
+	From       time.Time // Matches the questions submitted at or after From
+	To         time.Time // Matches the questions submitted before To
+	MinUpvotes *int
+	Sort       string // One of upvotes, date, edits or pending
+	Descending bool
+}
+
+func (question *Question) GetMissingFields() []string {
 
-	var missing string
+	var missing []string
 
-	switch {
-	case question.UserID == "":
-		missing = "Author's user ID\n"
-	case question.Username == "":
-		missing += "Author's username\n"
-	case question.Title == "":
-		missing += "Title\n"
-	case question.Category == "":
-		missing += "Category\n"
+	if question.UserID == "" {
+		missing = append(missing, "questionUserID")
+	}
+	if question.Username == "" {
+		missing = append(missing, "questionUsername")
+	}
+	if question.Title == "" {
+		missing = append(missing, "questionTitle")
+	}
+	if question.Category == "" {
+		missing = append(missing, "questionCategory")
 	}
 
 	return missing
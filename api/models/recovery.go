@@ -0,0 +1,89 @@
+package models
+
+// Registration is the body of a request to register a user, whose email is verified through a link that is mailed to it
+type Registration struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Email    string `json:"email"`
+}
+
+func (registration *Registration) GetMissingFields() []string {
+
+	var missing []string
+
+	if registration.Username == "" {
+		missing = append(missing, "username")
+	}
+	if registration.Password == "" {
+		missing = append(missing, "password")
+	}
+	if registration.Email == "" {
+		missing = append(missing, "email")
+	}
+
+	return missing
+}
+
+// ForgottenPassword is the body of a request to mail a password reset link to the verified email of a user
+type ForgottenPassword struct {
+	Email string `json:"email"`
+}
+
+func (forgotten *ForgottenPassword) GetMissingFields() []string {
+	if forgotten.Email == "" {
+		return []string{"email"}
+	}
+	return nil
+}
+
+// PasswordReset is the body of a request to set a new password with the token of a password reset link
+type PasswordReset struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"newPassword"`
+}
+
+func (reset *PasswordReset) GetMissingFields() []string {
+
+	var missing []string
+
+	if reset.Token == "" {
+		missing = append(missing, "token")
+	}
+	if reset.NewPassword == "" {
+		missing = append(missing, "newPassword")
+	}
+
+	return missing
+}
+
+// EmailVerification is the body of a request to verify an email with the token of a verification link
+type EmailVerification struct {
+	Token string `json:"token"`
+}
+
+func (verification *EmailVerification) GetMissingFields() []string {
+	if verification.Token == "" {
+		return []string{"token"}
+	}
+	return nil
+}
+
+// OIDCCallback is the body of a request that completes a login with an OpenID Connect provider, with the code and state that the provider redirected back with
+type OIDCCallback struct {
+	Code  string `json:"code"`
+	State string `json:"state"`
+}
+
+func (callback *OIDCCallback) GetMissingFields() []string {
+
+	var missing []string
+
+	if callback.Code == "" {
+		missing = append(missing, "code")
+	}
+	if callback.State == "" {
+		missing = append(missing, "state")
+	}
+
+	return missing
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+)
+
+// AnswerRevision is a snapshot of an answer at the moment it became the current answer of a question
+type AnswerRevision struct {
+	ID              string    `json:"revisionID"`
+	QuestionID      string    `json:"revisionQuestionID"`
+	Revision        int       `json:"revisionNumber"`
+	AnswerID        string    `json:"revisionAnswerID"` // Empty once the answer has been deleted
+	UserID          string    `json:"revisionUserID"`
+	Username        string    `json:"revisionUsername"`
+	Content         string    `json:"revisionContent"`
+	Upvotes         int       `json:"revisionUpvotes"`
+	BecameCurrentAt time.Time `json:"revisionBecameCurrentAt"`
+}
@@ -0,0 +1,97 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Role determines what a user may do, either everywhere or within one category. Every role may do what the roles below it may do
+type Role string
+
+const (
+	RoleUser      Role = "user" // Every user has at least this role, so it is never stored
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
+var roleRanks = map[Role]int{RoleUser: 1, RoleModerator: 2, RoleAdmin: 3}
+
+// IsValid reports whether role is one of the known roles
+func (role Role) IsValid() bool {
+	return roleRanks[role] != 0
+}
+
+// AtLeast reports whether role may do everything that other may do
+func (role Role) AtLeast(other Role) bool {
+	return roleRanks[role] >= roleRanks[other]
+}
+
+// Roles are the roles of a user, which are carried in the claims of their access tokens
+type Roles struct {
+	Role          Role            `json:"role"`
+	CategoryRoles map[string]Role `json:"categoryRoles,omitempty"` // Keyed by category name in lower case
+	Banned        bool            `json:"banned"`
+}
+
+// In returns the role of the user within category, which is the higher of their role everywhere and their role in the category.
+// An empty category returns the role everywhere
+func (roles *Roles) In(category string) Role {
+
+	role := roles.Role
+	if role == "" {
+		role = RoleUser
+	}
+
+	if categoryRole, ok := roles.CategoryRoles[strings.ToLower(category)]; ok && category != "" && !role.AtLeast(categoryRole) {
+		role = categoryRole
+	}
+
+	return role
+}
+
+// Highest returns the highest role of the user in any scope, whether everywhere or within one of their categories
+func (roles *Roles) Highest() Role {
+
+	role := roles.In("")
+	for _, categoryRole := range roles.CategoryRoles {
+		if !role.AtLeast(categoryRole) {
+			role = categoryRole
+		}
+	}
+
+	return role
+}
+
+// RoleGrant records a role that was granted to a user. Category is empty for roles that apply everywhere
+type RoleGrant struct {
+	UserID    string    `json:"userID"`
+	Username  string    `json:"username"`
+	Role      Role      `json:"role"`
+	Category  string    `json:"category,omitempty"`
+	GrantedAt time.Time `json:"grantedAt"`
+}
+
+// RoleChange is the body of a request to change the role of a user, either everywhere or within a category. Changing it to the user role revokes it
+type RoleChange struct {
+	Role     Role   `json:"role"`
+	Category string `json:"category"`
+}
+
+func (change *RoleChange) GetMissingFields() []string {
+	if change.Role == "" {
+		return []string{"role"}
+	}
+	return nil
+}
+
+// BanRequest is the body of a request to ban a user, which keeps them from logging in until the ban is lifted
+type BanRequest struct {
+	Reason string `json:"reason"`
+}
+
+func (request *BanRequest) GetMissingFields() []string {
+	if request.Reason == "" {
+		return []string{"reason"}
+	}
+	return nil
+}
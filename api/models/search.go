@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+)
+
+// SearchQuery is a full-text search of the questions, read from the query string of the request. Category and Author narrow the search if they are not empty
+type SearchQuery struct {
+	Terms    string
+	Category string
+	Author   string
+}
+
+// SearchResult is a question that matches a search. Its title and snippet are escaped HTML, in which the matching words are wrapped in <mark> tags
+type SearchResult struct {
+	QuestionID     string    `json:"questionID"`
+	UserID         string    `json:"questionUserID"`
+	Username       string    `json:"questionUsername"`
+	Category       string    `json:"questionCategory"`
+	Title          string    `json:"questionTitle"`
+	Upvotes        int       `json:"questionUpvotes"`
+	SubmittedAt    time.Time `json:"questionSubmittedAt"`
+	TitleHighlight string    `json:"titleHighlight"`
+	Snippet        string    `json:"snippet"` // Drawn from the question and its current answer
+	Rank           float64   `json:"rank"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import (
+	"time"
+)
+
+// Session is a login on one device, which lasts through any amount of token refreshes until it is revoked or its refresh token expires
+type Session struct {
+	ID         string    `json:"sessionID"`
+	UserAgent  string    `json:"sessionUserAgent"`
+	CreatedAt  time.Time `json:"sessionCreatedAt"`
+	LastUsedAt time.Time `json:"sessionLastUsedAt"`
+	Current    bool      `json:"sessionCurrent"` // Whether the session is the one of the token that requested it
+}
@@ -0,0 +1,13 @@
+package models
+
+// RefreshRequest is the body of a request to exchange a refresh token for a new pair of tokens
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+func (req *RefreshRequest) GetMissingFields() []string {
+	if req.RefreshToken == "" {
+		return []string{"refreshToken"}
+	}
+	return nil
+}
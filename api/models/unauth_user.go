@@ -1,43 +1,56 @@
 package models
 
-import (
-	"log"
-
-	"code.google.com/p/go.crypto/bcrypt"
-)
-
 type UnauthUser struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
 }
 
-const (
-	hashCost = 10
-)
-
 func NewUnauthUser() *UnauthUser {
 	return &UnauthUser{}
 }
 
-func (unauth *UnauthUser) GetMissingFields() string {
+func (unauth *UnauthUser) GetMissingFields() []string {
 
-	var missing string
+	var missing []string
 
-	switch {
-	case unauth.Username == "":
-		missing = "username\n"
-	case unauth.Password == "":
-		missing += "password\n"
+	if unauth.Username == "" {
+		missing = append(missing, "username")
+	}
+	if unauth.Password == "" {
+		missing = append(missing, "password")
 	}
 
 	return missing
 }
 
-func (unauth *UnauthUser) HashPassword() string {
-	hash, err := bcrypt.GenerateFromPassword([]byte(unauth.Password), hashCost)
-	if err != nil {
-		log.Fatal(err)
+// PasswordChange is the body of a request to change the password of the current user, which must be confirmed with the old password
+type PasswordChange struct {
+	OldPassword string `json:"oldPassword"`
+	NewPassword string `json:"newPassword"`
+}
+
+func (change *PasswordChange) GetMissingFields() []string {
+
+	var missing []string
+
+	if change.OldPassword == "" {
+		missing = append(missing, "oldPassword")
 	}
+	if change.NewPassword == "" {
+		missing = append(missing, "newPassword")
+	}
+
+	return missing
+}
 
-	return string(hash)
+// UsernameChange is the body of a request to rename the current user
+type UsernameChange struct {
+	Username string `json:"username"`
+}
+
+func (change *UsernameChange) GetMissingFields() []string {
+	if change.Username == "" {
+		return []string{"username"}
+	}
+	return nil
 }
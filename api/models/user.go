@@ -8,5 +8,7 @@ type User struct {
 	ID             string    `json:"userID"`
 	Username       string    `json:"username"`
 	HashedPassword string    `json:"hashedPassword"`
+	Email          string    `json:"-"` // Emails are private, so they are never shown along with the public profile
+	EmailVerified  bool      `json:"-"`
 	CreatedAt      time.Time `json:"createdAt"`
 }
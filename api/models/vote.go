@@ -0,0 +1,12 @@
+package models
+
+import (
+	"time"
+)
+
+type AnswerVote struct {
+	AnswerID   string    `json:"voteAnswerID"`
+	QuestionID string    `json:"voteQuestionID"`
+	Vote       int       `json:"vote"`
+	CastAt     time.Time `json:"voteCastAt"`
+}
@@ -5,15 +5,26 @@ import (
 )
 
 const (
-	CreatePendingAnswer = "put:pending_answer"
-	UpdateAnswerVote    = "put:answer_vote"
+	CreatePendingAnswer       = "put:pending_answer"
+	UpdateAnswerVote          = "put:answer_vote"
+	DeleteAnswerVote          = "delete:answer_vote"
+	ReadAnswerVotes           = "get:answer_votes"
+	ReadAnswerVotesByQuestion = "get:answer_votes_by_question"
 )
 
 func InitAnswerRoutes(r *mux.Router) *mux.Router {
 
+	//GET
+	r.Path("/votes/answers").Methods("GET").Name(ReadAnswerVotes)
+	r.Path("/votes/answers/{questionID:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}").Methods("GET").Name(ReadAnswerVotesByQuestion)
+
 	//PUT
-	r.Path("/{category:[a-z]+}/{questionID:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}/answer}").Methods("PUT").Name(CreatePendingAnswer)
+	r.Path("/{category:[a-z]+}/{questionID:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}/answer").Methods("PUT").Name(CreatePendingAnswer)
+
+	r.Path("/{category:[a-z]+}/answer/{answerID:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}/vote/{vote:-1|1}").Methods("PUT").Name(UpdateAnswerVote)
+
+	//DELETE
+	r.Path("/{category:[a-z]+}/answer/{answerID:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}/vote").Methods("DELETE").Name(DeleteAnswerVote)
 
-	r.Path("/{category:[a-z]+}/answer/{answerID:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}/vote/{vote:-1|1}}").Methods("PUT").Name(UpdateAnswerVote)
 	return r
 }
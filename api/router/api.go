@@ -4,13 +4,25 @@ import (
 	"github.com/gorilla/mux"
 )
 
+// InitRouter registers the routes of the API under /api, along with the well-known routes, which clients expect at the root
 func InitRouter() *mux.Router {
 
-	r := mux.NewRouter().PathPrefix("/api").Subrouter()
+	root := mux.NewRouter()
+	root = InitWellKnownRoutes(root)
+
+	r := root.PathPrefix("/api").Subrouter()
 
 	r = InitQuestionRoutes(r)
 	r = InitAnswerRoutes(r)
 	r = InitUserRoutes(r)
+	r = InitRevisionRoutes(r)
+	r = InitCategoryRoutes(r)
+	r = InitSessionRoutes(r)
+	r = InitPasswordRoutes(r)
+	r = InitOIDCRoutes(r)
+	r = InitRoleRoutes(r)
+	r = InitModerationRoutes(r)
+	r = InitSearchRoutes(r)
 
-	return r
+	return root
 }
@@ -0,0 +1,27 @@
+package router
+
+import (
+	"github.com/gorilla/mux"
+)
+
+const (
+	ReadCategories = "get:categories"
+	ReadCategory   = "get:category"
+	CreateCategory = "post:category"
+	UpdateCategory = "put:category"
+)
+
+func InitCategoryRoutes(r *mux.Router) *mux.Router {
+
+	//GET
+	r.Path("/categories").Methods("GET").Name(ReadCategories)
+	r.Path("/categories/{category:[a-z]+}").Methods("GET").Name(ReadCategory)
+
+	//POST
+	r.Path("/categories").Methods("POST").Name(CreateCategory)
+
+	//PUT
+	r.Path("/categories/{category:[a-z]+}").Methods("PUT").Name(UpdateCategory)
+
+	return r
+}
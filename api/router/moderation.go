@@ -0,0 +1,37 @@
+package router
+
+import (
+	"github.com/gorilla/mux"
+)
+
+const (
+	ReadFlaggedPosts         = "get:flagged_posts"
+	ReadCategoryFlaggedPosts = "get:category_flagged_posts"
+	ReadModerationActions    = "get:moderation_actions"
+	CreateQuestionFlag       = "post:question_flag"
+	CreateAnswerFlag         = "post:answer_flag"
+	DeleteQuestionFlags      = "delete:question_flags"
+	DeleteAnswerFlags        = "delete:answer_flags"
+	DeleteQuestion           = "delete:question"
+	DeleteAnswer             = "delete:answer"
+)
+
+func InitModerationRoutes(r *mux.Router) *mux.Router {
+
+	//GET
+	r.Path("/flags").Methods("GET").Name(ReadFlaggedPosts)
+	r.Path("/{category:[a-z]+}/flags").Methods("GET").Name(ReadCategoryFlaggedPosts)
+	r.Path("/moderation").Methods("GET").Name(ReadModerationActions)
+
+	//POST
+	r.Path("/{category:[a-z]+}/question/{questionID:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}/flag").Methods("POST").Name(CreateQuestionFlag)
+	r.Path("/{category:[a-z]+}/answer/{answerID:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}/flag").Methods("POST").Name(CreateAnswerFlag)
+
+	//DELETE
+	r.Path("/{category:[a-z]+}/question/{questionID:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}/flags").Methods("DELETE").Name(DeleteQuestionFlags)
+	r.Path("/{category:[a-z]+}/answer/{answerID:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}/flags").Methods("DELETE").Name(DeleteAnswerFlags)
+	r.Path("/{category:[a-z]+}/question/{questionID:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}").Methods("DELETE").Name(DeleteQuestion)
+	r.Path("/{category:[a-z]+}/answer/{answerID:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}").Methods("DELETE").Name(DeleteAnswer)
+
+	return r
+}
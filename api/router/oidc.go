@@ -0,0 +1,25 @@
+package router
+
+import (
+	"github.com/gorilla/mux"
+)
+
+const (
+	ReadOIDCProviders = "get:oidcProviders"
+	AuthorizeOIDC     = "post:oidcAuthorize"
+	LinkOIDC          = "post:oidcLink"
+	OIDCCallback      = "post:oidcCallback"
+)
+
+func InitOIDCRoutes(r *mux.Router) *mux.Router {
+
+	//GET
+	r.Path("/oidc/providers").Methods("GET").Name(ReadOIDCProviders)
+
+	//POST
+	r.Path("/oidc/{provider:[a-z0-9_-]+}/authorize").Methods("POST").Name(AuthorizeOIDC)
+	r.Path("/oidc/{provider:[a-z0-9_-]+}/link").Methods("POST").Name(LinkOIDC)
+	r.Path("/oidc/{provider:[a-z0-9_-]+}/callback").Methods("POST").Name(OIDCCallback)
+
+	return r
+}
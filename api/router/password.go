@@ -0,0 +1,19 @@
+package router
+
+import (
+	"github.com/gorilla/mux"
+)
+
+const (
+	ForgotPassword = "post:forgotPassword"
+	ResetPassword  = "post:resetPassword"
+)
+
+func InitPasswordRoutes(r *mux.Router) *mux.Router {
+
+	//POST
+	r.Path("/password/forgot").Methods("POST").Name(ForgotPassword)
+	r.Path("/password/reset").Methods("POST").Name(ResetPassword)
+
+	return r
+}
@@ -8,7 +8,9 @@ const (
 	ReadPost              = "get:post"
 	ReadQuestionsByFilter = "get:questions_by_filter"
 	ReadSortedQuestions   = "get:sorted_questions"
+	ReadQuestions         = "get:questions"
 	CreateQuestion        = "post:question"
+	UpdateQuestionVote    = "put:question_vote"
 )
 
 func InitQuestionRoutes(r *mux.Router) *mux.Router {
@@ -16,11 +18,15 @@ func InitQuestionRoutes(r *mux.Router) *mux.Router {
 	//GET
 	r.Path("/post/{questionId:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}").Methods("GET").Name(ReadPost)
 	r.Path("/questions/{filter:posted-by|answered-by|category}/{val:[A-Za-z0-9]+}").Methods("GET").Name(ReadQuestionsByFilter)
-	r.Path("/{postComponent:questions|answers}/{sortedBy:upvotes|edits|date}/{order:desc|asc}/{offset:[0-9]+}").Methods("GET").Name(ReadSortedQuestions)
+	r.Path("/{postComponent:questions|answers}/{sortedBy:upvotes|edits|date}/{order:desc|asc}").Methods("GET").Name(ReadSortedQuestions)
+	r.Path("/questions").Methods("GET").Name(ReadQuestions)
 
 	//POST
 	r.Path("/question/{category:[a-z]+}").Methods("POST").Name(CreateQuestion)
 
+	//PUT
+	r.Path("/{category:[a-z]+}/question/{questionID:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}/vote/{vote:-1|1}").Methods("PUT").Name(UpdateQuestionVote)
+
 	return r
 
 }
@@ -0,0 +1,23 @@
+package router
+
+import (
+	"github.com/gorilla/mux"
+)
+
+const (
+	ReadAnswerHistory = "get:answer_history"
+	ReadRevisionDiff  = "get:revision_diff"
+	RollbackRevision  = "put:revision_rollback"
+)
+
+func InitRevisionRoutes(r *mux.Router) *mux.Router {
+
+	//GET
+	r.Path("/post/{questionId:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}/history").Methods("GET").Name(ReadAnswerHistory)
+	r.Path("/post/{questionId:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}/history/{from:[0-9]+}/diff/{to:[0-9]+}").Methods("GET").Name(ReadRevisionDiff)
+
+	//PUT
+	r.Path("/{category:[a-z]+}/post/{questionId:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}/history/{revision:[0-9]+}/rollback").Methods("PUT").Name(RollbackRevision)
+
+	return r
+}
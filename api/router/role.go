@@ -0,0 +1,29 @@
+package router
+
+import (
+	"github.com/gorilla/mux"
+)
+
+const (
+	ReadRoles  = "get:roles"
+	UpdateRole = "put:role"
+	CreateBan  = "post:ban"
+	DeleteBan  = "delete:ban"
+)
+
+func InitRoleRoutes(r *mux.Router) *mux.Router {
+
+	//GET
+	r.Path("/roles").Methods("GET").Name(ReadRoles)
+
+	//POST
+	r.Path("/users/{userID:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}/ban").Methods("POST").Name(CreateBan)
+
+	//PUT
+	r.Path("/users/{userID:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}/role").Methods("PUT").Name(UpdateRole)
+
+	//DELETE
+	r.Path("/users/{userID:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}/ban").Methods("DELETE").Name(DeleteBan)
+
+	return r
+}
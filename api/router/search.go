@@ -0,0 +1,17 @@
+package router
+
+import (
+	"github.com/gorilla/mux"
+)
+
+const (
+	Search = "get:search"
+)
+
+func InitSearchRoutes(r *mux.Router) *mux.Router {
+
+	//GET
+	r.Path("/search").Methods("GET").Name(Search)
+
+	return r
+}
@@ -0,0 +1,23 @@
+package router
+
+import (
+	"github.com/gorilla/mux"
+)
+
+const (
+	ReadSessions   = "get:sessions"
+	DeleteSession  = "delete:session"
+	DeleteSessions = "delete:sessions"
+)
+
+func InitSessionRoutes(r *mux.Router) *mux.Router {
+
+	//GET
+	r.Path("/sessions").Methods("GET").Name(ReadSessions)
+
+	//DELETE
+	r.Path("/sessions").Methods("DELETE").Name(DeleteSessions)
+	r.Path("/sessions/{sessionID:[0-9a-f]{32}}").Methods("DELETE").Name(DeleteSession)
+
+	return r
+}
@@ -3,10 +3,18 @@ package router
 import "github.com/gorilla/mux"
 
 const (
-	ReadUser   = "get:user"
-	CreateUser = "post:user"
-	Login      = "post:login"
-	Logout     = "post:logout"
+	ReadUser     = "get:user"
+	CreateUser   = "post:user"
+	Login        = "post:login"
+	Logout       = "post:logout"
+	RefreshToken = "post:refreshToken"
+
+	VerifyEmail        = "post:verifyEmail"
+	ResendVerification = "post:emailVerification"
+
+	UpdatePassword = "put:password"
+	UpdateUsername = "put:username"
+	DeleteUser     = "delete:user"
 )
 
 func InitUserRoutes(r *mux.Router) *mux.Router {
@@ -18,6 +26,16 @@ func InitUserRoutes(r *mux.Router) *mux.Router {
 	r.Path("/register").Methods("POST").Name(CreateUser)
 	r.Path("/login").Methods("POST").Name(Login)
 	r.Path("/logout").Methods("POST").Name(Logout)
+	r.Path("/token/refresh").Methods("POST").Name(RefreshToken)
+	r.Path("/email/verify").Methods("POST").Name(VerifyEmail)
+	r.Path("/me/email/verification").Methods("POST").Name(ResendVerification)
+
+	//PUT
+	r.Path("/me/password").Methods("PUT").Name(UpdatePassword)
+	r.Path("/me/username").Methods("PUT").Name(UpdateUsername)
+
+	//DELETE
+	r.Path("/me").Methods("DELETE").Name(DeleteUser)
 
 	return r
 
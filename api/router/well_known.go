@@ -0,0 +1,17 @@
+package router
+
+import (
+	"github.com/gorilla/mux"
+)
+
+const (
+	ReadJWKS = "get:jwks"
+)
+
+func InitWellKnownRoutes(r *mux.Router) *mux.Router {
+
+	//GET
+	r.Path("/.well-known/jwks.json").Methods("GET").Name(ReadJWKS)
+
+	return r
+}
@@ -0,0 +1,176 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/mangoslicer/answer-patch/apierrors"
+	"github.com/mangoslicer/answer-patch/datastores"
+	"github.com/mangoslicer/answer-patch/settings"
+)
+
+/**
+ * Action tokens are signed JSON Web Tokens that are mailed to users to confirm an action, such as resetting their password
+ * Every action token carries a purpose claim, which access tokens never do, so an action token only ever serves its own purpose
+ * Issued tokens are recorded in the TokenStore until they expire, and are marked as used once they are consumed, which makes them single-use
+ */
+
+// Purposes of action tokens
+const (
+	PasswordResetPurpose     = "password_reset"
+	EmailVerificationPurpose = "email_verification"
+)
+
+var (
+	ErrInvalidActionToken = apierrors.New(apierrors.KindInvalid, apierrors.CodeInvalidToken, "The token is invalid or has expired", apierrors.FieldError{Field: "token", Reason: "invalid"})
+	ErrUsedActionToken    = apierrors.New(apierrors.KindGone, apierrors.CodeTokenUsed, "The token has already been used", apierrors.FieldError{Field: "token", Reason: "used"})
+)
+
+/**
+ * ActionToken is a parsed action token
+ * Binding is a value that must still hold when the token is redeemed, such as the email that is being verified, so that the token becomes invalid once the value changes
+ */
+type ActionToken struct {
+	ID      string
+	Purpose string
+	UserID  string
+	Binding string
+	Exp     time.Time
+}
+
+/**
+ * Signs an action token for userID, and records it in the TokenStore for as long as it lives
+ */
+func (ac *AuthContext) IssueActionToken(purpose, userID, binding string, life time.Duration) (string, error) {
+
+	tokenID, err := newRandomID()
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS512, jwt.MapClaims{
+		"iat":     time.Now().Unix(),
+		"exp":     time.Now().Add(life).Unix(),
+		"sub":     userID,
+		"jti":     tokenID,
+		"purpose": purpose,
+		"bnd":     binding,
+	})
+
+	key := settings.GetKeyring().Active
+	token.Header["kid"] = key.ID
+
+	signedToken, err := token.SignedString(key.Private)
+	if err != nil {
+		return "", apierrors.Internal(err)
+	}
+
+	if err = ac.TokenStore.StoreToken(actionTokenKey(tokenID), purpose, int(life.Seconds())); err != nil {
+		return "", err
+	}
+
+	return signedToken, nil
+}
+
+/**
+ * Verifies an action token of the provided purpose without consuming it, so that the rest of the request can be validated before the token is used up
+ */
+func (ac *AuthContext) ParseActionToken(purpose, signedToken string) (*ActionToken, error) {
+
+	parsedToken, err := jwt.Parse(signedToken, VerificationKey)
+	if err != nil || !parsedToken.Valid {
+		return nil, ErrInvalidActionToken
+	}
+
+	claims, ok := parsedToken.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, ErrInvalidActionToken
+	}
+
+	token := new(ActionToken)
+	token.ID, _ = claims["jti"].(string)
+	token.Purpose, _ = claims["purpose"].(string)
+	token.UserID, _ = claims["sub"].(string)
+	token.Binding, _ = claims["bnd"].(string)
+	exp, _ := claims["exp"].(float64)
+	token.Exp = time.Unix(int64(exp), 0)
+
+	if token.ID == "" || token.UserID == "" || token.Purpose != purpose {
+		return nil, ErrInvalidActionToken
+	}
+
+	// Tokens that were never recorded were not issued by this deployment's TokenStore, or have outlived their record
+	if _, err = ac.TokenStore.FindToken(actionTokenKey(token.ID)); errors.Is(err, datastores.ErrTokenNotFound) {
+		return nil, ErrInvalidActionToken
+	} else if err != nil {
+		return nil, err
+	}
+
+	isUsed, err := ac.TokenStore.IsTokenStored(usedActionTokenKey(token.ID))
+	if err != nil {
+		return nil, err
+	} else if isUsed {
+		return nil, ErrUsedActionToken
+	}
+
+	return token, nil
+}
+
+/**
+ * Marks an action token as used. Marking is atomic, so a token that is redeemed concurrently is only consumed once
+ */
+func (ac *AuthContext) ConsumeActionToken(token *ActionToken) error {
+
+	// The mark outlives the token by StoreOffset, which covers the leeway between the clocks of the api and the TokenStore
+	life := int(time.Until(token.Exp).Seconds()) + StoreOffset
+
+	isFirstUse, err := ac.TokenStore.StoreTokenOnce(usedActionTokenKey(token.ID), token.Purpose, life)
+	if err != nil {
+		return err
+	} else if !isFirstUse {
+		return ErrUsedActionToken
+	}
+
+	return nil
+}
+
+/**
+ * Binds password reset tokens to the password they reset, so every outstanding reset token becomes invalid once the password changes
+ * Only a prefix of the hash of the hashed password is used, so the token reveals nothing about the password
+ */
+func PasswordFingerprint(hashedPassword string) string {
+	hash := sha256.Sum256([]byte(hashedPassword))
+	return hex.EncodeToString(hash[:8])
+}
+
+/**
+ * Finds the key of the keyring that verifies a JSON Web Token, which is named by the kid in the token's header
+ * Only RSA signatures are accepted, since an HMAC signature could otherwise be forged with the public key as its secret
+ */
+func VerificationKey(token *jwt.Token) (interface{}, error) {
+
+	if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+		return nil, fmt.Errorf("Unrecognized signing method: %v", token.Header["alg"])
+	}
+
+	// Tokens are verified by the key that signed them, which may have been rotated out of the active position since
+	kid, _ := token.Header["kid"].(string)
+	key, ok := settings.GetKeyring().Key(kid)
+	if !ok {
+		return nil, fmt.Errorf("Unrecognized signing key: %q", kid)
+	}
+
+	return key.Public, nil
+}
+
+func actionTokenKey(tokenID string) string {
+	return "action:" + tokenID
+}
+
+func usedActionTokenKey(tokenID string) string {
+	return "action_used:" + tokenID
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mangoslicer/answer-patch/datastores"
+)
+
+/**
+ * Tests that an action token can be parsed until it is consumed, and is rejected afterwards
+ */
+func TestActionTokenIsSingleUse(t *testing.T) {
+
+	ac := &AuthContext{TokenStore: datastores.NewMemoryTokenStore()}
+
+	signedToken, err := ac.IssueActionToken(PasswordResetPurpose, userID, "binding", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := ac.ParseActionToken(PasswordResetPurpose, signedToken)
+	if err != nil {
+		t.Fatal(err)
+	} else if token.UserID != userID || token.Binding != "binding" {
+		t.Errorf("Expected the token to carry the userID and binding it was issued with, but recieved %+v", token)
+	}
+
+	if err = ac.ConsumeActionToken(token); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = ac.ConsumeActionToken(token); !errors.Is(err, ErrUsedActionToken) {
+		t.Errorf("Expected a second consumption to fail with %v, but recieved %v", ErrUsedActionToken, err)
+	}
+	if _, err = ac.ParseActionToken(PasswordResetPurpose, signedToken); !errors.Is(err, ErrUsedActionToken) {
+		t.Errorf("Expected a consumed token to be rejected with %v, but recieved %v", ErrUsedActionToken, err)
+	}
+}
+
+/**
+ * Tests that action tokens are rejected when they are used for another purpose, were not recorded, or have expired
+ */
+func TestParseActionTokenWithInvalidTokens(t *testing.T) {
+
+	ac := &AuthContext{TokenStore: datastores.NewMemoryTokenStore()}
+
+	resetToken, err := ac.IssueActionToken(PasswordResetPurpose, userID, "", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The token is recorded in another TokenStore
+	otherContext := &AuthContext{TokenStore: datastores.NewMemoryTokenStore()}
+	unrecordedToken, err := otherContext.IssueActionToken(PasswordResetPurpose, userID, "", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expiredToken, err := ac.IssueActionToken(PasswordResetPurpose, userID, "", -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	accessToken, err := ac.issueTokens(userID, "family")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var parseTests = []struct {
+		purpose string
+		token   string
+	}{
+		{EmailVerificationPurpose, resetToken},
+		{PasswordResetPurpose, unrecordedToken},
+		{PasswordResetPurpose, expiredToken},
+		{PasswordResetPurpose, accessToken.SignedToken},
+		{PasswordResetPurpose, "not.a.token"},
+	}
+
+	for i, pt := range parseTests {
+		if _, err = ac.ParseActionToken(pt.purpose, pt.token); !errors.Is(err, ErrInvalidActionToken) {
+			t.Errorf("Expected test %d to fail with %v, but recieved %v", i, ErrInvalidActionToken, err)
+		}
+	}
+}
+
+func TestPasswordFingerprint(t *testing.T) {
+
+	hash := "$2a$10$XgxVoZidTuxugFcAkBipBeSYxFJSLv/w0t1Lt7ihTOu0cThCBHgA."
+
+	if PasswordFingerprint(hash) != PasswordFingerprint(hash) {
+		t.Error("Expected the fingerprint of a hash to be stable")
+	} else if PasswordFingerprint(hash) == PasswordFingerprint(hash+"x") {
+		t.Error("Expected the fingerprints of different hashes to differ")
+	} else if len(PasswordFingerprint(hash)) != 16 {
+		t.Errorf("Expected a fingerprint of 16 hex characters, but recieved %q", PasswordFingerprint(hash))
+	}
+}
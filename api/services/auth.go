@@ -1,40 +1,63 @@
 package services
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
-	"log"
+	"fmt"
+	"strings"
 	"time"
-	"regexp"
 
 	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/mangoslicer/answer-patch/apierrors"
 	"github.com/mangoslicer/answer-patch/datastores"
+	"github.com/mangoslicer/answer-patch/models"
 	"github.com/mangoslicer/answer-patch/settings"
-	"golang.org/x/crypto/bcrypt"
 )
 
 /**
  * Constants for JSON Web Token configuration
 */
 const (
-	JWTLife     = 72 // Represents the amount of hours until a JSON Web Token will expire
 	StoreOffset = 60 // Represents the amount of seconds beyond the token's expiration time that the token will be stored as invalid
 )
 
 /**
- * Constants for password validity verification
+ * Errors returned by Login for credentials that are rejected
 */
-const (
-	PasswordLength = 6
+var (
+	ErrInvalidPassword      = apierrors.New(apierrors.KindUnauthorized, apierrors.CodeInvalidPassword, "Invalid password recieved", apierrors.FieldError{Field: "password", Reason: "invalid"})
+	ErrIncorrectCredentials = apierrors.New(apierrors.KindUnauthorized, apierrors.CodeInvalidCredentials, "Credentials are incorrect")
 )
 
+/**
+ * Errors returned by RefreshToken for refresh tokens that are rejected
+*/
+var (
+	ErrInvalidRefreshToken = apierrors.New(apierrors.KindUnauthorized, apierrors.CodeInvalidToken, "Refresh token is invalid or has expired", apierrors.FieldError{Field: "refreshToken", Reason: "invalid"})
+	ErrRevokedRefreshToken = apierrors.New(apierrors.KindUnauthorized, apierrors.CodeRevokedToken, "Refresh token has been revoked")
+	ErrReusedRefreshToken  = apierrors.New(apierrors.KindUnauthorized, apierrors.CodeRevokedToken, "Refresh token has already been used, so every token issued along with it has been revoked")
+)
+
+// ErrRevokedToken is returned by CheckToken for JSON Web Tokens that were revoked before their expiration
+var ErrRevokedToken = apierrors.New(apierrors.KindUnauthorized, apierrors.CodeRevokedToken, "Token is no longer valid")
+
+/**
+ * Token is the pair of tokens issued upon login and upon every refresh
+ * SignedToken is a short-lived JSON Web Token that authenticates requests, while RefreshToken is an opaque, single-use token that is exchanged for the next pair
+*/
 type Token struct {
-	SignedToken string `json:"token"`
+	SignedToken  string `json:"token"`
+	ExpiresIn    int    `json:"expiresIn"` // Amount of seconds until SignedToken expires
+	RefreshToken string `json:"refreshToken"`
 }
 
 type AuthServices interface {
 	Login(string, string) (*Token, error)
 	Logout(string) error
-	RefreshToken() (*Token, error)
+	RefreshToken(string) (*Token, error)
 }
 
 /**
@@ -43,8 +66,13 @@ type AuthServices interface {
 */
 type AuthContext struct {
 	UserID     string
+	TokenID    string // The jti claim of the current token
+	FamilyID   string // Identifies the session that the current token descends from, through any amount of refreshes
 	Exp        time.Time
+	UserAgent  string       // Recorded in the session that Login starts
+	Roles      models.Roles // Parsed from the claims of the current token
 	TokenStore datastores.TokenStoreServices
+	RoleStore  datastores.RoleStoreServices // Looked up whenever tokens are issued. Without one, every user has the user role
 }
 
 /**
@@ -56,20 +84,46 @@ func NewAuthContext(ts datastores.TokenStoreServices) *AuthContext {
 
 /**
  * Provides a JSON Web Token for authenticated users
+ * Passwords that the password policy rejects are turned away before they are verified, which also keeps overly long passwords from being hashed
+ * The username is not known here, so the similarity check is left to the handlers that set passwords
 */
 func (ac *AuthContext) Login(enteredPassword, hashedPassword string) (*Token, error) {
-	if !isValidPassword(enteredPassword) {
-		return nil, errors.New("Invalid password recieved")
-	} else if !authenticate(hashedPassword, enteredPassword) {
-		return nil, errors.New("Credentials are incorrect")
+	if reasons := NewPasswordPolicy().Check("password", "", enteredPassword); len(reasons) != 0 {
+		return nil, apierrors.New(apierrors.KindUnauthorized, apierrors.CodeInvalidPassword, ErrInvalidPassword.Detail, reasons...)
+	} else if !VerifyPassword(hashedPassword, enteredPassword) {
+		return nil, ErrIncorrectCredentials
 	}
 
-	return setTokenClaims(ac.UserID)
+	return ac.IssueSession()
 }
 
 /**
- * The token parameter is stored in the TokenStore for an amount of hours determined by the sum of the remaining token life and the Offset
- * Tokens of logged-out users are stored until at least expiration such that the server can detect and block any HTTP requests with these tokens
+ * Starts a session for ac.UserID and provides its first tokens. The identity of the user must already have been verified, by Login or by an identity provider
+*/
+func (ac *AuthContext) IssueSession() (*Token, error) {
+
+	familyID, err := newRandomID()
+	if err != nil {
+		return nil, err
+	}
+
+	// The tokens are issued first, so that banned users are turned away before a session is started for them
+	token, err := ac.issueTokens(ac.UserID, familyID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = ac.startSession(familyID); err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+/**
+ * The token parameter is stored in the TokenStore under its jti for an amount of seconds determined by the sum of the remaining token life and the Offset
+ * Revoked tokens are stored until at least expiration such that the server can detect and block any HTTP requests with these tokens
+ * Only the current token is revoked, so the user stays logged in on every other device
 */
 func (ac *AuthContext) Logout(signedToken string) error {
 
@@ -77,52 +131,224 @@ func (ac *AuthContext) Logout(signedToken string) error {
 
 	// Checking if storeTime is greater than 0 prevents the storage of expired JSON Web Tokens
 	if storeTime > 0 {
-		err := ac.TokenStore.StoreToken(ac.UserID, signedToken, storeTime)
+		err := ac.TokenStore.StoreToken(revokedTokenKey(ac.TokenID), signedToken, storeTime)
 		if err != nil {
-			return errors.New("Internal Error")
+			return err
 		}
 	}
 
+	// Revoking the session prevents its refresh token from outliving the logout
+	if ac.FamilyID != "" {
+		return ac.RevokeSession(ac.FamilyID)
+	}
+
 	return nil
 }
 
 /**
- * Rather than parsing, delaying the expiration time, and signing the current token, RefreshToken simply returns a new token
+ * Checks whether a JSON Web Token was revoked, either on its own by a logout, along with its session, or by the user logging out everywhere
+*/
+func (ac *AuthContext) CheckToken(userID, tokenID, familyID string, issuedAt time.Time) error {
+
+	isRevoked, err := ac.TokenStore.IsTokenStored(revokedTokenKey(tokenID))
+	if err != nil {
+		return err
+	} else if isRevoked {
+		return ErrRevokedToken
+	}
+
+	if familyID != "" {
+		isRevoked, err = ac.IsFamilyRevoked(familyID)
+		if err != nil {
+			return err
+		} else if isRevoked {
+			return ErrRevokedToken
+		}
+	}
+
+	validAfter, err := ac.tokensValidAfter(userID)
+	if err != nil {
+		return err
+	} else if issuedAt.Before(validAfter) {
+		return ErrRevokedToken
+	}
+
+	return nil
+}
+
+/**
+ * Exchanges a refresh token for a new pair of tokens of the same family. Every refresh token can only be exchanged once
+ * A refresh token that is exchanged twice has been leaked, so the whole family is revoked, which logs out both the legitimate user and the attacker
+*/
+func (ac *AuthContext) RefreshToken(refreshToken string) (*Token, error) {
+
+	key := refreshTokenKey(refreshToken)
+
+	record, err := ac.TokenStore.FindToken(key)
+	if errors.Is(err, datastores.ErrTokenNotFound) {
+		return nil, ErrInvalidRefreshToken
+	} else if err != nil {
+		return nil, err
+	}
+
+	fields := strings.SplitN(record, " ", 2)
+	if len(fields) != 2 {
+		return nil, apierrors.Internal(fmt.Errorf("malformed refresh token record %q", record))
+	}
+	familyID, userID := fields[0], fields[1]
+
+	isRevoked, err := ac.IsFamilyRevoked(familyID)
+	if err != nil {
+		return nil, err
+	} else if isRevoked {
+		return nil, ErrRevokedRefreshToken
+	}
+
+	session, err := ac.findSession(familyID)
+	if errors.Is(err, ErrSessionNotFound) {
+		return nil, ErrRevokedRefreshToken
+	} else if err != nil {
+		return nil, err
+	}
+
+	validAfter, err := ac.tokensValidAfter(userID)
+	if err != nil {
+		return nil, err
+	} else if session.CreatedAt.Before(validAfter) {
+		return nil, ErrRevokedRefreshToken
+	}
+
+	// Marking the token as used is atomic, so a token that is replayed concurrently is only exchanged once
+	isFirstUse, err := ac.TokenStore.StoreTokenOnce(key+":used", "used", refreshTokenLife())
+	if err != nil {
+		return nil, err
+	} else if !isFirstUse {
+		if err = ac.revokeFamily(familyID); err != nil {
+			return nil, err
+		}
+		return nil, ErrReusedRefreshToken
+	}
+
+	ac.UserID = userID
+	ac.FamilyID = familyID
+
+	session.LastUsedAt = time.Now().UTC()
+	if err = ac.storeSession(session); err != nil {
+		return nil, err
+	}
+
+	return ac.issueTokens(userID, familyID)
+}
+
+/**
+ * Checks whether the tokens of a family have been revoked by a logout or by the reuse of a refresh token
 */
-func (ac *AuthContext) RefreshToken() (*Token, error) {
-	return setTokenClaims(ac.UserID)
+func (ac *AuthContext) IsFamilyRevoked(familyID string) (bool, error) {
+	return ac.TokenStore.IsTokenStored(familyKey(familyID))
 }
 
 /**
- * Authentication of user is performed by comparing the hash of the entered password with the stored hash of the user's password
+ * Families are revoked for as long as a refresh token lives, since every token of the family expires by then
 */
-func authenticate(hashedPassword, enteredPassword string) bool {
-	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(enteredPassword)) == nil
+func (ac *AuthContext) revokeFamily(familyID string) error {
+	return ac.TokenStore.StoreToken(familyKey(familyID), "revoked", refreshTokenLife())
 }
 
 /**
- * Initializes JSON Web Token with initialization time, expiration time, and the userID
+ * Signs a JSON Web Token with the current roles of the user and stores a new refresh token, which records the family and user that it was issued to
+ * Only the hash of the refresh token is used as a key, so that the contents of the TokenStore can not be used to refresh tokens
 */
-func setTokenClaims(userID string) (*Token, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodRS512, jwt.MapClaims {
-		"iat": time.Now().Unix(),
-		"exp": time.Now().Add(time.Hour * time.Duration(JWTLife)).Unix(),
-		"sub": userID,
-  })
+func (ac *AuthContext) issueTokens(userID, familyID string) (*Token, error) {
+
+	roles, err := ac.findRoles(userID)
+	if err != nil {
+		return nil, err
+	}
 
-	signedToken, err := token.SignedString(settings.GetPrivateKey())
+	token, err := setTokenClaims(userID, familyID, roles)
 	if err != nil {
-		log.Fatal(err)
-		return nil, errors.New("Internal Error")
+		return nil, err
 	}
-	return &Token{SignedToken: signedToken}, nil
+
+	b := make([]byte, 32)
+	if _, err = rand.Read(b); err != nil {
+		return nil, apierrors.Internal(err)
+	}
+	token.RefreshToken = base64.RawURLEncoding.EncodeToString(b)
+
+	err = ac.TokenStore.StoreToken(refreshTokenKey(token.RefreshToken), familyID+" "+userID, refreshTokenLife())
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
 }
 
 /**
- * Checks whether password strings pass the character requirements of valid passwords
+ * Generates the ids of sessions and the jti claims of JSON Web Tokens
 */
-func isValidPassword(password string) bool {
-	capitalLetterRegex, _ := regexp.Compile("[A-Z]+")  // Checks whether the password contains at least one capital letter
-	specialCharacterRegex, _ := regexp.Compile("\\W+") // Checks whether the password contains at least one special character
-	return len(password) >= PasswordLength && capitalLetterRegex.MatchString(password) && specialCharacterRegex.MatchString(password)
+func newRandomID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", apierrors.Internal(err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func refreshTokenKey(refreshToken string) string {
+	hash := sha256.Sum256([]byte(refreshToken))
+	return "refresh:" + hex.EncodeToString(hash[:])
+}
+
+func familyKey(familyID string) string {
+	return "family:" + familyID
+}
+
+func revokedTokenKey(tokenID string) string {
+	return "revoked:" + tokenID
+}
+
+/**
+ * Amount of seconds until an unused refresh token expires
+*/
+func refreshTokenLife() int {
+	return settings.Get().Rules.RefreshTokenLifeHours * 3600
+}
+
+/**
+ * Initializes JSON Web Token with initialization time, expiration time, the userID, the family of the token, and the roles of the user
+ * The claims of the roles are left out for users without any granted role, which keeps their tokens as they were before roles existed
+*/
+func setTokenClaims(userID, familyID string, roles *models.Roles) (*Token, error) {
+	tokenID, err := newRandomID()
+	if err != nil {
+		return nil, err
+	}
+
+	life := time.Minute * time.Duration(settings.Get().Rules.AccessTokenLifeMinutes)
+	token := jwt.NewWithClaims(jwt.SigningMethodRS512, jwt.MapClaims {
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(life).Unix(),
+		"sub": userID,
+		"jti": tokenID,
+		"fam": familyID,
+  })
+
+	claims := token.Claims.(jwt.MapClaims)
+	if roles.Role != "" && roles.Role != models.RoleUser {
+		claims["role"] = string(roles.Role)
+	}
+	if len(roles.CategoryRoles) != 0 {
+		claims["croles"] = roles.CategoryRoles // The roles of the user within categories, keyed by category name
+	}
+
+	// The kid lets the token be verified by the key that signed it after the active key is rotated
+	key := settings.GetKeyring().Active
+	token.Header["kid"] = key.ID
+
+	signedToken, err := token.SignedString(key.Private)
+	if err != nil {
+		return nil, apierrors.Internal(err)
+	}
+	return &Token{SignedToken: signedToken, ExpiresIn: int(life.Seconds())}, nil
 }
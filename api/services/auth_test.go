@@ -1,10 +1,13 @@
 package services
 
 import (
+	"errors"
 	"testing"
 	"time"
 
 	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/mangoslicer/answer-patch/apierrors"
+	"github.com/mangoslicer/answer-patch/datastores"
 	"github.com/mangoslicer/answer-patch/settings"
 )
 
@@ -21,7 +24,7 @@ type MockTokenStore struct {
 */
 var (
 	userID = "0" // Arbitrary userID for testing purposes
-	ac = &AuthContext{UserID: userID}
+	ac = &AuthContext{UserID: userID, TokenStore: &MockTokenStore{}}
 )
 
 func init() {
@@ -45,6 +48,38 @@ func (store *MockTokenStore) IsTokenStored(key string) (bool, error) {
 	return false, nil
 }
 
+func (store *MockTokenStore) StoreTokenOnce(key, val string, exp int) (bool, error) {
+	return true, nil
+}
+
+func (store *MockTokenStore) FindToken(key string) (string, error) {
+	return "", datastores.ErrTokenNotFound
+}
+
+func (store *MockTokenStore) AddToTokenSet(key, member string, exp int) error {
+	return nil
+}
+
+func (store *MockTokenStore) FindTokenSet(key string) ([]string, error) {
+	return nil, nil
+}
+
+func (store *MockTokenStore) RemoveFromTokenSet(key, member string) error {
+	return nil
+}
+
+func (store *MockTokenStore) RemoveToken(key string) error {
+	return nil
+}
+
+func (store *MockTokenStore) IncrementToken(key string, exp int) (int, error) {
+	return 1, nil
+}
+
+func (store *MockTokenStore) TakeFromBucket(key string, burst int, perSecond float64) (bool, float64, error) {
+	return true, float64(burst - 1), nil
+}
+
 /**
  * Tests that Login rejects passwords which do not meet the password requirements defined in auth.go
 */
@@ -146,3 +181,76 @@ func TestLogoutStoreTimeCalculation(t *testing.T) {
 		t.Errorf("Expected the calculated store time of the logged-out user's token to be less five seconds, but the store time was %d", mockTokenStore.StoreTime)
 	}
 }
+
+/**
+ * Tests that every refresh token is exchanged for a new pair of tokens of the same user, and that the exchanged token is rejected afterwards
+*/
+func TestRefreshTokenRotation(t *testing.T) {
+
+	refreshContext := &AuthContext{UserID: userID, TokenStore: datastores.NewMemoryTokenStore()}
+
+	token, err := refreshContext.Login("Passw!rd", "$2a$10$XgxVoZidTuxugFcAkBipBeSYxFJSLv/w0t1Lt7ihTOu0cThCBHgA.")
+	if err != nil {
+		t.Fatal(err)
+	} else if token.RefreshToken == "" || token.ExpiresIn != settings.Get().Rules.AccessTokenLifeMinutes*60 {
+		t.Fatalf("Expected Login to issue a refresh token along with a JSON Web Token, but recieved %+v", token)
+	}
+
+	// The refreshing context knows nothing about the user, just like the context of a request without a JSON Web Token
+	refreshed, err := (&AuthContext{TokenStore: refreshContext.TokenStore}).RefreshToken(token.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	} else if refreshed.RefreshToken == token.RefreshToken {
+		t.Errorf("Expected RefreshToken to rotate the refresh token")
+	}
+
+	parsedToken, err := jwt.Parse(refreshed.SignedToken, func(token *jwt.Token) (interface{}, error) {
+		return settings.GetPublicKey(), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims, ok := parsedToken.Claims.(jwt.MapClaims); !ok || claims["sub"] != userID {
+		t.Errorf("Expected the refreshed token to have a \"sub\" claim of %s, but recieved %v", userID, parsedToken.Claims)
+	}
+
+	_, err = refreshContext.RefreshToken("unknown")
+	if apierrors.CodeOf(err) != apierrors.CodeInvalidToken {
+		t.Errorf("Expected an unknown refresh token to be rejected as invalid, but recieved %v", err)
+	}
+}
+
+/**
+ * Tests that replaying a refresh token revokes every token of its family, including the tokens issued by the legitimate refresh
+*/
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+
+	refreshContext := &AuthContext{UserID: userID, TokenStore: datastores.NewMemoryTokenStore()}
+
+	token, err := refreshContext.Login("Passw!rd", "$2a$10$XgxVoZidTuxugFcAkBipBeSYxFJSLv/w0t1Lt7ihTOu0cThCBHgA.")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	refreshed, err := refreshContext.RefreshToken(token.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = refreshContext.RefreshToken(token.RefreshToken)
+	if !errors.Is(err, ErrReusedRefreshToken) {
+		t.Errorf("Expected the replayed refresh token to be detected, but recieved %v", err)
+	}
+
+	_, err = refreshContext.RefreshToken(refreshed.RefreshToken)
+	if !errors.Is(err, ErrRevokedRefreshToken) {
+		t.Errorf("Expected the refresh token issued before the replay to be revoked, but recieved %v", err)
+	}
+
+	isRevoked, err := refreshContext.IsFamilyRevoked(refreshContext.FamilyID)
+	if err != nil {
+		t.Error(err)
+	} else if !isRevoked {
+		t.Errorf("Expected the family of the replayed refresh token to be revoked")
+	}
+}
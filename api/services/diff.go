@@ -0,0 +1,135 @@
+package services
+
+import (
+	"strings"
+)
+
+const (
+	DiffEqual  = "equal"
+	DiffInsert = "insert"
+	DiffDelete = "delete"
+)
+
+type DiffLine struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+type Diff struct {
+	From  int        `json:"from"`
+	To    int        `json:"to"`
+	Lines []DiffLine `json:"lines"`
+}
+
+// DiffLines computes a line-level diff that turns the from string into the to string
+// The diff is a shortest edit script found by the linear space variant of Myers' algorithm, so that memory grows with the length of the answers, not their product
+func DiffLines(from, to string) []DiffLine {
+
+	a := strings.Split(from, "\n")
+	b := strings.Split(to, "\n")
+
+	return diffLines(nil, a, b)
+}
+
+// diffLines appends the diff of a and b to lines, splitting both at the middle snake of their edit script until only insertions or deletions remain
+func diffLines(lines []DiffLine, a, b []string) []DiffLine {
+
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		lines = append(lines, DiffLine{DiffEqual, a[prefix]})
+		prefix++
+	}
+	a, b = a[prefix:], b[prefix:]
+
+	suffix := 0
+	for suffix < len(a) && suffix < len(b) && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	common := a[len(a)-suffix:]
+	a, b = a[:len(a)-suffix], b[:len(b)-suffix]
+
+	switch {
+	case len(a) == 0:
+		for _, line := range b {
+			lines = append(lines, DiffLine{DiffInsert, line})
+		}
+	case len(b) == 0:
+		for _, line := range a {
+			lines = append(lines, DiffLine{DiffDelete, line})
+		}
+	default:
+		x, y, u, v := middleSnake(a, b)
+		lines = diffLines(lines, a[:x], b[:y])
+		for _, line := range a[x:u] {
+			lines = append(lines, DiffLine{DiffEqual, line})
+		}
+		lines = diffLines(lines, a[u:], b[v:])
+	}
+
+	for _, line := range common {
+		lines = append(lines, DiffLine{DiffEqual, line})
+	}
+
+	return lines
+}
+
+// middleSnake finds the diagonal run of equal lines, from (x, y) to (u, v), that lies in the middle of a shortest edit script of a and b
+// The forward and backward searches only keep the furthest reaching x of each diagonal, which takes O(len(a)+len(b)) space
+func middleSnake(a, b []string) (x, y, u, v int) {
+
+	n, m := len(a), len(b)
+	max := (n + m + 1) / 2
+	delta := n - m
+	odd := delta%2 != 0
+
+	// Diagonal k is stored at index k+offset, where k ranges from -max-1 to max+1
+	offset := max + 1
+	forward := make([]int, 2*max+3)
+	backward := make([]int, 2*max+3)
+
+	for d := 0; d <= max; d++ {
+
+		for k := -d; k <= d; k += 2 {
+			if k == -d || (k != d && forward[offset+k-1] < forward[offset+k+1]) {
+				x = forward[offset+k+1]
+			} else {
+				x = forward[offset+k-1] + 1
+			}
+			y = x - k
+			u, v = x, y
+			for u < n && v < m && a[u] == b[v] {
+				u++
+				v++
+			}
+			forward[offset+k] = u
+
+			// The backward search runs on the reversed lines, where forward diagonal k is diagonal delta-k
+			if odd && delta-k >= -(d-1) && delta-k <= d-1 && u+backward[offset+delta-k] >= n {
+				return x, y, u, v
+			}
+		}
+
+		for k := -d; k <= d; k += 2 {
+			var rx int
+			if k == -d || (k != d && backward[offset+k-1] < backward[offset+k+1]) {
+				rx = backward[offset+k+1]
+			} else {
+				rx = backward[offset+k-1] + 1
+			}
+			ry := rx - k
+			ru, rv := rx, ry
+			for ru < n && rv < m && a[n-1-ru] == b[m-1-rv] {
+				ru++
+				rv++
+			}
+			backward[offset+k] = ru
+
+			if !odd && delta-k >= -d && delta-k <= d && ru+forward[offset+delta-k] >= n {
+				return n - ru, m - rv, n - rx, m - ry
+			}
+		}
+	}
+
+	// A shortest edit script never takes more than len(a)+len(b) steps, so the searches always meet
+	panic("services: middle snake not found")
+}
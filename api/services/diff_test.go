@@ -0,0 +1,89 @@
+package services
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffLines(t *testing.T) {
+
+	diffTests := []struct {
+		from     string
+		to       string
+		expected []DiffLine
+	}{
+		{"same", "same", []DiffLine{{DiffEqual, "same"}}},
+		{"a\nb\nc", "a\nc", []DiffLine{{DiffEqual, "a"}, {DiffDelete, "b"}, {DiffEqual, "c"}}},
+		{"a\nc", "a\nb\nc", []DiffLine{{DiffEqual, "a"}, {DiffInsert, "b"}, {DiffEqual, "c"}}},
+		{"Not Utah", "Not Massachusetts", []DiffLine{{DiffDelete, "Not Utah"}, {DiffInsert, "Not Massachusetts"}}},
+		{"a\nb\nc\nd", "b\nx\nd\ny", []DiffLine{{DiffDelete, "a"}, {DiffEqual, "b"}, {DiffDelete, "c"}, {DiffInsert, "x"}, {DiffEqual, "d"}, {DiffInsert, "y"}}},
+	}
+
+	for _, dt := range diffTests {
+		retrieved := DiffLines(dt.from, dt.to)
+		if !reflect.DeepEqual(retrieved, dt.expected) {
+			t.Errorf("Expected the diff of %q and %q to be %+v, but recieved %+v", dt.from, dt.to, dt.expected, retrieved)
+		}
+	}
+}
+
+func TestDiffLinesIsShortest(t *testing.T) {
+
+	random := rand.New(rand.NewSource(1))
+	randomLines := func() []string {
+		lines := make([]string, random.Intn(12))
+		for i := range lines {
+			lines[i] = string(rune('a' + random.Intn(3)))
+		}
+		return lines
+	}
+
+	for i := 0; i < 500; i++ {
+		a, b := randomLines(), randomLines()
+		from, to := strings.Join(a, "\n"), strings.Join(b, "\n")
+
+		var fromLines, toLines []string
+		edits := 0
+		for _, line := range DiffLines(from, to) {
+			if line.Op != DiffInsert {
+				fromLines = append(fromLines, line.Text)
+			}
+			if line.Op != DiffDelete {
+				toLines = append(toLines, line.Text)
+			}
+			if line.Op != DiffEqual {
+				edits++
+			}
+		}
+
+		if strings.Join(fromLines, "\n") != from || strings.Join(toLines, "\n") != to {
+			t.Fatalf("Expected the diff of %q and %q to rebuild both strings, but recieved %q and %q", from, to, strings.Join(fromLines, "\n"), strings.Join(toLines, "\n"))
+		} else if expected := shortestEditCount(strings.Split(from, "\n"), strings.Split(to, "\n")); edits != expected {
+			t.Fatalf("Expected the diff of %q and %q to take %d edits, but recieved %d", from, to, expected, edits)
+		}
+	}
+}
+
+// shortestEditCount counts the insertions and deletions of a shortest edit script by way of the longest common subsequence
+func shortestEditCount(a, b []string) int {
+
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	return len(a) + len(b) - 2*lcs[0][0]
+}
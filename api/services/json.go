@@ -2,7 +2,10 @@ package services
 
 import (
 	"encoding/json"
+	"log"
 	"net/http"
+
+	"github.com/mangoslicer/answer-patch/apierrors"
 )
 
 //Marshals and writes JSON to the http.ResponseWriter
@@ -25,3 +28,23 @@ w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE"
 	}
 
 }
+
+//Writes err as an RFC 7807 problem details document, tagged with the request ID assigned by the RequestID middleware
+//The status code is derived from the kind of err. Server errors are logged along with their causes, which clients never see
+func PrintError(w http.ResponseWriter, err error) {
+
+	problem := apierrors.NewProblem(err)
+	problem.RequestID = w.Header().Get("X-Request-ID")
+
+	if problem.Status >= http.StatusInternalServerError {
+		log.Printf("request %s: %v", problem.RequestID, err)
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(problem.Status)
+
+	problemJSON, _ := json.MarshalIndent(problem, "", " ")
+	w.Write(problemJSON)
+}
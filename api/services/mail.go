@@ -0,0 +1,156 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"mime"
+	"net/mail"
+	"net/smtp"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mangoslicer/answer-patch/apierrors"
+	"github.com/mangoslicer/answer-patch/settings"
+)
+
+/**
+ * Mail is a plain text email
+ */
+type Mail struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+/**
+ * A MailSender delivers emails, or at least hands them to something that does
+ */
+type MailSender interface {
+	Send(*Mail) error
+}
+
+/**
+ * Creates the sender of the configured kind
+ */
+func NewMailSender(cfg settings.Mail) MailSender {
+	if cfg.Sender == settings.SMTPSender {
+		return &SMTPSender{Addr: cfg.SMTPHost + ":" + strconv.Itoa(cfg.SMTPPort), Host: cfg.SMTPHost, Username: cfg.SMTPUsername, Password: cfg.SMTPPassword, From: cfg.From}
+	}
+	return &LogSender{Path: cfg.LogPath, From: cfg.From}
+}
+
+/**
+ * SMTPSender delivers emails through an SMTP server, authenticating with PLAIN auth when a username is set
+ * net/smtp upgrades the connection with STARTTLS whenever the server offers it, and refuses PLAIN auth over an unencrypted connection to anything but localhost
+ */
+type SMTPSender struct {
+	Addr     string
+	Host     string
+	Username string
+	Password string
+	From     string
+}
+
+func (sender *SMTPSender) Send(m *Mail) error {
+
+	from, err := mail.ParseAddress(sender.From)
+	if err != nil {
+		return apierrors.Internal(err)
+	}
+
+	var auth smtp.Auth
+	if sender.Username != "" {
+		auth = smtp.PlainAuth("", sender.Username, sender.Password, sender.Host)
+	}
+
+	if err = smtp.SendMail(sender.Addr, auth, from.Address, []string{m.To}, formatMail(sender.From, m)); err != nil {
+		return apierrors.Unavailable(err)
+	}
+
+	return nil
+}
+
+/**
+ * LogSender appends every email to a file, or writes it to the log when no file is set, which lets the links in emails be followed during local development
+ */
+type LogSender struct {
+	Path string
+	From string
+	mu   sync.Mutex // Keeps concurrent emails from interleaving in the file
+}
+
+func (sender *LogSender) Send(m *Mail) error {
+
+	message := formatMail(sender.From, m)
+	if sender.Path == "" {
+		log.Printf("Mail to %s:\n%s", m.To, message)
+		return nil
+	}
+
+	sender.mu.Lock()
+	defer sender.mu.Unlock()
+
+	file, err := os.OpenFile(sender.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return apierrors.Internal(err)
+	}
+	defer file.Close()
+
+	if _, err = file.Write(append(message, '\n')); err != nil {
+		return apierrors.Internal(err)
+	}
+
+	return nil
+}
+
+/**
+ * Formats an email as an RFC 5322 message. Header values never contain line breaks, since they could otherwise inject headers
+ */
+func formatMail(from string, m *Mail) []byte {
+
+	stripLineBreaks := strings.NewReplacer("\r", "", "\n", "").Replace
+
+	var message strings.Builder
+	fmt.Fprintf(&message, "From: %s\r\n", stripLineBreaks(from))
+	fmt.Fprintf(&message, "To: %s\r\n", stripLineBreaks(m.To))
+	fmt.Fprintf(&message, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", stripLineBreaks(m.Subject)))
+	fmt.Fprintf(&message, "Date: %s\r\n", time.Now().UTC().Format(time.RFC1123Z))
+	message.WriteString("MIME-Version: 1.0\r\n")
+	message.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	message.WriteString("\r\n")
+	message.WriteString(strings.Replace(m.Body, "\n", "\r\n", -1))
+
+	return []byte(message.String())
+}
+
+/**
+ * Mails a link that verifies the email of a newly registered user
+ */
+func NewVerificationMail(to, username, token string) *Mail {
+	return &Mail{
+		To:      to,
+		Subject: "Verify your email",
+		Body: fmt.Sprintf("Hi %s,\n\nOpen the link below to verify your email. The link expires in %d hours.\n\n%s\n\nIf you did not register, you can ignore this email.\n",
+			username, settings.Get().Rules.EmailVerificationLifeHours, appLink("/verify-email", token)),
+	}
+}
+
+/**
+ * Mails a link that resets the password of a user
+ */
+func NewPasswordResetMail(to, username, token string) *Mail {
+	return &Mail{
+		To:      to,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nOpen the link below to choose a new password. The link expires in %d minutes and can only be used once.\n\n%s\n\nIf you did not ask to reset your password, you can ignore this email.\n",
+			username, settings.Get().Rules.PasswordResetLifeMinutes, appLink("/reset-password", token)),
+	}
+}
+
+func appLink(path, token string) string {
+	return strings.TrimRight(settings.Get().Mail.AppURL, "/") + path + "?token=" + url.QueryEscape(token)
+}
@@ -0,0 +1,51 @@
+package services
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+/**
+ * Tests that header values can not inject headers, and that the body uses CRLF line endings
+ */
+func TestFormatMail(t *testing.T) {
+
+	message := string(formatMail("Answer Patch <no-reply@localhost>", &Mail{To: "tester@example.com\r\nBcc: victim@example.com", Subject: "Héllo", Body: "Line one\nLine two\n"}))
+
+	if strings.Contains(message, "\r\nBcc:") {
+		t.Errorf("Expected line breaks to be stripped from the headers, but recieved %q", message)
+	} else if !strings.Contains(message, "Subject: =?utf-8?q?H=C3=A9llo?=\r\n") {
+		t.Errorf("Expected the subject to be Q-encoded, but recieved %q", message)
+	} else if !strings.HasSuffix(message, "\r\n\r\nLine one\r\nLine two\r\n") {
+		t.Errorf("Expected the body to follow the headers with CRLF line endings, but recieved %q", message)
+	}
+}
+
+func TestLogSenderAppendsToFile(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "mail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sender := &LogSender{Path: filepath.Join(dir, "mail.log"), From: "no-reply@localhost"}
+	for _, to := range []string{"first@example.com", "second@example.com"} {
+		if err = sender.Send(NewPasswordResetMail(to, "Tester", "token")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	contents, err := ioutil.ReadFile(sender.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(contents), "To: first@example.com") || !strings.Contains(string(contents), "To: second@example.com") {
+		t.Errorf("Expected both mails to be appended to the file, but recieved %s", contents)
+	} else if !strings.Contains(string(contents), "/reset-password?token=token") {
+		t.Errorf("Expected the mail to contain the reset link, but recieved %s", contents)
+	}
+}
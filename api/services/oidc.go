@@ -0,0 +1,489 @@
+package services
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/mangoslicer/answer-patch/apierrors"
+	"github.com/mangoslicer/answer-patch/datastores"
+	"github.com/mangoslicer/answer-patch/settings"
+)
+
+/**
+ * Users log in with an OpenID Connect provider through the authorization code flow with PKCE:
+ * BeginOIDCLogin records a state and returns the URL of the provider's login page, the provider redirects the user back to the app with a code,
+ * and CompleteOIDCLogin redeems the code for an ID token, whose subject is linked to a user. The tokens of the api are then issued as usual
+ */
+
+var (
+	ErrUnknownOIDCProvider = apierrors.New(apierrors.KindNotFound, apierrors.CodeProviderNotFound, "No identity provider exists with the provided name")
+	ErrInvalidOIDCState    = apierrors.New(apierrors.KindInvalid, apierrors.CodeInvalidToken, "The state is invalid or has expired", apierrors.FieldError{Field: "state", Reason: "invalid"})
+	ErrOIDCRejected        = apierrors.New(apierrors.KindUnauthorized, apierrors.CodeProviderRejected, "The identity provider did not confirm the login")
+	ErrOIDCLinkUser        = apierrors.New(apierrors.KindUnauthorized, apierrors.CodeAuthRequired, "Identities can only be linked while logged in as the user that began linking them")
+)
+
+// Amount of time that a user has to log in with the provider
+const oidcStateLife = 10 * time.Minute
+
+/**
+ * OIDCProvider is a client of an OpenID Connect provider. Its discovery document and keys are fetched when they are first needed
+ */
+type OIDCProvider struct {
+	Name   string
+	Config settings.OIDCProvider
+	Client *http.Client
+
+	mu        sync.Mutex
+	discovery *oidcDiscovery
+	keys      map[string]*rsa.PublicKey // Keyed by kid
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+/**
+ * OIDCClaims are the claims of a verified ID token that the api uses
+ */
+type OIDCClaims struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	PreferredUsername string
+}
+
+/**
+ * oidcState is recorded under the state of a login until the provider redirects back
+ */
+type oidcState struct {
+	Provider string `json:"provider"`
+	Verifier string `json:"verifier"`
+	Nonce    string `json:"nonce"`
+	UserID   string `json:"userID,omitempty"` // Set when a logged in user links the identity to their account, rather than logging in with it
+}
+
+/**
+ * Creates a client for every configured provider, keyed by the provider's name
+ */
+func NewOIDCProviders(cfg map[string]settings.OIDCProvider) map[string]*OIDCProvider {
+	providers := make(map[string]*OIDCProvider, len(cfg))
+	for name, providerCfg := range cfg {
+		providers[name] = &OIDCProvider{Name: name, Config: providerCfg, Client: &http.Client{Timeout: 10 * time.Second}}
+	}
+	return providers
+}
+
+/**
+ * Lists the names of the providers in alphabetical order
+ */
+func OIDCProviderNames(providers map[string]*OIDCProvider) []string {
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+/**
+ * Records a new login with provider, and returns the URL of the provider's login page. A login begun by a logged in user links the identity to ac.UserID instead
+ */
+func (ac *AuthContext) BeginOIDCLogin(provider *OIDCProvider) (string, error) {
+
+	discovery, err := provider.discover()
+	if err != nil {
+		return "", err
+	}
+
+	state, err := newRandomID()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := newRandomID()
+	if err != nil {
+		return "", err
+	}
+	verifier, challenge, err := newPKCE()
+	if err != nil {
+		return "", err
+	}
+
+	record, err := json.Marshal(&oidcState{Provider: provider.Name, Verifier: verifier, Nonce: nonce, UserID: ac.UserID})
+	if err != nil {
+		return "", apierrors.Internal(err)
+	}
+	if err = ac.TokenStore.StoreToken(oidcStateKey(state), string(record), int(oidcStateLife.Seconds())); err != nil {
+		return "", err
+	}
+
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {provider.Config.ClientID},
+		"redirect_uri":          {provider.Config.RedirectURL},
+		"scope":                 {strings.Join(append([]string{"openid"}, provider.Config.Scopes...), " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {challenge},
+		"code_challenge_method": {"S256"},
+	}
+
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+
+	return discovery.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+/**
+ * Redeems the code that provider redirected back with, and returns the claims of the verified ID token. The state can only be used once
+ * The returned userID is set if the login was begun to link the identity to that user
+ */
+func (ac *AuthContext) CompleteOIDCLogin(provider *OIDCProvider, code, state string) (*OIDCClaims, string, error) {
+
+	record, err := ac.TokenStore.FindToken(oidcStateKey(state))
+	if errors.Is(err, datastores.ErrTokenNotFound) {
+		return nil, "", ErrInvalidOIDCState
+	} else if err != nil {
+		return nil, "", err
+	}
+
+	login := new(oidcState)
+	if err = json.Unmarshal([]byte(record), login); err != nil || login.Provider != provider.Name {
+		return nil, "", ErrInvalidOIDCState
+	}
+
+	isFirstUse, err := ac.TokenStore.StoreTokenOnce(usedOIDCStateKey(state), "used", int(oidcStateLife.Seconds())+StoreOffset)
+	if err != nil {
+		return nil, "", err
+	} else if !isFirstUse {
+		return nil, "", ErrInvalidOIDCState
+	}
+
+	rawIDToken, err := provider.exchange(code, login.Verifier)
+	if err != nil {
+		return nil, "", err
+	}
+
+	claims, err := provider.verifyIDToken(rawIDToken, login.Nonce)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return claims, login.UserID, nil
+}
+
+/**
+ * Fetches the discovery document of the provider once, and checks that it describes the configured issuer
+ */
+func (provider *OIDCProvider) discover() (*oidcDiscovery, error) {
+
+	provider.mu.Lock()
+	defer provider.mu.Unlock()
+
+	if provider.discovery != nil {
+		return provider.discovery, nil
+	}
+
+	issuer := strings.TrimRight(provider.Config.Issuer, "/")
+	discovery := new(oidcDiscovery)
+	if err := provider.getJSON(issuer+"/.well-known/openid-configuration", discovery); err != nil {
+		return nil, err
+	}
+
+	if strings.TrimRight(discovery.Issuer, "/") != issuer || discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, apierrors.Unavailable(fmt.Errorf("the discovery document of %s is incomplete or names another issuer", provider.Name))
+	}
+
+	provider.discovery = discovery
+	return discovery, nil
+}
+
+/**
+ * Exchanges the code for the tokens of the user, and returns the ID token. The client authenticates with HTTP basic authentication
+ */
+func (provider *OIDCProvider) exchange(code, verifier string) (string, error) {
+
+	discovery, err := provider.discover()
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {provider.Config.RedirectURL},
+		"code_verifier": {verifier},
+		"client_id":     {provider.Config.ClientID},
+	}
+
+	req, err := http.NewRequest("POST", discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", apierrors.Internal(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if provider.Config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(provider.Config.ClientID), url.QueryEscape(provider.Config.ClientSecret))
+	}
+
+	resp, err := provider.Client.Do(req)
+	if err != nil {
+		return "", apierrors.Unavailable(err)
+	}
+	defer resp.Body.Close()
+
+	var tokens struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err = json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&tokens); err != nil && resp.StatusCode == http.StatusOK {
+		return "", apierrors.Unavailable(err)
+	}
+
+	// Providers answer invalid and expired codes with a 400 invalid_grant error, which the user can only fix by logging in again
+	if resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusUnauthorized {
+		return "", apierrors.Wrap(apierrors.KindUnauthorized, apierrors.CodeProviderRejected, ErrOIDCRejected.Detail, fmt.Errorf("%s: %s", tokens.Error, tokens.ErrorDescription))
+	} else if resp.StatusCode != http.StatusOK {
+		return "", apierrors.Unavailable(fmt.Errorf("the token endpoint of %s responded with %s", provider.Name, resp.Status))
+	} else if tokens.IDToken == "" {
+		return "", apierrors.Unavailable(fmt.Errorf("the token endpoint of %s responded without an ID token", provider.Name))
+	}
+
+	return tokens.IDToken, nil
+}
+
+/**
+ * Verifies the signature, issuer, audience, expiry and nonce of an ID token
+ */
+func (provider *OIDCProvider) verifyIDToken(rawIDToken, nonce string) (*OIDCClaims, error) {
+
+	discovery, err := provider.discover()
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := jwt.Parse(rawIDToken, provider.verificationKey)
+	if err != nil || !token.Valid {
+		// Keys that could not be fetched are the provider's fault rather than the user's
+		var validationErr *jwt.ValidationError
+		if errors.As(err, &validationErr) && apierrors.KindOf(validationErr.Inner) == apierrors.KindUnavailable {
+			return nil, validationErr.Inner
+		}
+		return nil, ErrOIDCRejected
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, ErrOIDCRejected
+	}
+
+	if iss, _ := claims["iss"].(string); iss != discovery.Issuer {
+		return nil, ErrOIDCRejected
+	} else if !hasAudience(claims["aud"], provider.Config.ClientID) {
+		return nil, ErrOIDCRejected
+	} else if tokenNonce, _ := claims["nonce"].(string); tokenNonce != nonce {
+		return nil, ErrOIDCRejected
+	} else if _, ok := claims["exp"]; !ok {
+		return nil, ErrOIDCRejected
+	}
+
+	oidcClaims := new(OIDCClaims)
+	oidcClaims.Subject, _ = claims["sub"].(string)
+	oidcClaims.Email, _ = claims["email"].(string)
+	oidcClaims.PreferredUsername, _ = claims["preferred_username"].(string)
+
+	// Some providers send email_verified as a string
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		oidcClaims.EmailVerified = verified
+	case string:
+		oidcClaims.EmailVerified = verified == "true"
+	}
+
+	if oidcClaims.Subject == "" {
+		return nil, ErrOIDCRejected
+	}
+
+	return oidcClaims, nil
+}
+
+/**
+ * Finds the key of the provider that verifies an ID token. The keys are fetched again once for an unknown kid, since providers rotate their keys
+ */
+func (provider *OIDCProvider) verificationKey(token *jwt.Token) (interface{}, error) {
+
+	if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+		return nil, fmt.Errorf("Unrecognized signing method: %v", token.Header["alg"])
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	for attempt := 0; attempt < 2; attempt++ {
+
+		keys, err := provider.fetchKeys(attempt > 0)
+		if err != nil {
+			return nil, err
+		}
+
+		if key, ok := keys[kid]; ok {
+			return key, nil
+		} else if kid == "" && len(keys) == 1 {
+			for _, key := range keys {
+				return key, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("Unrecognized signing key: %q", kid)
+}
+
+func (provider *OIDCProvider) fetchKeys(refresh bool) (map[string]*rsa.PublicKey, error) {
+
+	discovery, err := provider.discover()
+	if err != nil {
+		return nil, err
+	}
+
+	provider.mu.Lock()
+	defer provider.mu.Unlock()
+
+	if provider.keys != nil && !refresh {
+		return provider.keys, nil
+	}
+
+	jwks := new(settings.JWKSet)
+	if err = provider.getJSON(discovery.JWKSURI, jwks); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, jwk := range jwks.Keys {
+		if jwk.Kty != "RSA" || (jwk.Use != "" && jwk.Use != "sig") {
+			continue
+		}
+		if key, err := rsaPublicKey(jwk); err == nil {
+			keys[jwk.Kid] = key
+		}
+	}
+
+	provider.keys = keys
+	return keys, nil
+}
+
+func (provider *OIDCProvider) getJSON(url string, v interface{}) error {
+
+	resp, err := provider.Client.Get(url)
+	if err != nil {
+		return apierrors.Unavailable(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return apierrors.Unavailable(fmt.Errorf("%s responded with %s", url, resp.Status))
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return apierrors.Unavailable(err)
+	}
+
+	if err = json.Unmarshal(body, v); err != nil {
+		return apierrors.Unavailable(err)
+	}
+
+	return nil
+}
+
+/**
+ * The aud claim is either a single client id or an array of them
+ */
+func hasAudience(aud interface{}, clientID string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == clientID
+	case []interface{}:
+		for _, a := range aud {
+			if a == clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func rsaPublicKey(jwk *settings.JWK) (*rsa.PublicKey, error) {
+
+	n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+	if err != nil {
+		return nil, err
+	}
+
+	exponent := new(big.Int).SetBytes(e)
+	if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 {
+		return nil, errors.New("the exponent of the key is too large")
+	}
+
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+}
+
+/**
+ * Generates a PKCE code verifier along with its S256 code challenge
+ */
+func newPKCE() (string, string, error) {
+
+	verifier, err := newRandomID()
+	if err != nil {
+		return "", "", err
+	}
+	// 32 hex characters are shorter than the 43 characters that PKCE requires, so two ids are concatenated
+	suffix, err := newRandomID()
+	if err != nil {
+		return "", "", err
+	}
+	verifier += suffix
+
+	hash := sha256.Sum256([]byte(verifier))
+
+	return verifier, base64.RawURLEncoding.EncodeToString(hash[:]), nil
+}
+
+/**
+ * Users created from an identity have no password. The stored hash is unique, since hashes are, and no password ever matches it
+ */
+func UnusablePassword() (string, error) {
+	id, err := newRandomID()
+	if err != nil {
+		return "", err
+	}
+	return "!" + id, nil
+}
+
+func oidcStateKey(state string) string {
+	return "oidc:" + state
+}
+
+func usedOIDCStateKey(state string) string {
+	return "oidc_used:" + state
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mangoslicer/answer-patch/datastores"
+	"github.com/mangoslicer/answer-patch/services/oidctest"
+	"github.com/mangoslicer/answer-patch/settings"
+)
+
+func newTestOIDCProvider(t *testing.T) (*oidctest.Provider, *OIDCProvider) {
+
+	fake, err := oidctest.NewProvider()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return fake, NewOIDCProviders(map[string]settings.OIDCProvider{"fake": fake.Config()})["fake"]
+}
+
+/**
+ * Tests that a login completes with the claims of the subject that logged in, and that its state can only be used once
+ */
+func TestOIDCLogin(t *testing.T) {
+
+	fake, provider := newTestOIDCProvider(t)
+	defer fake.Close()
+
+	ac := &AuthContext{TokenStore: datastores.NewMemoryTokenStore()}
+
+	authURL, err := ac.BeginOIDCLogin(provider)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, state, err := fake.Authorize(authURL, "subject-1", map[string]interface{}{"email": "tester@example.com", "email_verified": true, "preferred_username": "tester"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, linkUserID, err := ac.CompleteOIDCLogin(provider, code, state)
+	if err != nil {
+		t.Fatal(err)
+	} else if claims.Subject != "subject-1" || claims.Email != "tester@example.com" || !claims.EmailVerified || claims.PreferredUsername != "tester" {
+		t.Errorf("Expected the claims of the ID token, but recieved %+v", claims)
+	} else if linkUserID != "" {
+		t.Errorf("Expected a login rather than a link, but recieved the userID %s", linkUserID)
+	}
+
+	if _, _, err = ac.CompleteOIDCLogin(provider, code, state); !errors.Is(err, ErrInvalidOIDCState) {
+		t.Errorf("Expected a replayed state to be rejected with %v, but recieved %v", ErrInvalidOIDCState, err)
+	}
+}
+
+/**
+ * Tests that a login begun by a logged in user links the identity to that user
+ */
+func TestOIDCLoginLinksLoggedInUser(t *testing.T) {
+
+	fake, provider := newTestOIDCProvider(t)
+	defer fake.Close()
+
+	ac := &AuthContext{UserID: userID, TokenStore: datastores.NewMemoryTokenStore()}
+
+	authURL, err := ac.BeginOIDCLogin(provider)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, state, err := fake.Authorize(authURL, "subject-1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The callback is not authenticated, so the userID must come from the state
+	callback := &AuthContext{TokenStore: ac.TokenStore}
+	if _, linkUserID, err := callback.CompleteOIDCLogin(provider, code, state); err != nil {
+		t.Fatal(err)
+	} else if linkUserID != userID {
+		t.Errorf("Expected the identity to be linked to %s, but recieved %q", userID, linkUserID)
+	}
+}
+
+/**
+ * Tests that ID tokens are rejected unless they are issued by the provider to the client for the nonce of the login
+ */
+func TestOIDCLoginWithInvalidIDTokens(t *testing.T) {
+
+	fake, provider := newTestOIDCProvider(t)
+	defer fake.Close()
+
+	tests := []struct {
+		name   string
+		claims map[string]interface{}
+	}{
+		{"another issuer", map[string]interface{}{"iss": "https://evil.example.com"}},
+		{"another audience", map[string]interface{}{"aud": "another-client"}},
+		{"another nonce", map[string]interface{}{"nonce": "replayed"}},
+		{"expired", map[string]interface{}{"exp": 1}},
+		{"no subject", map[string]interface{}{"sub": ""}},
+	}
+
+	for _, test := range tests {
+
+		ac := &AuthContext{TokenStore: datastores.NewMemoryTokenStore()}
+
+		authURL, err := ac.BeginOIDCLogin(provider)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		code, state, err := fake.Authorize(authURL, "subject-1", test.claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, _, err = ac.CompleteOIDCLogin(provider, code, state); !errors.Is(err, ErrOIDCRejected) {
+			t.Errorf("Expected an ID token with %s to be rejected with %v, but recieved %v", test.name, ErrOIDCRejected, err)
+		}
+	}
+
+	// An audience array is accepted as long as it contains the client
+	ac := &AuthContext{TokenStore: datastores.NewMemoryTokenStore()}
+	authURL, _ := ac.BeginOIDCLogin(provider)
+	code, state, _ := fake.Authorize(authURL, "subject-1", map[string]interface{}{"aud": []string{"another-client", oidctest.ClientID}})
+	if _, _, err := ac.CompleteOIDCLogin(provider, code, state); err != nil {
+		t.Errorf("Expected an audience array containing the client to be accepted, but recieved %v", err)
+	}
+}
+
+/**
+ * Tests that the provider refuses codes that are redeemed without the PKCE verifier of the login
+ */
+func TestOIDCLoginWithoutVerifier(t *testing.T) {
+
+	fake, provider := newTestOIDCProvider(t)
+	defer fake.Close()
+
+	ac := &AuthContext{TokenStore: datastores.NewMemoryTokenStore()}
+
+	authURL, err := ac.BeginOIDCLogin(provider)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// An attacker who intercepted the code has neither the state record nor the verifier
+	code, _, err := fake.Authorize(authURL, "subject-1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = provider.exchange(code, "intercepted-without-verifier-0000000000000000"); !errors.Is(err, ErrOIDCRejected) {
+		t.Errorf("Expected the code to be refused with %v, but recieved %v", ErrOIDCRejected, err)
+	}
+
+	if _, _, err = ac.CompleteOIDCLogin(provider, code, "unknown-state"); !errors.Is(err, ErrInvalidOIDCState) {
+		t.Errorf("Expected an unknown state to be rejected with %v, but recieved %v", ErrInvalidOIDCState, err)
+	}
+}
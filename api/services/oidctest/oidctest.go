@@ -0,0 +1,203 @@
+// Package oidctest runs a fake OpenID Connect provider, against which the login flow is tested without reaching a real provider.
+// The provider implements the authorization code flow with PKCE, and simulates the consent of users through Authorize
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/mangoslicer/answer-patch/settings"
+)
+
+// Credentials of the client that the provider accepts
+const (
+	ClientID     = "answer-patch"
+	ClientSecret = "oidctest-secret"
+	RedirectURL  = "http://localhost:3000/oidc/callback"
+	KeyID        = "oidctest"
+)
+
+// Provider is a fake provider served over HTTP by an httptest.Server
+type Provider struct {
+	Server *httptest.Server
+	Key    *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]*grant
+}
+
+// grant is recorded under every issued code until the code is redeemed
+type grant struct {
+	challenge   string
+	redirectURL string
+	claims      jwt.MapClaims
+}
+
+// NewProvider starts a provider, which the caller must Close
+func NewProvider() (*Provider, error) {
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := &Provider{Key: key, codes: make(map[string]*grant)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", provider.serveDiscovery)
+	mux.HandleFunc("/jwks", provider.serveJWKS)
+	mux.HandleFunc("/token", provider.serveToken)
+	provider.Server = httptest.NewServer(mux)
+
+	return provider, nil
+}
+
+func (provider *Provider) Close() {
+	provider.Server.Close()
+}
+
+func (provider *Provider) Issuer() string {
+	return provider.Server.URL
+}
+
+// Config returns the settings of a client of the provider
+func (provider *Provider) Config() settings.OIDCProvider {
+	return settings.OIDCProvider{
+		Issuer:       provider.Issuer(),
+		ClientID:     ClientID,
+		ClientSecret: ClientSecret,
+		RedirectURL:  RedirectURL,
+		Scopes:       []string{"email", "profile"},
+	}
+}
+
+// Authorize simulates a user that logs in as subject on the page at authURL, and returns the code and state that the provider redirects back with.
+// The ID token carries claims in addition to the standard claims, which claims can also override to issue invalid tokens
+func (provider *Provider) Authorize(authURL, subject string, claims map[string]interface{}) (string, string, error) {
+
+	u, err := url.Parse(authURL)
+	if err != nil {
+		return "", "", err
+	}
+	query := u.Query()
+
+	if query.Get("response_type") != "code" || query.Get("client_id") != ClientID || query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		return "", "", errors.New("oidctest: the authorization request is not a code request with an S256 challenge from the client")
+	}
+
+	idClaims := jwt.MapClaims{
+		"iss":   provider.Issuer(),
+		"sub":   subject,
+		"aud":   ClientID,
+		"nonce": query.Get("nonce"),
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	}
+	for name, value := range claims {
+		idClaims[name] = value
+	}
+
+	code, err := randomString()
+	if err != nil {
+		return "", "", err
+	}
+
+	provider.mu.Lock()
+	provider.codes[code] = &grant{challenge: query.Get("code_challenge"), redirectURL: query.Get("redirect_uri"), claims: idClaims}
+	provider.mu.Unlock()
+
+	return code, query.Get("state"), nil
+}
+
+func (provider *Provider) serveDiscovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"issuer":                 provider.Issuer(),
+		"authorization_endpoint": provider.Issuer() + "/authorize",
+		"token_endpoint":         provider.Issuer() + "/token",
+		"jwks_uri":               provider.Issuer() + "/jwks",
+	})
+}
+
+func (provider *Provider) serveJWKS(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, &settings.JWKSet{Keys: []*settings.JWK{{
+		Kty: "RSA",
+		Use: "sig",
+		Alg: "RS256",
+		Kid: KeyID,
+		N:   base64.RawURLEncoding.EncodeToString(provider.Key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(provider.Key.E)).Bytes()),
+	}}})
+}
+
+// serveToken redeems a code once, if the client authenticates and proves that it holds the verifier of the challenge
+func (provider *Provider) serveToken(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != "POST" {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	id, secret, ok := r.BasicAuth()
+	if !ok || id != ClientID || secret != ClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+
+	provider.mu.Lock()
+	code := r.PostForm.Get("code")
+	g, ok := provider.codes[code]
+	delete(provider.codes, code)
+	provider.mu.Unlock()
+
+	hash := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || g.redirectURL != r.PostForm.Get("redirect_uri") || base64.RawURLEncoding.EncodeToString(hash[:]) != g.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, g.claims)
+	token.Header["kid"] = KeyID
+	idToken, err := token.SignedString(provider.Key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": code,
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/mangoslicer/answer-patch/apierrors"
+	"github.com/mangoslicer/answer-patch/settings"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+/**
+ * A PasswordHasher hashes passwords with one algorithm, and encodes the algorithm and its parameters into every hash
+ * Hashes therefore verify regardless of the current settings, which only determine how new hashes are made
+ */
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Verify(hashedPassword, password string) bool
+	Recognizes(hashedPassword string) bool // Whether the hash was made by the algorithm of the hasher
+	IsCurrent(hashedPassword string) bool  // Whether the hash was made with the parameters of the hasher
+}
+
+/**
+ * Creates the hasher of the configured algorithm
+ */
+func NewPasswordHasher(cfg settings.Passwords) PasswordHasher {
+	if cfg.Algorithm == settings.BcryptAlgorithm {
+		return &BcryptHasher{Cost: cfg.BcryptCost}
+	}
+	return &Argon2idHasher{Time: uint32(cfg.Argon2Time), MemoryKiB: uint32(cfg.Argon2MemoryKiB), Threads: uint8(cfg.Argon2Threads)}
+}
+
+/**
+ * Hashes a new password with the configured hasher
+ */
+func HashPassword(password string) (string, error) {
+	return NewPasswordHasher(settings.Get().Passwords).Hash(password)
+}
+
+/**
+ * Verifies a password against a hash of any supported algorithm
+ */
+func VerifyPassword(hashedPassword, password string) bool {
+	for _, hasher := range []PasswordHasher{new(BcryptHasher), new(Argon2idHasher)} {
+		if hasher.Recognizes(hashedPassword) {
+			return hasher.Verify(hashedPassword, password)
+		}
+	}
+	return false
+}
+
+/**
+ * Reports whether a hash was made with another algorithm or other parameters than the configured ones, in which case it should be replaced after the next successful login
+ */
+func NeedsRehash(hashedPassword string) bool {
+	return !NewPasswordHasher(settings.Get().Passwords).IsCurrent(hashedPassword)
+}
+
+type BcryptHasher struct {
+	Cost int
+}
+
+func (hasher *BcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), hasher.Cost)
+	if err != nil {
+		return "", apierrors.Internal(err)
+	}
+	return string(hash), nil
+}
+
+func (hasher *BcryptHasher) Verify(hashedPassword, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password)) == nil
+}
+
+func (hasher *BcryptHasher) Recognizes(hashedPassword string) bool {
+	return strings.HasPrefix(hashedPassword, "$2a$") || strings.HasPrefix(hashedPassword, "$2b$") || strings.HasPrefix(hashedPassword, "$2y$")
+}
+
+func (hasher *BcryptHasher) IsCurrent(hashedPassword string) bool {
+	cost, err := bcrypt.Cost([]byte(hashedPassword))
+	return hasher.Recognizes(hashedPassword) && err == nil && cost == hasher.Cost
+}
+
+/**
+ * Argon2idHasher encodes its hashes in the PHC string format, e.g. $argon2id$v=19$m=65536,t=1,p=4$<salt>$<key>
+ */
+type Argon2idHasher struct {
+	Time      uint32
+	MemoryKiB uint32
+	Threads   uint8
+}
+
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+func (hasher *Argon2idHasher) Hash(password string) (string, error) {
+
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", apierrors.Internal(err)
+	}
+
+	key := argon2.IDKey([]byte(password), salt, hasher.Time, hasher.MemoryKiB, hasher.Threads, argon2KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, hasher.MemoryKiB, hasher.Time, hasher.Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (hasher *Argon2idHasher) Verify(hashedPassword, password string) bool {
+
+	params, salt, key, err := decodeArgon2id(hashedPassword)
+	if err != nil {
+		return false
+	}
+
+	entered := argon2.IDKey([]byte(password), salt, params.Time, params.MemoryKiB, params.Threads, uint32(len(key)))
+
+	return subtle.ConstantTimeCompare(entered, key) == 1
+}
+
+func (hasher *Argon2idHasher) Recognizes(hashedPassword string) bool {
+	return strings.HasPrefix(hashedPassword, "$argon2id$")
+}
+
+func (hasher *Argon2idHasher) IsCurrent(hashedPassword string) bool {
+	params, _, _, err := decodeArgon2id(hashedPassword)
+	return err == nil && *params == *hasher
+}
+
+func decodeArgon2id(hashedPassword string) (*Argon2idHasher, []byte, []byte, error) {
+
+	fields := strings.Split(hashedPassword, "$")
+	if len(fields) != 6 || fields[1] != "argon2id" {
+		return nil, nil, nil, fmt.Errorf("malformed argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(fields[2], "v=%d", &version); err != nil {
+		return nil, nil, nil, err
+	} else if version != argon2.Version {
+		return nil, nil, nil, fmt.Errorf("unsupported argon2 version %d", version)
+	}
+
+	params := new(Argon2idHasher)
+	if _, err := fmt.Sscanf(fields[3], "m=%d,t=%d,p=%d", &params.MemoryKiB, &params.Time, &params.Threads); err != nil {
+		return nil, nil, nil, err
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(fields[4])
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	key, err := base64.RawStdEncoding.DecodeString(fields[5])
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return params, salt, key, nil
+}
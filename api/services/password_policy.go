@@ -0,0 +1,173 @@
+package services
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mangoslicer/answer-patch/apierrors"
+	"github.com/mangoslicer/answer-patch/settings"
+)
+
+/**
+ * Reasons that a password policy reports for the password field of a request
+ */
+const (
+	ReasonTooShort          = "too_short"
+	ReasonTooLong           = "too_long"
+	ReasonMissingLowercase  = "missing_lowercase"
+	ReasonMissingUppercase  = "missing_uppercase"
+	ReasonMissingDigit      = "missing_digit"
+	ReasonMissingSymbol     = "missing_symbol"
+	ReasonSimilarToUsername = "similar_to_username"
+	ReasonBreached          = "breached"
+)
+
+// bcrypt only hashes the first 72 bytes of a password, and golang.org/x/crypto/bcrypt refuses longer passwords altogether
+const bcryptMaxBytes = 72
+
+// Usernames shorter than this are too short for the similarity check to tell anything apart
+const minSimilarUsernameLength = 3
+
+/**
+ * A PasswordPolicy decides which passwords are acceptable, and gives every reason that a password is not
+ */
+type PasswordPolicy struct {
+	MinLength       int
+	MaxLength       int
+	MaxBytes        int // Zero when the hasher takes passwords of any length
+	RequiredClasses []string
+	Breached        *settings.BreachedPasswords
+}
+
+/**
+ * Creates the policy of the current settings
+ */
+func NewPasswordPolicy() *PasswordPolicy {
+
+	cfg := settings.Get().Passwords
+
+	policy := &PasswordPolicy{MinLength: cfg.MinLength, MaxLength: cfg.MaxLength, RequiredClasses: cfg.RequiredClasses, Breached: settings.GetBreachedPasswords()}
+	if cfg.Algorithm == settings.BcryptAlgorithm {
+		policy.MaxBytes = bcryptMaxBytes
+	}
+
+	return policy
+}
+
+/**
+ * Checks a password that is being set, and returns a KindInvalid error listing every reason that the password was rejected for
+ * field is the JSON name of the password in the request body, which the reasons are reported on
+ */
+func CheckPassword(field, username, password string) error {
+	if reasons := NewPasswordPolicy().Check(field, username, password); len(reasons) != 0 {
+		return apierrors.New(apierrors.KindInvalid, apierrors.CodeWeakPassword, "The password does not satisfy the password policy", reasons...)
+	}
+	return nil
+}
+
+/**
+ * Returns a field error on the password field for every rule that the password breaks. The similarity check is skipped for an empty username
+ */
+func (policy *PasswordPolicy) Check(field, username, password string) []apierrors.FieldError {
+
+	var reasons []apierrors.FieldError
+	reject := func(reason string) {
+		reasons = append(reasons, apierrors.FieldError{Field: field, Reason: reason})
+	}
+
+	length := utf8.RuneCountInString(password)
+	if length < policy.MinLength {
+		reject(ReasonTooShort)
+	} else if length > policy.MaxLength || (policy.MaxBytes != 0 && len(password) > policy.MaxBytes) {
+		reject(ReasonTooLong)
+	}
+
+	for _, class := range policy.RequiredClasses {
+		if !containsClass(password, class) {
+			reject(missingClassReasons[class])
+		}
+	}
+
+	if isSimilarToUsername(username, password) {
+		reject(ReasonSimilarToUsername)
+	}
+
+	if policy.Breached != nil && policy.Breached.Contains(password) {
+		reject(ReasonBreached)
+	}
+
+	return reasons
+}
+
+var missingClassReasons = map[string]string{
+	settings.LowercaseClass: ReasonMissingLowercase,
+	settings.UppercaseClass: ReasonMissingUppercase,
+	settings.DigitClass:     ReasonMissingDigit,
+	settings.SymbolClass:    ReasonMissingSymbol,
+}
+
+func containsClass(password, class string) bool {
+
+	is := map[string]func(rune) bool{
+		settings.LowercaseClass: unicode.IsLower,
+		settings.UppercaseClass: unicode.IsUpper,
+		settings.DigitClass:     unicode.IsDigit,
+		settings.SymbolClass:    func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsSpace(r) },
+	}[class]
+
+	return strings.IndexFunc(password, is) >= 0
+}
+
+/**
+ * A password is too similar to a username when either contains the other, ignoring case and the order of the username,
+ * or when only a couple of edits turn one into the other, e.g. Tester1 and Tester1!
+ */
+func isSimilarToUsername(username, password string) bool {
+
+	username, password = strings.ToLower(username), strings.ToLower(password)
+	if utf8.RuneCountInString(username) < minSimilarUsernameLength || utf8.RuneCountInString(password) < minSimilarUsernameLength {
+		return false
+	}
+
+	reversed := []rune(username)
+	for i, j := 0, len(reversed)-1; i < j; i, j = i+1, j-1 {
+		reversed[i], reversed[j] = reversed[j], reversed[i]
+	}
+
+	return strings.Contains(password, username) || strings.Contains(password, string(reversed)) ||
+		strings.Contains(username, password) || editDistance(username, password) <= 2
+}
+
+/**
+ * Levenshtein distance between two strings, counted in characters
+ */
+func editDistance(a, b string) int {
+
+	ra, rb := []rune(a), []rune(b)
+	row := make([]int, len(rb)+1)
+	for j := range row {
+		row[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		diagonal := row[0]
+		row[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			next := diagonal + cost
+			if row[j]+1 < next {
+				next = row[j] + 1
+			}
+			if row[j-1]+1 < next {
+				next = row[j-1] + 1
+			}
+			diagonal, row[j] = row[j], next
+		}
+	}
+
+	return row[len(rb)]
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/mangoslicer/answer-patch/apierrors"
+	"github.com/mangoslicer/answer-patch/settings"
+)
+
+func TestPasswordPolicyReasons(t *testing.T) {
+
+	breached, err := settings.LoadBreachedPasswords("../settings/preproduction/breached_passwords.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	policy := &PasswordPolicy{MinLength: 8, MaxLength: 16, RequiredClasses: []string{settings.LowercaseClass, settings.UppercaseClass, settings.DigitClass, settings.SymbolClass}, Breached: breached}
+
+	var policyTests = []struct {
+		username string
+		password string
+		reasons  []string
+	}{
+		{"Tester1", "Correct-Horse-9", nil},
+		{"Tester1", "Sh0rt!", []string{ReasonTooShort}},
+		{"Tester1", "Much-Too-L0ng-For-The-Policy", []string{ReasonTooLong}},
+		{"Tester1", "lowercase-only", []string{ReasonMissingUppercase, ReasonMissingDigit}},
+		{"Tester1", "UPPERCASE 123", []string{ReasonMissingLowercase, ReasonMissingSymbol}},
+		{"Tester1", "MyTester1!", []string{ReasonSimilarToUsername}},
+		{"Tester1", "1retseT!Aa", []string{ReasonSimilarToUsername}}, // The reversed username
+		{"Groupon", "Gr0upon!", []string{ReasonSimilarToUsername}},   // Two edits away from the username
+		{"Tester1", "Password1!", []string{ReasonBreached}},
+		{"Tester1", "password", []string{ReasonMissingUppercase, ReasonMissingDigit, ReasonMissingSymbol, ReasonBreached}}, // Listed by its SHA-1 hash
+		{"", "Tester1-Ok", nil}, // The similarity check is skipped without a username
+	}
+
+	for _, pt := range policyTests {
+		var reasons []string
+		for _, fieldErr := range policy.Check("password", pt.username, pt.password) {
+			if fieldErr.Field != "password" {
+				t.Errorf("Expected the password field to be reported, but recieved %s", fieldErr.Field)
+			}
+			reasons = append(reasons, fieldErr.Reason)
+		}
+		if !reflect.DeepEqual(reasons, pt.reasons) {
+			t.Errorf("Expected %q of %s to be rejected for %v, but recieved %v", pt.password, pt.username, pt.reasons, reasons)
+		}
+	}
+}
+
+func TestPasswordPolicyLimitsBcryptPasswords(t *testing.T) {
+
+	previous := settings.Get()
+	defer settings.Set(previous)
+
+	cfg := *previous
+	cfg.Passwords.Algorithm = settings.BcryptAlgorithm
+	settings.Set(&cfg)
+
+	// 40 three-byte characters fit MaxLength, but not the 72 bytes that bcrypt hashes
+	password := "A!"
+	for i := 0; i < 40; i++ {
+		password += "€"
+	}
+
+	if err := CheckPassword("password", "Tester1", password); !errors.Is(err, apierrors.New(0, apierrors.CodeWeakPassword, "")) {
+		t.Errorf("Expected the password to be rejected with %s, but recieved %v", apierrors.CodeWeakPassword, err)
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	for _, et := range []struct {
+		a, b     string
+		distance int
+	}{{"", "abc", 3}, {"kitten", "sitting", 3}, {"groupon", "gr0upon!", 2}, {"tester", "tester", 0}} {
+		if distance := editDistance(et.a, et.b); distance != et.distance {
+			t.Errorf("Expected the distance between %s and %s to be %d, but recieved %d", et.a, et.b, et.distance, distance)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mangoslicer/answer-patch/settings"
+)
+
+func TestPasswordHashers(t *testing.T) {
+
+	for _, hasher := range []PasswordHasher{&BcryptHasher{Cost: 4}, &Argon2idHasher{Time: 1, MemoryKiB: 1024, Threads: 2}} {
+
+		hash, err := hasher.Hash("Passw!rd")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !hasher.Recognizes(hash) || !hasher.IsCurrent(hash) {
+			t.Errorf("Expected %T to recognize its own hash %s as current", hasher, hash)
+		}
+		if !VerifyPassword(hash, "Passw!rd") {
+			t.Errorf("Expected the correct password to verify against %s", hash)
+		}
+		if VerifyPassword(hash, "Passw!rd2") {
+			t.Errorf("Expected an incorrect password not to verify against %s", hash)
+		}
+	}
+
+	if VerifyPassword("$md5$unsupported", "Passw!rd") {
+		t.Error("Expected a hash of an unsupported algorithm not to verify")
+	}
+}
+
+func TestArgon2idHashEncodesParameters(t *testing.T) {
+
+	hash, err := (&Argon2idHasher{Time: 2, MemoryKiB: 1024, Threads: 1}).Hash("Passw!rd")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=2,p=1$") {
+		t.Errorf("Expected the parameters to be encoded in the hash, but recieved %s", hash)
+	}
+
+	// The hash verifies with the parameters it encodes, regardless of the parameters of the verifying hasher
+	if !(&Argon2idHasher{Time: 1, MemoryKiB: 2048, Threads: 4}).Verify(hash, "Passw!rd") {
+		t.Error("Expected the hash to verify with its encoded parameters")
+	}
+	if (&Argon2idHasher{Time: 1, MemoryKiB: 1024, Threads: 1}).IsCurrent(hash) {
+		t.Error("Expected a hash with other parameters not to be current")
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+
+	previous := settings.Get()
+	defer settings.Set(previous)
+
+	cfg := *previous
+	cfg.Passwords = settings.Passwords{Algorithm: settings.BcryptAlgorithm, BcryptCost: 4}
+	settings.Set(&cfg)
+
+	current, err := HashPassword("Passw!rd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if NeedsRehash(current) {
+		t.Error("Expected a hash with the configured cost not to need a rehash")
+	}
+
+	cfg.Passwords.BcryptCost = 5
+	if !NeedsRehash(current) {
+		t.Error("Expected a hash with an outdated cost to need a rehash")
+	}
+
+	cfg.Passwords = settings.Passwords{Algorithm: settings.Argon2idAlgorithm, Argon2Time: 1, Argon2MemoryKiB: 1024, Argon2Threads: 1}
+	if !NeedsRehash(current) {
+		t.Error("Expected a hash of an outdated algorithm to need a rehash")
+	}
+}
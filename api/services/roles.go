@@ -0,0 +1,81 @@
+package services
+
+import (
+	"github.com/mangoslicer/answer-patch/apierrors"
+	"github.com/mangoslicer/answer-patch/models"
+)
+
+/**
+ * Users are authorized by the roles in the claims of their access tokens, which are looked up whenever tokens are issued
+ * A role that is granted or revoked therefore applies once the access token is refreshed, while bans log the user out at once
+ */
+
+var ErrBannedUser = apierrors.New(apierrors.KindForbidden, apierrors.CodeAccountBanned, "The account has been banned")
+
+/**
+ * A Permission allows an action, and is granted by a role either everywhere or within a category
+ */
+type Permission string
+
+const (
+	PermCreateCategory Permission = "create_category"
+	PermEditCategory   Permission = "edit_category"
+	PermRemoveContent  Permission = "remove_content"
+	PermRollbackAnswer Permission = "rollback_answer"
+	PermReviewFlags    Permission = "review_flags"
+	PermBanUsers       Permission = "ban_users"
+	PermManageRoles    Permission = "manage_roles"
+)
+
+/**
+ * The lowest role that has each permission. Every role above it has the permission as well
+ */
+var permissionRoles = map[Permission]models.Role{
+	PermCreateCategory: models.RoleAdmin, // Other users create categories once they have enough rep
+	PermEditCategory:   models.RoleAdmin,
+	PermRemoveContent:  models.RoleModerator,
+	PermRollbackAnswer: models.RoleModerator,
+	PermReviewFlags:    models.RoleModerator,
+	PermBanUsers:       models.RoleModerator,
+	PermManageRoles:    models.RoleAdmin,
+}
+
+/**
+ * Returns the role of the current user within category, or everywhere if category is empty
+ */
+func (ac *AuthContext) RoleIn(category string) models.Role {
+	return ac.Roles.In(category)
+}
+
+/**
+ * Reports whether the current user has perm within category. Permissions checked with an empty category are only granted by the roles that apply everywhere,
+ * so category moderators can not act beyond their categories
+ */
+func (ac *AuthContext) Can(perm Permission, category string) bool {
+
+	role, ok := permissionRoles[perm]
+	if !ok || ac.UserID == "" {
+		return false
+	}
+
+	return ac.RoleIn(category).AtLeast(role)
+}
+
+/**
+ * Looks up the roles of a user, and refuses banned users any tokens. Without a RoleStore every user has the user role
+ */
+func (ac *AuthContext) findRoles(userID string) (*models.Roles, error) {
+
+	if ac.RoleStore == nil {
+		return &models.Roles{Role: models.RoleUser}, nil
+	}
+
+	roles, err := ac.RoleStore.FindRoles(userID)
+	if err != nil {
+		return nil, err
+	} else if roles.Banned {
+		return nil, ErrBannedUser
+	}
+
+	return roles, nil
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/mangoslicer/answer-patch/datastores"
+	"github.com/mangoslicer/answer-patch/models"
+	"github.com/mangoslicer/answer-patch/settings"
+)
+
+/**
+ * Stores a user along with a category in memory, and returns the role store and the id of the user
+ */
+func newRoleTestStores(t *testing.T) (datastores.RoleStoreServices, string) {
+
+	stores := datastores.NewMemoryStores(datastores.NewMemoryDB())
+	if err := stores.Users.StoreUser("tester", "!hash", ""); err != nil {
+		t.Fatal(err)
+	}
+	user, err := stores.Users.FindUser("username", "tester")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = stores.Categories.StoreCategory(user.ID, "gains", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	return stores.Roles, user.ID
+}
+
+/**
+ * Tests that the roles of a user are carried in the claims of their access tokens
+ */
+func TestIssueSessionWithRoles(t *testing.T) {
+
+	roleStore, id := newRoleTestStores(t)
+	if err := roleStore.SetRole(id, "", models.RoleModerator); err != nil {
+		t.Fatal(err)
+	}
+	if err := roleStore.SetRole(id, "gains", models.RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+
+	ac := &AuthContext{UserID: id, TokenStore: datastores.NewMemoryTokenStore(), RoleStore: roleStore}
+	token, err := ac.IssueSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsedToken, err := jwt.Parse(token.SignedToken, func(token *jwt.Token) (interface{}, error) {
+		return settings.GetPublicKey(), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims := parsedToken.Claims.(jwt.MapClaims)
+	if claims["role"] != "moderator" {
+		t.Errorf("Expected a role claim of moderator, but recieved %v", claims["role"])
+	}
+	if categoryRoles, ok := claims["croles"].(map[string]interface{}); !ok || categoryRoles["gains"] != "admin" {
+		t.Errorf("Expected a croles claim with the admin role in gains, but recieved %v", claims["croles"])
+	}
+}
+
+/**
+ * Tests that banned users are refused new sessions and refreshed tokens
+ */
+func TestIssueSessionWithBannedUser(t *testing.T) {
+
+	roleStore, id := newRoleTestStores(t)
+	ac := &AuthContext{UserID: id, TokenStore: datastores.NewMemoryTokenStore(), RoleStore: roleStore}
+
+	token, err := ac.IssueSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = roleStore.BanUser(id, id, "spam"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = ac.IssueSession(); !errors.Is(err, ErrBannedUser) {
+		t.Errorf("Expected a banned user to be refused a session with %v, but recieved %v", ErrBannedUser, err)
+	}
+	if _, err = ac.RefreshToken(token.RefreshToken); !errors.Is(err, ErrBannedUser) {
+		t.Errorf("Expected a banned user to be refused a refresh with %v, but recieved %v", ErrBannedUser, err)
+	}
+}
+
+/**
+ * Tests that permissions checked without a category are only granted by roles that apply everywhere
+ */
+func TestCan(t *testing.T) {
+
+	ac := &AuthContext{UserID: userID, Roles: models.Roles{Role: models.RoleUser, CategoryRoles: map[string]models.Role{"gains": models.RoleModerator}}}
+
+	var canTests = []struct {
+		perm     Permission
+		category string
+		can      bool
+	}{
+		{PermRemoveContent, "gains", true},
+		{PermRemoveContent, "Gains", true},
+		{PermRemoveContent, "sushi", false},
+		{PermBanUsers, "", false},
+		{PermEditCategory, "gains", false},
+	}
+
+	for _, ct := range canTests {
+		if can := ac.Can(ct.perm, ct.category); can != ct.can {
+			t.Errorf("Expected Can(%s, %q) of a moderator of gains to be %t, but recieved %t", ct.perm, ct.category, ct.can, can)
+		}
+	}
+
+	ac.Roles.Role = models.RoleAdmin
+	if !ac.Can(PermManageRoles, "") || !ac.Can(PermRemoveContent, "sushi") {
+		t.Error("Expected an admin to have every permission everywhere")
+	}
+
+	ac.UserID = ""
+	if ac.Can(PermRemoveContent, "gains") {
+		t.Error("Expected anonymous requests to have no permission")
+	}
+}
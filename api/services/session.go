@@ -0,0 +1,202 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/mangoslicer/answer-patch/apierrors"
+	"github.com/mangoslicer/answer-patch/datastores"
+	"github.com/mangoslicer/answer-patch/models"
+)
+
+/**
+ * Every login starts a session, which is identified by the family of its tokens
+ * A session is stored as JSON along with a set of the ids of every session of its user, so that the sessions of a user can be listed
+ * Both expire along with the refresh token of the session, which is when every token of the session becomes unusable anyway
+ */
+
+var ErrSessionNotFound = apierrors.New(apierrors.KindNotFound, apierrors.CodeSessionNotFound, "No session exists with the provided session id")
+
+/**
+ * Lists a page of the sessions of the current user that have not been revoked, most recently used first
+ * Sessions that have expired or been revoked are removed from the user's set along the way
+ */
+func (ac *AuthContext) FindSessions(page models.PageRequest) (*models.SessionPage, error) {
+
+	familyIDs, err := ac.TokenStore.FindTokenSet(userSessionsKey(ac.UserID))
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]*models.Session, 0, len(familyIDs))
+	for _, familyID := range familyIDs {
+
+		isRevoked, err := ac.IsFamilyRevoked(familyID)
+		if err != nil {
+			return nil, err
+		}
+
+		session, err := ac.findSession(familyID)
+		if errors.Is(err, ErrSessionNotFound) || (err == nil && isRevoked) {
+			if err = ac.TokenStore.RemoveFromTokenSet(userSessionsKey(ac.UserID), familyID); err != nil {
+				return nil, err
+			}
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		session.Current = session.ID == ac.FamilyID
+		sessions = append(sessions, session)
+	}
+
+	return datastores.PageSessions(sessions, page)
+}
+
+/**
+ * Revokes a session of the current user, which logs out the device of the session
+ */
+func (ac *AuthContext) RevokeSession(familyID string) error {
+
+	familyIDs, err := ac.TokenStore.FindTokenSet(userSessionsKey(ac.UserID))
+	if err != nil {
+		return err
+	}
+
+	for _, id := range familyIDs {
+		if id == familyID {
+			if err = ac.revokeFamily(familyID); err != nil {
+				return err
+			}
+			return ac.TokenStore.RemoveFromTokenSet(userSessionsKey(ac.UserID), familyID)
+		}
+	}
+
+	return ErrSessionNotFound
+}
+
+/**
+ * Revokes every session of the current user but the current one, which logs out every other device, e.g. after the password was changed
+ */
+func (ac *AuthContext) RevokeOtherSessions() error {
+
+	familyIDs, err := ac.TokenStore.FindTokenSet(userSessionsKey(ac.UserID))
+	if err != nil {
+		return err
+	}
+
+	for _, familyID := range familyIDs {
+		if familyID == ac.FamilyID {
+			continue
+		}
+		if err = ac.revokeFamily(familyID); err != nil {
+			return err
+		}
+		if err = ac.TokenStore.RemoveFromTokenSet(userSessionsKey(ac.UserID), familyID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/**
+ * Logs the current user out everywhere by invalidating every token that was issued before now
+ * Timestamps only have a precision of seconds, so the listed sessions are revoked as well, which covers the tokens issued within the current second
+ */
+func (ac *AuthContext) RevokeAllSessions() error {
+
+	validAfter := strconv.FormatInt(time.Now().Unix(), 10)
+	if err := ac.TokenStore.StoreToken(tokensValidAfterKey(ac.UserID), validAfter, refreshTokenLife()); err != nil {
+		return err
+	}
+
+	familyIDs, err := ac.TokenStore.FindTokenSet(userSessionsKey(ac.UserID))
+	if err != nil {
+		return err
+	}
+
+	for _, familyID := range familyIDs {
+		if err = ac.revokeFamily(familyID); err != nil {
+			return err
+		}
+		if err = ac.TokenStore.RemoveFromTokenSet(userSessionsKey(ac.UserID), familyID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/**
+ * Returns the time before which every token of a user is invalid, which is the zero time if the user never logged out everywhere
+ */
+func (ac *AuthContext) tokensValidAfter(userID string) (time.Time, error) {
+
+	val, err := ac.TokenStore.FindToken(tokensValidAfterKey(userID))
+	if errors.Is(err, datastores.ErrTokenNotFound) {
+		return time.Time{}, nil
+	} else if err != nil {
+		return time.Time{}, err
+	}
+
+	seconds, err := strconv.ParseInt(val, 10, 64)
+	if err != nil {
+		return time.Time{}, apierrors.Internal(err)
+	}
+
+	return time.Unix(seconds, 0), nil
+}
+
+func (ac *AuthContext) startSession(familyID string) error {
+
+	now := time.Now().UTC()
+	session := &models.Session{ID: familyID, UserAgent: ac.UserAgent, CreatedAt: now, LastUsedAt: now}
+
+	if err := ac.storeSession(session); err != nil {
+		return err
+	}
+
+	return ac.TokenStore.AddToTokenSet(userSessionsKey(ac.UserID), familyID, refreshTokenLife())
+}
+
+func (ac *AuthContext) storeSession(session *models.Session) error {
+
+	record, err := json.Marshal(session)
+	if err != nil {
+		return apierrors.Internal(err)
+	}
+
+	return ac.TokenStore.StoreToken(sessionKey(session.ID), string(record), refreshTokenLife())
+}
+
+func (ac *AuthContext) findSession(familyID string) (*models.Session, error) {
+
+	val, err := ac.TokenStore.FindToken(sessionKey(familyID))
+	if errors.Is(err, datastores.ErrTokenNotFound) {
+		return nil, ErrSessionNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	session := new(models.Session)
+	if err = json.Unmarshal([]byte(val), session); err != nil {
+		return nil, apierrors.Internal(err)
+	}
+
+	return session, nil
+}
+
+func sessionKey(familyID string) string {
+	return "session:" + familyID
+}
+
+func userSessionsKey(userID string) string {
+	return "sessions:" + userID
+}
+
+func tokensValidAfterKey(userID string) string {
+	return "tokens_valid_after:" + userID
+}
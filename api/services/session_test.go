@@ -0,0 +1,191 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mangoslicer/answer-patch/datastores"
+	"github.com/mangoslicer/answer-patch/models"
+)
+
+var firstPage = models.PageRequest{Size: 10}
+
+/**
+ * Logs userID in on a new device, and returns the context of the device's requests along with the tokens it recieved
+ */
+func loginOnDevice(t *testing.T, tokenStore datastores.TokenStoreServices, userAgent string) (*AuthContext, *Token) {
+
+	device := &AuthContext{UserID: userID, UserAgent: userAgent, TokenStore: tokenStore}
+
+	token, err := device.Login("Passw!rd", "$2a$10$XgxVoZidTuxugFcAkBipBeSYxFJSLv/w0t1Lt7ihTOu0cThCBHgA.")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sessions, err := device.FindSessions(firstPage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, session := range sessions.Items {
+		if session.UserAgent == userAgent {
+			device.FamilyID = session.ID
+		}
+	}
+
+	return device, token
+}
+
+/**
+ * Tests that every login is listed as a session, and that revoking a session only logs out its own device
+ */
+func TestRevokeSession(t *testing.T) {
+
+	tokenStore := datastores.NewMemoryTokenStore()
+	laptop, _ := loginOnDevice(t, tokenStore, "laptop")
+	phone, phoneToken := loginOnDevice(t, tokenStore, "phone")
+
+	sessions, err := laptop.FindSessions(firstPage)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(sessions.Items) != 2 {
+		t.Fatalf("Expected 2 sessions, but recieved %d", len(sessions.Items))
+	}
+	for _, session := range sessions.Items {
+		if session.Current != (session.UserAgent == "laptop") {
+			t.Errorf("Expected only the session of the laptop to be current, but recieved %+v", session)
+		}
+	}
+
+	if err = laptop.RevokeSession(phone.FamilyID); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = phone.RefreshToken(phoneToken.RefreshToken)
+	if !errors.Is(err, ErrRevokedRefreshToken) {
+		t.Errorf("Expected the refresh token of the revoked session to be rejected, but recieved %v", err)
+	}
+	if err = laptop.CheckToken(userID, "phone jti", phone.FamilyID, time.Now()); !errors.Is(err, ErrRevokedToken) {
+		t.Errorf("Expected the tokens of the revoked session to be rejected, but recieved %v", err)
+	}
+	if err = laptop.CheckToken(userID, "laptop jti", laptop.FamilyID, time.Now()); err != nil {
+		t.Errorf("Expected the tokens of the laptop to stay valid, but recieved %v", err)
+	}
+
+	if sessions, err = laptop.FindSessions(firstPage); err != nil {
+		t.Fatal(err)
+	} else if len(sessions.Items) != 1 || sessions.Items[0].ID != laptop.FamilyID {
+		t.Errorf("Expected only the session of the laptop to remain, but recieved %+v", sessions.Items)
+	}
+
+	if err = laptop.RevokeSession(phone.FamilyID); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Expected revoking a revoked session to fail with ErrSessionNotFound, but recieved %v", err)
+	}
+}
+
+/**
+ * Tests that the sessions of a user are paged through with the cursors of their pages
+ */
+func TestFindSessionsWithCursors(t *testing.T) {
+
+	tokenStore := datastores.NewMemoryTokenStore()
+	laptop, _ := loginOnDevice(t, tokenStore, "laptop")
+	loginOnDevice(t, tokenStore, "phone")
+	loginOnDevice(t, tokenStore, "tablet")
+
+	first, err := laptop.FindSessions(models.PageRequest{Size: 2})
+	if err != nil {
+		t.Fatal(err)
+	} else if len(first.Items) != 2 || first.Next == "" || first.Prev != "" {
+		t.Fatalf("Expected 2 sessions and a next cursor, but recieved %+v", first)
+	}
+
+	second, err := laptop.FindSessions(models.PageRequest{Cursor: first.Next, Size: 2})
+	if err != nil {
+		t.Fatal(err)
+	} else if len(second.Items) != 1 || second.Next != "" || second.Prev == "" {
+		t.Fatalf("Expected the last session and a prev cursor, but recieved %+v", second)
+	}
+	for _, session := range first.Items {
+		if session.ID == second.Items[0].ID {
+			t.Errorf("Expected the pages not to overlap, but both listed %s", session.ID)
+		}
+	}
+
+	if _, err = laptop.FindSessions(models.PageRequest{Cursor: "bm90LWEtY3Vyc29y", Size: 2}); err == nil {
+		t.Error("Expected an invalid cursor to be rejected")
+	}
+}
+
+/**
+ * Tests that logging out only revokes the jti of the current token
+ */
+func TestLogoutRevokesTokenID(t *testing.T) {
+
+	tokenStore := datastores.NewMemoryTokenStore()
+	laptop, _ := loginOnDevice(t, tokenStore, "laptop")
+	phone, _ := loginOnDevice(t, tokenStore, "phone")
+
+	laptop.TokenID = "laptop jti"
+	laptop.Exp = time.Now().Add(time.Minute)
+	if err := laptop.Logout("signed token"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := phone.CheckToken(userID, "laptop jti", "", time.Now()); !errors.Is(err, ErrRevokedToken) {
+		t.Errorf("Expected the logged-out token to be rejected, but recieved %v", err)
+	}
+	if err := phone.CheckToken(userID, "phone jti", phone.FamilyID, time.Now()); err != nil {
+		t.Errorf("Expected the tokens of the phone to stay valid, but recieved %v", err)
+	}
+}
+
+/**
+ * Tests that logging out everywhere rejects every token issued beforehand, but not the tokens of later logins
+ */
+func TestRevokeAllSessions(t *testing.T) {
+
+	tokenStore := datastores.NewMemoryTokenStore()
+	laptop, laptopToken := loginOnDevice(t, tokenStore, "laptop")
+	phone, _ := loginOnDevice(t, tokenStore, "phone")
+
+	if err := phone.RevokeAllSessions(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := phone.CheckToken(userID, "old jti", "", time.Now().Add(-time.Minute)); !errors.Is(err, ErrRevokedToken) {
+		t.Errorf("Expected a token issued before logging out everywhere to be rejected, but recieved %v", err)
+	}
+	if err := phone.CheckToken(userID, "laptop jti", laptop.FamilyID, time.Now()); !errors.Is(err, ErrRevokedToken) {
+		t.Errorf("Expected the tokens of every session to be rejected, but recieved %v", err)
+	}
+	if _, err := laptop.RefreshToken(laptopToken.RefreshToken); !errors.Is(err, ErrRevokedRefreshToken) {
+		t.Errorf("Expected the refresh tokens of every session to be rejected, but recieved %v", err)
+	}
+
+	tablet, tabletToken := loginOnDevice(t, tokenStore, "tablet")
+	if _, err := tablet.RefreshToken(tabletToken.RefreshToken); err != nil {
+		t.Errorf("Expected a later login to be unaffected, but recieved %v", err)
+	}
+}
+
+/**
+ * Tests that revoking the other sessions keeps the current session logged in
+ */
+func TestRevokeOtherSessions(t *testing.T) {
+
+	tokenStore := datastores.NewMemoryTokenStore()
+	laptop, laptopToken := loginOnDevice(t, tokenStore, "laptop")
+	phone, phoneToken := loginOnDevice(t, tokenStore, "phone")
+
+	if err := laptop.RevokeOtherSessions(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := phone.RefreshToken(phoneToken.RefreshToken); !errors.Is(err, ErrRevokedRefreshToken) {
+		t.Errorf("Expected the refresh token of the other session to be rejected, but recieved %v", err)
+	}
+	if _, err := laptop.RefreshToken(laptopToken.RefreshToken); err != nil {
+		t.Errorf("Expected the current session to stay logged in, but recieved %v", err)
+	}
+}
@@ -1,69 +1,67 @@
 package settings
 
 import (
-	"bufio"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
-	"log"
-	"os"
+	"errors"
+	"io/ioutil"
 )
 
+// GetPrivateKey returns the private key of the active key of the keyring
 func GetPrivateKey() *rsa.PrivateKey {
+	return GetKeyring().Active.Private
+}
+
+// GetPublicKey returns the public key of the active key of the keyring
+func GetPublicKey() *rsa.PublicKey {
+	return GetKeyring().Active.Public
+}
 
-	fileData := getKeyData("private_key")
+// ReadPrivateKey parses the PEM encoded PKCS #1 RSA private key at path
+func ReadPrivateKey(path string) (*rsa.PrivateKey, error) {
 
-	privateKey, err := x509.ParsePKCS1PrivateKey(fileData.Bytes)
+	fileData, err := getKeyData(path)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
-	return privateKey
+	return x509.ParsePKCS1PrivateKey(fileData.Bytes)
 }
 
-func GetPublicKey() *rsa.PublicKey {
+// ReadPublicKey parses the PEM encoded PKIX RSA public key at path
+func ReadPublicKey(path string) (*rsa.PublicKey, error) {
 
-	fileData := getKeyData("public_key.pub")
+	fileData, err := getKeyData(path)
+	if err != nil {
+		return nil, err
+	}
 
 	publicKey, err := x509.ParsePKIXPublicKey(fileData.Bytes)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
 	rsaPublicKey, ok := publicKey.(*rsa.PublicKey)
 	if !ok {
-		log.Fatalf("Failed to cast decoded public key to type *rsa.Public")
+		return nil, errors.New("Failed to cast decoded public key to type *rsa.Public")
 	}
 
-	return rsaPublicKey
+	return rsaPublicKey, nil
 }
 
-func getKeyData(fileName string) *pem.Block {
-
-	file, err := os.Open("/home/dipen/go/src/github.com/mangoslicer/answer-patch/settings/" + os.Getenv("GO_ENV") + "/" + fileName)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	defer file.Close()
-
-	fileInfo, err := file.Stat()
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	fileBytes := make([]byte, fileInfo.Size())
+func getKeyData(path string) (*pem.Block, error) {
 
-	_, err = bufio.NewReader(file).Read(fileBytes)
+	fileBytes, err := ioutil.ReadFile(path)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
-	fileData, _ := pem.Decode([]byte(fileBytes))
+	fileData, _ := pem.Decode(fileBytes)
 	if fileData == nil {
-		log.Fatalf("Failed to decode PEM format")
+		return nil, errors.New("Failed to decode PEM format")
 	}
 
-	return fileData
+	return fileData, nil
 
 }
@@ -0,0 +1,94 @@
+package settings
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"log"
+	"os"
+	"strings"
+)
+
+// BreachedPasswords is an offline list of passwords that have appeared in breaches. Only the SHA-1 hashes of the passwords
+// are kept, so the list can be given either as plain passwords or as the hashes that breach corpora such as Pwned Passwords
+// are published as, one per line and optionally followed by ":<count>"
+type BreachedPasswords struct {
+	hashes map[string]bool
+}
+
+// breachedPasswords caches the breached passwords of the current config, and is guarded by mu
+var breachedPasswords *BreachedPasswords
+
+// GetBreachedPasswords returns the breached passwords of the current config, which are read from disk only once per config
+func GetBreachedPasswords() *BreachedPasswords {
+
+	mu.RLock()
+	breached := breachedPasswords
+	mu.RUnlock()
+	if breached != nil {
+		return breached
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if breachedPasswords == nil {
+		var err error
+		if breachedPasswords, err = LoadBreachedPasswords(current.Passwords.BreachedList); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	return breachedPasswords
+}
+
+// LoadBreachedPasswords reads the list at path. An empty path yields an empty list
+func LoadBreachedPasswords(path string) (*BreachedPasswords, error) {
+
+	breached := &BreachedPasswords{hashes: make(map[string]bool)}
+	if path == "" {
+		return breached, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+
+		if hash := strings.SplitN(line, ":", 2)[0]; isSHA1Hex(hash) {
+			breached.hashes[strings.ToLower(hash)] = true
+		} else {
+			breached.hashes[sha1Hex(line)] = true
+		}
+	}
+
+	return breached, scanner.Err()
+}
+
+// Contains reports whether password appears in the list
+func (breached *BreachedPasswords) Contains(password string) bool {
+	return breached.hashes[sha1Hex(password)]
+}
+
+// Len returns the amount of passwords in the list
+func (breached *BreachedPasswords) Len() int {
+	return len(breached.hashes)
+}
+
+func sha1Hex(password string) string {
+	hash := sha1.Sum([]byte(password))
+	return hex.EncodeToString(hash[:])
+}
+
+func isSHA1Hex(s string) bool {
+	_, err := hex.DecodeString(s)
+	return len(s) == 2*sha1.Size && err == nil
+}
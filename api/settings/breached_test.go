@@ -0,0 +1,47 @@
+package settings
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadBreachedPasswords(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "breached")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Plain passwords and Pwned Passwords style SHA-1 hashes can be mixed, and the hashes are matched case-insensitively
+	path := filepath.Join(dir, "breached.txt")
+	content := "Password1!\r\n\n7c4a8d09ca3762af61e59520943dc26494f8941b\n5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:9659365\n"
+	if err = ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	breached, err := LoadBreachedPasswords(path)
+	if err != nil {
+		t.Fatal(err)
+	} else if breached.Len() != 3 {
+		t.Errorf("Expected 3 breached passwords, but recieved %d", breached.Len())
+	}
+
+	for _, password := range []string{"Password1!", "123456", "password"} {
+		if !breached.Contains(password) {
+			t.Errorf("Expected %s to be breached", password)
+		}
+	}
+	if breached.Contains("Passw!rd") {
+		t.Error("Expected an unlisted password not to be breached")
+	}
+
+	if _, err = LoadBreachedPasswords(filepath.Join(dir, "missing.txt")); err == nil {
+		t.Error("Expected a missing list to be reported")
+	}
+	if empty, err := LoadBreachedPasswords(""); err != nil || empty.Len() != 0 {
+		t.Errorf("Expected an empty path to disable the check, but recieved %v", err)
+	}
+}
@@ -0,0 +1,579 @@
+package settings
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/mail"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+// Backends that the stores can be kept in
+const (
+	DatabaseBackend = "databases" // Postgres, with rep in MongoDB and tokens in Redis
+	MemoryBackend   = "memory"    // Process memory, which needs no running services but loses every change on exit
+)
+
+// Prefix of the environment variables that override the config file, e.g. ANSWER_PATCH_POSTGRES_PASSWORD
+const envPrefix = "ANSWER_PATCH_"
+
+// Config holds every setting of the api. It is assembled by Load from, in increasing order of precedence,
+// the defaults, a JSON, YAML or TOML config file, ANSWER_PATCH_* environment variables and command-line flags
+type Config struct {
+	ListenAddr string                  `json:"listenAddr" yaml:"listenAddr" toml:"listenAddr"`
+	Backend    string                  `json:"backend" yaml:"backend" toml:"backend"`
+	Postgres   PostgresDSN             `json:"postgres" yaml:"postgres" toml:"postgres"`
+	Redis      RedisDSN                `json:"redis" yaml:"redis" toml:"redis"`
+	Mongo      MongoDSN                `json:"mongo" yaml:"mongo" toml:"mongo"`
+	Keys       KeyPaths                `json:"keys" yaml:"keys" toml:"keys"`
+	Passwords  Passwords               `json:"passwords" yaml:"passwords" toml:"passwords"`
+	Mail       Mail                    `json:"mail" yaml:"mail" toml:"mail"`
+	RateLimits RateLimits              `json:"rateLimits" yaml:"rateLimits" toml:"rateLimits"`
+	OIDC       map[string]OIDCProvider `json:"oidc" yaml:"oidc" toml:"oidc"` // Identity providers that users can log in with, keyed by the name that their routes use
+	Rules      Rules                   `json:"rules" yaml:"rules" toml:"rules"`
+}
+
+// KeyPaths locates the RSA keys that sign and verify JSON Web Tokens. Relative paths are resolved against the directory of the config file
+// To rotate keys, the public key of the current pair is moved to Previous, so that the tokens it signed stay valid until they expire
+type KeyPaths struct {
+	PrivateKey string   `json:"privateKey" yaml:"privateKey" toml:"privateKey"` // Signs every new token
+	PublicKey  string   `json:"publicKey" yaml:"publicKey" toml:"publicKey"`
+	Previous   []string `json:"previous" yaml:"previous" toml:"previous"` // Public keys that only verify tokens, until they are removed
+}
+
+// Algorithms that passwords can be hashed with
+const (
+	Argon2idAlgorithm = "argon2id"
+	BcryptAlgorithm   = "bcrypt"
+)
+
+// Character classes that a password policy can require
+const (
+	LowercaseClass = "lowercase"
+	UppercaseClass = "uppercase"
+	DigitClass     = "digit"
+	SymbolClass    = "symbol"
+)
+
+// Passwords configures the policy that passwords must satisfy, and how new passwords are hashed. Hashes of another algorithm or
+// with other parameters keep verifying, and are replaced upon the next successful login, so the parameters can be raised at any time
+type Passwords struct {
+	MinLength       int      `json:"minLength" yaml:"minLength" toml:"minLength"` // Lengths are counted in characters
+	MaxLength       int      `json:"maxLength" yaml:"maxLength" toml:"maxLength"`
+	RequiredClasses []string `json:"requiredClasses" yaml:"requiredClasses" toml:"requiredClasses"`
+	BreachedList    string   `json:"breachedList" yaml:"breachedList" toml:"breachedList"` // Path of a file of breached passwords, which are rejected. Empty disables the check
+	Algorithm       string   `json:"algorithm" yaml:"algorithm" toml:"algorithm"`
+	BcryptCost      int      `json:"bcryptCost" yaml:"bcryptCost" toml:"bcryptCost"`
+	Argon2Time      int      `json:"argon2Time" yaml:"argon2Time" toml:"argon2Time"`                // Amount of passes over the memory
+	Argon2MemoryKiB int      `json:"argon2MemoryKiB" yaml:"argon2MemoryKiB" toml:"argon2MemoryKiB"` // Memory used by every hash, in KiB
+	Argon2Threads   int      `json:"argon2Threads" yaml:"argon2Threads" toml:"argon2Threads"`
+}
+
+// Senders that emails can be delivered with
+const (
+	SMTPSender = "smtp"
+	LogSender  = "log" // Appends every email to a file, or to the log, so that local development needs no mail server
+)
+
+// Mail configures the delivery of the emails that verify email addresses and reset passwords
+type Mail struct {
+	Sender       string `json:"sender" yaml:"sender" toml:"sender"`
+	From         string `json:"from" yaml:"from" toml:"from"`
+	AppURL       string `json:"appURL" yaml:"appURL" toml:"appURL"` // Base URL of the links in emails, which open the pages of the app that complete the verification or reset
+	SMTPHost     string `json:"smtpHost" yaml:"smtpHost" toml:"smtpHost"`
+	SMTPPort     int    `json:"smtpPort" yaml:"smtpPort" toml:"smtpPort"`
+	SMTPUsername string `json:"smtpUsername" yaml:"smtpUsername" toml:"smtpUsername"`
+	SMTPPassword string `json:"smtpPassword" yaml:"smtpPassword" toml:"smtpPassword"`
+	LogPath      string `json:"logPath" yaml:"logPath" toml:"logPath"` // File that the log sender appends to. Empty writes to the log
+}
+
+// RateLimit is a token bucket that holds up to Burst requests, and refills at PerMinute requests a minute. A PerMinute of 0 disables the limit
+type RateLimit struct {
+	Burst     int `json:"burst" yaml:"burst" toml:"burst"`
+	PerMinute int `json:"perMinute" yaml:"perMinute" toml:"perMinute"`
+}
+
+// RateLimits throttles clients by their IP, the account they act on and the route they request, and locks accounts out after repeated failed logins
+type RateLimits struct {
+	ClientIPHeader       string               `json:"clientIPHeader" yaml:"clientIPHeader" toml:"clientIPHeader"`             // Header that a trusted proxy puts the client IP in, such as X-Forwarded-For. Empty uses the address of the connection
+	IP                   RateLimit            `json:"ip" yaml:"ip" toml:"ip"`                                                 // Every request of an IP
+	Account              RateLimit            `json:"account" yaml:"account" toml:"account"`                                  // Every rate limited request on behalf of an account, from any IP
+	Routes               map[string]RateLimit `json:"routes" yaml:"routes" toml:"routes"`                                     // Requests of an IP to a route, keyed by the route names of the router package
+	LockoutThreshold     int                  `json:"lockoutThreshold" yaml:"lockoutThreshold" toml:"lockoutThreshold"`       // Failed logins after which an account is locked out. 0 disables lockouts
+	LockoutBaseSeconds   int                  `json:"lockoutBaseSeconds" yaml:"lockoutBaseSeconds" toml:"lockoutBaseSeconds"` // First lockout, which doubles with every further failure
+	LockoutMaxSeconds    int                  `json:"lockoutMaxSeconds" yaml:"lockoutMaxSeconds" toml:"lockoutMaxSeconds"`
+	FailureWindowMinutes int                  `json:"failureWindowMinutes" yaml:"failureWindowMinutes" toml:"failureWindowMinutes"` // Failures are forgotten once none has occurred for this long
+}
+
+// OIDCProvider configures an OpenID Connect identity provider. Its endpoints and keys are discovered from the issuer
+type OIDCProvider struct {
+	Issuer       string   `json:"issuer" yaml:"issuer" toml:"issuer"`
+	ClientID     string   `json:"clientID" yaml:"clientID" toml:"clientID"`
+	ClientSecret string   `json:"clientSecret" yaml:"clientSecret" toml:"clientSecret"`
+	RedirectURL  string   `json:"redirectURL" yaml:"redirectURL" toml:"redirectURL"` // Page of the app that the provider redirects to, which passes the code and state on to the callback route
+	Scopes       []string `json:"scopes" yaml:"scopes" toml:"scopes"`                // Requested in addition to openid
+}
+
+// Names of identity providers, which appear in the routes and in the links between users and providers
+var oidcProviderNameRegex = regexp.MustCompile(`^[a-z0-9_-]{1,64}$`)
+
+// Rules holds the business constants that govern rep, answers, tokens and listings
+type Rules struct {
+	MaxRep                     int `json:"maxRep" yaml:"maxRep" toml:"maxRep"`                                  // Users stop earning rep from votes in a category once their rep exceeds MaxRep
+	QuestionAskingFee          int `json:"questionAskingFee" yaml:"questionAskingFee" toml:"questionAskingFee"` // Rep change charged for asking a question
+	MinRepForAskingQuestion    int `json:"minRepForAskingQuestion" yaml:"minRepForAskingQuestion" toml:"minRepForAskingQuestion"`
+	MinRepForCreatingCategory  int `json:"minRepForCreatingCategory" yaml:"minRepForCreatingCategory" toml:"minRepForCreatingCategory"` // Rep earned across every category, not counting the starting rep of each
+	MaxPendingAnswers          int `json:"maxPendingAnswers" yaml:"maxPendingAnswers" toml:"maxPendingAnswers"`
+	AccessTokenLifeMinutes     int `json:"accessTokenLifeMinutes" yaml:"accessTokenLifeMinutes" toml:"accessTokenLifeMinutes"` // Amount of minutes until a JSON Web Token expires
+	RefreshTokenLifeHours      int `json:"refreshTokenLifeHours" yaml:"refreshTokenLifeHours" toml:"refreshTokenLifeHours"`    // Amount of hours until an unused refresh token expires
+	PasswordResetLifeMinutes   int `json:"passwordResetLifeMinutes" yaml:"passwordResetLifeMinutes" toml:"passwordResetLifeMinutes"`
+	EmailVerificationLifeHours int `json:"emailVerificationLifeHours" yaml:"emailVerificationLifeHours" toml:"emailVerificationLifeHours"`
+	DefaultPageSize            int `json:"defaultPageSize" yaml:"defaultPageSize" toml:"defaultPageSize"` // Amount of items listed per page when a request sets no limit
+	MaxPageSize                int `json:"maxPageSize" yaml:"maxPageSize" toml:"maxPageSize"`             // Largest limit that a request can set
+}
+
+func Defaults() *Config {
+	return &Config{
+		ListenAddr: ":3030",
+		Backend:    DatabaseBackend,
+		Postgres:   PostgresDSN{Host: "localhost", Port: 5432, SSLMode: "disable"},
+		Redis:      RedisDSN{Addr: ":6379"},
+		Mongo:      MongoDSN{Addr: "localhost:27017", ColName: "rep"},
+		Keys:       KeyPaths{PrivateKey: "private_key", PublicKey: "public_key.pub"},
+		Passwords: Passwords{
+			MinLength:       6,
+			MaxLength:       128,
+			RequiredClasses: []string{UppercaseClass, SymbolClass},
+			Algorithm:       Argon2idAlgorithm,
+			BcryptCost:      12,
+			Argon2Time:      1,
+			Argon2MemoryKiB: 64 * 1024,
+			Argon2Threads:   4,
+		},
+		Mail: Mail{Sender: LogSender, From: "Answer Patch <no-reply@localhost>", AppURL: "http://localhost:3030", SMTPPort: 587},
+		RateLimits: RateLimits{
+			IP:      RateLimit{Burst: 300, PerMinute: 300},
+			Account: RateLimit{Burst: 20, PerMinute: 10},
+			Routes: map[string]RateLimit{
+				"post:login":          {Burst: 10, PerMinute: 5},
+				"post:user":           {Burst: 5, PerMinute: 1},
+				"post:forgotPassword": {Burst: 5, PerMinute: 1},
+				"post:resetPassword":  {Burst: 10, PerMinute: 5},
+				"post:oidcCallback":   {Burst: 10, PerMinute: 5},
+			},
+			LockoutThreshold:     5,
+			LockoutBaseSeconds:   30,
+			LockoutMaxSeconds:    3600,
+			FailureWindowMinutes: 15,
+		},
+		Rules: Rules{
+			MaxRep:                     25,
+			QuestionAskingFee:          -2,
+			MinRepForAskingQuestion:    10,
+			MinRepForCreatingCategory:  50,
+			MaxPendingAnswers:          5,
+			AccessTokenLifeMinutes:     15,
+			RefreshTokenLifeHours:      720,
+			PasswordResetLifeMinutes:   30,
+			EmailVerificationLifeHours: 48,
+			DefaultPageSize:            10,
+			MaxPageSize:                50,
+		},
+	}
+}
+
+var (
+	mu      sync.RWMutex
+	current = Defaults()
+)
+
+// Get returns the config that was last loaded. The defaults are returned if no config has been loaded
+func Get() *Config {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// Set replaces the current config, which lets tests adjust individual settings. The keyring and the breached passwords are reloaded from the new config when they are next used
+func Set(cfg *Config) {
+	mu.Lock()
+	defer mu.Unlock()
+	current = cfg
+	keyring = nil
+	breachedPasswords = nil
+}
+
+// Load assembles, validates and installs the config. args are the command-line arguments without the program name, and
+// the arguments that remain after the flags are returned. The config file is named by the -config flag or the
+// ANSWER_PATCH_CONFIG variable, and otherwise defaults to the config.{json,yaml,yml,toml} file in settings/<GO_ENV>/
+func Load(args []string) (*Config, []string, error) {
+
+	// The flags are parsed once into a scratch config to locate the config file, since they must override its values
+	path := os.Getenv(envPrefix + "CONFIG")
+	if err := newFlagSet(Defaults(), &path).Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	cfg := Defaults()
+	if path == "" {
+		path = findConfigFile()
+	}
+
+	if path != "" {
+		if err := decodeFile(path, cfg); err != nil {
+			return nil, nil, fmt.Errorf("invalid config file %s: %v", path, err)
+		}
+		cfg.Keys = cfg.Keys.resolve(filepath.Dir(path))
+		if cfg.Passwords.BreachedList != "" && !filepath.IsAbs(cfg.Passwords.BreachedList) {
+			cfg.Passwords.BreachedList = filepath.Join(filepath.Dir(path), cfg.Passwords.BreachedList)
+		}
+		if cfg.Mail.LogPath != "" && !filepath.IsAbs(cfg.Mail.LogPath) {
+			cfg.Mail.LogPath = filepath.Join(filepath.Dir(path), cfg.Mail.LogPath)
+		}
+	}
+
+	var problems []string
+
+	for _, opt := range cfg.options() {
+		if val, ok := os.LookupEnv(opt.envName()); ok {
+			if err := opt.Set(val); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", opt.envName(), err))
+			}
+		}
+	}
+	if len(problems) != 0 {
+		return nil, nil, invalidConfig(problems)
+	}
+
+	fs := newFlagSet(cfg, &path)
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	Set(cfg)
+
+	return cfg, fs.Args(), nil
+}
+
+// Validate reports every invalid setting at once, so that a misconfigured deployment can be fixed in one go
+func (cfg *Config) Validate() error {
+
+	var problems []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	_, port, err := splitHostPort(cfg.ListenAddr)
+	check(err == nil && port > 0 && port < 65536, "listenAddr %q must be of the form [host]:port", cfg.ListenAddr)
+
+	check(cfg.Backend == DatabaseBackend || cfg.Backend == MemoryBackend, "backend %q must be either %q or %q", cfg.Backend, DatabaseBackend, MemoryBackend)
+
+	// The connection settings are only required by the backend that connects to the databases
+	if cfg.Backend == DatabaseBackend {
+		check(cfg.Postgres.Host != "", "postgres.host must be set")
+		check(cfg.Postgres.Port > 0 && cfg.Postgres.Port < 65536, "postgres.port %d is not a valid port", cfg.Postgres.Port)
+		check(cfg.Postgres.Username != "", "postgres.username must be set")
+		check(cfg.Postgres.DBName != "", "postgres.dbName must be set")
+		check(cfg.Redis.Addr != "", "redis.addr must be set")
+		check(cfg.Mongo.Addr != "", "mongo.addr must be set")
+		check(cfg.Mongo.DBName != "", "mongo.dbName must be set")
+		check(cfg.Mongo.ColName != "", "mongo.colName must be set")
+	}
+
+	privateKey, err := ReadPrivateKey(cfg.Keys.PrivateKey)
+	check(err == nil, "keys.privateKey %q is not a usable RSA private key: %v", cfg.Keys.PrivateKey, err)
+	publicKey, err := ReadPublicKey(cfg.Keys.PublicKey)
+	check(err == nil, "keys.publicKey %q is not a usable RSA public key: %v", cfg.Keys.PublicKey, err)
+	if privateKey != nil && publicKey != nil {
+		check(KeyID(&privateKey.PublicKey) == KeyID(publicKey), "keys.publicKey %q does not belong to keys.privateKey %q", cfg.Keys.PublicKey, cfg.Keys.PrivateKey)
+	}
+	for _, path := range cfg.Keys.Previous {
+		_, err = ReadPublicKey(path)
+		check(err == nil, "keys.previous %q is not a usable RSA public key: %v", path, err)
+	}
+
+	check(cfg.Passwords.MinLength > 0, "passwords.minLength must be positive")
+	check(cfg.Passwords.MaxLength >= cfg.Passwords.MinLength, "passwords.maxLength must not be less than passwords.minLength")
+	for _, class := range cfg.Passwords.RequiredClasses {
+		check(class == LowercaseClass || class == UppercaseClass || class == DigitClass || class == SymbolClass,
+			"passwords.requiredClasses %q must be one of %q, %q, %q and %q", class, LowercaseClass, UppercaseClass, DigitClass, SymbolClass)
+	}
+	if cfg.Passwords.BreachedList != "" {
+		_, err = LoadBreachedPasswords(cfg.Passwords.BreachedList)
+		check(err == nil, "passwords.breachedList %q is not readable: %v", cfg.Passwords.BreachedList, err)
+	}
+	check(cfg.Passwords.Algorithm == Argon2idAlgorithm || cfg.Passwords.Algorithm == BcryptAlgorithm, "passwords.algorithm %q must be either %q or %q", cfg.Passwords.Algorithm, Argon2idAlgorithm, BcryptAlgorithm)
+	check(cfg.Passwords.BcryptCost >= 4 && cfg.Passwords.BcryptCost <= 31, "passwords.bcryptCost must be between 4 and 31")
+	check(cfg.Passwords.Argon2Time > 0, "passwords.argon2Time must be positive")
+	check(cfg.Passwords.Argon2MemoryKiB >= 8*cfg.Passwords.Argon2Threads, "passwords.argon2MemoryKiB must be at least 8 KiB per thread")
+	check(cfg.Passwords.Argon2Threads > 0 && cfg.Passwords.Argon2Threads < 256, "passwords.argon2Threads must be between 1 and 255")
+
+	check(cfg.Mail.Sender == SMTPSender || cfg.Mail.Sender == LogSender, "mail.sender %q must be either %q or %q", cfg.Mail.Sender, SMTPSender, LogSender)
+	_, err = mail.ParseAddress(cfg.Mail.From)
+	check(err == nil, "mail.from %q is not an email address: %v", cfg.Mail.From, err)
+	appURL, err := url.Parse(cfg.Mail.AppURL)
+	check(err == nil && (appURL.Scheme == "http" || appURL.Scheme == "https") && appURL.Host != "", "mail.appURL %q must be an absolute http or https URL", cfg.Mail.AppURL)
+	if cfg.Mail.Sender == SMTPSender {
+		check(cfg.Mail.SMTPHost != "", "mail.smtpHost must be set")
+		check(cfg.Mail.SMTPPort > 0 && cfg.Mail.SMTPPort < 65536, "mail.smtpPort %d is not a valid port", cfg.Mail.SMTPPort)
+	}
+
+	checkRateLimit := func(key string, limit RateLimit) {
+		check(limit.PerMinute >= 0, "%s.perMinute must not be negative", key)
+		check(limit.PerMinute == 0 || limit.Burst > 0, "%s.burst must be positive", key)
+	}
+	checkRateLimit("rateLimits.ip", cfg.RateLimits.IP)
+	checkRateLimit("rateLimits.account", cfg.RateLimits.Account)
+	for route, limit := range cfg.RateLimits.Routes {
+		checkRateLimit("rateLimits.routes."+route, limit)
+	}
+	check(cfg.RateLimits.LockoutThreshold >= 0, "rateLimits.lockoutThreshold must not be negative")
+	if cfg.RateLimits.LockoutThreshold > 0 {
+		check(cfg.RateLimits.LockoutBaseSeconds > 0, "rateLimits.lockoutBaseSeconds must be positive")
+		check(cfg.RateLimits.LockoutMaxSeconds >= cfg.RateLimits.LockoutBaseSeconds, "rateLimits.lockoutMaxSeconds must not be less than rateLimits.lockoutBaseSeconds")
+		check(cfg.RateLimits.FailureWindowMinutes > 0, "rateLimits.failureWindowMinutes must be positive")
+	}
+
+	for name, provider := range cfg.OIDC {
+		check(oidcProviderNameRegex.MatchString(name), "oidc %q must be named by at most 64 lowercase letters, digits, dashes and underscores", name)
+		issuer, err := url.Parse(provider.Issuer)
+		check(err == nil && (issuer.Scheme == "http" || issuer.Scheme == "https") && issuer.Host != "", "oidc.%s.issuer %q must be an absolute http or https URL", name, provider.Issuer)
+		check(provider.ClientID != "", "oidc.%s.clientID must be set", name)
+		redirectURL, err := url.Parse(provider.RedirectURL)
+		check(err == nil && (redirectURL.Scheme == "http" || redirectURL.Scheme == "https") && redirectURL.Host != "", "oidc.%s.redirectURL %q must be an absolute http or https URL", name, provider.RedirectURL)
+	}
+
+	check(cfg.Rules.MaxRep > 0, "rules.maxRep must be positive")
+	check(cfg.Rules.QuestionAskingFee <= 0, "rules.questionAskingFee must not be positive, since it is deducted from the asker's rep")
+	check(cfg.Rules.MinRepForAskingQuestion >= 0, "rules.minRepForAskingQuestion must not be negative")
+	check(cfg.Rules.MinRepForCreatingCategory >= 0, "rules.minRepForCreatingCategory must not be negative")
+	check(cfg.Rules.MaxPendingAnswers > 0, "rules.maxPendingAnswers must be positive")
+	check(cfg.Rules.AccessTokenLifeMinutes > 0, "rules.accessTokenLifeMinutes must be positive")
+	check(cfg.Rules.RefreshTokenLifeHours*60 > cfg.Rules.AccessTokenLifeMinutes, "rules.refreshTokenLifeHours must outlast rules.accessTokenLifeMinutes")
+	check(cfg.Rules.PasswordResetLifeMinutes > 0, "rules.passwordResetLifeMinutes must be positive")
+	check(cfg.Rules.EmailVerificationLifeHours > 0, "rules.emailVerificationLifeHours must be positive")
+	check(cfg.Rules.DefaultPageSize > 0, "rules.defaultPageSize must be positive")
+	check(cfg.Rules.MaxPageSize >= cfg.Rules.DefaultPageSize, "rules.maxPageSize must not be less than rules.defaultPageSize")
+
+	if len(problems) != 0 {
+		return invalidConfig(problems)
+	}
+
+	return nil
+}
+
+func invalidConfig(problems []string) error {
+	return errors.New("invalid configuration:\n  - " + strings.Join(problems, "\n  - "))
+}
+
+// findConfigFile looks for the config of GO_ENV relative to the working directory, and then relative to the settings
+// package's source directory, which is where the config is found when tests run from the directories of other packages
+func findConfigFile() string {
+
+	dirs := []string{filepath.Join("settings", os.Getenv("GO_ENV"))}
+	if _, file, _, ok := runtime.Caller(0); ok {
+		dirs = append(dirs, filepath.Join(filepath.Dir(file), os.Getenv("GO_ENV")))
+	}
+
+	for _, dir := range dirs {
+		for _, ext := range []string{".json", ".yaml", ".yml", ".toml"} {
+			path := filepath.Join(dir, "config"+ext)
+			if _, err := os.Stat(path); err == nil {
+				return path
+			}
+		}
+	}
+
+	return ""
+}
+
+// decodeFile decodes the config file at path according to its extension. Unknown keys are rejected, since they are most likely typos
+func decodeFile(path string, cfg *Config) error {
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(content))
+		decoder.DisallowUnknownFields()
+		return decoder.Decode(cfg)
+	case ".yaml", ".yml":
+		return yaml.UnmarshalStrict(content, cfg)
+	case ".toml":
+		meta, err := toml.Decode(string(content), cfg)
+		if err != nil {
+			return err
+		} else if undecoded := meta.Undecoded(); len(undecoded) != 0 {
+			return fmt.Errorf("unknown keys %v", undecoded)
+		}
+		return nil
+	}
+
+	return errors.New("the config file must have a .json, .yaml, .yml or .toml extension")
+}
+
+func (keys KeyPaths) resolve(dir string) KeyPaths {
+	if keys.PrivateKey != "" && !filepath.IsAbs(keys.PrivateKey) {
+		keys.PrivateKey = filepath.Join(dir, keys.PrivateKey)
+	}
+	if keys.PublicKey != "" && !filepath.IsAbs(keys.PublicKey) {
+		keys.PublicKey = filepath.Join(dir, keys.PublicKey)
+	}
+	previous := make([]string, len(keys.Previous))
+	for i, path := range keys.Previous {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		previous[i] = path
+	}
+	keys.Previous = previous
+	return keys
+}
+
+func newFlagSet(cfg *Config, configPath *string) *flag.FlagSet {
+	fs := flag.NewFlagSet("answer-patch", flag.ContinueOnError)
+	fs.StringVar(configPath, "config", *configPath, "path of a JSON, YAML or TOML config file")
+	for _, opt := range cfg.options() {
+		fs.Var(opt, opt.key, opt.usage)
+	}
+	return fs
+}
+
+// option binds a setting to its config key, from which the names of its environment variable and flag are derived
+type option struct {
+	key   string
+	usage string
+	str   *string
+	num   *int
+	list  *[]string // Set from comma separated values
+}
+
+func (cfg *Config) options() []*option {
+	return []*option{
+		{key: "listenAddr", usage: "address that the api listens on", str: &cfg.ListenAddr},
+		{key: "backend", usage: "where the stores are kept, either \"databases\" or \"memory\"", str: &cfg.Backend},
+		{key: "postgres.host", usage: "postgres host", str: &cfg.Postgres.Host},
+		{key: "postgres.port", usage: "postgres port", num: &cfg.Postgres.Port},
+		{key: "postgres.username", usage: "postgres user", str: &cfg.Postgres.Username},
+		{key: "postgres.password", usage: "postgres password", str: &cfg.Postgres.Password},
+		{key: "postgres.dbName", usage: "postgres database", str: &cfg.Postgres.DBName},
+		{key: "postgres.sslMode", usage: "postgres sslmode", str: &cfg.Postgres.SSLMode},
+		{key: "redis.addr", usage: "redis address", str: &cfg.Redis.Addr},
+		{key: "redis.password", usage: "redis password", str: &cfg.Redis.Password},
+		{key: "mongo.username", usage: "mongodb user", str: &cfg.Mongo.Username},
+		{key: "mongo.password", usage: "mongodb password", str: &cfg.Mongo.Password},
+		{key: "mongo.addr", usage: "mongodb host:port", str: &cfg.Mongo.Addr},
+		{key: "mongo.dbName", usage: "mongodb database", str: &cfg.Mongo.DBName},
+		{key: "mongo.colName", usage: "mongodb collection that holds rep", str: &cfg.Mongo.ColName},
+		{key: "keys.privateKey", usage: "path of the PEM encoded RSA private key that signs tokens", str: &cfg.Keys.PrivateKey},
+		{key: "keys.publicKey", usage: "path of the PEM encoded RSA public key that verifies tokens", str: &cfg.Keys.PublicKey},
+		{key: "keys.previous", usage: "comma separated paths of PEM encoded RSA public keys that still verify the tokens they signed", list: &cfg.Keys.Previous},
+		{key: "passwords.minLength", usage: "minimum amount of characters of a password", num: &cfg.Passwords.MinLength},
+		{key: "passwords.maxLength", usage: "maximum amount of characters of a password", num: &cfg.Passwords.MaxLength},
+		{key: "passwords.requiredClasses", usage: "comma separated character classes that passwords must contain, of \"lowercase\", \"uppercase\", \"digit\" and \"symbol\"", list: &cfg.Passwords.RequiredClasses},
+		{key: "passwords.breachedList", usage: "path of a file of breached passwords, which are rejected", str: &cfg.Passwords.BreachedList},
+		{key: "passwords.algorithm", usage: "algorithm that new passwords are hashed with, either \"argon2id\" or \"bcrypt\"", str: &cfg.Passwords.Algorithm},
+		{key: "passwords.bcryptCost", usage: "cost of new bcrypt hashes", num: &cfg.Passwords.BcryptCost},
+		{key: "passwords.argon2Time", usage: "passes over the memory of new argon2id hashes", num: &cfg.Passwords.Argon2Time},
+		{key: "passwords.argon2MemoryKiB", usage: "KiB of memory used by new argon2id hashes", num: &cfg.Passwords.Argon2MemoryKiB},
+		{key: "passwords.argon2Threads", usage: "threads used by new argon2id hashes", num: &cfg.Passwords.Argon2Threads},
+		{key: "mail.sender", usage: "how emails are delivered, either \"smtp\" or \"log\"", str: &cfg.Mail.Sender},
+		{key: "mail.from", usage: "sender address of emails", str: &cfg.Mail.From},
+		{key: "mail.appURL", usage: "base URL of the links in emails", str: &cfg.Mail.AppURL},
+		{key: "mail.smtpHost", usage: "smtp host", str: &cfg.Mail.SMTPHost},
+		{key: "mail.smtpPort", usage: "smtp port", num: &cfg.Mail.SMTPPort},
+		{key: "mail.smtpUsername", usage: "smtp user", str: &cfg.Mail.SMTPUsername},
+		{key: "mail.smtpPassword", usage: "smtp password", str: &cfg.Mail.SMTPPassword},
+		{key: "mail.logPath", usage: "file that the log sender appends emails to", str: &cfg.Mail.LogPath},
+		{key: "rateLimits.clientIPHeader", usage: "header that a trusted proxy puts the client IP in", str: &cfg.RateLimits.ClientIPHeader},
+		{key: "rateLimits.ip.burst", usage: "requests an IP can make at once", num: &cfg.RateLimits.IP.Burst},
+		{key: "rateLimits.ip.perMinute", usage: "requests an IP can make a minute, 0 disables the limit", num: &cfg.RateLimits.IP.PerMinute},
+		{key: "rateLimits.account.burst", usage: "requests on behalf of an account that can be made at once", num: &cfg.RateLimits.Account.Burst},
+		{key: "rateLimits.account.perMinute", usage: "requests on behalf of an account that can be made a minute, 0 disables the limit", num: &cfg.RateLimits.Account.PerMinute},
+		{key: "rateLimits.lockoutThreshold", usage: "failed logins after which an account is locked out, 0 disables lockouts", num: &cfg.RateLimits.LockoutThreshold},
+		{key: "rateLimits.lockoutBaseSeconds", usage: "seconds of the first lockout, which double with every further failure", num: &cfg.RateLimits.LockoutBaseSeconds},
+		{key: "rateLimits.lockoutMaxSeconds", usage: "maximum seconds of a lockout", num: &cfg.RateLimits.LockoutMaxSeconds},
+		{key: "rateLimits.failureWindowMinutes", usage: "minutes without failures after which failed logins are forgotten", num: &cfg.RateLimits.FailureWindowMinutes},
+		{key: "rules.maxRep", usage: "rep above which votes no longer earn rep", num: &cfg.Rules.MaxRep},
+		{key: "rules.questionAskingFee", usage: "rep change charged for asking a question", num: &cfg.Rules.QuestionAskingFee},
+		{key: "rules.minRepForAskingQuestion", usage: "rep required to ask a question", num: &cfg.Rules.MinRepForAskingQuestion},
+		{key: "rules.minRepForCreatingCategory", usage: "rep earned across every category that is required to create a category", num: &cfg.Rules.MinRepForCreatingCategory},
+		{key: "rules.maxPendingAnswers", usage: "amount of pending answers a question can hold", num: &cfg.Rules.MaxPendingAnswers},
+		{key: "rules.accessTokenLifeMinutes", usage: "minutes until a JSON Web Token expires", num: &cfg.Rules.AccessTokenLifeMinutes},
+		{key: "rules.refreshTokenLifeHours", usage: "hours until an unused refresh token expires", num: &cfg.Rules.RefreshTokenLifeHours},
+		{key: "rules.passwordResetLifeMinutes", usage: "minutes until a password reset link expires", num: &cfg.Rules.PasswordResetLifeMinutes},
+		{key: "rules.emailVerificationLifeHours", usage: "hours until an email verification link expires", num: &cfg.Rules.EmailVerificationLifeHours},
+		{key: "rules.defaultPageSize", usage: "amount of items listed per page when a request sets no limit", num: &cfg.Rules.DefaultPageSize},
+		{key: "rules.maxPageSize", usage: "largest amount of items that a request can list per page", num: &cfg.Rules.MaxPageSize},
+	}
+}
+
+// envName converts a key such as postgres.dbName into ANSWER_PATCH_POSTGRES_DBNAME
+func (opt *option) envName() string {
+	return envPrefix + strings.ToUpper(strings.Replace(opt.key, ".", "_", -1))
+}
+
+func (opt *option) String() string {
+	if opt == nil {
+		return ""
+	} else if opt.num != nil {
+		return strconv.Itoa(*opt.num)
+	} else if opt.str != nil {
+		return *opt.str
+	} else if opt.list != nil {
+		return strings.Join(*opt.list, ",")
+	}
+	return ""
+}
+
+func (opt *option) Set(val string) error {
+	if opt.num != nil {
+		n, err := strconv.Atoi(val)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", val)
+		}
+		*opt.num = n
+		return nil
+	} else if opt.list != nil {
+		*opt.list = nil
+		for _, item := range strings.Split(val, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				*opt.list = append(*opt.list, item)
+			}
+		}
+		return nil
+	}
+	*opt.str = val
+	return nil
+}
+
+func splitHostPort(addr string) (string, int, error) {
+	i := strings.LastIndex(addr, ":")
+	if i < 0 {
+		return "", 0, errors.New("missing port")
+	}
+	port, err := strconv.Atoi(addr[i+1:])
+	return addr[:i], port, err
+}
@@ -0,0 +1,199 @@
+package settings
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, name, content string) string {
+
+	dir, err := ioutil.TempDir("", "settings")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := filepath.Abs("preproduction")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content = strings.Replace(content, "KEYS", keys, -1)
+
+	path := filepath.Join(dir, name)
+	if err = ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestLoadPreproductionConfig(t *testing.T) {
+
+	os.Setenv("GO_ENV", "preproduction")
+	defer os.Unsetenv("GO_ENV")
+
+	cfg, _, err := Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg != Get() {
+		t.Error("Expected Load to install the loaded config")
+	}
+	if cfg.Postgres.DBName != "ap1" || cfg.Mongo.ColName != "rep" || cfg.Rules.MaxRep != 25 {
+		t.Errorf("Expected the preproduction config, but recieved %+v", cfg)
+	}
+}
+
+func TestLoadLayersFileEnvAndFlags(t *testing.T) {
+
+	path := writeConfig(t, "config.yaml", `
+listenAddr: ":4000"
+postgres:
+  username: file
+  password: file
+  dbName: file
+redis:
+  password: file
+mongo:
+  dbName: file
+keys:
+  privateKey: KEYS/private_key
+  publicKey: KEYS/public_key.pub
+rules:
+  maxRep: 30
+`)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	os.Setenv("ANSWER_PATCH_POSTGRES_PASSWORD", "env")
+	os.Setenv("ANSWER_PATCH_POSTGRES_DBNAME", "env")
+	defer os.Unsetenv("ANSWER_PATCH_POSTGRES_PASSWORD")
+	defer os.Unsetenv("ANSWER_PATCH_POSTGRES_DBNAME")
+	defer Set(Defaults())
+
+	cfg, args, err := Load([]string{"-config", path, "-postgres.dbName=flag", "up"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.ListenAddr != ":4000" || cfg.Rules.MaxRep != 30 || cfg.Postgres.Username != "file" {
+		t.Errorf("Expected the config file to override the defaults, but recieved %+v", cfg)
+	}
+	if cfg.Rules.MaxPendingAnswers != 5 || cfg.Postgres.Port != 5432 {
+		t.Errorf("Expected the defaults to fill in unset settings, but recieved %+v", cfg)
+	}
+	if cfg.Postgres.Password != "env" {
+		t.Errorf("Expected the environment to override the config file, but recieved %s", cfg.Postgres.Password)
+	}
+	if cfg.Postgres.DBName != "flag" {
+		t.Errorf("Expected the flags to override the environment, but recieved %s", cfg.Postgres.DBName)
+	}
+	if len(args) != 1 || args[0] != "up" {
+		t.Errorf("Expected the remaining arguments to be [up], but recieved %v", args)
+	}
+}
+
+func TestLoadTOMLConfig(t *testing.T) {
+
+	path := writeConfig(t, "config.toml", `
+[postgres]
+username = "toml"
+dbName = "toml"
+
+[mongo]
+dbName = "toml"
+
+[keys]
+privateKey = "KEYS/private_key"
+publicKey = "KEYS/public_key.pub"
+`)
+	defer os.RemoveAll(filepath.Dir(path))
+	defer Set(Defaults())
+
+	cfg, _, err := Load([]string{"-config", path})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Postgres.Username != "toml" {
+		t.Errorf("Expected postgres.username to be toml, but recieved %s", cfg.Postgres.Username)
+	}
+}
+
+func TestLoadWithUnknownKey(t *testing.T) {
+
+	path := writeConfig(t, "config.json", `{"postgres": {"usrname": "typo"}}`)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	_, _, err := Load([]string{"-config", path})
+	if err == nil || !strings.Contains(err.Error(), "usrname") {
+		t.Errorf("Expected the unknown key to be reported, but recieved %v", err)
+	}
+}
+
+func TestLoadWithMalformedEnvVar(t *testing.T) {
+
+	os.Setenv("ANSWER_PATCH_RULES_MAXREP", "many")
+	defer os.Unsetenv("ANSWER_PATCH_RULES_MAXREP")
+
+	_, _, err := Load(nil)
+	if err == nil || !strings.Contains(err.Error(), "ANSWER_PATCH_RULES_MAXREP") {
+		t.Errorf("Expected the malformed variable to be reported, but recieved %v", err)
+	}
+}
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+
+	cfg := Defaults()
+	cfg.ListenAddr = "3030"
+	cfg.Keys.PrivateKey = "missing"
+	cfg.Keys.PublicKey = "preproduction/public_key.pub"
+	cfg.Rules.MaxPendingAnswers = 0
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Expected the config to be invalid")
+	}
+
+	for _, setting := range []string{"listenAddr", "postgres.username", "postgres.dbName", "mongo.dbName", "keys.privateKey", "rules.maxPendingAnswers"} {
+		if !strings.Contains(err.Error(), setting) {
+			t.Errorf("Expected %s to be reported, but recieved %v", setting, err)
+		}
+	}
+	if strings.Contains(err.Error(), "keys.publicKey") {
+		t.Errorf("Expected the valid public key not to be reported, but recieved %v", err)
+	}
+}
+
+func TestGetPostgresDSNQuotesValues(t *testing.T) {
+
+	cfg := Defaults()
+	cfg.Postgres.Username = "root"
+	cfg.Postgres.Password = "it's secret"
+	cfg.Postgres.DBName = "ap1"
+	Set(cfg)
+	defer Set(Defaults())
+
+	expected := `host='localhost' port=5432 user='root' password='it\'s secret' dbname='ap1' sslmode='disable'`
+	if dsn := GetPostgresDSN(); dsn != expected {
+		t.Errorf("Expected %s, but recieved %s", expected, dsn)
+	}
+}
+
+func TestValidateMemoryBackendWithoutConnections(t *testing.T) {
+
+	cfg := Defaults()
+	cfg.Backend = MemoryBackend
+	cfg.Keys = KeyPaths{PrivateKey: "preproduction/private_key", PublicKey: "preproduction/public_key.pub"}
+
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected the memory backend to need no connection settings, but recieved %v", err)
+	}
+
+	cfg.Backend = "sqlite"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "backend") {
+		t.Errorf("Expected the unknown backend to be reported, but recieved %v", err)
+	}
+}
@@ -1,72 +1,64 @@
 package settings
 
 import (
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"log"
-	"os"
 )
 
 type PostgresDSN struct {
-	Username string
-	Password string
-	DBName   string
-	SSLMode  string
+	Host     string `json:"host" yaml:"host" toml:"host"`
+	Port     int    `json:"port" yaml:"port" toml:"port"`
+	Username string `json:"username" yaml:"username" toml:"username"`
+	Password string `json:"password" yaml:"password" toml:"password"`
+	DBName   string `json:"dbName" yaml:"dbName" toml:"dbName"`
+	SSLMode  string `json:"sslMode" yaml:"sslMode" toml:"sslMode"`
 }
 
 type RedisDSN struct {
-	Addr     string
-	Password string
+	Addr     string `json:"addr" yaml:"addr" toml:"addr"`
+	Password string `json:"password" yaml:"password" toml:"password"`
 }
 
 type MongoDSN struct {
-	Username string
-	Password string
-	Addr     string
-	DBName   string
-	ColName  string
+	Username string `json:"username" yaml:"username" toml:"username"`
+	Password string `json:"password" yaml:"password" toml:"password"`
+	Addr     string `json:"addr" yaml:"addr" toml:"addr"` // host:port of the mongod instance
+	DBName   string `json:"dbName" yaml:"dbName" toml:"dbName"`
+	ColName  string `json:"colName" yaml:"colName" toml:"colName"`
 }
 
 func GetPostgresDSN() string {
 
-	dsn := new(PostgresDSN)
+	dsn := Get().Postgres
 
-	getDSN("postgres", dsn)
-
-	return fmt.Sprintf("user=%s password=%s dbname=%s sslmode=%s", dsn.Username, dsn.Password, dsn.DBName, dsn.SSLMode)
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s", quoteDSNValue(dsn.Host), dsn.Port, quoteDSNValue(dsn.Username), quoteDSNValue(dsn.Password), quoteDSNValue(dsn.DBName), quoteDSNValue(dsn.SSLMode))
 
 }
 
 func GetRedisDSN() *RedisDSN {
 
-	dsn := new(RedisDSN)
-
-	getDSN("redis", dsn)
+	dsn := Get().Redis
 
-	return dsn
+	return &dsn
 }
 
 func GetMongoDSN() *MongoDSN {
 
-	dsn := new(MongoDSN)
-
-	getDSN("mongo", dsn)
+	dsn := Get().Mongo
 
-	return dsn
+	return &dsn
 
 }
 
-func getDSN(dbName string, dsnStruct interface{}) {
-
-	content, err := ioutil.ReadFile("/home/dipen/go/src/github.com/mangoslicer/answer-patch/settings/" + os.Getenv("GO_ENV") + "/" + dbName + ".json")
-	if err != nil {
-		log.Fatal(err)
-	}
+// quoteDSNValue quotes a value of a key/value connection string, so that passwords can contain spaces and quotes
+func quoteDSNValue(value string) string {
 
-	err = json.Unmarshal(content, dsnStruct)
-	if err != nil {
-		log.Fatal(err)
+	quoted := []rune{'\''}
+	for _, r := range value {
+		if r == '\'' || r == '\\' {
+			quoted = append(quoted, '\\')
+		}
+		quoted = append(quoted, r)
 	}
 
+	return string(append(quoted, '\''))
 }
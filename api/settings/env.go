@@ -5,10 +5,29 @@ import (
 	"os"
 )
 
+// SetPreproductionEnv sets GO_ENV to "preproduction" and loads the preproduction config, so that tests can reach the preproduction databases
 func SetPreproductionEnv() {
 
 	err := os.Setenv("GO_ENV", "preproduction")
 	if err != nil {
 		log.Fatal(err)
 	}
+
+	_, _, err = Load(nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// DefaultEnv sets GO_ENV to env unless GO_ENV is already set, which lets deployments select their config through GO_ENV
+func DefaultEnv(env string) {
+
+	if os.Getenv("GO_ENV") != "" {
+		return
+	}
+
+	err := os.Setenv("GO_ENV", env)
+	if err != nil {
+		log.Fatal(err)
+	}
 }
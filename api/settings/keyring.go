@@ -0,0 +1,134 @@
+package settings
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"math/big"
+)
+
+// SigningAlgorithm is the JWS algorithm of every key of the keyring
+const SigningAlgorithm = "RS512"
+
+// Key is an RSA key of the keyring, which tokens refer to by the kid in their header
+type Key struct {
+	ID      string
+	Public  *rsa.PublicKey
+	Private *rsa.PrivateKey // Only set for the active key
+}
+
+// Keyring holds the active key, which signs new tokens, along with the previous keys, which only verify the tokens they signed
+type Keyring struct {
+	Active *Key
+	keys   []*Key // The active key first, followed by the previous keys in the order they are configured
+}
+
+// keyring caches the keyring of the current config, and is guarded by mu
+var keyring *Keyring
+
+// GetKeyring returns the keyring of the current config, which is read from disk only once per config
+func GetKeyring() *Keyring {
+
+	mu.RLock()
+	kr := keyring
+	mu.RUnlock()
+	if kr != nil {
+		return kr
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if keyring == nil {
+		var err error
+		if keyring, err = LoadKeyring(current.Keys); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	return keyring
+}
+
+// LoadKeyring reads every key that paths locates
+func LoadKeyring(paths KeyPaths) (*Keyring, error) {
+
+	privateKey, err := ReadPrivateKey(paths.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("keys.privateKey: %v", err)
+	}
+
+	active := &Key{ID: KeyID(&privateKey.PublicKey), Public: &privateKey.PublicKey, Private: privateKey}
+	kr := &Keyring{Active: active, keys: []*Key{active}}
+
+	for _, path := range paths.Previous {
+		publicKey, err := ReadPublicKey(path)
+		if err != nil {
+			return nil, fmt.Errorf("keys.previous: %v", err)
+		}
+		if _, ok := kr.Key(KeyID(publicKey)); !ok {
+			kr.keys = append(kr.keys, &Key{ID: KeyID(publicKey), Public: publicKey})
+		}
+	}
+
+	return kr, nil
+}
+
+// Key finds the key that a token's kid refers to
+func (kr *Keyring) Key(id string) (*Key, bool) {
+	for _, key := range kr.keys {
+		if key.ID == id {
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+// KeyID derives the kid of a public key from its JWK thumbprint (RFC 7638), so keys keep their ids without any bookkeeping
+func KeyID(publicKey *rsa.PublicKey) string {
+
+	jwk := newJWK("", publicKey)
+
+	// The thumbprint is the hash of the required members of the JWK, in lexicographic order and without whitespace
+	hash := sha256.Sum256([]byte(fmt.Sprintf(`{"e":"%s","kty":"%s","n":"%s"}`, jwk.E, jwk.Kty, jwk.N)))
+
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+// JWK is the JSON Web Key (RFC 7517) of a public key
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// JWKSet is the document served at /.well-known/jwks.json, from which clients can verify tokens without sharing any secret
+type JWKSet struct {
+	Keys []*JWK `json:"keys"`
+}
+
+// JWKS publishes the public keys of every key of the keyring
+func (kr *Keyring) JWKS() *JWKSet {
+
+	set := &JWKSet{Keys: make([]*JWK, len(kr.keys))}
+	for i, key := range kr.keys {
+		set.Keys[i] = newJWK(key.ID, key.Public)
+	}
+
+	return set
+}
+
+func newJWK(id string, publicKey *rsa.PublicKey) *JWK {
+	return &JWK{
+		Kty: "RSA",
+		Use: "sig",
+		Alg: SigningAlgorithm,
+		Kid: id,
+		N:   base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes()),
+	}
+}
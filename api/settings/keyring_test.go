@@ -0,0 +1,152 @@
+package settings
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writePublicKey writes the public key of a fresh RSA key, which stands in for a key that was rotated out
+func writePublicKey(t *testing.T) (string, *rsa.PrivateKey) {
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "keyring")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "previous.pub")
+	if err = ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path, privateKey
+}
+
+func TestLoadKeyring(t *testing.T) {
+
+	previous, previousKey := writePublicKey(t)
+	defer os.RemoveAll(filepath.Dir(previous))
+
+	// Listing the active key as a previous key as well must not publish it twice
+	kr, err := LoadKeyring(KeyPaths{PrivateKey: "preproduction/private_key", PublicKey: "preproduction/public_key.pub", Previous: []string{previous, "preproduction/public_key.pub"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	publicKey, err := ReadPublicKey("preproduction/public_key.pub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kr.Active.ID != KeyID(publicKey) || kr.Active.Private == nil {
+		t.Errorf("Expected the private key to be the active key, but recieved %+v", kr.Active)
+	}
+
+	key, ok := kr.Key(KeyID(&previousKey.PublicKey))
+	if !ok || key.Private != nil || key.Public.N.Cmp(previousKey.N) != 0 {
+		t.Errorf("Expected the previous key to verify tokens without signing any, but recieved %+v", key)
+	}
+	if _, ok = kr.Key("unknown"); ok {
+		t.Error("Expected an unknown kid not to be found")
+	}
+
+	jwks := kr.JWKS()
+	if len(jwks.Keys) != 2 || jwks.Keys[0].Kid != kr.Active.ID || jwks.Keys[1].Kid != key.ID {
+		t.Fatalf("Expected the active and the previous key to be published, but recieved %+v", jwks.Keys)
+	}
+	if jwk := jwks.Keys[0]; jwk.Kty != "RSA" || jwk.Alg != "RS512" || jwk.Use != "sig" || jwk.E != "AQAB" {
+		t.Errorf("Expected an RS512 signing key, but recieved %+v", jwk)
+	}
+}
+
+func TestKeyIDIsThumbprint(t *testing.T) {
+
+	publicKey, err := ReadPublicKey("preproduction/public_key.pub")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Thumbprints are the base64url encoding of a SHA-256 hash, without padding
+	id := KeyID(publicKey)
+	if len(id) != 43 || strings.ContainsAny(id, "+/=") {
+		t.Errorf("Expected a base64url encoded SHA-256 thumbprint, but recieved %s", id)
+	}
+	if reread, _ := ReadPublicKey("preproduction/public_key.pub"); KeyID(reread) != id {
+		t.Error("Expected the kid of a key to be stable across reads")
+	}
+}
+
+func TestGetKeyringIsResetBySet(t *testing.T) {
+
+	previous, _ := writePublicKey(t)
+	defer os.RemoveAll(filepath.Dir(previous))
+	defer Set(Defaults())
+
+	cfg := Defaults()
+	cfg.Keys = KeyPaths{PrivateKey: "preproduction/private_key", PublicKey: "preproduction/public_key.pub"}
+	Set(cfg)
+
+	kr := GetKeyring()
+	if GetKeyring() != kr {
+		t.Error("Expected the keyring to be cached")
+	}
+
+	cfg = Defaults()
+	cfg.Keys = KeyPaths{PrivateKey: "preproduction/private_key", PublicKey: "preproduction/public_key.pub", Previous: []string{previous}}
+	Set(cfg)
+
+	if len(GetKeyring().JWKS().Keys) != 2 {
+		t.Error("Expected the keyring to be reloaded after the config is replaced")
+	}
+}
+
+func TestValidateReportsKeyMismatch(t *testing.T) {
+
+	previous, _ := writePublicKey(t)
+	defer os.RemoveAll(filepath.Dir(previous))
+
+	cfg := Defaults()
+	cfg.Backend = MemoryBackend
+	cfg.Keys = KeyPaths{PrivateKey: "preproduction/private_key", PublicKey: previous, Previous: []string{"missing"}}
+
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "does not belong to keys.privateKey") {
+		t.Errorf("Expected the mismatched public key to be reported, but recieved %v", err)
+	}
+	if err == nil || !strings.Contains(err.Error(), `keys.previous "missing"`) {
+		t.Errorf("Expected the missing previous key to be reported, but recieved %v", err)
+	}
+}
+
+func TestLoadPreviousKeysFlag(t *testing.T) {
+
+	defer Set(Defaults())
+
+	keys, err := filepath.Abs("preproduction")
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("GO_ENV", "preproduction")
+	defer os.Unsetenv("GO_ENV")
+
+	cfg, _, err := Load([]string{"-keys.previous=" + filepath.Join(keys, "public_key.pub") + "," + filepath.Join(keys, "public_key.pub")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Keys.Previous) != 2 {
+		t.Errorf("Expected the comma separated paths to be split, but recieved %v", cfg.Keys.Previous)
+	}
+}